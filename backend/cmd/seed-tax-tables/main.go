@@ -0,0 +1,152 @@
+// Command seed-tax-tables loads the federal and state tax brackets that
+// EstimateTaxes reads into tax_tables_federal and tax_tables_state.
+//
+// The data is embedded from tax_tables.json, with amounts in whole dollars
+// (stored as cents) and rates in basis points. A top bracket has a high of
+// 0, meaning no upper bound. Each year and state in the file replaces any
+// rows already stored for it, so the command is safe to re-run after
+// editing the data. Usage:
+//
+//	DATABASE_URL=postgres://... go run ./backend/cmd/seed-tax-tables
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"dayboard/backend/internal/db"
+)
+
+//go:embed tax_tables.json
+var taxTablesJSON []byte
+
+type bracket struct {
+	Low     int `json:"low"`
+	High    int `json:"high"`
+	RateBps int `json:"rateBps"`
+}
+
+type federalYear struct {
+	Year               int       `json:"year"`
+	StdDeductionSingle int       `json:"stdDeductionSingle"`
+	StdDeductionMFJ    int       `json:"stdDeductionMfj"`
+	Brackets           []bracket `json:"brackets"`
+}
+
+type stateYear struct {
+	State              string    `json:"state"`
+	Year               int       `json:"year"`
+	StdDeductionSingle int       `json:"stdDeductionSingle"`
+	Brackets           []bracket `json:"brackets"`
+}
+
+type taxTables struct {
+	Federal []federalYear `json:"federal"`
+	States  []stateYear   `json:"states"`
+}
+
+func main() {
+	var tables taxTables
+	if err := json.Unmarshal(taxTablesJSON, &tables); err != nil {
+		log.Fatalf("parse tax_tables.json: %v", err)
+	}
+
+	database, err := db.New()
+	if err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	defer database.Close()
+
+	if err := seed(context.Background(), database, tables); err != nil {
+		log.Fatalf("seed tax tables: %v", err)
+	}
+	log.Printf("seeded %d federal years and %d state years", len(tables.Federal), len(tables.States))
+}
+
+// seed replaces the stored brackets for every year and state in tables in
+// a single transaction.
+func seed(ctx context.Context, d *db.DB, tables taxTables) error {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, f := range tables.Federal {
+		if err := validate(fmt.Sprintf("federal %d", f.Year), f.Brackets); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tax_tables_federal WHERE year = $1`, f.Year); err != nil {
+			return err
+		}
+		for _, b := range f.Brackets {
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO tax_tables_federal (year, bracket_low, bracket_high, rate_bps, std_deduction_single, std_deduction_mfj)
+                VALUES ($1, $2, $3, $4, $5, $6)
+            `, f.Year, cents(b.Low), cents(b.High), b.RateBps, cents(f.StdDeductionSingle), cents(f.StdDeductionMFJ))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, s := range tables.States {
+		if err := validate(fmt.Sprintf("%s %d", s.State, s.Year), s.Brackets); err != nil {
+			return err
+		}
+		if err := replaceState(ctx, tx, s); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func replaceState(ctx context.Context, tx *sql.Tx, s stateYear) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tax_tables_state WHERE state = $1 AND year = $2`, s.State, s.Year); err != nil {
+		return err
+	}
+	for _, b := range s.Brackets {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO tax_tables_state (state, year, bracket_low, bracket_high, rate_bps, std_deduction_single)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `, s.State, s.Year, cents(b.Low), cents(b.High), b.RateBps, cents(s.StdDeductionSingle))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks that brackets start at 0, are contiguous, and only the
+// last one is unbounded, which is what applyBrackets assumes.
+func validate(name string, brackets []bracket) error {
+	if len(brackets) == 0 {
+		return fmt.Errorf("%s: no brackets", name)
+	}
+	if brackets[0].Low != 0 {
+		return fmt.Errorf("%s: first bracket must start at 0", name)
+	}
+	for i, b := range brackets {
+		last := i == len(brackets)-1
+		if last && b.High != 0 {
+			return fmt.Errorf("%s: top bracket must be unbounded (high 0)", name)
+		}
+		if !last && (b.High <= b.Low || brackets[i+1].Low != b.High) {
+			return fmt.Errorf("%s: bracket %d is not contiguous", name, i)
+		}
+		if b.RateBps < 0 {
+			return fmt.Errorf("%s: bracket %d has a negative rate", name, i)
+		}
+	}
+	return nil
+}
+
+// cents converts whole dollars to the cents stored in the tax tables.
+func cents(dollars int) int {
+	return dollars * 100
+}
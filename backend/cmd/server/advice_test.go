@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"dayboard/backend/internal/ai"
+)
+
+func TestAdviceError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: SAFETY", ai.ErrBlocked), http.StatusUnprocessableEntity},
+		{ai.ErrQueryRejected, http.StatusBadRequest},
+		{ai.ErrTimeout, http.StatusGatewayTimeout},
+		{context.Canceled, http.StatusInternalServerError},
+		{errors.New("gemini API error: 500"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got, _ := adviceError(tt.err); got != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+	if _, message := adviceError(ai.ErrBlocked); message != aiBlockedMessage {
+		t.Errorf("blocked message = %q, want the friendly one", message)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"dayboard/backend/internal/estimate"
+	"dayboard/backend/internal/store"
+)
+
+func TestHousingComparisonFlagsStatesWithoutTaxTables(t *testing.T) {
+	rents := []store.CityRent{
+		{City: "Austin", State: "TX", BedroomType: "1", AvgRentCents: 150000},
+		{City: "Portland", State: "OR", BedroomType: "1", AvgRentCents: 160000},
+		{City: "Chicago", State: "IL", BedroomType: "1", AvgRentCents: 180000},
+	}
+	results := []estimate.StateResult{
+		{State: "TX", TaxResult: estimate.TaxResult{TermNetCents: 2000000}},
+		{State: "OR", Unsupported: true},
+		{State: "IL", TaxResult: estimate.TaxResult{TermNetCents: 1900000}},
+	}
+
+	// A 13-week term is three months of rent.
+	got := housingComparison(rents, results, 13)
+	want := []HousingComparison{
+		{City: "Austin, TX", Bedrooms: "1", AvgRentCents: 150000, NetAfterRentCents: 2000000 - 450000},
+		{City: "Chicago, IL", Bedrooms: "1", AvgRentCents: 180000, NetAfterRentCents: 1900000 - 540000},
+		{City: "Portland, OR", Bedrooms: "1", AvgRentCents: 160000, TaxUnavailable: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
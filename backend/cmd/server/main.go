@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,51 +22,71 @@ import (
 
 	"dayboard/backend/internal/ai"
 	"dayboard/backend/internal/auth"
+	"dayboard/backend/internal/campus"
 	"dayboard/backend/internal/commute"
 	"dayboard/backend/internal/db"
 	"dayboard/backend/internal/estimate"
 	"dayboard/backend/internal/google"
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/ics"
+	"dayboard/backend/internal/metrics"
+	"dayboard/backend/internal/microsoft"
+	"dayboard/backend/internal/middleware"
+	"dayboard/backend/internal/migrate"
 	"dayboard/backend/internal/plaid"
+	"dayboard/backend/internal/reminder"
 	"dayboard/backend/internal/store"
+	"dayboard/backend/migrations"
 )
 
+// Build metadata, injected at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=...".
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+// maxOccurrences bounds ?count= on /subs/:id/occurrences.
+const maxOccurrences = 24
+
+// maxAgendaDays caps the range GET /agenda will return.
+const maxAgendaDays = 31
+
+// maxBulkSubscriptions caps how many subscriptions POST /subs/bulk takes.
+const maxBulkSubscriptions = 100
+
 // In-memory demo data (used only when DEMO_MODE is enabled)
 var (
 	demoSubs         []store.Subscription
 	demoEvents       []store.Event
 	demoProfile      store.Profile
-	demoCommutes     []CommuteEntry
-	demoEmails       EmailSummary
+	demoCommutes     []store.CommuteEntry
+	demoEmails       google.EmailSummary
 	demoStateTax     []StateTaxComparison
 	demoHousing      []HousingComparison
 	demoCampusEvents []CampusEvent
 	demoSeeded       bool
 )
 
-type CommuteEntry struct {
-	ID        uuid.UUID `json:"id"`
-	Date      time.Time `json:"date"`
-	From      string    `json:"from"`
-	To        string    `json:"to"`
-	CostCents int       `json:"costCents"`
-	Method    string    `json:"method"`
-}
-
-type EmailSummary struct {
-	UnreadCount int      `json:"unreadCount"`
-	TopSubjects []string `json:"topSubjects"`
-}
-
+// StateTaxComparison is one state's row in /finance/state-comparison.
+// Unsupported rows have no tax table loaded, so no rate or net pay.
 type StateTaxComparison struct {
 	State       string  `json:"state"`
 	TaxRate     float64 `json:"taxRate"`
 	NetPayCents int     `json:"netPayCents"`
+	Unsupported bool    `json:"unsupported,omitempty"`
 }
 
+// HousingComparison is one city's row in /finance/housing-comparison.
+// TaxUnavailable rows are in a state without a tax table, so there is no
+// net pay to subtract the rent from.
 type HousingComparison struct {
 	City              string `json:"city"`
+	Bedrooms          string `json:"bedrooms,omitempty"`
 	AvgRentCents      int    `json:"avgRentCents"`
 	NetAfterRentCents int    `json:"netAfterRentCents"`
+	TaxUnavailable    bool   `json:"taxUnavailable,omitempty"`
 }
 
 type CampusEvent struct {
@@ -81,16 +109,30 @@ func main() {
 	// Demo mode allows running without a database or external API keys.
 	demoMode := strings.EqualFold(os.Getenv("DEMO_MODE"), "true") || os.Getenv("DEMO_MODE") == "1"
 
+	// Background subsystems register here to be drained on shutdown.
+	var shutdownHooks []func(context.Context) error
+
 	// Use Gin in release mode for production. Gin automatically logs requests.
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	if err := router.SetTrustedProxies(middleware.TrustedProxies()); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.Use(gin.Logger(), gin.Recovery(), metrics.Middleware(), middleware.CORS(), middleware.BodyLimit())
 
-	// Register health check endpoint for uptime monitoring.
+	// Register health check endpoint for uptime monitoring. It is a pure
+	// liveness check; /readyz also checks dependencies.
 	router.GET("/healthz", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
 
+	// Prometheus metrics: per-route request timings and external API calls.
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Report which build is running and which integrations are configured.
+	// Only presence of credentials is exposed, never their values.
+	router.GET("/version", versionHandler(demoMode))
+
 	// Mount API routes under /api/v1.
 	api := router.Group("/api/v1")
 
@@ -100,29 +142,40 @@ func main() {
 	// Auth routes
 	authGroup := api.Group("/auth")
 
-	// Demo auth endpoints that return mock responses
-	authGroup.POST("/signup", func(c *gin.Context) {
-		c.JSON(http.StatusCreated, gin.H{
-			"token": "demo_jwt_token_for_testing",
-			"user": gin.H{
-				"id":    "demo-user-123",
-				"email": "demo@dayboard.app",
-				"name":  "Demo User",
-			},
+	if demoMode {
+		// Readiness: demo mode has no dependencies to wait on.
+		router.GET("/readyz", func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
 		})
-	})
-	authGroup.POST("/login", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"token": "demo_jwt_token_for_testing",
-			"user": gin.H{
-				"id":    "demo-user-123",
-				"email": "demo@dayboard.app",
-				"name":  "Demo User",
-			},
+
+		// Optional latency/error injection (DEMO_LATENCY_MS, DEMO_ERROR_RATE)
+		// for every demo route registered below.
+		faults := middleware.DemoFaults()
+		api.Use(faults)
+		authGroup.Use(faults)
+
+		// Demo auth endpoints that return mock responses
+		authGroup.POST("/signup", func(c *gin.Context) {
+			c.JSON(http.StatusCreated, gin.H{
+				"token": "demo_jwt_token_for_testing",
+				"user": gin.H{
+					"id":    "demo-user-123",
+					"email": "demo@dayboard.app",
+					"name":  "Demo User",
+				},
+			})
+		})
+		authGroup.POST("/login", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{
+				"token": "demo_jwt_token_for_testing",
+				"user": gin.H{
+					"id":    "demo-user-123",
+					"email": "demo@dayboard.app",
+					"name":  "Demo User",
+				},
+			})
 		})
-	})
 
-	if demoMode {
 		// Seed demo data once at startup
 		if !demoSeeded {
 			seedDemoData()
@@ -135,12 +188,17 @@ func main() {
 			c.JSON(http.StatusOK, demoEvents)
 		})
 
-		api.POST("/agenda/today", func(c *gin.Context) {
+		api.POST("/agenda/events", func(c *gin.Context) {
 			var req store.Event
 			if err := c.BindJSON(&req); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			req.End = store.EventEnd(req.Start, req.End, req.AllDay)
+			if req.End.Before(req.Start) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": store.ErrEventEndBeforeStart.Error()})
+				return
+			}
 			if req.ID == uuid.Nil {
 				req.ID = uuid.New()
 			}
@@ -148,6 +206,21 @@ func main() {
 			c.JSON(http.StatusCreated, req)
 		})
 
+		api.GET("/agenda", func(c *gin.Context) {
+			from, to, err := agendaRange(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			events := []store.Event{}
+			for _, e := range demoEvents {
+				if e.Start.Before(to) && (e.End.After(from) || !e.Start.Before(from)) {
+					events = append(events, e)
+				}
+			}
+			c.JSON(http.StatusOK, events)
+		})
+
 		api.GET("/subs", func(c *gin.Context) {
 			c.JSON(http.StatusOK, demoSubs)
 		})
@@ -190,6 +263,9 @@ func main() {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			if !validateProfile(c, &prof) {
+				return
+			}
 			demoProfile = prof
 			c.JSON(http.StatusCreated, prof)
 		})
@@ -205,7 +281,7 @@ func main() {
 		})
 
 		api.POST("/commute/entries", func(c *gin.Context) {
-			var req CommuteEntry
+			var req store.CommuteEntry
 			if err := c.BindJSON(&req); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
@@ -216,6 +292,10 @@ func main() {
 			if req.Date.IsZero() {
 				req.Date = time.Now().UTC()
 			}
+			if req.SplitWith == 0 {
+				req.SplitWith = 1
+			}
+			req.ShareCents = req.UserShareCents()
 			demoCommutes = append(demoCommutes, req)
 			c.JSON(http.StatusCreated, req)
 		})
@@ -232,12 +312,14 @@ func main() {
 				}
 			}
 
-			// Add commute costs for today
+			// Add the user's share of today's commutes
+			commuteCents := 0
 			for _, commute := range demoCommutes {
 				if isSameDay(commute.Date, today) {
-					totalCents += commute.CostCents
+					commuteCents += commute.UserShareCents()
 				}
 			}
+			totalCents += commuteCents
 
 			// Add food cost if it's an office day (simplified: assume today is office day)
 			totalCents += demoProfile.FoodCostCents
@@ -247,11 +329,25 @@ func main() {
 				"breakdown": gin.H{
 					"subscriptions": getSubsDueToday(),
 					"commutes":      getCommutesToday(),
+					"commuteCents":  commuteCents,
 					"food":          demoProfile.FoodCostCents,
 				},
 			})
 		})
 
+		// Weekly and monthly spend trends over the demo data, shaped like
+		// the production endpoints
+		for _, period := range []string{"weekly", "monthly"} {
+			api.GET("/burn/"+period, func(c *gin.Context) {
+				start, days, loc, err := burnPeriod(c, period)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusOK, store.ComputeBurnSeries(start, days, loc, demoSubs, demoCommutes, &demoProfile, nil, store.BurnOptions{}))
+			})
+		}
+
 		// Finance comparison endpoints
 		api.GET("/finance/state-comparison", func(c *gin.Context) {
 			c.JSON(http.StatusOK, demoStateTax)
@@ -261,9 +357,26 @@ func main() {
 			c.JSON(http.StatusOK, demoHousing)
 		})
 
-		// Campus events endpoint
+		// Campus events endpoint, filtered like the production one by
+		// ?category=, ?from= and ?to= and sorted by date
 		api.GET("/campus/events", func(c *gin.Context) {
-			c.JSON(http.StatusOK, demoCampusEvents)
+			from, to, err := campusEventRange(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			category := c.Query("category")
+			events := []CampusEvent{}
+			for _, e := range demoCampusEvents {
+				if e.Date.Before(from) || !e.Date.Before(to) {
+					continue
+				}
+				if category == "" || strings.EqualFold(e.Category, category) {
+					events = append(events, e)
+				}
+			}
+			sort.Slice(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+			c.JSON(http.StatusOK, events)
 		})
 
 		// AI advice endpoint (demo responses)
@@ -312,17 +425,16 @@ func main() {
 			fica := body.IncomeCents * 765 / 10000
 			totalTax := federal + state + fica
 			netAnnual := body.IncomeCents - totalTax
-			checks := 0
-			switch body.PayFreq {
-			case "weekly":
-				checks = body.TermWeeks
-			case "biweekly":
-				checks = body.TermWeeks / 2
-			case "monthly":
-				checks = body.TermWeeks / 4
-			default:
-				checks = body.TermWeeks / 2
+			payFreq := estimate.DefaultPayFreq
+			if body.PayFreq != "" {
+				f, err := estimate.ParsePayFreq(body.PayFreq)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				payFreq = f
 			}
+			checks, _ := payFreq.Paychecks(body.TermWeeks)
 			perPay := 0
 			if checks > 0 {
 				perPay = netAnnual / checks
@@ -360,85 +472,316 @@ func main() {
 		})
 	} else {
 		// Initialize DB connection. Fatal if cannot connect.
-		database := db.New()
-		defer database.Close()
+		database, err := db.New()
+		if err != nil {
+			log.Fatalf("database: %v", err)
+		}
+
+		// Bring the schema up to date unless DB_AUTO_MIGRATE=false, so a
+		// fresh database provisions itself.
+		if !strings.EqualFold(os.Getenv("DB_AUTO_MIGRATE"), "false") {
+			applied, err := migrate.Run(context.Background(), database, migrations.FS)
+			if err != nil {
+				log.Fatalf("migrate: %v", err)
+			}
+			for _, version := range applied {
+				log.Printf("migrate: applied %s", version)
+			}
+		}
+
+		// Readiness: unlike /healthz, report 503 while the database is
+		// unreachable so load balancers stop routing here.
+		router.GET("/readyz", func(c *gin.Context) {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+			defer cancel()
+			if err := database.PingContext(ctx); err != nil {
+				c.String(http.StatusServiceUnavailable, "database unavailable")
+				return
+			}
+			c.String(http.StatusOK, "ok")
+		})
 
-		// Initialize auth handlers for production
-		authHandlers := auth.NewAuthHandlers(database, jwtManager)
+		// Background reminder delivery for upcoming events. It is drained
+		// after the HTTP server stops.
+		reminderWorker := reminder.NewWorker(database)
+		reminderWorker.Start(context.Background())
+		shutdownHooks = append(shutdownHooks, reminderWorker.Shutdown)
+
+		// Initialize auth handlers for production. Password resets need a
+		// way to reach the user, so startup fails without one.
+		resetSender, err := auth.NewResetSender()
+		if err != nil {
+			log.Fatalf("password reset: %v", err)
+		}
+		authHandlers := auth.NewAuthHandlers(database, jwtManager, resetSender)
 		authGroup.POST("/signup", authHandlers.Signup)
 		authGroup.POST("/login", authHandlers.Login)
-		authGroup.GET("/profile", auth.AuthMiddleware(jwtManager), authHandlers.GetProfile)
+		authGroup.GET("/profile", auth.AuthMiddleware(jwtManager, database), authHandlers.GetProfile)
 		authGroup.POST("/refresh", authHandlers.RefreshToken)
+		authGroup.POST("/forgot-password", authHandlers.ForgotPassword)
+		authGroup.POST("/reset-password", authHandlers.ResetPassword)
+
+		// Admin routes
+		adminGroup := api.Group("/admin", auth.AuthMiddleware(jwtManager, database), auth.RequireAdmin(database))
+		adminGroup.POST("/users/:id/suspend", authHandlers.SuspendUser)
+		adminGroup.POST("/users/:id/unsuspend", authHandlers.UnsuspendUser)
+		adminGroup.GET("/commute/cache", func(c *gin.Context) {
+			hits, misses := commute.CacheStats()
+			c.JSON(http.StatusOK, gin.H{"hits": hits, "misses": misses})
+		})
+
+		// Campus event feeds, keyed by the school users set in their
+		// profile. Sync ingests one school's feed (?school=) or all of them.
+		adminGroup.GET("/campus/sources", func(c *gin.Context) {
+			sources, err := store.GetCampusEventSources(c.Request.Context(), database)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, sources)
+		})
+		adminGroup.PUT("/campus/sources/:school", func(c *gin.Context) {
+			var req store.CampusEventSource
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			req.School = c.Param("school")
+			if !store.IsSchoolKey(req.School) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "school must be lower-case letters, digits and dashes"})
+				return
+			}
+			if req.Format != campus.FormatICS && req.Format != campus.FormatJSON {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ics or json"})
+				return
+			}
+			if u, err := url.Parse(req.FeedURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "feedUrl must be an http or https URL"})
+				return
+			}
+			if err := store.UpsertCampusEventSource(c.Request.Context(), database, req); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, req)
+		})
+		adminGroup.POST("/campus/sync", func(c *gin.Context) {
+			ctx := c.Request.Context()
+			if school := c.Query("school"); school != "" {
+				src, err := store.GetCampusEventSource(ctx, database, school)
+				if errors.Is(err, store.ErrCampusSourceNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+					return
+				}
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				n, err := campus.Ingest(ctx, database, *src)
+				if err != nil {
+					c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusOK, gin.H{"imported": gin.H{school: n}})
+				return
+			}
+			counts, err := campus.IngestAll(ctx, database)
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "imported": counts})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"imported": counts})
+		})
 
 		// Initialize OAuth handlers
 		googleHandlers := google.NewOAuthHandlers(database)
+		microsoftHandlers := microsoft.NewOAuthHandlers(database)
+		icsHandlers := ics.NewHandlers(database)
 		plaidHandlers := plaid.NewOAuthHandlers(database)
+
+		// Deleting an account disconnects Google and Plaid first.
+		authGroup.DELETE("/account", auth.AuthMiddleware(jwtManager, database), authHandlers.DeleteAccount(googleHandlers, plaidHandlers))
+
 		geminiService := ai.NewGeminiService()
+		aiQuota := ai.NewQuota(database)
+
+		// Resolved non-secret configuration for debugging an environment
+		adminGroup.GET("/config", configHandler(port, demoMode, database, jwtManager, authHandlers.Limiter(), plaidHandlers.Service(), geminiService, aiQuota, reminderWorker.Interval()))
 
 		// Google Calendar OAuth routes
-		googleGroup := api.Group("/google", auth.AuthMiddleware(jwtManager))
+		googleGroup := api.Group("/google", auth.AuthMiddleware(jwtManager, database))
 		googleGroup.GET("/auth", googleHandlers.InitiateGoogleAuth)
 		googleGroup.GET("/callback", googleHandlers.HandleGoogleCallback)
 		googleGroup.POST("/sync", googleHandlers.SyncCalendarEvents)
+		googleGroup.GET("/calendars", googleHandlers.ListCalendars)
+		googleGroup.POST("/calendars", googleHandlers.SelectCalendars)
+
+		// Microsoft 365 / Outlook calendar routes
+		microsoftGroup := api.Group("/microsoft", auth.AuthMiddleware(jwtManager, database))
+		microsoftGroup.GET("/auth", microsoftHandlers.InitiateMicrosoftAuth)
+		microsoftGroup.GET("/callback", microsoftHandlers.HandleMicrosoftCallback)
+		microsoftGroup.POST("/sync", microsoftHandlers.SyncCalendarEvents)
+
+		// Unread Gmail count and newest subjects, via the Google connection
+		api.GET("/email/summary", auth.AuthMiddleware(jwtManager, database), googleHandlers.EmailSummary)
+
+		// Import events from an .ics feed URL or uploaded file
+		api.POST("/calendar/ics", auth.AuthMiddleware(jwtManager, database), icsHandlers.Import)
 
 		// Plaid OAuth routes
-		plaidGroup := api.Group("/plaid", auth.AuthMiddleware(jwtManager))
+		plaidGroup := api.Group("/plaid", auth.AuthMiddleware(jwtManager, database))
 		plaidGroup.POST("/link-token", plaidHandlers.CreateLinkToken)
 		plaidGroup.POST("/exchange", plaidHandlers.ExchangePublicToken)
 		plaidGroup.POST("/sync", plaidHandlers.SyncTransactions)
 		plaidGroup.GET("/accounts", plaidHandlers.GetConnectedAccounts)
-
-		// AI Assistant route with real Gemini integration
-		api.POST("/ai/advice", auth.OptionalAuthMiddleware(jwtManager), func(c *gin.Context) {
+		plaidGroup.POST("/disconnect", plaidHandlers.Disconnect)
+		// Plaid calls this directly, so it authenticates by webhook
+		// signature rather than a user token.
+		api.POST("/plaid/webhook", plaidHandlers.Webhook)
+
+		// Re-run subscription detection over already-synced transactions
+		api.POST("/subs/redetect", auth.AuthMiddleware(jwtManager, database), plaidHandlers.RedetectSubscriptions)
+
+		// AI Assistant route with real Gemini integration. Signed-in users'
+		// questions are kept as a conversation: pass the returned
+		// conversationId as conversation_id to continue it, and the last
+		// AI_HISTORY_MESSAGES messages are sent along as context.
+		aiHistory := aiHistoryLimit()
+		api.POST("/ai/advice", auth.OptionalAuthMiddleware(jwtManager, database), func(c *gin.Context) {
 			var req struct {
-				Query string `json:"query" binding:"required"`
+				Query          string     `json:"query" binding:"required"`
+				ConversationID *uuid.UUID `json:"conversation_id"`
 			}
 			if err := c.ShouldBindJSON(&req); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			ctx := c.Request.Context()
+			userID, signedIn := auth.GetUserIDFromContext(c)
+			if req.ConversationID != nil && !signedIn {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in to continue a conversation"})
+				return
+			}
+			// Rejected queries don't count against the quota.
+			if err := ai.CheckQuery(req.Query); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if !allowAIRequest(c, aiQuota) {
+				return
+			}
 
 			// Get user context for personalized advice
-			userContext := make(map[string]interface{})
-			if userID, exists := auth.GetUserIDFromContext(c); exists {
-				// Get user profile for context
-				if profile, err := store.GetProfile(c.Request.Context(), database, userID); err == nil && profile != nil {
-					userContext["profile"] = map[string]interface{}{
-						"state":        profile.State,
-						"hourly_cents": profile.HourlyCents,
-					}
+			userContext := adviceContext(c, database)
+			if req.ConversationID != nil {
+				messages, err := store.GetConversationMessages(ctx, database, userID, *req.ConversationID, aiHistory)
+				if errors.Is(err, store.ErrConversationNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+					return
+				}
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load conversation"})
+					return
+				}
+				// Gemini expects turns to start with the user, which an odd
+				// limit can cut off.
+				if len(messages) > 0 && messages[0].Role == store.AIRoleModel {
+					messages = messages[1:]
 				}
-				// Get subscriptions for context
-				if subs, err := store.GetSubscriptions(c.Request.Context(), database, userID); err == nil {
-					userContext["subscriptions"] = subs
+				history := make([]ai.Content, 0, len(messages))
+				for _, m := range messages {
+					history = append(history, ai.Content{Role: m.Role, Parts: []ai.Part{{Text: m.Content}}})
 				}
+				userContext["history"] = history
 			}
 
-			advice, err := geminiService.GenerateAdvice(c.Request.Context(), req.Query, userContext)
+			advice, err := geminiService.GenerateAdvice(ctx, req.Query, userContext)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate advice"})
+				status, message := adviceError(err)
+				c.JSON(status, gin.H{"error": message})
+				return
+			}
+			if !signedIn {
+				c.JSON(http.StatusOK, gin.H{"advice": advice.Text, "usage": advice.Usage})
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{"advice": advice})
+			conversationID := uuid.Nil
+			if req.ConversationID != nil {
+				conversationID = *req.ConversationID
+			} else if conversationID, err = store.CreateConversation(ctx, database, userID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation"})
+				return
+			}
+			if err := store.AddConversationTurn(ctx, database, conversationID, req.Query, advice.Text); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"advice": advice.Text, "usage": advice.Usage, "conversationId": conversationID})
 		})
 
-		api.GET("/agenda/today", func(c *gin.Context) {
-			// In a production system you'd derive the user ID from the
-			// authenticated session. For demonstration we read a query param.
-			userParam := c.Query("user_id")
-			userID := uuid.Nil
-			if userParam != "" {
-				if uid, err := uuid.Parse(userParam); err == nil {
-					userID = uid
+		// Same advice as POST /ai/advice for ?query=, streamed as
+		// server-sent events: a "chunk" event {text} per piece of the
+		// answer, then "done" {usage}, or "error" {error} if generation
+		// fails part way. The stream stops when the client disconnects.
+		api.GET("/ai/advice/stream", auth.OptionalAuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			query := strings.TrimSpace(c.Query("query"))
+			if query == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+				return
+			}
+			if err := ai.CheckQuery(query); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if !allowAIRequest(c, aiQuota) {
+				return
+			}
+			userContext := adviceContext(c, database)
+
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Status(http.StatusOK)
+			c.Writer.Flush()
+
+			ctx := c.Request.Context()
+			usage, err := geminiService.GenerateAdviceStream(ctx, query, userContext, func(text string) error {
+				if err := ctx.Err(); err != nil {
+					return err
 				}
+				c.SSEvent("chunk", gin.H{"text": text})
+				c.Writer.Flush()
+				return nil
+			})
+			if ctx.Err() != nil {
+				// Client went away; nothing left to send to.
+				return
 			}
-			// Determine start and end of today in UTC based on the server's time.
-			now := time.Now().UTC()
-			y, m, d := now.Date()
-			loc := now.Location()
-			startOfDay := time.Date(y, m, d, 0, 0, 0, 0, loc)
-			endOfDay := startOfDay.Add(24 * time.Hour)
+			if err != nil {
+				_, message := adviceError(err)
+				c.SSEvent("error", gin.H{"error": message})
+			} else {
+				c.SSEvent("done", gin.H{"usage": usage})
+			}
+			c.Writer.Flush()
+		})
+
+		api.GET("/agenda/today", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			// Determine start and end of today in the caller's timezone
+			// (UTC unless ?tz= is provided).
+			loc, err := requestLocation(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			startOfDay, endOfDay := dayBounds(time.Now(), loc)
 			events, err := store.GetTodayEvents(c.Request.Context(), database, userID, startOfDay, endOfDay)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -449,138 +792,1465 @@ func main() {
 			c.JSON(http.StatusOK, events)
 		})
 
-		api.GET("/subs", func(c *gin.Context) {
-			userParam := c.Query("user_id")
-			userID := uuid.Nil
-			if uid, err := uuid.Parse(userParam); err == nil {
-				userID = uid
+		// Campus events for ?school=, or the school in the user's profile,
+		// from ?from= to ?to= (YYYY-MM-DD in ?tz=, to is exclusive;
+		// defaults to the next 30 days), optionally filtered by ?category=.
+		api.GET("/campus/events", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
 			}
-			subs, err := store.GetSubscriptions(c.Request.Context(), database, userID)
+			school := strings.ToLower(c.Query("school"))
+			if school == "" {
+				prof, err := store.GetProfile(c.Request.Context(), database, userID)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if prof != nil {
+					school = prof.School
+				}
+			}
+			if school == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Set a school in your profile or pass ?school="})
+				return
+			}
+			from, to, err := campusEventRange(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			events, err := store.GetCampusEvents(c.Request.Context(), database, school, from, to, c.Query("category"))
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusOK, subs)
+			if events == nil {
+				events = []store.CampusEvent{}
+			}
+			c.JSON(http.StatusOK, events)
 		})
 
-		api.POST("/subs", func(c *gin.Context) {
-			userParam := c.Query("user_id")
-			userID := uuid.Nil
-			if uid, err := uuid.Parse(userParam); err == nil {
-				userID = uid
+		// Events overlapping ?from= through ?to= (YYYY-MM-DD in ?tz=, to
+		// is exclusive). Defaults to the week starting today.
+		api.GET("/agenda", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
 			}
-			var req store.Subscription
-			if err := c.BindJSON(&req); err != nil {
+			from, to, err := agendaRange(c)
+			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-			sub, err := store.CreateSubscription(c.Request.Context(), database, userID, req)
+			events, err := store.GetEventsInRange(c.Request.Context(), database, userID, from, to)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusCreated, sub)
-		})
-
-		// TODO: Implement real delete in DB. For demo, return 204.
-		api.DELETE("/subs/:id", func(c *gin.Context) {
-			c.Status(http.StatusNoContent)
+			c.JSON(http.StatusOK, events)
 		})
 
-		api.POST("/estimate/taxes", func(c *gin.Context) {
-			// Parse payload {incomeCents,state,filingStatus,payFreq,termWeeks}
-			var body struct {
-				IncomeCents  int    `json:"incomeCents"`
-				State        string `json:"state"`
-				FilingStatus string `json:"filingStatus"`
-				PayFreq      string `json:"payFreq"`
-				TermWeeks    int    `json:"termWeeks"`
+		// Manually entered events. Reminder lead times default to the
+		// profile setting when reminderMinutesBefore is omitted.
+		api.POST("/agenda/events", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
 			}
-			if err := c.BindJSON(&body); err != nil {
+			var req store.Event
+			if err := c.BindJSON(&req); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-			// Use current year for taxes. In production you might allow specifying.
-			year := time.Now().Year()
-			res, err := estimate.EstimateTaxes(c.Request.Context(), database, body.IncomeCents, body.State, body.FilingStatus, year, body.PayFreq, body.TermWeeks)
+			ev, err := store.CreateEvent(c.Request.Context(), database, userID, req)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusOK, res)
+			c.JSON(http.StatusCreated, ev)
 		})
 
-		api.GET("/commute/estimate", func(c *gin.Context) {
-			origin := c.Query("from")
-			destination := c.Query("to")
-			// Example surge parameter, default to 1.0 (no surge)
-			surge := 1.0
-			if s := c.Query("surge"); s != "" {
-				if v, err := strconv.ParseFloat(s, 64); err == nil {
-					surge = v
-				}
+		// Mark an event attended or skipped; {"status": ""} clears it.
+		api.POST("/agenda/:id/attendance", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
 			}
-			// For demonstration, fetch cost model from DB based on city. Here
-			// we simply hardcode a generic model. In production, you would
-			// select by city/state.
-			baseCents := 200    // $2 base fare
-			perMileCents := 150 // $1.50 per mile
-			perMinCents := 25   // $0.25 per minute
-			est, err := commute.EstimateCommute(c.Request.Context(), origin, destination, baseCents, perMileCents, perMinCents, surge)
+			id, err := uuid.Parse(c.Param("id"))
 			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event id"})
+				return
+			}
+			var req struct {
+				Status *string `json:"status" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusOK, est)
+			if err := store.ValidateAttendance(*req.Status); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			err = store.SetEventAttendance(c.Request.Context(), database, userID, id, *req.Status)
+			if errors.Is(err, store.ErrEventNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"id": id, "attendance": *req.Status})
 		})
 
-		api.GET("/profile", func(c *gin.Context) {
-			userParam := c.Query("user_id")
-			userID := uuid.Nil
-			if uid, err := uuid.Parse(userParam); err == nil {
-				userID = uid
+		// Spending by category for a date range (default: this month).
+		// ?from= is inclusive and ?to= exclusive, so from=2024-06-01&to=2024-07-01
+		// covers June. ?view=gross ignores refunds; the default net view
+		// subtracts them.
+		api.GET("/spending/summary", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
 			}
-			prof, err := store.GetProfile(c.Request.Context(), database, userID)
+			view := c.DefaultQuery("view", "net")
+			if view != "net" && view != "gross" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "view must be net or gross"})
+				return
+			}
+			now := time.Now().UTC()
+			from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+			to := from.AddDate(0, 1, 0)
+			if v := c.Query("from"); v != "" {
+				t, err := time.Parse("2006-01-02", v)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+					return
+				}
+				from = t
+			}
+			if v := c.Query("to"); v != "" {
+				t, err := time.Parse("2006-01-02", v)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+					return
+				}
+				to = t
+			}
+			summary, err := store.CategorySpendingSummary(c.Request.Context(), database, userID, from, to)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-			if prof == nil {
-				c.JSON(http.StatusOK, gin.H{})
-				return
+			total := 0
+			for _, cs := range summary {
+				if view == "gross" {
+					total += cs.GrossCents
+				} else {
+					total += cs.NetCents
+				}
 			}
-			c.JSON(http.StatusOK, prof)
+			c.JSON(http.StatusOK, gin.H{
+				"view":       view,
+				"totalCents": total,
+				"categories": summary,
+			})
 		})
 
-		api.POST("/profile", func(c *gin.Context) {
-			userParam := c.Query("user_id")
-			userID := uuid.Nil
-			if uid, err := uuid.Parse(userParam); err == nil {
-				userID = uid
-			}
-			var prof store.Profile
-			if err := c.BindJSON(&prof); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api.GET("/subs", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 				return
 			}
-			prof.UserID = userID
-			if err := store.UpsertProfile(c.Request.Context(), database, prof); err != nil {
+			subs, err := store.GetSubscriptions(c.Request.Context(), database, userID)
+			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
-			c.JSON(http.StatusCreated, prof)
+			// Past due dates are shown as the next billing date; nothing
+			// is written back.
+			store.ProjectDueDates(subs, time.Now())
+			c.JSON(http.StatusOK, subs)
 		})
-	}
-
-	// Start listening and serving requests. If an error occurs, log and exit.
-	if err := router.Run(fmt.Sprintf(":" + port)); err != nil {
-		log.Fatalf("failed to run server: %v", err)
-	}
-}
-
-func ptrTime(t time.Time) *time.Time { return &t }
 
-func seedDemoData() {
+		api.POST("/subs", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			var req store.Subscription
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			sub, err := store.CreateSubscription(c.Request.Context(), database, userID, req)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusCreated, sub)
+		})
+
+		// Imports up to maxBulkSubscriptions manual subscriptions in one
+		// transaction. Each item is validated like POST /subs and reported on
+		// separately; invalid items are skipped unless all_or_nothing is set,
+		// in which case any failure leaves nothing created (422).
+		api.POST("/subs/bulk", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			var req struct {
+				Subscriptions []store.Subscription `json:"subscriptions"`
+				AllOrNothing  bool                 `json:"all_or_nothing"`
+			}
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if len(req.Subscriptions) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "subscriptions is required"})
+				return
+			}
+			if len(req.Subscriptions) > maxBulkSubscriptions {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d subscriptions per request", maxBulkSubscriptions)})
+				return
+			}
+			results, committed, err := store.CreateSubscriptions(c.Request.Context(), database, userID, req.Subscriptions, req.AllOrNothing)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			created := 0
+			for _, r := range results {
+				if r.Subscription != nil {
+					created++
+				}
+			}
+			status := http.StatusOK
+			if req.AllOrNothing && !committed {
+				status = http.StatusUnprocessableEntity
+			}
+			c.JSON(status, gin.H{
+				"created": created,
+				"failed":  len(results) - created,
+				"results": results,
+			})
+		})
+
+		// Monthly and yearly cost of all active subscriptions, each
+		// normalized with store.AnnualizedCents, plus the most expensive one
+		// by annual cost (null when there are none).
+		api.GET("/subs/summary", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			subs, err := store.GetSubscriptions(c.Request.Context(), database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			yearly := 0
+			var top *store.Subscription
+			topAnnual := 0
+			for i, sub := range subs {
+				annual := store.AnnualizedCents(sub)
+				yearly += annual
+				if top == nil || annual > topAnnual {
+					top = &subs[i]
+					topAnnual = annual
+				}
+			}
+			var mostExpensive gin.H
+			if top != nil {
+				mostExpensive = gin.H{
+					"id":          top.ID,
+					"merchant":    top.Merchant,
+					"amountCents": top.AmountCents,
+					"cadenceDays": top.CadenceDays,
+					"annualCents": topAnnual,
+				}
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"count":         len(subs),
+				"monthlyCents":  yearly / 12,
+				"yearlyCents":   yearly,
+				"mostExpensive": mostExpensive,
+			})
+		})
+
+		// Active subscriptions that look unused: no charge from the merchant
+		// in more than two billing cycles.
+		api.GET("/subs/stale", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			stale, err := store.DetectStaleSubscriptions(c.Request.Context(), database, userID, time.Now())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, stale)
+		})
+
+		// Upcoming charge dates for one subscription
+		api.GET("/subs/:id/occurrences", auth.AuthMiddleware(jwtManager, database), occurrencesHandler(database))
+
+		// Timeline of one subscription: creation, price and status changes,
+		// and charges from the same merchant, oldest first.
+		api.GET("/subs/:id/history", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			id, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription id"})
+				return
+			}
+			history, err := store.GetSubscriptionHistory(c.Request.Context(), database, userID, id)
+			if errors.Is(err, store.ErrSubscriptionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, history)
+		})
+
+		// Projected savings from cancelling a set of subscriptions, plus the
+		// burn that would remain. All ids must be the user's active subs.
+		api.POST("/subs/savings", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			var req struct {
+				IDs []uuid.UUID `json:"ids" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			subs, err := store.GetSubscriptions(c.Request.Context(), database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			savings, err := store.CancellationSavings(subs, req.IDs)
+			if errors.Is(err, store.ErrSubscriptionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, savings)
+		})
+
+		// TODO: Implement real delete in DB. For demo, return 204.
+		api.DELETE("/subs/:id", func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		// Deactivate every subscription from ?source= (plaid or manual). The
+		// source is required so a bare DELETE /subs can't wipe everything.
+		api.DELETE("/subs", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			source := c.Query("source")
+			if source != "plaid" && source != "manual" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "source must be plaid or manual"})
+				return
+			}
+			n, err := store.DeactivateSubscriptionsBySource(c.Request.Context(), database, userID, source)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"deleted": n})
+		})
+
+		// States, years and filing statuses with loaded tax tables
+		api.GET("/estimate/supported", func(c *gin.Context) {
+			supported, err := estimate.SupportedInputs(c.Request.Context(), database)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, supported)
+		})
+
+		// payFreq and termWeeks default to the signed-in user's profile
+		// when omitted. Without incomeCents, gross pay for the term is
+		// computed from hourlyCents and hoursPerWeek (or the profile's),
+		// with overtime past overtimeAfterHours (default 40; 0 makes every
+		// hour overtime) at overtimeMultiplier, or from the profile's
+		// stipend when it has no hourly rate. Hourly estimates also report
+		// annualGrossCents, a full year at the same hours. Taxes use the
+		// tables for ?year= (default this year) or, when those aren't
+		// loaded, the latest earlier ones; taxYear reports which.
+		api.POST("/estimate/taxes", auth.OptionalAuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			// Parse payload {incomeCents,state,filingStatus,payFreq,termWeeks,...}
+			var body struct {
+				IncomeCents        int      `json:"incomeCents"`
+				State              string   `json:"state"`
+				FilingStatus       string   `json:"filingStatus"`
+				PayFreq            string   `json:"payFreq"`
+				TermWeeks          int      `json:"termWeeks"`
+				HourlyCents        int      `json:"hourlyCents"`
+				HoursPerWeek       float64  `json:"hoursPerWeek"`
+				OvertimeAfterHours *float64 `json:"overtimeAfterHours"`
+				OvertimeMultiplier float64  `json:"overtimeMultiplier"`
+			}
+			if err := c.BindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			year, err := queryYear(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			taxYear, err := estimate.TaxYear(c.Request.Context(), database, year)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			payFreq, termWeeks, err := payTermDefaults(c, database, time.Now().Year(), body.PayFreq, body.TermWeeks)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			income, annual := body.IncomeCents, 0
+			if income == 0 {
+				pay := estimate.HourlyPay{
+					RateCents:          body.HourlyCents,
+					HoursPerWeek:       body.HoursPerWeek,
+					OvertimeAfterHours: body.OvertimeAfterHours,
+					OvertimeMultiplier: body.OvertimeMultiplier,
+				}
+				prof, err := hourlyDefaults(c, database, &pay)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if pay.RateCents == 0 && prof != nil && prof.StipendCents != nil {
+					// Stipend-only profile: no hourly wages to compute from.
+					income, err = estimate.StipendGrossCents(*prof.StipendCents, payFreq, termWeeks)
+				} else if annual, err = pay.AnnualGrossCents(); err == nil {
+					income, err = pay.GrossCents(termWeeks)
+				}
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			res, err := estimate.EstimateTaxes(c.Request.Context(), database, income, body.State, body.FilingStatus, taxYear, payFreq, termWeeks)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, struct {
+				*estimate.TaxResult
+				TaxYear          int `json:"taxYear"`
+				AnnualGrossCents int `json:"annualGrossCents,omitempty"`
+			}{res, taxYear, annual})
+		})
+
+		// Net pay for the profile's income over its term in each of ?states=
+		// (comma separated, default STATE_COMPARISON_STATES), best first,
+		// using the tax tables for ?year= as on /estimate/taxes.
+		api.GET("/finance/state-comparison", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			ctx := c.Request.Context()
+			states, err := comparisonStates(c.Query("states"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			prof, err := store.GetProfile(ctx, database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			year, err := queryYear(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			taxYear, err := estimate.TaxYear(ctx, database, year)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			payFreq, termWeeks, err := payTermDefaults(c, database, time.Now().Year(), "", 0)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			gross, err := estimate.ProfileGrossCents(prof, termWeeks)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			results, err := estimate.CompareStates(ctx, database, gross, states, "single", taxYear, payFreq, termWeeks)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			comparison := make([]StateTaxComparison, 0, len(results))
+			for _, r := range results {
+				if r.Unsupported {
+					comparison = append(comparison, StateTaxComparison{State: r.State, Unsupported: true})
+					continue
+				}
+				rate := 0.0
+				if gross > 0 {
+					rate = math.Round(float64(r.StateCents)/float64(gross)*10000) / 100
+				}
+				comparison = append(comparison, StateTaxComparison{State: r.State, TaxRate: rate, NetPayCents: r.TermNetCents})
+			}
+			// Unsupported states go last.
+			sort.SliceStable(comparison, func(i, j int) bool {
+				if comparison[i].Unsupported != comparison[j].Unsupported {
+					return comparison[j].Unsupported
+				}
+				return comparison[i].NetPayCents > comparison[j].NetPayCents
+			})
+			c.JSON(http.StatusOK, comparison)
+		})
+
+		// What the user takes home per working day, to set against the
+		// daily burn.
+		api.GET("/finance/daily-income", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			prof, err := store.GetProfile(c.Request.Context(), database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			income, err := estimate.DailyTakeHome(c.Request.Context(), database, prof)
+			if errors.Is(err, estimate.ErrNoProfileIncome) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, income)
+		})
+
+		// The user's next paycheck, gross and net of withholding. ?hours=
+		// is hours worked each week of the pay period (default the
+		// profile's hours per week; 0 for a period with no hours worked);
+		// overtime past 40 is paid at 1.5x.
+		api.GET("/finance/next-paycheck", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			hours, err := queryHours(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			prof, err := store.GetProfile(c.Request.Context(), database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			check, err := estimate.ProjectNextPaycheck(c.Request.Context(), database, prof, hours)
+			if errors.Is(err, estimate.ErrNoProfileIncome) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, check)
+		})
+
+		// Set the savings target for the term: {targetCents, deadline}
+		// with deadline as YYYY-MM-DD.
+		api.POST("/finance/savings-goal", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			var req struct {
+				TargetCents int    `json:"targetCents" binding:"required"`
+				Deadline    string `json:"deadline" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			deadline, err := time.Parse("2006-01-02", req.Deadline)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "deadline must be YYYY-MM-DD"})
+				return
+			}
+			goal, err := store.CreateSavingsGoal(c.Request.Context(), database, userID, store.SavingsGoal{
+				TargetCents: req.TargetCents,
+				Deadline:    deadline,
+			})
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusCreated, goal)
+		})
+
+		// Whether the savings goal will be met: daily take-home on the
+		// remaining working days minus today's burn on every remaining day,
+		// compared to the target.
+		api.GET("/finance/savings-progress", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			loc, err := requestLocation(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			ctx := c.Request.Context()
+			goal, err := store.GetSavingsGoal(ctx, database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if goal == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No savings goal set"})
+				return
+			}
+			prof, err := store.GetProfile(ctx, database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			income, err := estimate.DailyTakeHome(ctx, database, prof)
+			if errors.Is(err, estimate.ErrNoProfileIncome) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			today := time.Now().In(loc)
+			burn, err := store.DailyBurn(ctx, database, userID, today, loc, store.BurnOptions{})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, estimate.ProjectSavings(*goal, income.DailyNetCents, burn.TotalCents, today))
+		})
+
+		// Net pay over the profile's term, taxed in each city's state, minus
+		// that city's rent for the same period. ?bedrooms= (studio, 1, 2,
+		// ...) limits the unit size and ?year= the tax tables, as on
+		// /estimate/taxes. Best first.
+		api.GET("/finance/housing-comparison", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			ctx := c.Request.Context()
+			prof, err := store.GetProfile(ctx, database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			year, err := queryYear(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			taxYear, err := estimate.TaxYear(ctx, database, year)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			payFreq, termWeeks, err := payTermDefaults(c, database, time.Now().Year(), "", 0)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			gross, err := estimate.ProfileGrossCents(prof, termWeeks)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			rents, err := store.GetCityRents(ctx, database, c.Query("bedrooms"))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			var states []string
+			seen := make(map[string]bool)
+			for _, r := range rents {
+				if !seen[r.State] {
+					seen[r.State] = true
+					states = append(states, r.State)
+				}
+			}
+			results, err := estimate.CompareStates(ctx, database, gross, states, "single", taxYear, payFreq, termWeeks)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, housingComparison(rents, results, termWeeks))
+		})
+
+		// Pricing comes from the city_cost_models row for ?city= (or the
+		// signed-in user's profile city), falling back to a national default.
+		api.GET("/commute/estimate", auth.OptionalAuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			origin := c.Query("from")
+			destination := c.Query("to")
+			// Example surge parameter, default to 1.0 (no surge)
+			surge := 1.0
+			if s := c.Query("surge"); s != "" {
+				if v, err := strconv.ParseFloat(s, 64); err == nil {
+					surge = v
+				}
+			}
+			mode, err := commute.ParseMode(c.Query("mode"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			ctx := c.Request.Context()
+			city, state := c.Query("city"), c.Query("state")
+			if city == "" {
+				if userID, ok := auth.GetUserIDFromContext(c); ok {
+					prof, err := store.GetProfile(ctx, database, userID)
+					if err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+						return
+					}
+					if prof != nil {
+						city, state = prof.City, prof.State
+					}
+				}
+			}
+			model := store.DefaultCityCostModel
+			if city != "" {
+				m, err := store.GetCityCostModel(ctx, database, city, state)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if m != nil {
+					model = *m
+				}
+			}
+			est, err := commute.EstimateCommute(ctx, database, origin, destination, city, state, mode, model, surge)
+			if errors.Is(err, commute.ErrNoAPIKey) {
+				log.Printf("commute: %v", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Commute estimation unavailable: maps integration is not configured"})
+				return
+			}
+			if errors.Is(err, commute.ErrInvalidAddress) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, est)
+		})
+
+		// Logged commutes, optionally filtered by ?from= and ?to= dates
+		// (YYYY-MM-DD in ?tz=, to is exclusive).
+		api.GET("/commute/entries", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			loc, err := requestLocation(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			from, err := queryDate(c, "from", loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			to, err := queryDate(c, "to", loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			entries, err := store.GetCommuteEntries(c.Request.Context(), database, userID, from, to)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, entries)
+		})
+
+		api.POST("/commute/entries", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			var req store.CommuteEntry
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			entry, err := store.CreateCommuteEntry(c.Request.Context(), database, userID, req)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusCreated, entry)
+		})
+
+		// Spend for ?date= (YYYY-MM-DD, default today) in ?tz=: subscriptions
+		// billing that day, the user's share of commutes logged that day and
+		// the profile food cost on office days. With ?attendance=true,
+		// commutes and food are dropped on days whose in-person events were
+		// all skipped. Same response shape as the demo endpoint.
+		api.GET("/daily/burn", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			loc, err := requestLocation(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			day, err := queryDate(c, "date", loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if day.IsZero() {
+				day = time.Now().In(loc)
+			}
+			ctx := c.Request.Context()
+
+			// DailyBurn projects billing dates from next_due without
+			// storing them, so this GET never writes.
+			opts := store.BurnOptions{UseAttendance: c.Query("attendance") == "true"}
+			burn, err := store.DailyBurn(ctx, database, userID, day, loc, opts)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, burn)
+		})
+
+		// Spend trends for the week (Monday to Sunday) or calendar month
+		// containing ?date= (default today) in ?tz=, day by day and in
+		// total. Subscriptions are pro-rated by cadence rather than counted
+		// on billing days; commutes and food are as in /daily/burn,
+		// including ?attendance=true.
+		for _, period := range []string{"weekly", "monthly"} {
+			api.GET("/burn/"+period, auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+				userID, exists := auth.GetUserIDFromContext(c)
+				if !exists {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+					return
+				}
+				start, days, loc, err := burnPeriod(c, period)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				ctx := c.Request.Context()
+				opts := store.BurnOptions{UseAttendance: c.Query("attendance") == "true"}
+				series, err := store.BurnRange(ctx, database, userID, start, days, loc, opts)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusOK, series)
+			})
+		}
+
+		// Budgets: spending limits per transaction category, weekly or
+		// monthly. {category, limitCents, period}
+		api.GET("/budgets", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			budgets, err := store.GetBudgets(c.Request.Context(), database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, budgets)
+		})
+
+		api.POST("/budgets", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			var req store.Budget
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			budget, err := store.CreateBudget(c.Request.Context(), database, userID, req)
+			if errors.Is(err, store.ErrBudgetExists) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusCreated, budget)
+		})
+
+		api.PUT("/budgets/:id", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			id, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid budget id"})
+				return
+			}
+			var req store.Budget
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			req.ID = id
+			budget, err := store.UpdateBudget(c.Request.Context(), database, userID, req)
+			switch {
+			case errors.Is(err, store.ErrBudgetNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			case errors.Is(err, store.ErrBudgetExists):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			case err != nil:
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, budget)
+		})
+
+		api.DELETE("/budgets/:id", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			id, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid budget id"})
+				return
+			}
+			err = store.DeleteBudget(c.Request.Context(), database, userID, id)
+			if errors.Is(err, store.ErrBudgetNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Status(http.StatusNoContent)
+		})
+
+		// Spending against each budget for the week or month containing
+		// ?date= (default today) in ?tz=, with the over-limit categories and
+		// what is left of the monthly budgets this month.
+		api.GET("/budgets/status", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			loc, err := requestLocation(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			day, err := queryDate(c, "date", loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if day.IsZero() {
+				day = time.Now().In(loc)
+			}
+			status, err := store.GetBudgetsStatus(c.Request.Context(), database, userID, day, loc)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, status)
+		})
+
+		api.GET("/profile", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			prof, err := store.GetProfile(c.Request.Context(), database, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if prof == nil {
+				c.JSON(http.StatusOK, gin.H{})
+				return
+			}
+			c.JSON(http.StatusOK, prof)
+		})
+
+		api.POST("/profile", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			var prof store.Profile
+			if err := c.BindJSON(&prof); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if !validateProfile(c, &prof) {
+				return
+			}
+			prof.UserID = userID
+			if err := store.UpsertProfile(c.Request.Context(), database, prof); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusCreated, prof)
+		})
+
+		// Everything stored for the signed-in user as one JSON download:
+		// account, profile, subscriptions (inactive too), calendar events,
+		// commute entries and transactions. The user ID only ever comes
+		// from the token.
+		api.GET("/export/all", auth.AuthMiddleware(jwtManager, database), func(c *gin.Context) {
+			userID, exists := auth.GetUserIDFromContext(c)
+			if !exists {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+				return
+			}
+			export, err := store.ExportUserData(c.Request.Context(), database, userID)
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Header("Content-Disposition", `attachment; filename="dayboard-export.json"`)
+			c.Header("Cache-Control", "no-store")
+			c.JSON(http.StatusOK, export)
+		})
+
+		// Close the pool after the server and every other hook are done
+		// with it, so a drained Plaid sync can still commit.
+		shutdownHooks = append(shutdownHooks, func(context.Context) error {
+			return database.Close()
+		})
+	}
+
+	// Start listening and serving requests. If an error occurs, log and exit.
+	srv := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to run server: %v", err)
+		}
+	}()
+
+	// On SIGINT/SIGTERM stop accepting requests, let in-flight ones finish,
+	// then drain background workers, all within SHUTDOWN_TIMEOUT_SECONDS.
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+	log.Printf("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	for _, hook := range shutdownHooks {
+		if err := hook(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}
+}
+
+// validateProfile normalizes prof (upper-case state, lower-case school,
+// canonical pay frequency) and checks it. On failure it writes a 400
+// listing every invalid field and returns false.
+func validateProfile(c *gin.Context, prof *store.Profile) bool {
+	prof.State = strings.ToUpper(strings.TrimSpace(prof.State))
+	prof.School = strings.ToLower(strings.TrimSpace(prof.School))
+	errs := prof.Validate()
+	if prof.PayFreq != "" {
+		if f, err := estimate.ParsePayFreq(prof.PayFreq); err != nil {
+			if errs == nil {
+				errs = store.FieldErrors{}
+			}
+			errs["PayFreq"] = err.Error()
+		} else {
+			prof.PayFreq = string(f)
+		}
+	}
+	if errs != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs.Error(), "fields": errs})
+		return false
+	}
+	return true
+}
+
+// readyTimeout bounds the database ping behind /readyz.
+const readyTimeout = 2 * time.Second
+
+// aiBlockedMessage is shown when Gemini's safety filters withhold an
+// answer.
+const aiBlockedMessage = "I can't help with that. Try rephrasing your question about careers or finances."
+
+// adviceError maps an error from generating advice to the status and
+// message sent to the caller; streams only send the message.
+func adviceError(err error) (int, string) {
+	switch {
+	case errors.Is(err, ai.ErrQueryRejected):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ai.ErrBlocked):
+		return http.StatusUnprocessableEntity, aiBlockedMessage
+	case errors.Is(err, ai.ErrTimeout):
+		return http.StatusGatewayTimeout, "The AI assistant took too long to respond, please try again"
+	}
+	return http.StatusInternalServerError, "Failed to generate advice"
+}
+
+// aiTopSubscriptions is how many of the priciest subscriptions are
+// described to the AI assistant.
+const aiTopSubscriptions = 3
+
+// adviceContext gathers the signed-in user's profile, priciest
+// subscriptions and today's burn for personalizing AI advice. Anonymous
+// callers get an empty context, and lookups that fail are left out.
+func adviceContext(c *gin.Context, database *db.DB) map[string]interface{} {
+	userContext := make(map[string]interface{})
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		return userContext
+	}
+	// Get user profile for context
+	if profile, err := store.GetProfile(c.Request.Context(), database, userID); err == nil && profile != nil {
+		p := map[string]interface{}{"state": profile.State}
+		// buildPrompt expects a plain int; stipend-only profiles have none.
+		if profile.HourlyCents != nil {
+			p["hourly_cents"] = *profile.HourlyCents
+		}
+		userContext["profile"] = p
+	}
+	// Get subscriptions for context, most expensive per year first
+	if subs, err := store.GetSubscriptions(c.Request.Context(), database, userID); err == nil {
+		sort.SliceStable(subs, func(i, j int) bool {
+			return store.AnnualizedCents(subs[i]) > store.AnnualizedCents(subs[j])
+		})
+		top := make([]map[string]interface{}, 0, aiTopSubscriptions)
+		for i := 0; i < len(subs) && i < aiTopSubscriptions; i++ {
+			top = append(top, map[string]interface{}{
+				"merchant":     subs[i].Merchant,
+				"amount_cents": subs[i].AmountCents,
+				"cadence_days": subs[i].CadenceDays,
+				"annual_cents": store.AnnualizedCents(subs[i]),
+			})
+		}
+		userContext["subscription_count"] = len(subs)
+		userContext["top_subscriptions"] = top
+	}
+	// Today's burn, in the caller's ?tz= when it is valid
+	loc, err := requestLocation(c)
+	if err != nil {
+		loc = time.UTC
+	}
+	if burn, err := store.DailyBurn(c.Request.Context(), database, userID, time.Now(), loc, store.BurnOptions{}); err == nil {
+		userContext["daily_burn_cents"] = burn.TotalCents
+	}
+	return userContext
+}
+
+// allowAIRequest counts an AI request against the caller's daily quota:
+// the signed-in user's, or the client IP's for anonymous callers. When the
+// request may not proceed it writes the 429 (or 500) and returns false.
+func allowAIRequest(c *gin.Context, quota *ai.Quota) bool {
+	var allowed bool
+	var err error
+	if userID, ok := auth.GetUserIDFromContext(c); ok {
+		allowed, err = quota.AllowUser(c.Request.Context(), userID)
+	} else {
+		allowed, err = quota.AllowAnonymous(c.Request.Context(), c.ClientIP())
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check AI quota"})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Daily AI request limit reached, please try again tomorrow"})
+		return false
+	}
+	return true
+}
+
+// aiHistoryLimit reads AI_HISTORY_MESSAGES, the number of earlier
+// conversation messages sent with an AI question (default 10).
+func aiHistoryLimit() int {
+	if v := os.Getenv("AI_HISTORY_MESSAGES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 10
+}
+
+// shutdownTimeout reads SHUTDOWN_TIMEOUT_SECONDS (default 15).
+func shutdownTimeout() time.Duration {
+	if v := os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return 15 * time.Second
+}
+
+// housingComparison sets each city's rent over termWeeks against its
+// state's net pay from results, best first. Cities in states without a tax
+// table are flagged TaxUnavailable and listed last.
+func housingComparison(rents []store.CityRent, results []estimate.StateResult, termWeeks int) []HousingComparison {
+	byState := make(map[string]estimate.StateResult, len(results))
+	for _, r := range results {
+		byState[r.State] = r
+	}
+	comparison := make([]HousingComparison, 0, len(rents))
+	for _, r := range rents {
+		row := HousingComparison{
+			City:         r.City + ", " + r.State,
+			Bedrooms:     r.BedroomType,
+			AvgRentCents: r.AvgRentCents,
+		}
+		if res, ok := byState[r.State]; !ok || res.Unsupported {
+			row.TaxUnavailable = true
+		} else {
+			// Rent is monthly; the term is in weeks.
+			termRent := r.AvgRentCents * termWeeks * 12 / 52
+			row.NetAfterRentCents = res.TermNetCents - termRent
+		}
+		comparison = append(comparison, row)
+	}
+	sort.SliceStable(comparison, func(i, j int) bool {
+		if comparison[i].TaxUnavailable != comparison[j].TaxUnavailable {
+			return comparison[j].TaxUnavailable
+		}
+		return comparison[i].NetAfterRentCents > comparison[j].NetAfterRentCents
+	})
+	return comparison
+}
+
+// occurrencesHandler serves the next ?count= charge dates (default 6, at
+// most maxOccurrences) of one of the user's subscriptions.
+func occurrencesHandler(database *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := auth.GetUserIDFromContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription id"})
+			return
+		}
+		count := 6
+		if v := c.Query("count"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxOccurrences {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", maxOccurrences)})
+				return
+			}
+			count = n
+		}
+		sub, err := store.GetSubscription(c.Request.Context(), database, userID, id)
+		if errors.Is(err, store.ErrSubscriptionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		dates := []string{}
+		if sub.NextDue != nil {
+			for _, d := range store.Occurrences(*sub.NextDue, sub.CadenceDays, sub.BillingDay, time.Now(), count) {
+				dates = append(dates, d.Format("2006-01-02"))
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"id":          sub.ID,
+			"merchant":    sub.Merchant,
+			"amountCents": sub.AmountCents,
+			"occurrences": dates,
+		})
+	}
+}
+
+// configHandler serves the resolved configuration for debugging an
+// environment. Integrations are reported by whether their credentials are
+// set; secret values (keys, client secrets, JWT_SECRET, DATABASE_URL) are
+// never included. Login and Plaid settings come from the running limiter
+// and client.
+func configHandler(port string, demoMode bool, database *db.DB, jwtManager *auth.JWTManager, loginLimiter *auth.LoginLimiter, plaidService *plaid.PlaidService, geminiService *ai.GeminiService, aiQuota *ai.Quota, reminderPoll time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxOpen, maxIdle := database.PoolLimits()
+		maxFailures, maxEmailFailures, lockout := loginLimiter.Settings()
+		aiUserLimit, aiAnonLimit := aiQuota.Settings()
+		latency, errorRate := middleware.DemoFaultSettings()
+		detection := plaidService.Detection()
+		c.JSON(http.StatusOK, gin.H{
+			"version": version,
+			"port":    port,
+			"demo": gin.H{
+				"enabled":   demoMode,
+				"latencyMs": latency.Milliseconds(),
+				"errorRate": errorRate,
+			},
+			"integrations": gin.H{
+				"googleCalendar":    os.Getenv("GOOGLE_CLIENT_ID") != "" && os.Getenv("GOOGLE_CLIENT_SECRET") != "",
+				"microsoftCalendar": os.Getenv("MICROSOFT_CLIENT_ID") != "" && os.Getenv("MICROSOFT_CLIENT_SECRET") != "",
+				"plaid":             os.Getenv("PLAID_CLIENT_ID") != "" && os.Getenv("PLAID_SECRET") != "",
+				"gemini":            os.Getenv("GEMINI_API_KEY") != "",
+				"maps":              os.Getenv("MAPS_API_KEY") != "",
+			},
+			"database": gin.H{
+				"maxOpenConns":           maxOpen,
+				"maxIdleConns":           maxIdle,
+				"connMaxLifetimeSeconds": database.ConnMaxLifetime().Seconds(),
+			},
+			"http": gin.H{
+				"maxRequestBodyBytes": middleware.MaxBodyBytes(),
+				"trustedProxies":      middleware.TrustedProxies(),
+			},
+			"cors": gin.H{
+				"allowedOrigins": middleware.CORSAllowedOrigins(),
+			},
+			"auth": gin.H{
+				"jwtSigningAlg":         jwtManager.SigningAlg(),
+				"jwtExpiryHours":        jwtManager.TokenDuration().Hours(),
+				"jwtLeewaySeconds":      jwtManager.Leeway().Seconds(),
+				"jwtSecretConfigured":   os.Getenv("JWT_SECRET") != "",
+				"loginMaxFailures":      maxFailures,
+				"loginMaxEmailFailures": maxEmailFailures,
+				"loginLockoutMinutes":   lockout.Minutes(),
+				"statusCacheSeconds":    auth.StatusCacheTTL().Seconds(),
+			},
+			"outbound": gin.H{
+				"httpTimeoutSeconds":   httpx.Client.Timeout.Seconds(),
+				"geminiTimeoutSeconds": geminiService.Timeout().Seconds(),
+				"geminiModel":          geminiService.Model(),
+				"geminiPricePer1k":     geminiService.PricePer1K(),
+				"retryMaxAttempts":     httpx.DefaultRetryPolicy().MaxAttempts,
+			},
+			"ai": gin.H{
+				"dailyLimit":     aiUserLimit,
+				"anonDailyLimit": aiAnonLimit,
+			},
+			"plaid": gin.H{
+				"env":                  plaidService.Env(),
+				"subMinAmount":         detection.MinAmount,
+				"subRequireCategory":   detection.RequireSubscriptionCategory,
+				"subAmountTolerance":   detection.AmountTolerance,
+				"subIntervalTolerance": detection.IntervalTolerance,
+			},
+			"commuteCacheTtlHours":        commute.CacheTTL().Hours(),
+			"eventDefaultDurationMinutes": store.DefaultEventDuration().Minutes(),
+			"reminderPollSeconds":         reminderPoll.Seconds(),
+			"shutdownTimeoutSeconds":      shutdownTimeout().Seconds(),
+		})
+	}
+}
+
+// versionHandler serves the build info injected at link time, the server
+// mode and which integrations have credentials configured.
+func versionHandler(demoMode bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode := "production"
+		if demoMode {
+			mode = "demo"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"version":   version,
+			"commit":    commit,
+			"buildTime": buildTime,
+			"mode":      mode,
+			"features": gin.H{
+				"googleCalendar":    os.Getenv("GOOGLE_CLIENT_ID") != "",
+				"microsoftCalendar": os.Getenv("MICROSOFT_CLIENT_ID") != "",
+				"plaid":             os.Getenv("PLAID_CLIENT_ID") != "",
+				"gemini":            os.Getenv("GEMINI_API_KEY") != "",
+				"maps":              os.Getenv("MAPS_API_KEY") != "",
+			},
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
+
+func seedDemoData() {
 	now := time.Now().UTC()
 
 	// Seed events
@@ -619,12 +2289,12 @@ func seedDemoData() {
 	}
 
 	// Seed commute entries
-	demoCommutes = []CommuteEntry{
-		{ID: uuid.New(), Date: now, From: "Home", To: "Office", CostCents: 1250, Method: "Uber"},
+	demoCommutes = []store.CommuteEntry{
+		{ID: uuid.New(), Date: now, From: "Home", To: "Office", CostCents: 1250, Method: "Uber", SplitWith: 1, ShareCents: 1250},
 	}
 
 	// Seed email summary
-	demoEmails = EmailSummary{
+	demoEmails = google.EmailSummary{
 		UnreadCount: 7,
 		TopSubjects: []string{"Weekly Team Update", "Action Required: Submit Timesheet", "Lunch & Learn Tomorrow"},
 	}
@@ -657,6 +2327,246 @@ func seedDemoData() {
 	}
 }
 
+// requestLocation resolves the optional ?tz= query parameter (an IANA name
+// such as "America/Los_Angeles") to a location. A missing parameter means
+// UTC; an unparseable one is returned as an error so the caller can reject
+// the request instead of silently using the wrong day.
+func requestLocation(c *gin.Context) (*time.Location, error) {
+	tz := c.Query("tz")
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz: %s", tz)
+	}
+	return loc, nil
+}
+
+// queryYear parses the optional ?year= query parameter, defaulting to the
+// current year.
+func queryYear(c *gin.Context) (int, error) {
+	v := c.Query("year")
+	if v == "" {
+		return time.Now().Year(), nil
+	}
+	year, err := strconv.Atoi(v)
+	if err != nil || year < 1900 || year > 9999 {
+		return 0, fmt.Errorf("invalid year: %s", v)
+	}
+	return year, nil
+}
+
+// queryHours parses the optional ?hours= query parameter, hours worked in
+// a week. It returns nil when the parameter is missing, so that 0 hours
+// can be told apart from the profile's default.
+func queryHours(c *gin.Context) (*float64, error) {
+	v := c.Query("hours")
+	if v == "" {
+		return nil, nil
+	}
+	hours, err := strconv.ParseFloat(v, 64)
+	if err != nil || hours < 0 || hours > 168 {
+		return nil, errors.New("hours must be a number between 0 and 168")
+	}
+	return &hours, nil
+}
+
+// queryDate parses an optional YYYY-MM-DD query parameter as midnight in
+// loc. A missing parameter yields the zero time.
+func queryDate(c *gin.Context, name string, loc *time.Location) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s date: %s", name, v)
+	}
+	return t, nil
+}
+
+// burnPeriod resolves ?date= and ?tz= for GET /burn/weekly and
+// /burn/monthly into the first day and length of the week (starting
+// Monday) or calendar month containing the date, which defaults to today.
+func burnPeriod(c *gin.Context, period string) (time.Time, int, *time.Location, error) {
+	loc, err := requestLocation(c)
+	if err != nil {
+		return time.Time{}, 0, nil, err
+	}
+	day, err := queryDate(c, "date", loc)
+	if err != nil {
+		return time.Time{}, 0, nil, err
+	}
+	if day.IsZero() {
+		day, _ = dayBounds(time.Now(), loc)
+	}
+	if period == "monthly" {
+		first := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, loc)
+		return first, first.AddDate(0, 1, -1).Day(), loc, nil
+	}
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset), 7, loc, nil
+}
+
+// agendaRange resolves the ?from=, ?to= and ?tz= parameters of GET /agenda.
+// from defaults to today and to to a week after from; the range may not
+// be empty or longer than maxAgendaDays.
+func agendaRange(c *gin.Context) (time.Time, time.Time, error) {
+	loc, err := requestLocation(c)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, err := queryDate(c, "from", loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := queryDate(c, "to", loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if from.IsZero() {
+		from, _ = dayBounds(time.Now(), loc)
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 7)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be after from")
+	}
+	if to.After(from.AddDate(0, 0, maxAgendaDays)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("range may not exceed %d days", maxAgendaDays)
+	}
+	return from, to, nil
+}
+
+// campusEventRange resolves the ?from=, ?to= and ?tz= parameters of GET
+// /campus/events. from defaults to today and to to 30 days after from.
+func campusEventRange(c *gin.Context) (time.Time, time.Time, error) {
+	loc, err := requestLocation(c)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, err := queryDate(c, "from", loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := queryDate(c, "to", loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if from.IsZero() {
+		from, _ = dayBounds(time.Now(), loc)
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 30)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be after from")
+	}
+	return from, to, nil
+}
+
+// dayBounds returns midnight at the start of t's day in loc and midnight of
+// the following day. The end is computed from the calendar date rather than
+// by adding 24 hours so days that cross a DST transition (23 or 25 hours
+// long) are bounded correctly.
+func dayBounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+}
+
+// payTermDefaults fills in pay frequency and term length from the
+// authenticated user's profile when the request omits them: payFreq from
+// Profile.PayFreq and termWeeks from Profile.StartDate to the end of year.
+// Explicit values always win; with neither, pay frequency falls back to
+// estimate.DefaultPayFreq. Unrecognized pay frequencies and a term that is
+// not positive are errors.
+func payTermDefaults(c *gin.Context, database *db.DB, year int, payFreq string, termWeeks int) (estimate.PayFreq, int, error) {
+	if userID, ok := auth.GetUserIDFromContext(c); ok && (payFreq == "" || termWeeks == 0) {
+		prof, err := store.GetProfile(c.Request.Context(), database, userID)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to load profile: %w", err)
+		}
+		if prof != nil {
+			if payFreq == "" {
+				payFreq = prof.PayFreq
+			}
+			if termWeeks == 0 && prof.StartDate != nil {
+				termWeeks = estimate.TermWeeksFromStart(*prof.StartDate, year)
+				if termWeeks <= 0 {
+					return "", 0, fmt.Errorf("profile start date leaves no term in %d; provide termWeeks", year)
+				}
+			}
+		}
+	}
+	if termWeeks <= 0 {
+		return "", 0, fmt.Errorf("termWeeks must be positive")
+	}
+	if payFreq == "" {
+		return estimate.DefaultPayFreq, termWeeks, nil
+	}
+	freq, err := estimate.ParsePayFreq(payFreq)
+	if err != nil {
+		return "", 0, err
+	}
+	return freq, termWeeks, nil
+}
+
+// defaultComparisonStates is used when neither ?states= nor
+// STATE_COMPARISON_STATES is set.
+const defaultComparisonStates = "CA,TX,NY,WA,IN"
+
+// comparisonStates parses a comma-separated list of two-letter state codes,
+// falling back to STATE_COMPARISON_STATES and then the default list.
+// Codes are uppercased and duplicates dropped.
+func comparisonStates(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		list = os.Getenv("STATE_COMPARISON_STATES")
+	}
+	if strings.TrimSpace(list) == "" {
+		list = defaultComparisonStates
+	}
+	seen := make(map[string]bool)
+	var states []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		if len(s) != 2 || s[0] < 'A' || s[0] > 'Z' || s[1] < 'A' || s[1] > 'Z' {
+			return nil, fmt.Errorf("invalid state code: %q", s)
+		}
+		seen[s] = true
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// hourlyDefaults fills the rate and weekly hours of pay from the signed-in
+// user's profile where the request left them unset. It returns the profile
+// it consulted, if any.
+func hourlyDefaults(c *gin.Context, database *db.DB, pay *estimate.HourlyPay) (*store.Profile, error) {
+	userID, ok := auth.GetUserIDFromContext(c)
+	if !ok || (pay.RateCents != 0 && pay.HoursPerWeek != 0) {
+		return nil, nil
+	}
+	prof, err := store.GetProfile(c.Request.Context(), database, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load profile: %w", err)
+	}
+	if prof == nil {
+		return nil, nil
+	}
+	if pay.RateCents == 0 && prof.HourlyCents != nil {
+		pay.RateCents = *prof.HourlyCents
+	}
+	if pay.HoursPerWeek == 0 && prof.HoursPerWeek != nil {
+		pay.HoursPerWeek = float64(*prof.HoursPerWeek)
+	}
+	return prof, nil
+}
+
 func isSameDay(t1, t2 time.Time) bool {
 	y1, m1, d1 := t1.Date()
 	y2, m2, d2 := t2.Date()
@@ -674,9 +2584,9 @@ func getSubsDueToday() []store.Subscription {
 	return result
 }
 
-func getCommutesToday() []CommuteEntry {
+func getCommutesToday() []store.CommuteEntry {
 	today := time.Now().UTC()
-	var result []CommuteEntry
+	var result []store.CommuteEntry
 	for _, commute := range demoCommutes {
 		if isSameDay(commute.Date, today) {
 			result = append(result, commute)
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+	"dayboard/backend/internal/estimate"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testContext returns a gin context for a GET of target.
+func testContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c, w
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func TestRequestLocation(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{"/agenda/today", "UTC", false},
+		{"/agenda/today?tz=America/Los_Angeles", "America/Los_Angeles", false},
+		{"/agenda/today?tz=Not/AZone", "", true},
+	}
+	for _, tt := range tests {
+		c, _ := testContext(tt.target)
+		loc, err := requestLocation(c)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", tt.target, loc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.target, err)
+			continue
+		}
+		if loc.String() != tt.want {
+			t.Errorf("%s: location = %s, want %s", tt.target, loc, tt.want)
+		}
+	}
+}
+
+func TestDayBoundsDST(t *testing.T) {
+	la := mustLoadLocation(t, "America/Los_Angeles")
+	tests := []struct {
+		name      string
+		at        time.Time
+		wantStart time.Time
+		wantLen   time.Duration
+	}{
+		{
+			// 2024-03-10 springs forward, so the day is 23 hours long.
+			name:      "spring forward",
+			at:        time.Date(2024, 3, 10, 12, 0, 0, 0, la),
+			wantStart: time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC),
+			wantLen:   23 * time.Hour,
+		},
+		{
+			// 2024-11-03 falls back, so the day is 25 hours long.
+			name:      "fall back",
+			at:        time.Date(2024, 11, 3, 23, 30, 0, 0, la),
+			wantStart: time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC),
+			wantLen:   25 * time.Hour,
+		},
+		{
+			// 11pm Pacific is already the next day in UTC but must stay
+			// on the Pacific calendar day.
+			name:      "late evening",
+			at:        time.Date(2024, 3, 9, 7, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 3, 8, 8, 0, 0, 0, time.UTC),
+			wantLen:   24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		start, end := dayBounds(tt.at, la)
+		if !start.Equal(tt.wantStart) {
+			t.Errorf("%s: start = %v, want %v", tt.name, start.UTC(), tt.wantStart)
+		}
+		if got := end.Sub(start); got != tt.wantLen {
+			t.Errorf("%s: day length = %v, want %v", tt.name, got, tt.wantLen)
+		}
+		if end.In(la).Hour() != 0 {
+			t.Errorf("%s: end = %v, want local midnight", tt.name, end.In(la))
+		}
+	}
+}
+
+// profileHandler answers GetProfile with one profile paid payFreq whose
+// internship starts on start.
+func profileHandler(payFreq string, start time.Time) dbtest.Handler {
+	return func(q dbtest.Query) dbtest.Result {
+		if !strings.Contains(q.SQL, "FROM profiles") {
+			return dbtest.Result{}
+		}
+		cols := []string{"home_addr", "office_addr", "city", "state", "hourly_cents", "hours_per_week",
+			"stipend_cents", "pay_freq", "start_date", "in_office_days", "food_cost_cents",
+			"reminder_minutes_before", "school"}
+		return dbtest.Rows(cols, []any{"", "", "Austin", "TX", 3000, 40, nil, payFreq, start, 3, 1500, "{10}", ""})
+	}
+}
+
+func TestPayTermDefaults(t *testing.T) {
+	// A September start leaves 17 weeks in 2024.
+	d, _ := dbtest.Open(t, profileHandler("weekly", time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC)))
+	tests := []struct {
+		name      string
+		payFreq   string
+		termWeeks int
+		wantFreq  estimate.PayFreq
+		wantWeeks int
+	}{
+		{"profile values", "", 0, estimate.PayWeekly, 17},
+		{"explicit frequency", "monthly", 0, estimate.PayMonthly, 17},
+		{"explicit term", "", 10, estimate.PayWeekly, 10},
+		{"both explicit", "biweekly", 12, estimate.PayBiweekly, 12},
+	}
+	for _, tt := range tests {
+		c, _ := testContext("/finance/what-if")
+		c.Set("user_id", uuid.New())
+		freq, weeks, err := payTermDefaults(c, d, 2024, tt.payFreq, tt.termWeeks)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if freq != tt.wantFreq || weeks != tt.wantWeeks {
+			t.Errorf("%s: got %s/%d weeks, want %s/%d", tt.name, freq, weeks, tt.wantFreq, tt.wantWeeks)
+		}
+	}
+}
+
+func TestPayTermDefaultsRejectsEmptyTerm(t *testing.T) {
+	// The profile's start date is after the tax year, so no term remains.
+	d, _ := dbtest.Open(t, profileHandler("weekly", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)))
+	c, _ := testContext("/finance/what-if")
+	c.Set("user_id", uuid.New())
+	if _, _, err := payTermDefaults(c, d, 2024, "", 0); err == nil {
+		t.Error("expected an error for a term that ends before it starts")
+	}
+
+	// Anonymous callers have no profile to fall back on.
+	c, _ = testContext("/finance/what-if")
+	if _, _, err := payTermDefaults(c, d, 2024, "", 0); err == nil {
+		t.Error("expected an error when termWeeks is omitted without a profile")
+	}
+}
+
+func TestAdviceContextHourlyPay(t *testing.T) {
+	d, _ := dbtest.Open(t, profileHandler("weekly", time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)))
+	c, _ := testContext("/ai/advice")
+	c.Set("user_id", uuid.New())
+
+	profile, _ := adviceContext(c, d)["profile"].(map[string]interface{})
+	// buildPrompt only renders a plain int, not the profile's *int.
+	if hourly, ok := profile["hourly_cents"].(int); !ok || hourly != 3000 {
+		t.Errorf("hourly_cents = %#v, want int 3000", profile["hourly_cents"])
+	}
+}
+
+func TestQueryYear(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    int
+		wantErr bool
+	}{
+		{"/estimate/taxes", time.Now().Year(), false},
+		{"/estimate/taxes?year=2023", 2023, false},
+		{"/estimate/taxes?year=last", 0, true},
+		{"/estimate/taxes?year=24", 0, true},
+	}
+	for _, tt := range tests {
+		c, _ := testContext(tt.target)
+		got, err := queryYear(c)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: got %d, %v; want %d, error %v", tt.target, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestQueryHours(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    float64
+		wantNil bool
+		wantErr bool
+	}{
+		{"/finance/next-paycheck", 0, true, false},
+		{"/finance/next-paycheck?hours=", 0, true, false},
+		{"/finance/next-paycheck?hours=0", 0, false, false},
+		{"/finance/next-paycheck?hours=42.5", 42.5, false, false},
+		{"/finance/next-paycheck?hours=-1", 0, true, true},
+		{"/finance/next-paycheck?hours=200", 0, true, true},
+		{"/finance/next-paycheck?hours=lots", 0, true, true},
+	}
+	for _, tt := range tests {
+		c, _ := testContext(tt.target)
+		got, err := queryHours(c)
+		if (err != nil) != tt.wantErr || (got == nil) != tt.wantNil || (got != nil && *got != tt.want) {
+			t.Errorf("%s: got %v, %v; want %v (nil %v), error %v", tt.target, got, err, tt.want, tt.wantNil, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func TestOccurrencesHandler(t *testing.T) {
+	owner := uuid.New()
+	subID := uuid.New()
+	y, m, day := time.Now().AddDate(0, 0, 3).Date()
+	due := time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		cols := []string{"id", "merchant", "amount_cents", "cadence_days", "next_due", "source", "is_active", "billing_day"}
+		if !strings.Contains(q.SQL, "FROM subscriptions") || q.Args[0] != owner || q.Args[1] != subID {
+			return dbtest.Result{Columns: cols}
+		}
+		return dbtest.Rows(cols, []any{subID.String(), "Spotify", 999, 30, due, "manual", true, 0})
+	})
+
+	get := func(userID uuid.UUID, id, query string) (int, []string) {
+		c, w := testContext("/subs/" + id + "/occurrences" + query)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		c.Set("user_id", userID)
+		occurrencesHandler(d)(c)
+		var body struct {
+			Occurrences []string `json:"occurrences"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body.Occurrences
+	}
+
+	code, dates := get(owner, subID.String(), "?count=3")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if len(dates) != 3 || dates[0] != due.Format("2006-01-02") {
+		t.Fatalf("occurrences = %v, want 3 dates from %s", dates, due.Format("2006-01-02"))
+	}
+	// Exact month-end sequences are covered by the store tests.
+	for i := 1; i < len(dates); i++ {
+		prev, _ := time.Parse("2006-01-02", dates[i-1])
+		cur, _ := time.Parse("2006-01-02", dates[i])
+		if gap := cur.Sub(prev).Hours() / 24; gap < 28 || gap > 31 {
+			t.Errorf("%s follows %s, want a month later", dates[i], dates[i-1])
+		}
+	}
+	if _, dates := get(owner, subID.String(), ""); len(dates) != 6 {
+		t.Errorf("default count returned %d dates, want 6", len(dates))
+	}
+
+	for _, query := range []string{"?count=0", "?count=25", "?count=many"} {
+		if code, _ := get(owner, subID.String(), query); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, code)
+		}
+	}
+	if code, _ := get(uuid.New(), subID.String(), ""); code != http.StatusNotFound {
+		t.Errorf("another user's subscription: status = %d, want 404", code)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"dayboard/backend/internal/ai"
+	"dayboard/backend/internal/db/dbtest"
+	"dayboard/backend/internal/middleware"
+)
+
+func TestAllowAIRequestIgnoresForwardedFor(t *testing.T) {
+	t.Setenv("AI_ANON_DAILY_LIMIT", "2")
+	t.Setenv("TRUSTED_PROXIES", "")
+	// The fake ai_anon_usage table counts requests per IP up to the limit.
+	counts := map[string]int{}
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if !strings.Contains(q.SQL, "INSERT INTO ai_anon_usage") {
+			return dbtest.Result{}
+		}
+		ip := q.Args[0].(string)
+		if counts[ip] >= 2 {
+			return dbtest.Result{Columns: []string{"requests"}}
+		}
+		counts[ip]++
+		return dbtest.Rows([]string{"requests"}, []any{counts[ip]})
+	})
+	quota := ai.NewQuota(d)
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(middleware.TrustedProxies()); err != nil {
+		t.Fatal(err)
+	}
+	r.POST("/ai/advice", func(c *gin.Context) {
+		if allowAIRequest(c, quota) {
+			c.Status(http.StatusOK)
+		}
+	})
+
+	// A new X-Forwarded-For on every request doesn't buy a fresh quota.
+	var codes []int
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/ai/advice", nil)
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v, want 200, 200, 429", codes)
+	}
+	// httptest requests come from 192.0.2.1.
+	if len(counts) != 1 || counts["192.0.2.1"] != 2 {
+		t.Errorf("quota counted %v, want only the remote address", counts)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVersionHandler(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "secret-key")
+	t.Setenv("PLAID_CLIENT_ID", "")
+
+	for _, demo := range []bool{false, true} {
+		c, w := testContext("/version")
+		versionHandler(demo)(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d", w.Code)
+		}
+		var got struct {
+			Version   string          `json:"version"`
+			Commit    string          `json:"commit"`
+			BuildTime string          `json:"buildTime"`
+			Mode      string          `json:"mode"`
+			Features  map[string]bool `json:"features"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		// Without -ldflags the build info keeps its defaults.
+		if got.Version != "dev" || got.Commit != "unknown" || got.BuildTime != "unknown" {
+			t.Errorf("build info = %s/%s/%s, want dev/unknown/unknown", got.Version, got.Commit, got.BuildTime)
+		}
+		wantMode := "production"
+		if demo {
+			wantMode = "demo"
+		}
+		if got.Mode != wantMode {
+			t.Errorf("mode = %s, want %s", got.Mode, wantMode)
+		}
+		if !got.Features["gemini"] || got.Features["plaid"] {
+			t.Errorf("features = %v, want gemini only", got.Features)
+		}
+		if strings.Contains(w.Body.String(), "secret-key") {
+			t.Error("response exposes a credential value")
+		}
+	}
+}
@@ -1,19 +1,88 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/metrics"
 )
 
+// ErrTimeout is returned when Gemini does not answer within the service's
+// configured timeout.
+var ErrTimeout = errors.New("gemini request timed out")
+
+// ErrBlocked is returned when Gemini declines to answer for safety
+// reasons. Wrapped errors carry the block reason.
+var ErrBlocked = errors.New("gemini blocked the response")
+
+// defaultTimeout is deliberately longer than other outbound calls: model
+// generation routinely takes several seconds.
+const defaultTimeout = 30 * time.Second
+
+// defaultPricePer1K is a rough blended USD price per 1,000 tokens for
+// gemini-1.5-flash, used when GEMINI_PRICE_PER_1K_TOKENS is unset.
+const defaultPricePer1K = 0.0003
+
+// defaultModel is used when GEMINI_MODEL is unset or blank.
+const defaultModel = "gemini-1.5-flash"
+
+// modelsURL is the Gemini API's model collection; requests go to
+// {modelsURL}/{model}:{method}.
+const modelsURL = "https://generativelanguage.googleapis.com/v1beta/models"
+
 // GeminiService handles Gemini AI API operations
 type GeminiService struct {
 	apiKey  string
+	model   string
 	baseURL string
+	// streamURL is the streamGenerateContent endpoint for the same model.
+	streamURL string
+	timeout   time.Duration
+	client    *http.Client
+	// pricePer1K is the USD price per 1,000 tokens used for cost estimates.
+	pricePer1K float64
+}
+
+// Model returns the Gemini model requests are sent to.
+func (s *GeminiService) Model() string {
+	return s.model
+}
+
+// PricePer1K returns the USD price per 1,000 tokens used for cost
+// estimates.
+func (s *GeminiService) PricePer1K() float64 {
+	return s.pricePer1K
+}
+
+// usage converts Gemini's token counts into a Usage with a cost estimate.
+func (s *GeminiService) usage(meta UsageMetadata) Usage {
+	total := meta.TotalTokenCount
+	if total == 0 {
+		total = meta.PromptTokenCount + meta.CandidatesTokenCount
+	}
+	return Usage{
+		PromptTokens:    meta.PromptTokenCount,
+		CandidateTokens: meta.CandidatesTokenCount,
+		TotalTokens:     total,
+		EstimatedCost:   float64(total) / 1000 * s.pricePer1K,
+	}
+}
+
+// Timeout returns how long GenerateAdvice waits for Gemini, including
+// retries.
+func (s *GeminiService) Timeout() time.Duration {
+	return s.timeout
 }
 
 // GeminiRequest represents a request to the Gemini API
@@ -21,8 +90,10 @@ type GeminiRequest struct {
 	Contents []Content `json:"contents"`
 }
 
-// Content represents the content of a message
+// Content represents the content of a message. Role is "user" or "model"
+// for multi-turn requests and may be left empty for a single prompt.
 type Content struct {
+	Role  string `json:"role,omitempty"`
 	Parts []Part `json:"parts"`
 }
 
@@ -33,75 +104,266 @@ type Part struct {
 
 // GeminiResponse represents the response from Gemini API
 type GeminiResponse struct {
-	Candidates []Candidate `json:"candidates"`
+	Candidates     []Candidate     `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  UsageMetadata   `json:"usageMetadata"`
+}
+
+// PromptFeedback is set when Gemini refuses the prompt itself.
+type PromptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+// UsageMetadata is Gemini's token accounting for a request. In a stream
+// each chunk carries the running totals.
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
-// Candidate represents a response candidate
+// Usage is the tokens an answer consumed and a rough cost at the
+// service's per-1k-token price. Demo answers use no tokens.
+type Usage struct {
+	PromptTokens    int     `json:"promptTokens"`
+	CandidateTokens int     `json:"candidateTokens"`
+	TotalTokens     int     `json:"totalTokens"`
+	EstimatedCost   float64 `json:"estimatedCostUsd"`
+}
+
+// Advice is a generated answer and what it cost to produce.
+type Advice struct {
+	Text  string `json:"advice"`
+	Usage Usage  `json:"usage"`
+}
+
+// Candidate represents a response candidate. FinishReason is "SAFETY"
+// when the answer was cut off by safety filters.
 type Candidate struct {
-	Content Content `json:"content"`
+	Content      Content `json:"content"`
+	FinishReason string  `json:"finishReason,omitempty"`
+}
+
+// blocked reports whether a response was withheld for safety, as an
+// ErrBlocked wrapping the reason, or nil if it was not.
+func (r GeminiResponse) blocked() error {
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return fmt.Errorf("%w: %s", ErrBlocked, r.PromptFeedback.BlockReason)
+	}
+	for _, cand := range r.Candidates {
+		if cand.FinishReason == "SAFETY" && len(cand.Content.Parts) == 0 {
+			return fmt.Errorf("%w: SAFETY", ErrBlocked)
+		}
+	}
+	return nil
 }
 
-// NewGeminiService creates a new Gemini AI service
+// NewGeminiService creates a new Gemini AI service. The model is read from
+// GEMINI_MODEL (default gemini-1.5-flash). The per-request timeout defaults
+// to 30s and can be overridden with GEMINI_TIMEOUT_SECONDS. Cost estimates
+// use GEMINI_PRICE_PER_1K_TOKENS (USD, default 0.0003).
 func NewGeminiService() *GeminiService {
+	model := strings.TrimSpace(os.Getenv("GEMINI_MODEL"))
+	if model == "" {
+		model = defaultModel
+	}
+	timeout := defaultTimeout
+	if v := os.Getenv("GEMINI_TIMEOUT_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			timeout = time.Duration(secs) * time.Second
+		}
+	}
+	price := defaultPricePer1K
+	if v := os.Getenv("GEMINI_PRICE_PER_1K_TOKENS"); v != "" {
+		if p, err := strconv.ParseFloat(v, 64); err == nil && p >= 0 {
+			price = p
+		}
+	}
 	return &GeminiService{
 		apiKey:  os.Getenv("GEMINI_API_KEY"),
-		baseURL: "https://generativelanguage.googleapis.com/v1beta/models/gemini-pro:generateContent",
+		model:   model,
+		baseURL: fmt.Sprintf("%s/%s:generateContent", modelsURL, url.PathEscape(model)),
+		// alt=sse makes Gemini send each chunk as a server-sent event.
+		streamURL:  fmt.Sprintf("%s/%s:streamGenerateContent?alt=sse", modelsURL, url.PathEscape(model)),
+		timeout:    timeout,
+		client:     httpx.NewClient(timeout),
+		pricePer1K: price,
 	}
 }
 
-// GenerateAdvice generates career advice using Gemini AI
-func (s *GeminiService) GenerateAdvice(ctx context.Context, query string, userContext map[string]interface{}) (string, error) {
+// GenerateAdvice generates career advice using Gemini AI, along with the
+// tokens it used. Queries that fail CheckQuery return ErrQueryRejected
+// without calling the API.
+func (s *GeminiService) GenerateAdvice(ctx context.Context, query string, userContext map[string]interface{}) (*Advice, error) {
+	if err := CheckQuery(query); err != nil {
+		return nil, err
+	}
 	if s.apiKey == "" {
 		// Return demo response if no API key
-		return s.getDemoResponse(query), nil
+		return &Advice{Text: s.getDemoResponse(query)}, nil
 	}
 
-	// Build context-aware prompt
-	prompt := s.buildPrompt(query, userContext)
-
-	request := GeminiRequest{
-		Contents: []Content{
-			{
-				Parts: []Part{
-					{Text: prompt},
-				},
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(request)
+	jsonData, err := s.requestBody(query, userContext)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	url := fmt.Sprintf("%s?key=%s", s.baseURL, s.apiKey)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s?key=%s", s.baseURL, s.apiKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonData))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	// generateContent has no side effects, so the POST is safe to repeat.
+	policy := httpx.DefaultRetryPolicy()
+	policy.RetryNonIdempotent = true
+	resp, err := httpx.Do(s.client, req, policy)
+	metrics.ObserveExternal(metrics.ServiceGemini, start, resp, err)
 	if err != nil {
-		return "", err
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrTimeout
+		}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("gemini API error: %s", resp.Status)
+		return nil, fmt.Errorf("gemini API error: %s", resp.Status)
 	}
 
 	var geminiResp GeminiResponse
 	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", err
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrTimeout
+		}
+		return nil, err
 	}
 
+	if err := geminiResp.blocked(); err != nil {
+		return nil, err
+	}
 	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no response from Gemini API")
+		return nil, fmt.Errorf("no response from Gemini API")
+	}
+
+	return &Advice{
+		Text:  geminiResp.Candidates[0].Content.Parts[0].Text,
+		Usage: s.usage(geminiResp.UsageMetadata),
+	}, nil
+}
+
+// GenerateAdviceStream generates the same advice as GenerateAdvice but
+// calls onChunk with each piece of text as Gemini produces it. It returns
+// when the answer is complete, onChunk returns an error, or ctx is
+// cancelled (for example because the client went away). Streams are not
+// retried, since part of the answer may already have been delivered.
+// Queries are checked as in GenerateAdvice; callers streaming to a client
+// should run CheckQuery themselves first, while they can still send a 400.
+// The returned usage is from the last chunk, which holds the totals.
+func (s *GeminiService) GenerateAdviceStream(ctx context.Context, query string, userContext map[string]interface{}, onChunk func(string) error) (*Usage, error) {
+	if err := CheckQuery(query); err != nil {
+		return nil, err
+	}
+	if s.apiKey == "" {
+		// Demo responses arrive a paragraph at a time
+		for _, para := range strings.SplitAfter(s.getDemoResponse(query), "\n\n") {
+			if err := onChunk(para); err != nil {
+				return nil, err
+			}
+		}
+		return &Usage{}, nil
+	}
+
+	jsonData, err := s.requestBody(query, userContext)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s&key=%s", s.streamURL, s.apiKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	metrics.ObserveExternal(metrics.ServiceGemini, start, resp, err)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrTimeout
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gemini API error: %s", resp.Status)
+	}
+
+	// Each event is a "data: " line holding one GeminiResponse.
+	var meta UsageMetadata
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			return nil, fmt.Errorf("gemini stream: %w", err)
+		}
+		if err := chunk.blocked(); err != nil {
+			return nil, err
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			meta = chunk.UsageMetadata
+		}
+		for _, cand := range chunk.Candidates {
+			for _, part := range cand.Content.Parts {
+				if part.Text == "" {
+					continue
+				}
+				if err := onChunk(part.Text); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrTimeout
+		}
+		return nil, err
 	}
+	usage := s.usage(meta)
+	return &usage, nil
+}
 
-	return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+// requestBody builds the JSON request for a query with its user context.
+// Earlier turns in userContext["history"] ([]Content, oldest first) are
+// sent ahead of the prompt so the model can follow the conversation.
+func (s *GeminiService) requestBody(query string, userContext map[string]interface{}) ([]byte, error) {
+	history, _ := userContext["history"].([]Content)
+	contents := make([]Content, 0, len(history)+1)
+	contents = append(contents, history...)
+	contents = append(contents, Content{
+		Role: "user",
+		Parts: []Part{
+			{Text: s.buildPrompt(query, userContext)},
+		},
+	})
+	return json.Marshal(GeminiRequest{Contents: contents})
 }
 
 // buildPrompt creates a context-aware prompt for the AI
@@ -110,7 +372,7 @@ func (s *GeminiService) buildPrompt(query string, userContext map[string]interfa
 
 	// Add user context if available
 	if profile, ok := userContext["profile"].(map[string]interface{}); ok {
-		if state, ok := profile["state"].(string); ok {
+		if state, ok := profile["state"].(string); ok && state != "" {
 			contextInfo.WriteString(fmt.Sprintf("User is located in %s. ", state))
 		}
 		if hourly, ok := profile["hourly_cents"].(int); ok {
@@ -118,8 +380,23 @@ func (s *GeminiService) buildPrompt(query string, userContext map[string]interfa
 		}
 	}
 
-	if subscriptions, ok := userContext["subscriptions"].([]interface{}); ok {
-		contextInfo.WriteString(fmt.Sprintf("User has %d active subscriptions. ", len(subscriptions)))
+	if count, ok := userContext["subscription_count"].(int); ok {
+		contextInfo.WriteString(fmt.Sprintf("User has %d active subscriptions. ", count))
+	}
+	if top, ok := userContext["top_subscriptions"].([]map[string]interface{}); ok && len(top) > 0 {
+		descriptions := make([]string, 0, len(top))
+		for _, sub := range top {
+			merchant, _ := sub["merchant"].(string)
+			amount, _ := sub["amount_cents"].(int)
+			cadence, _ := sub["cadence_days"].(int)
+			annual, _ := sub["annual_cents"].(int)
+			descriptions = append(descriptions, fmt.Sprintf("%s $%.2f every %d days ($%.2f/year)",
+				merchant, float64(amount)/100, cadence, float64(annual)/100))
+		}
+		contextInfo.WriteString(fmt.Sprintf("Priciest subscriptions: %s. ", strings.Join(descriptions, "; ")))
+	}
+	if burn, ok := userContext["daily_burn_cents"].(int); ok {
+		contextInfo.WriteString(fmt.Sprintf("User spends $%.2f today on subscriptions, commuting and food. ", float64(burn)/100))
 	}
 
 	// Build the full prompt
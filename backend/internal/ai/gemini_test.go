@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dayboard/backend/internal/httpx"
+)
+
+// geminiServer returns a GeminiService with the given timeout whose
+// requests go to handler.
+func geminiServer(t *testing.T, timeout time.Duration, handler http.HandlerFunc) *GeminiService {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &GeminiService{
+		apiKey:    "test-key",
+		model:     defaultModel,
+		baseURL:   srv.URL + "/generate",
+		streamURL: srv.URL + "/stream?alt=sse",
+		timeout:   timeout,
+		client:    httpx.NewClient(timeout),
+	}
+}
+
+func TestGenerateAdviceTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	s := geminiServer(t, 50*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	start := time.Now()
+	_, err := s.GenerateAdvice(context.Background(), "How should I negotiate my internship offer?", nil)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("gave up after %v, want about the 50ms Gemini timeout", elapsed)
+	}
+}
+
+func TestNewGeminiServiceTimeout(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", defaultTimeout},
+		{"90", 90 * time.Second},
+		{"0", defaultTimeout},
+		{"soon", defaultTimeout},
+	}
+	for _, tt := range tests {
+		t.Setenv("GEMINI_TIMEOUT_SECONDS", tt.env)
+		if got := NewGeminiService().Timeout(); got != tt.want {
+			t.Errorf("GEMINI_TIMEOUT_SECONDS=%q: timeout = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPromptHourlyPay(t *testing.T) {
+	s := &GeminiService{}
+	prompt := s.buildPrompt("Should I take the offer?", map[string]interface{}{
+		"profile": map[string]interface{}{"state": "TX", "hourly_cents": 3050},
+	})
+	if !strings.Contains(prompt, "User earns $30.50/hour.") {
+		t.Errorf("prompt is missing the hourly line:\n%s", prompt)
+	}
+
+	prompt = s.buildPrompt("Should I take the offer?", map[string]interface{}{
+		"profile": map[string]interface{}{"state": ""},
+	})
+	if strings.Contains(prompt, "/hour") || strings.Contains(prompt, "located in") {
+		t.Errorf("prompt has lines for missing profile fields:\n%s", prompt)
+	}
+}
+
+func TestGenerateAdviceBlocked(t *testing.T) {
+	s := geminiServer(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/stream") {
+			// The answer starts, then safety filters stop it with no text.
+			w.Write([]byte("data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Sure\"}]}}]}\n\n"))
+			w.Write([]byte("data: {\"candidates\":[{\"content\":{},\"finishReason\":\"SAFETY\"}]}\n\n"))
+			return
+		}
+		w.Write([]byte(`{"candidates":[],"promptFeedback":{"blockReason":"SAFETY"}}`))
+	})
+	ctx := context.Background()
+
+	if _, err := s.GenerateAdvice(ctx, "How do I negotiate my salary?", nil); !errors.Is(err, ErrBlocked) {
+		t.Errorf("GenerateAdvice err = %v, want ErrBlocked", err)
+	}
+	_, err := s.GenerateAdviceStream(ctx, "How do I negotiate my salary?", nil, func(string) error { return nil })
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("GenerateAdviceStream err = %v, want ErrBlocked", err)
+	}
+}
@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrQueryRejected is returned for queries that fail CheckQuery. Wrapped
+// errors carry the reason, which is safe to show the user.
+var ErrQueryRejected = errors.New("query rejected")
+
+// maxQueryLength caps a query in characters. Real questions fit easily;
+// longer inputs are usually pasted documents or injection payloads.
+const maxQueryLength = 2000
+
+// injectionPhrases are lowercase fragments typical of attempts to override
+// the advisor prompt. Queries are lowercased and whitespace-collapsed
+// before matching, so "Ignore   Previous\nInstructions" still matches.
+var injectionPhrases = []string{
+	"ignore previous instructions",
+	"ignore all previous instructions",
+	"ignore the above",
+	"ignore your instructions",
+	"disregard previous instructions",
+	"disregard the above",
+	"forget your instructions",
+	"forget all previous instructions",
+	"you are no longer",
+	"new instructions:",
+	"system prompt",
+	"reveal your prompt",
+	"developer mode",
+}
+
+// CheckQuery applies the input rules for AI queries: non-empty, at most
+// maxQueryLength characters, and free of injectionPhrases. Rejections are
+// logged (with the query truncated) so the rules can be tuned.
+func CheckQuery(query string) error {
+	trimmed := strings.TrimSpace(query)
+	if trimmed == "" {
+		return fmt.Errorf("%w: query is empty", ErrQueryRejected)
+	}
+	if n := utf8.RuneCountInString(trimmed); n > maxQueryLength {
+		logRejected("too long", trimmed)
+		return fmt.Errorf("%w: query is longer than %d characters", ErrQueryRejected, maxQueryLength)
+	}
+	normalized := strings.ToLower(strings.Join(strings.Fields(trimmed), " "))
+	for _, phrase := range injectionPhrases {
+		if strings.Contains(normalized, phrase) {
+			logRejected(fmt.Sprintf("matched %q", phrase), trimmed)
+			return fmt.Errorf("%w: query contains instructions for the assistant", ErrQueryRejected)
+		}
+	}
+	return nil
+}
+
+func logRejected(reason, query string) {
+	const maxLogged = 200
+	if utf8.RuneCountInString(query) > maxLogged {
+		query = string([]rune(query)[:maxLogged]) + "…"
+	}
+	log.Printf("ai: rejected query (%s): %q", reason, query)
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// Quota caps how many AI requests a caller may make per UTC day. Counts
+// are stored in ai_usage (per user) and ai_anon_usage (per IP for callers
+// who aren't signed in), so limits hold across restarts and instances.
+type Quota struct {
+	db        *db.DB
+	userLimit int
+	anonLimit int
+}
+
+// NewQuota creates a quota configured from AI_DAILY_LIMIT (default 50
+// requests per user) and AI_ANON_DAILY_LIMIT (default 5 per IP).
+func NewQuota(database *db.DB) *Quota {
+	userLimit := 50
+	if v := os.Getenv("AI_DAILY_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			userLimit = n
+		}
+	}
+	anonLimit := 5
+	if v := os.Getenv("AI_ANON_DAILY_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			anonLimit = n
+		}
+	}
+	return &Quota{db: database, userLimit: userLimit, anonLimit: anonLimit}
+}
+
+// Settings returns the daily limits for signed-in and anonymous callers.
+func (q *Quota) Settings() (userLimit, anonLimit int) {
+	return q.userLimit, q.anonLimit
+}
+
+// AllowUser counts a request against the user's daily limit. It reports
+// false, without counting, once the limit has been reached.
+func (q *Quota) AllowUser(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return q.take(ctx, `
+        INSERT INTO ai_usage (user_id, day, requests) VALUES ($1, $2, 1)
+        ON CONFLICT (user_id, day)
+        DO UPDATE SET requests = ai_usage.requests + 1
+        WHERE ai_usage.requests < $3
+        RETURNING requests
+    `, userID, today(), q.userLimit)
+}
+
+// AllowAnonymous counts a request against the IP's daily limit, like
+// AllowUser.
+func (q *Quota) AllowAnonymous(ctx context.Context, ip string) (bool, error) {
+	return q.take(ctx, `
+        INSERT INTO ai_anon_usage (ip, day, requests) VALUES ($1, $2, 1)
+        ON CONFLICT (ip, day)
+        DO UPDATE SET requests = ai_anon_usage.requests + 1
+        WHERE ai_anon_usage.requests < $3
+        RETURNING requests
+    `, ip, today(), q.anonLimit)
+}
+
+// take runs a conditional increment. No row back means the update's
+// WHERE failed, i.e. the limit was already reached.
+func (q *Quota) take(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	var requests int
+	err := q.db.QueryRowContext(ctx, query, args...).Scan(&requests)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// today is the current UTC date, which quota days are keyed on.
+func today() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
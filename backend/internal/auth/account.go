@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"dayboard/backend/internal/store"
+)
+
+// ConnectionRevoker disconnects a user's third-party connection (Plaid
+// items, a Google grant) at the provider before the account is deleted.
+// A user with nothing connected is not an error.
+type ConnectionRevoker interface {
+	RevokeConnections(ctx context.Context, userID uuid.UUID) error
+}
+
+// DeleteAccountRequest represents the request body for deleting an account
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// DeleteAccount returns a handler that deletes the current user's account
+// and data once they confirm their password. Connections are revoked
+// first, since the tokens needed to revoke them are deleted with the
+// account; if any revocation fails nothing is deleted and the user can
+// retry. Wrong passwords count towards the login limit for the account.
+func (h *AuthHandlers) DeleteAccount(revokers ...ConnectionRevoker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := GetUserIDFromContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+		var req DeleteAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "password is required"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		var email, passwordHash string
+		err := h.db.QueryRowContext(ctx, `
+			SELECT email, password_hash FROM users WHERE id = $1`,
+			userID).Scan(&email, &passwordHash)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		ip := c.ClientIP()
+		retry, err := h.limiter.Check(ctx, email, ip)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if retry > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, please try again later"})
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
+			if err := h.limiter.RecordFailure(ctx, email, ip); err != nil {
+				log.Printf("delete account: failed to record attempt for %s: %v", email, err)
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": "Incorrect password"})
+			return
+		}
+
+		for _, r := range revokers {
+			if err := r.RevokeConnections(ctx, userID); err != nil {
+				log.Printf("delete account: failed to revoke connections for %s: %v", userID, err)
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to disconnect linked accounts, please try again"})
+				return
+			}
+		}
+
+		if err := store.DeleteUser(ctx, h.db, userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+			return
+		}
+		statuses.forget(userID)
+		if err := h.limiter.Reset(ctx, email, ip); err != nil {
+			log.Printf("delete account: failed to reset attempts for %s: %v", email, err)
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -2,7 +2,10 @@ package auth
 
 import (
 	"database/sql"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -14,18 +17,28 @@ import (
 
 // AuthHandlers contains the authentication-related HTTP handlers
 type AuthHandlers struct {
-	db         *db.DB
-	jwtManager *JWTManager
+	db          *db.DB
+	jwtManager  *JWTManager
+	resetSender ResetSender
+	limiter     *LoginLimiter
 }
 
-// NewAuthHandlers creates a new AuthHandlers instance
-func NewAuthHandlers(database *db.DB, jwtManager *JWTManager) *AuthHandlers {
+// NewAuthHandlers creates a new AuthHandlers instance. Password reset
+// tokens are delivered with resetSender.
+func NewAuthHandlers(database *db.DB, jwtManager *JWTManager, resetSender ResetSender) *AuthHandlers {
 	return &AuthHandlers{
-		db:         database,
-		jwtManager: jwtManager,
+		db:          database,
+		jwtManager:  jwtManager,
+		resetSender: resetSender,
+		limiter:     NewLoginLimiter(database),
 	}
 }
 
+// Limiter returns the login limiter the handlers enforce.
+func (h *AuthHandlers) Limiter() *LoginLimiter {
+	return h.limiter
+}
+
 // SignupRequest represents the request body for user signup
 type SignupRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -63,20 +76,6 @@ func (h *AuthHandlers) Signup(c *gin.Context) {
 	// Normalize email
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 
-	// Check if user already exists
-	var existingUserID string
-	err := h.db.QueryRowContext(c.Request.Context(),
-		"SELECT id FROM users WHERE email = $1", req.Email).Scan(&existingUserID)
-
-	if err != sql.ErrNoRows {
-		if err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
-	}
-
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -84,13 +83,19 @@ func (h *AuthHandlers) Signup(c *gin.Context) {
 		return
 	}
 
-	// Create user
+	// Create user. The unique constraint on users.email is the source of
+	// truth for duplicates, so concurrent signups with the same email
+	// cannot both succeed.
 	userID := uuid.New()
 	_, err = h.db.ExecContext(c.Request.Context(), `
 		INSERT INTO users (id, email, name, password_hash, created_at) 
 		VALUES ($1, $2, $3, $4, NOW())`,
 		userID, req.Email, req.Name, string(hashedPassword))
 
+	if db.IsUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
@@ -125,22 +130,38 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 	// Normalize email
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 
+	// Throttle repeated failures for this email, from this client and overall
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+	retry, err := h.limiter.Check(ctx, req.Email, ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if retry > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, please try again later"})
+		return
+	}
+
 	// Get user from database
 	var user struct {
-		ID           uuid.UUID
-		Email        string
-		Name         string
-		PasswordHash string
+		ID              uuid.UUID
+		Email           string
+		Name            string
+		PasswordHash    string
+		Suspended       bool
+		SuspendedReason sql.NullString
 	}
 
-	err := h.db.QueryRowContext(c.Request.Context(), `
-		SELECT id, email, name, password_hash 
+	err = h.db.QueryRowContext(ctx, `
+		SELECT id, email, name, password_hash, suspended, suspended_reason
 		FROM users 
 		WHERE email = $1`,
-		req.Email).Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash)
+		req.Email).Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash, &user.Suspended, &user.SuspendedReason)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		h.loginFailed(c, req.Email, ip)
 		return
 	}
 	if err != nil {
@@ -151,7 +172,16 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		h.loginFailed(c, req.Email, ip)
+		return
+	}
+
+	if err := h.limiter.Reset(ctx, req.Email, ip); err != nil {
+		log.Printf("login: failed to reset attempts for %s: %v", req.Email, err)
+	}
+
+	if user.Suspended {
+		c.JSON(http.StatusForbidden, suspendedResponse(user.SuspendedReason.String))
 		return
 	}
 
@@ -173,6 +203,16 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 	})
 }
 
+// loginFailed records a failed attempt and responds with the generic
+// invalid-credentials error, so unknown emails and wrong passwords look the
+// same to the caller.
+func (h *AuthHandlers) loginFailed(c *gin.Context, email, ip string) {
+	if err := h.limiter.RecordFailure(c.Request.Context(), email, ip); err != nil {
+		log.Printf("login: failed to record attempt for %s: %v", email, err)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+}
+
 // GetProfile returns the current user's profile information
 func (h *AuthHandlers) GetProfile(c *gin.Context) {
 	userID, exists := GetUserIDFromContext(c)
@@ -222,3 +262,63 @@ func (h *AuthHandlers) RefreshToken(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"token": newToken})
 }
+
+// SuspendRequest represents the request body for suspending a user
+type SuspendRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// SuspendUser marks a user as suspended (admin only). Existing tokens stop
+// working on the next request because AuthMiddleware checks the flag; other
+// server instances notice within StatusCacheTTL.
+func (h *AuthHandlers) SuspendUser(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req SuspendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.setSuspended(c, targetID, true, req.Reason)
+}
+
+// UnsuspendUser restores a suspended user's access (admin only)
+func (h *AuthHandlers) UnsuspendUser(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	h.setSuspended(c, targetID, false, "")
+}
+
+func (h *AuthHandlers) setSuspended(c *gin.Context, userID uuid.UUID, suspended bool, reason string) {
+	res, err := h.db.ExecContext(c.Request.Context(), `
+		UPDATE users
+		SET suspended = $2,
+			suspended_reason = NULLIF($3, ''),
+			suspended_at = CASE WHEN $2 THEN NOW() ELSE NULL END,
+			updated_at = NOW()
+		WHERE id = $1`,
+		userID, suspended, reason)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	statuses.forget(userID)
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":        userID,
+		"suspended": suspended,
+	})
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve runs handler for a request with the given JSON body and returns
+// the response.
+func serve(handler gin.HandlerFunc, method, body string, setup ...func(*gin.Context)) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	for _, f := range setup {
+		f(c)
+	}
+	handler(c)
+	return w
+}
+
+func TestSignupDuplicateEmail(t *testing.T) {
+	tests := []struct {
+		name      string
+		insertErr error
+		want      int
+	}{
+		{"created", nil, http.StatusCreated},
+		{"unique violation", &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}, http.StatusConflict},
+		{"other error", errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+			if strings.Contains(q.SQL, "INSERT INTO users") {
+				return dbtest.Result{Err: tt.insertErr}
+			}
+			return dbtest.Result{}
+		})
+		h := &AuthHandlers{db: d, jwtManager: NewJWTManager()}
+		w := serve(h.Signup, "POST", `{"email":"Intern@Example.com","password":"correct horse","name":"Sam"}`)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (body %s)", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
 	"time"
@@ -21,6 +23,34 @@ type Claims struct {
 type JWTManager struct {
 	secretKey     []byte
 	tokenDuration time.Duration
+	signingMethod *jwt.SigningMethodHMAC
+	// leeway tolerates clock skew between services when checking the
+	// exp, nbf and iat claims.
+	leeway time.Duration
+}
+
+// hmacMethods are the signing algorithms JWT_SIGNING_ALG may select. Only
+// HMAC algorithms are allowed since tokens are signed with a shared secret;
+// "none" and asymmetric algorithms are never accepted.
+var hmacMethods = map[string]*jwt.SigningMethodHMAC{
+	"HS256": jwt.SigningMethodHS256,
+	"HS384": jwt.SigningMethodHS384,
+	"HS512": jwt.SigningMethodHS512,
+}
+
+// SigningAlg returns the name of the algorithm tokens are signed with.
+func (m *JWTManager) SigningAlg() string {
+	return m.signingMethod.Alg()
+}
+
+// TokenDuration returns how long issued tokens are valid.
+func (m *JWTManager) TokenDuration() time.Duration {
+	return m.tokenDuration
+}
+
+// Leeway returns the clock skew allowed when validating time claims.
+func (m *JWTManager) Leeway() time.Duration {
+	return m.leeway
 }
 
 // NewJWTManager creates a new JWT manager with secret key from environment
@@ -38,9 +68,29 @@ func NewJWTManager() *JWTManager {
 		}
 	}
 
+	// Signing algorithm from env, default HS256
+	method := jwt.SigningMethodHS256
+	if alg := os.Getenv("JWT_SIGNING_ALG"); alg != "" {
+		if m, ok := hmacMethods[alg]; ok {
+			method = m
+		} else {
+			log.Printf("auth: unsupported JWT_SIGNING_ALG %q, using HS256", alg)
+		}
+	}
+
+	// Clock skew leeway from env, default 30 seconds; 0 disables it
+	leeway := 30 * time.Second
+	if v := os.Getenv("JWT_LEEWAY_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			leeway = time.Duration(secs) * time.Second
+		}
+	}
+
 	return &JWTManager{
 		secretKey:     []byte(secret),
 		tokenDuration: time.Duration(expiryHours) * time.Hour,
+		signingMethod: method,
+		leeway:        leeway,
 	}
 }
 
@@ -58,18 +108,25 @@ func (manager *JWTManager) GenerateToken(userID uuid.UUID, email string) (string
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(manager.signingMethod, claims)
 	return token.SignedString(manager.secretKey)
 }
 
-// ValidateToken parses and validates a JWT token
+// ValidateToken parses and validates a JWT token. Tokens must be signed
+// with the manager's configured algorithm; any other alg header, including
+// "none", is rejected before the secret is used.
 func (manager *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
+			if token.Method != manager.signingMethod {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
 			return manager.secretKey, nil
 		},
+		jwt.WithValidMethods([]string{manager.signingMethod.Alg()}),
+		jwt.WithLeeway(manager.leeway),
 	)
 
 	if err != nil {
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// testClaims returns valid claims for a new user.
+func testClaims() *Claims {
+	now := time.Now()
+	return &Claims{
+		UserID: uuid.New(),
+		Email:  "intern@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "dayboard",
+		},
+	}
+}
+
+func TestValidateTokenRejectsOtherAlgorithms(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	m := NewJWTManager()
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(none); err == nil {
+		t.Error(`accepted an "alg: none" token`)
+	}
+
+	// Signed with the right secret but a different HMAC algorithm.
+	hs512, err := jwt.NewWithClaims(jwt.SigningMethodHS512, testClaims()).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(hs512); err == nil {
+		t.Error("accepted an HS512 token while configured for HS256")
+	}
+
+	valid, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(valid); err != nil {
+		t.Errorf("rejected a valid HS256 token: %v", err)
+	}
+}
+
+func TestValidateTokenLeeway(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	// sign issues a token from a clock skew ahead of the validator's.
+	sign := func(skew, ttl time.Duration) string {
+		claims := testClaims()
+		issued := time.Now().Add(skew)
+		claims.IssuedAt = jwt.NewNumericDate(issued)
+		claims.NotBefore = jwt.NewNumericDate(issued)
+		claims.ExpiresAt = jwt.NewNumericDate(issued.Add(ttl))
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tests := []struct {
+		name   string
+		leeway string
+		token  string
+		valid  bool
+	}{
+		{"issuer 10s ahead", "", sign(10*time.Second, time.Hour), true},
+		{"issuer 10s ahead without leeway", "0", sign(10*time.Second, time.Hour), false},
+		{"issuer 2m ahead", "", sign(2*time.Minute, time.Hour), false},
+		{"expired 10s ago", "", sign(-time.Hour-10*time.Second, time.Hour), true},
+		{"expired 2m ago", "", sign(-time.Hour-2*time.Minute, time.Hour), false},
+		{"issuer 2m ahead with a 5m leeway", "300", sign(2*time.Minute, time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Setenv("JWT_LEEWAY_SECONDS", tt.leeway)
+		_, err := NewJWTManager().ValidateToken(tt.token)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"dayboard/backend/internal/db"
+)
+
+// LoginLimiter locks out an email/IP pair after too many failed logins
+// within a sliding window, and the email from every IP after a larger
+// number, so rotating client IPs doesn't reset the limit. Attempts are
+// stored in login_attempts so the limit holds across restarts and
+// multiple server instances.
+type LoginLimiter struct {
+	db               *db.DB
+	maxFailures      int
+	maxEmailFailures int
+	window           time.Duration
+}
+
+// NewLoginLimiter creates a limiter configured from LOGIN_MAX_FAILURES
+// (per email and IP, default 5), LOGIN_MAX_EMAIL_FAILURES (per email
+// across all IPs, default 20) and LOGIN_LOCKOUT_MINUTES (default 15).
+func NewLoginLimiter(database *db.DB) *LoginLimiter {
+	maxFailures := 5
+	if v := os.Getenv("LOGIN_MAX_FAILURES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxFailures = n
+		}
+	}
+	maxEmailFailures := 20
+	if v := os.Getenv("LOGIN_MAX_EMAIL_FAILURES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxEmailFailures = n
+		}
+	}
+	window := 15 * time.Minute
+	if v := os.Getenv("LOGIN_LOCKOUT_MINUTES"); v != "" {
+		if mins, err := strconv.Atoi(v); err == nil && mins > 0 {
+			window = time.Duration(mins) * time.Minute
+		}
+	}
+	return &LoginLimiter{db: database, maxFailures: maxFailures, maxEmailFailures: maxEmailFailures, window: window}
+}
+
+// Settings returns the per-pair and per-email failure limits and the
+// lockout window in effect.
+func (l *LoginLimiter) Settings() (maxFailures, maxEmailFailures int, window time.Duration) {
+	return l.maxFailures, l.maxEmailFailures, l.window
+}
+
+// Check reports how long the pair must wait before trying again. A zero
+// duration means the attempt may proceed.
+func (l *LoginLimiter) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	var pairFailures, emailFailures int
+	var pairOldest, emailOldest *time.Time
+	err := l.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE ip = $2), MIN(attempted_at) FILTER (WHERE ip = $2),
+		       COUNT(*), MIN(attempted_at)
+		FROM login_attempts
+		WHERE email = $1 AND attempted_at > $3`,
+		email, ip, time.Now().Add(-l.window)).Scan(&pairFailures, &pairOldest, &emailFailures, &emailOldest)
+	if err != nil {
+		return 0, err
+	}
+	oldest := pairOldest
+	if emailFailures >= l.maxEmailFailures {
+		oldest = emailOldest
+	} else if pairFailures < l.maxFailures {
+		return 0, nil
+	}
+	if oldest == nil {
+		return 0, nil
+	}
+	retry := time.Until(oldest.Add(l.window))
+	if retry < time.Second {
+		retry = time.Second
+	}
+	return retry, nil
+}
+
+// RecordFailure counts a failed attempt against the pair.
+func (l *LoginLimiter) RecordFailure(ctx context.Context, email, ip string) error {
+	_, err := l.db.ExecContext(ctx, `
+		INSERT INTO login_attempts (email, ip) VALUES ($1, $2)`,
+		email, ip)
+	return err
+}
+
+// Reset clears the pair's failures after a successful login and prunes
+// attempts that have aged out of the window.
+func (l *LoginLimiter) Reset(ctx context.Context, email, ip string) error {
+	_, err := l.db.ExecContext(ctx, `
+		DELETE FROM login_attempts
+		WHERE (email = $1 AND ip = $2) OR attempted_at < $3`,
+		email, ip, time.Now().Add(-l.window))
+	return err
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func TestLoginLimiterCheck(t *testing.T) {
+	oldest := time.Now().Add(-5 * time.Minute)
+	tests := []struct {
+		name          string
+		pairFailures  int
+		emailFailures int
+		locked        bool
+	}{
+		{"under both limits", 2, 4, false},
+		{"pair limit", 5, 5, true},
+		// Failures spread over many IPs still lock the email.
+		{"email limit across IPs", 1, 20, true},
+	}
+	for _, tt := range tests {
+		d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+			if !strings.Contains(q.SQL, "FROM login_attempts") {
+				return dbtest.Result{}
+			}
+			return dbtest.Rows([]string{"pair", "pair_oldest", "email", "email_oldest"},
+				[]any{tt.pairFailures, oldest, tt.emailFailures, oldest})
+		})
+		l := &LoginLimiter{db: d, maxFailures: 5, maxEmailFailures: 20, window: 15 * time.Minute}
+		retry, err := l.Check(context.Background(), "intern@example.com", "203.0.113.7")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if locked := retry > 0; locked != tt.locked {
+			t.Errorf("%s: retry = %v, want locked = %v", tt.name, retry, tt.locked)
+		}
+		if tt.locked && (retry < 9*time.Minute || retry > 10*time.Minute) {
+			t.Errorf("%s: retry = %v, want about 10m until the oldest failure ages out", tt.name, retry)
+		}
+	}
+}
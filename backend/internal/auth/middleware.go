@@ -1,39 +1,82 @@
 package auth
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// Machine-readable codes included in 401 responses. Clients should refresh
+// on CodeTokenExpired and send the user back to login on CodeTokenInvalid.
+const (
+	CodeTokenExpired = "token_expired"
+	CodeTokenInvalid = "token_invalid"
 )
 
-// AuthMiddleware creates a middleware function that validates JWT tokens
-func AuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
+// errUserNotFound is returned by userStatus when the token's user no longer
+// exists.
+var errUserNotFound = errors.New("user not found")
+
+// AuthMiddleware creates a middleware function that validates JWT tokens.
+// When database is non-nil the user's account status is checked on every
+// request (see userStatus for caching), so suspending an account revokes
+// its tokens.
+func AuthMiddleware(jwtManager *JWTManager, database *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			abortUnauthorized(c, "Authorization header required", CodeTokenInvalid)
 			return
 		}
 
 		// Check for Bearer token format
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+			abortUnauthorized(c, "Invalid authorization header format", CodeTokenInvalid)
 			return
 		}
 
 		tokenString := parts[1]
 		claims, err := jwtManager.ValidateToken(tokenString)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			abortUnauthorized(c, "Token expired", CodeTokenExpired)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			abortUnauthorized(c, "Invalid token", CodeTokenInvalid)
 			return
 		}
 
+		if database != nil {
+			suspended, reason, err := userStatus(c.Request.Context(), database, claims.UserID)
+			if errors.Is(err, errUserNotFound) {
+				abortUnauthorized(c, "Invalid token", CodeTokenInvalid)
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				c.Abort()
+				return
+			}
+			if suspended {
+				c.JSON(http.StatusForbidden, suspendedResponse(reason))
+				c.Abort()
+				return
+			}
+		}
+
 		// Add user info to context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
@@ -41,8 +84,10 @@ func AuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 	}
 }
 
-// OptionalAuthMiddleware extracts user info if token is present, but doesn't require it
-func OptionalAuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
+// OptionalAuthMiddleware extracts user info if token is present, but doesn't require it.
+// Tokens belonging to suspended users are ignored, so those requests proceed
+// as anonymous.
+func OptionalAuthMiddleware(jwtManager *JWTManager, database *db.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -63,6 +108,13 @@ func OptionalAuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		if database != nil {
+			if suspended, _, err := userStatus(c.Request.Context(), database, claims.UserID); err != nil || suspended {
+				c.Next()
+				return
+			}
+		}
+
 		// Add user info to context if valid
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
@@ -70,6 +122,34 @@ func OptionalAuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 	}
 }
 
+// RequireAdmin rejects requests from users without the is_admin flag. It
+// must run after AuthMiddleware.
+func RequireAdmin(database *db.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := GetUserIDFromContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		var isAdmin bool
+		err := database.QueryRowContext(c.Request.Context(),
+			"SELECT is_admin FROM users WHERE id = $1", userID).Scan(&isAdmin)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			c.Abort()
+			return
+		}
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // GetUserIDFromContext extracts the user ID from the Gin context
 func GetUserIDFromContext(c *gin.Context) (uuid.UUID, bool) {
 	userID, exists := c.Get("user_id")
@@ -91,3 +171,116 @@ func GetUserEmailFromContext(c *gin.Context) (string, bool) {
 	emailStr, ok := email.(string)
 	return emailStr, ok
 }
+
+// userStatus returns whether the user is suspended and why. Answers are
+// cached for statusCacheTTL so authenticated requests don't each query the
+// users table; suspending, unsuspending or deleting a user drops the
+// cached entry on this instance, and other instances pick the change up
+// once their entry expires.
+func userStatus(ctx context.Context, database *db.DB, userID uuid.UUID) (bool, string, error) {
+	if st, ok := statuses.get(userID); ok {
+		return st.suspended, st.reason, nil
+	}
+	var suspended bool
+	var reason sql.NullString
+	err := database.QueryRowContext(ctx,
+		"SELECT suspended, suspended_reason FROM users WHERE id = $1", userID).Scan(&suspended, &reason)
+	if err == sql.ErrNoRows {
+		return false, "", errUserNotFound
+	}
+	if err != nil {
+		return false, "", err
+	}
+	statuses.put(userID, cachedStatus{suspended: suspended, reason: reason.String})
+	return suspended, reason.String, nil
+}
+
+// statusCacheTTL is how long a user's suspension status is cached, read
+// from AUTH_STATUS_CACHE_SECONDS (default 10; 0 disables the cache).
+var statusCacheTTL = loadStatusCacheTTL()
+
+func loadStatusCacheTTL() time.Duration {
+	if v := os.Getenv("AUTH_STATUS_CACHE_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return 10 * time.Second
+}
+
+// StatusCacheTTL returns how long AuthMiddleware may rely on a cached
+// suspension status.
+func StatusCacheTTL() time.Duration {
+	return statusCacheTTL
+}
+
+// statuses caches userStatus answers for every middleware instance.
+var statuses = &statusCache{entries: make(map[uuid.UUID]cachedStatus)}
+
+// maxCachedStatuses bounds the cache; expired entries are swept once it
+// is reached.
+const maxCachedStatuses = 10000
+
+type cachedStatus struct {
+	suspended bool
+	reason    string
+	expires   time.Time
+}
+
+// statusCache is a small TTL cache of users' suspension status.
+type statusCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]cachedStatus
+}
+
+func (sc *statusCache) get(userID uuid.UUID) (cachedStatus, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	st, ok := sc.entries[userID]
+	if !ok || time.Now().After(st.expires) {
+		return cachedStatus{}, false
+	}
+	return st, true
+}
+
+func (sc *statusCache) put(userID uuid.UUID, st cachedStatus) {
+	if statusCacheTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	st.expires = now.Add(statusCacheTTL)
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.entries) >= maxCachedStatuses {
+		for id, e := range sc.entries {
+			if now.After(e.expires) {
+				delete(sc.entries, id)
+			}
+		}
+	}
+	sc.entries[userID] = st
+}
+
+// forget drops the user's cached status so the next request reads it
+// from the database.
+func (sc *statusCache) forget(userID uuid.UUID) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	delete(sc.entries, userID)
+}
+
+// abortUnauthorized stops the request with a 401 carrying both a
+// human-readable error and a machine-readable code.
+func abortUnauthorized(c *gin.Context, message, code string) {
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message, "code": code})
+	c.Abort()
+}
+
+// suspendedResponse builds the 403 body returned to suspended users.
+func suspendedResponse(reason string) gin.H {
+	body := gin.H{"error": "Account suspended"}
+	if reason != "" {
+		body["reason"] = reason
+	}
+	return body
+}
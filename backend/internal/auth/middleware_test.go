@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/db/dbtest"
+)
+
+// fakeUsers scripts the users table for a single user whose suspension
+// flag the admin handlers can change.
+type fakeUsers struct {
+	mu        sync.Mutex
+	id        uuid.UUID
+	suspended bool
+	reason    string
+}
+
+func (u *fakeUsers) handle(q dbtest.Query) dbtest.Result {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	switch {
+	case strings.Contains(q.SQL, "SELECT suspended, suspended_reason FROM users"):
+		if q.Args[0] != u.id {
+			return dbtest.Result{Columns: []string{"suspended", "suspended_reason"}}
+		}
+		var reason any
+		if u.reason != "" {
+			reason = u.reason
+		}
+		return dbtest.Rows([]string{"suspended", "suspended_reason"}, []any{u.suspended, reason})
+	case strings.Contains(q.SQL, "UPDATE users"):
+		if q.Args[0] != u.id {
+			return dbtest.Result{}
+		}
+		u.suspended = q.Args[1].(bool)
+		u.reason = q.Args[2].(string)
+		return dbtest.Result{RowsAffected: 1}
+	}
+	return dbtest.Result{}
+}
+
+// protectedRouter serves GET / behind AuthMiddleware.
+func protectedRouter(jm *JWTManager, d *db.DB) *gin.Engine {
+	r := gin.New()
+	r.GET("/", AuthMiddleware(jm, d), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func get(r http.Handler, token string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestSuspendedUserRejectedAndRestored(t *testing.T) {
+	users := &fakeUsers{id: uuid.New()}
+	d, _ := dbtest.Open(t, users.handle)
+	t.Cleanup(func() { statuses.forget(users.id) })
+
+	jm := NewJWTManager()
+	token, err := jm.GenerateToken(users.id, "intern@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := protectedRouter(jm, d)
+	h := &AuthHandlers{db: d, jwtManager: jm}
+	withID := func(c *gin.Context) { c.Params = gin.Params{{Key: "id", Value: users.id.String()}} }
+
+	if code := get(r, token); code != http.StatusOK {
+		t.Fatalf("before suspension: status %d, want 200", code)
+	}
+
+	if w := serve(h.SuspendUser, "POST", `{"reason":"spam"}`, withID); w.Code != http.StatusOK {
+		t.Fatalf("suspend: status %d, body %s", w.Code, w.Body)
+	}
+	if code := get(r, token); code != http.StatusForbidden {
+		t.Errorf("while suspended: status %d, want 403", code)
+	}
+
+	if w := serve(h.UnsuspendUser, "POST", "", withID); w.Code != http.StatusOK {
+		t.Fatalf("unsuspend: status %d, body %s", w.Code, w.Body)
+	}
+	if code := get(r, token); code != http.StatusOK {
+		t.Errorf("after unsuspending: status %d, want 200", code)
+	}
+}
+
+func TestUserStatusIsCached(t *testing.T) {
+	users := &fakeUsers{id: uuid.New()}
+	d, rec := dbtest.Open(t, users.handle)
+	t.Cleanup(func() { statuses.forget(users.id) })
+
+	jm := NewJWTManager()
+	token, err := jm.GenerateToken(users.id, "intern@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := protectedRouter(jm, d)
+	for i := 0; i < 3; i++ {
+		if code := get(r, token); code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, code)
+		}
+	}
+	if n := rec.Count("FROM users"); n != 1 {
+		t.Errorf("looked the user up %d times for 3 requests, want 1", n)
+	}
+}
+
+func TestDeletedUserRejected(t *testing.T) {
+	users := &fakeUsers{id: uuid.New()}
+	d, _ := dbtest.Open(t, users.handle)
+
+	jm := NewJWTManager()
+	token, err := jm.GenerateToken(uuid.New(), "gone@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := get(protectedRouter(jm, d), token); code != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401 for a user that doesn't exist", code)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// resetTokenTTL is how long a password reset token stays valid.
+const resetTokenTTL = time.Hour
+
+// ResetSender delivers a password reset token to the user. NewResetSender
+// picks the implementation from the environment.
+type ResetSender interface {
+	SendPasswordReset(ctx context.Context, email, token string) error
+}
+
+// logResetSender records that a reset was requested without delivering
+// it, for development. The token itself is never logged, since anyone reading the logs
+// could use it; the hash prefix matches the password_resets row.
+type logResetSender struct{}
+
+func (logResetSender) SendPasswordReset(ctx context.Context, email, token string) error {
+	log.Printf("password reset: email=%s token_hash=%s…", email, hashResetToken(token)[:8])
+	return nil
+}
+
+// ForgotPasswordRequest represents the request body for starting a reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the request body for completing a reset
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// ForgotPassword issues a single-use reset token for the given email. It
+// always responds 200 so the endpoint cannot be used to discover which
+// emails have accounts.
+func (h *AuthHandlers) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	ok := gin.H{"message": "If an account exists for that email, a reset link has been sent"}
+
+	ctx := c.Request.Context()
+	var userID uuid.UUID
+	err := h.db.QueryRowContext(ctx, `SELECT id FROM users WHERE email = $1`, req.Email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, ok)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token"})
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	_, err = h.db.ExecContext(ctx, `
+		INSERT INTO password_resets (token_hash, user_id, expires_at)
+		VALUES ($1, $2, $3)`,
+		hashResetToken(token), userID, time.Now().Add(resetTokenTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := h.resetSender.SendPasswordReset(ctx, req.Email, token); err != nil {
+		log.Printf("password reset: delivery to %s failed: %v", req.Email, err)
+	}
+	c.JSON(http.StatusOK, ok)
+}
+
+// ResetPassword validates a reset token and sets a new password. The token
+// is consumed in the same transaction as the password update, and any other
+// outstanding tokens for the user are invalidated.
+func (h *AuthHandlers) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_resets
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`,
+		hashResetToken(strings.TrimSpace(req.Token))).Scan(&userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1`,
+		userID, string(hashedPassword)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE password_resets SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// hashResetToken returns the hex SHA-256 of a reset token, which is what
+// password_resets stores.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestLogResetSenderOmitsToken(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+
+	token := "d2hhdGV2ZXItdGhlLXRva2VuLWlz"
+	if err := (logResetSender{}).SendPasswordReset(context.Background(), "intern@example.com", token); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, token) {
+		t.Errorf("log line contains the reset token: %q", out)
+	}
+	if !strings.Contains(out, "intern@example.com") || !strings.Contains(out, hashResetToken(token)[:8]) {
+		t.Errorf("log line = %q, want the email and token hash prefix", out)
+	}
+}
+
+func TestNewResetSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr error
+	}{
+		{"unconfigured", map[string]string{}, "", ErrNoResetSender},
+		{"log only", map[string]string{"PASSWORD_RESET_LOG_ONLY": "true"}, "log", nil},
+		{"smtp", map[string]string{"SMTP_HOST": "mail.example.com", "SMTP_FROM": "no-reply@example.com", "PASSWORD_RESET_LOG_ONLY": "true"}, "smtp", nil},
+		{"smtp without from", map[string]string{"SMTP_HOST": "mail.example.com"}, "", nil},
+		{"bad port", map[string]string{"SMTP_HOST": "mail.example.com", "SMTP_FROM": "no-reply@example.com", "SMTP_PORT": "mail"}, "", nil},
+		{"bad reset url", map[string]string{"SMTP_HOST": "mail.example.com", "SMTP_FROM": "no-reply@example.com", "PASSWORD_RESET_URL": "reset-page"}, "", nil},
+	}
+	for _, tt := range tests {
+		for _, k := range []string{"SMTP_HOST", "SMTP_PORT", "SMTP_FROM", "PASSWORD_RESET_URL", "PASSWORD_RESET_LOG_ONLY"} {
+			t.Setenv(k, tt.env[k])
+		}
+		sender, err := NewResetSender()
+		var got string
+		switch sender.(type) {
+		case logResetSender:
+			got = "log"
+		case *smtpResetSender:
+			got = "smtp"
+		}
+		if got != tt.want || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) || (tt.want == "") != (err != nil) {
+			t.Errorf("%s: got %q sender, err %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSMTPResetSenderDelivers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// A minimal SMTP server that accepts one message.
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ready\r\n")
+		var lines []string
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				received <- lines
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch {
+			case inData && line == ".":
+				inData = false
+				fmt.Fprint(conn, "250 queued\r\n")
+			case inData:
+			case strings.HasPrefix(line, "EHLO"):
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case line == "DATA":
+				inData = true
+				fmt.Fprint(conn, "354 go ahead\r\n")
+			case line == "QUIT":
+				fmt.Fprint(conn, "221 bye\r\n")
+				received <- lines
+				return
+			default:
+				fmt.Fprint(conn, "250 ok\r\n")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_FROM", "no-reply@dayboard.test")
+	t.Setenv("PASSWORD_RESET_URL", "https://dayboard.test/reset-password")
+	sender, err := NewResetSender()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.SendPasswordReset(context.Background(), "intern@example.com", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	session := strings.Join(<-received, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<no-reply@dayboard.test>",
+		"RCPT TO:<intern@example.com>",
+		"To: intern@example.com",
+		"https://dayboard.test/reset-password?token=abc123",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("session missing %q:\n%s", want, session)
+		}
+	}
+
+	if err := sender.SendPasswordReset(context.Background(), "intern@example.com\r\nBcc: x@example.com", "abc123"); err == nil {
+		t.Error("recipient with a header injection was accepted")
+	}
+}
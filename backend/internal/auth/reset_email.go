@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a whole reset email delivery.
+const smtpTimeout = 10 * time.Second
+
+// ErrNoResetSender is returned by NewResetSender when reset emails have
+// nowhere to go.
+var ErrNoResetSender = errors.New("SMTP_HOST is not set, so password reset emails can't be delivered; set PASSWORD_RESET_LOG_ONLY=true to only log resets in development")
+
+// NewResetSender returns the sender password resets are delivered with.
+// SMTP_HOST selects email over SMTP, with SMTP_PORT (default 587),
+// SMTP_USERNAME and SMTP_PASSWORD for authentication, and SMTP_FROM as the
+// required sender address. PASSWORD_RESET_URL, if set, is the page the
+// email links to with the token as ?token=; otherwise the email carries
+// the token itself. Without SMTP_HOST, PASSWORD_RESET_LOG_ONLY=true logs
+// resets instead of delivering them, for development; anything else is
+// ErrNoResetSender.
+func NewResetSender() (ResetSender, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		if strings.EqualFold(os.Getenv("PASSWORD_RESET_LOG_ONLY"), "true") {
+			return logResetSender{}, nil
+		}
+		return nil, ErrNoResetSender
+	}
+	port := 587
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %s", v)
+		}
+		port = n
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" || strings.ContainsAny(from, "\r\n") {
+		return nil, errors.New("SMTP_FROM must be set to the address reset emails come from")
+	}
+	s := &smtpResetSender{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     from,
+	}
+	if v := os.Getenv("PASSWORD_RESET_URL"); v != "" {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid PASSWORD_RESET_URL: %s", v)
+		}
+		s.resetURL = u
+	}
+	return s, nil
+}
+
+// smtpResetSender emails reset tokens through an SMTP server, upgrading
+// to TLS when the server offers STARTTLS.
+type smtpResetSender struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	resetURL *url.URL
+}
+
+func (s *smtpResetSender) SendPasswordReset(ctx context.Context, email, token string) error {
+	if strings.ContainsAny(email, "\r\n") {
+		return errors.New("invalid recipient address")
+	}
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(email); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(email, token)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message builds the reset email, linking to PASSWORD_RESET_URL when set.
+func (s *smtpResetSender) message(email, token string) []byte {
+	instructions := "Use this code to reset your password: " + token
+	if s.resetURL != nil {
+		u := *s.resetURL
+		q := u.Query()
+		q.Set("token", token)
+		u.RawQuery = q.Encode()
+		instructions = "Reset your password here: " + u.String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", email)
+	b.WriteString("Subject: Reset your DayBoard password\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\nIt expires in %d minutes. If you didn't ask to reset your password, ignore this email.\r\n",
+		instructions, int(resetTokenTTL.Minutes()))
+	return []byte(b.String())
+}
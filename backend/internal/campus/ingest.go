@@ -0,0 +1,175 @@
+// Package campus ingests schools' public event feeds into campus_events.
+//
+// A feed is either iCalendar (the event's first CATEGORIES value becomes
+// its category) or JSON: an array of objects with id, title, start and end
+// (RFC 3339), and optional location, category and url. Feeds are listed in
+// campus_event_sources, keyed by the school users name in their profile.
+package campus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/ics"
+	"dayboard/backend/internal/metrics"
+	"dayboard/backend/internal/store"
+)
+
+// Feed formats accepted in campus_event_sources.
+const (
+	FormatICS  = "ics"
+	FormatJSON = "json"
+)
+
+// windowDays is how far ahead an ingestion stores events; recurring ICS
+// events are expanded up to it.
+const windowDays = 90
+
+// maxFeedSize caps how much of a feed is read.
+const maxFeedSize = 10 << 20
+
+// jsonEvent is one entry of a JSON feed.
+type jsonEvent struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Location string    `json:"location"`
+	Category string    `json:"category"`
+	URL      string    `json:"url"`
+}
+
+// Ingest fetches src's feed and replaces the school's stored events from
+// today through windowDays ahead. Past events are kept. It returns how
+// many events were stored.
+func Ingest(ctx context.Context, d *db.DB, src store.CampusEventSource) (int, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := from.AddDate(0, 0, windowDays)
+
+	body, err := fetch(ctx, src.FeedURL)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	var events []store.CampusEvent
+	switch src.Format {
+	case FormatICS:
+		events, err = parseICS(io.LimitReader(body, maxFeedSize), from, to)
+	case FormatJSON:
+		events, err = parseJSON(io.LimitReader(body, maxFeedSize), from, to)
+	default:
+		err = fmt.Errorf("unknown feed format %q", src.Format)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s feed: %w", src.School, err)
+	}
+
+	if err := store.ReplaceCampusEvents(ctx, d, src.School, from, events); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// IngestAll ingests every configured feed, continuing past failures. It
+// returns the number of events stored per school and the joined errors.
+func IngestAll(ctx context.Context, d *db.DB) (map[string]int, error) {
+	sources, err := store.GetCampusEventSources(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(sources))
+	var errs []error
+	for _, src := range sources {
+		n, err := Ingest(ctx, d, src)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		counts[src.School] = n
+	}
+	return counts, errors.Join(errs...)
+}
+
+func fetch(ctx context.Context, feedURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := httpx.Do(httpx.Client, req, httpx.DefaultRetryPolicy())
+	metrics.ObserveExternal(metrics.ServiceCampus, start, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("campus feed error: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func parseICS(r io.Reader, from, to time.Time) ([]store.CampusEvent, error) {
+	cal, err := ics.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []store.CampusEvent
+	for _, e := range ics.Expand(cal, from, to) {
+		extID := e.UID
+		if !e.RecurrenceID.IsZero() {
+			extID += "@" + e.RecurrenceID.UTC().Format("20060102T150405Z")
+		}
+		category := ""
+		if len(e.Categories) > 0 {
+			category = e.Categories[0]
+		}
+		events = append(events, store.CampusEvent{
+			ExtID:    extID,
+			Title:    e.Summary,
+			Start:    e.Start,
+			End:      store.EventEnd(e.Start, e.End, e.AllDay),
+			Location: e.Location,
+			Category: category,
+			URL:      e.URL,
+		})
+	}
+	return events, nil
+}
+
+func parseJSON(r io.Reader, from, to time.Time) ([]store.CampusEvent, error) {
+	var feed []jsonEvent
+	if err := json.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+	var events []store.CampusEvent
+	for _, e := range feed {
+		if e.ID == "" || strings.TrimSpace(e.Title) == "" || e.Start.IsZero() {
+			continue
+		}
+		end := store.EventEnd(e.Start, e.End, false)
+		if !e.Start.Before(to) || end.Before(from) {
+			continue
+		}
+		events = append(events, store.CampusEvent{
+			ExtID:    e.ID,
+			Title:    strings.TrimSpace(e.Title),
+			Start:    e.Start,
+			End:      end,
+			Location: e.Location,
+			Category: e.Category,
+			URL:      e.URL,
+		})
+	}
+	return events, nil
+}
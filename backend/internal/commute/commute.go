@@ -2,13 +2,137 @@ package commute
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/metrics"
+	"dayboard/backend/internal/store"
+)
+
+// ErrNoAPIKey is returned when MAPS_API_KEY is not configured and no cached
+// distance exists. It signals a server misconfiguration, not a bad request.
+var ErrNoAPIKey = errors.New("MAPS_API_KEY environment variable not set")
+
+// ErrInvalidAddress is returned when the Maps API cannot locate an origin
+// or destination, or cannot route between them.
+var ErrInvalidAddress = errors.New("invalid origin or destination")
+
+// Mode is how the user travels. It selects both the Distance Matrix travel
+// mode and the cost formula.
+type Mode string
+
+const (
+	// ModeRideshare prices an Uber/Lyft-style ride from the city model.
+	ModeRideshare Mode = "rideshare"
+	// ModeDriving prices the user's own car at a per-mile rate.
+	ModeDriving Mode = "driving"
+	// ModeTransit charges the city's flat transit fare.
+	ModeTransit Mode = "transit"
+	// ModeWalking and ModeBicycling are free.
+	ModeWalking   Mode = "walking"
+	ModeBicycling Mode = "bicycling"
 )
 
+// ParseMode validates a mode from a request. An empty string means
+// ModeRideshare.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return ModeRideshare, nil
+	case ModeRideshare, ModeDriving, ModeTransit, ModeWalking, ModeBicycling:
+		return m, nil
+	}
+	return "", fmt.Errorf("unsupported commute mode: %s", s)
+}
+
+// travelMode is the Distance Matrix mode parameter for m.
+func (m Mode) travelMode() string {
+	if m == ModeRideshare {
+		return string(ModeDriving)
+	}
+	return string(m)
+}
+
+// speedMPH is a typical door-to-door speed for m, used to turn a distance
+// into a duration when no live route is available.
+func (m Mode) speedMPH() float64 {
+	switch m {
+	case ModeWalking:
+		return 3
+	case ModeBicycling:
+		return 10
+	case ModeTransit:
+		return 15
+	}
+	return urbanSpeedMPH
+}
+
+// mileageRateCents approximates the full cost of driving a personal car,
+// in the spirit of the IRS standard mileage rate.
+const mileageRateCents = 70
+
+// Provenance values report which method produced an estimate, from most to
+// least precise. Clients can use them to show appropriate confidence.
+const (
+	ProvenanceLive        = "live"
+	ProvenanceCache       = "cache"
+	ProvenanceHaversine   = "haversine"
+	ProvenanceCityAverage = "city_average"
+)
+
+// Fallback parameters. Straight-line distance is scaled by a road factor
+// and converted to time at a typical urban speed. The national averages
+// apply when a city has no average of its own.
+const (
+	roadFactor        = 1.3
+	urbanSpeedMPH     = 25.0
+	earthRadiusMiles  = 3958.8
+	defaultAvgMiles   = 10.0
+	defaultAvgMinutes = 25.0
+)
+
+// cacheTTL is how long a cached distance is trusted before the Maps API is
+// asked again. Commute distances rarely change, so it defaults to 24 hours;
+// override with COMMUTE_CACHE_TTL_HOURS.
+var cacheTTL = loadCacheTTL()
+
+// CacheTTL returns how long cached distances are served before the Maps API
+// is asked again.
+func CacheTTL() time.Duration {
+	return cacheTTL
+}
+
+func loadCacheTTL() time.Duration {
+	if v := os.Getenv("COMMUTE_CACHE_TTL_HOURS"); v != "" {
+		if hours, err := strconv.Atoi(v); err == nil && hours > 0 {
+			return time.Duration(hours) * time.Hour
+		}
+	}
+	return 24 * time.Hour
+}
+
+// Cache counters for observability. A miss means the Maps API was called.
+var cacheHits, cacheMisses atomic.Uint64
+
+// CacheStats returns how many distance lookups were served from a fresh
+// cache entry and how many went to the Maps API since startup.
+func CacheStats() (hits, misses uint64) {
+	return cacheHits.Load(), cacheMisses.Load()
+}
+
 // Estimate represents the output of a commute cost estimate. Distances and
 // durations are included along with low/high cost estimates (in cents).
 type Estimate struct {
@@ -16,30 +140,37 @@ type Estimate struct {
 	DurationMinutes  float64 `json:"durationMinutes"`
 	EstCostLowCents  int     `json:"estCostLowCents"`
 	EstCostHighCents int     `json:"estCostHighCents"`
+	Provenance       string  `json:"provenance"`
+	Mode             Mode    `json:"mode"`
 }
 
+// distanceMatrixURL is the Google Distance Matrix endpoint.
+var distanceMatrixURL = "https://maps.googleapis.com/maps/api/distancematrix/json"
+
 // estimateDistance calls the Google Distance Matrix API to compute the
 // distance and duration between two addresses. It returns miles and
 // minutes. The API key must be set via MAPS_API_KEY environment
 // variable. This function is blocking and should be called from a
 // goroutine or asynchronous context if latency is a concern.
-func estimateDistance(ctx context.Context, origin, destination string) (float64, float64, error) {
+func estimateDistance(ctx context.Context, origin, destination string, mode Mode) (float64, float64, error) {
 	apiKey := os.Getenv("MAPS_API_KEY")
 	if apiKey == "" {
-		return 0, 0, fmt.Errorf("MAPS_API_KEY environment variable not set")
+		return 0, 0, ErrNoAPIKey
 	}
-	endpoint := "https://maps.googleapis.com/maps/api/distancematrix/json"
 	params := url.Values{}
 	params.Set("origins", origin)
 	params.Set("destinations", destination)
+	params.Set("mode", mode.travelMode())
 	params.Set("units", "imperial")
 	params.Set("key", apiKey)
-	reqURL := fmt.Sprintf("%s?%s", endpoint, params.Encode())
+	reqURL := fmt.Sprintf("%s?%s", distanceMatrixURL, params.Encode())
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return 0, 0, err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := httpx.Client.Do(req)
+	metrics.ObserveExternal(metrics.ServiceMaps, start, resp, err)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -63,11 +194,18 @@ func estimateDistance(ctx context.Context, origin, destination string) (float64,
 	if err := json.NewDecoder(resp.Body).Decode(&dmResp); err != nil {
 		return 0, 0, err
 	}
+	if dmResp.Status == "INVALID_REQUEST" {
+		return 0, 0, fmt.Errorf("%w: distance matrix status %s", ErrInvalidAddress, dmResp.Status)
+	}
 	if dmResp.Status != "OK" || len(dmResp.Rows) == 0 || len(dmResp.Rows[0].Elements) == 0 {
 		return 0, 0, fmt.Errorf("distance matrix API error: %s", dmResp.Status)
 	}
 	elem := dmResp.Rows[0].Elements[0]
-	if elem.Status != "OK" {
+	switch elem.Status {
+	case "OK":
+	case "NOT_FOUND", "ZERO_RESULTS":
+		return 0, 0, fmt.Errorf("%w: distance matrix element status %s", ErrInvalidAddress, elem.Status)
+	default:
 		return 0, 0, fmt.Errorf("distance matrix element error: %s", elem.Status)
 	}
 	// Convert meters to miles and seconds to minutes.
@@ -77,22 +215,196 @@ func estimateDistance(ctx context.Context, origin, destination string) (float64,
 }
 
 // EstimateCommute calculates the commute cost between origin and destination
-// given a surge factor. The cost is computed based on a simple model:
-// base fare + per-mile * miles + per-minute * minutes. The cost model
-// parameters should be stored in a DB table (city_cost_models) and loaded
-// by the caller. For demonstration, this function accepts the cost
-// parameters directly.
-func EstimateCommute(ctx context.Context, origin, destination string, baseCents, perMileCents, perMinCents int, surge float64) (*Estimate, error) {
-	miles, minutes, err := estimateDistance(ctx, origin, destination)
+// for the given mode. Rideshare uses the city model: base fare + per-mile *
+// miles + per-minute * minutes, with the high estimate scaled by surge.
+// Driving costs a fixed per-mile rate, transit the model's flat fare, and
+// walking or bicycling nothing.
+//
+// Distance and duration come from the first method that succeeds: the live
+// Maps API, the distance cache, a Haversine estimate when both endpoints are
+// "lat,lng" pairs, and finally the average commute for city in state. The method used
+// is reported in Estimate.Provenance. d may be nil, in which case the cache
+// and per-city averages are skipped.
+//
+// Two failures end the chain early, since falling back would hide them:
+// ErrNoAPIKey when the Maps API isn't configured and nothing is cached, and
+// ErrInvalidAddress when an endpoint is empty or the API can't resolve it.
+func EstimateCommute(ctx context.Context, d *db.DB, origin, destination, city, state string, mode Mode, model store.CityCostModel, surge float64) (*Estimate, error) {
+	if strings.TrimSpace(origin) == "" || strings.TrimSpace(destination) == "" {
+		return nil, fmt.Errorf("%w: from and to are required", ErrInvalidAddress)
+	}
+	miles, minutes, provenance, err := resolveDistance(ctx, d, origin, destination, city, state, mode)
 	if err != nil {
 		return nil, err
 	}
-	low := float64(baseCents) + float64(perMileCents)*miles + float64(perMinCents)*minutes
-	high := low * surge
+	var low, high float64
+	switch mode {
+	case ModeRideshare:
+		low = float64(model.BaseFareCents) + float64(model.PerMileCents)*miles + float64(model.PerMinuteCents)*minutes
+		high = low * surge
+	case ModeDriving:
+		low = mileageRateCents * miles
+		high = low
+	case ModeTransit:
+		low = float64(model.TransitFareCents)
+		high = low
+	}
 	return &Estimate{
 		DistanceMiles:    miles,
 		DurationMinutes:  minutes,
 		EstCostLowCents:  int(low),
 		EstCostHighCents: int(high),
+		Provenance:       provenance,
+		Mode:             mode,
 	}, nil
 }
+
+// resolveDistance walks the fallback chain described on EstimateCommute.
+// A cached distance younger than the cache TTL is used without calling the
+// Maps API at all; older entries are only used when the API fails.
+func resolveDistance(ctx context.Context, d *db.DB, origin, destination, city, state string, mode Mode) (float64, float64, string, error) {
+	var cached *cachedRoute
+	if d != nil {
+		route, err := cachedDistance(ctx, d, origin, destination, mode)
+		switch {
+		case err == nil:
+			cached = route
+			if time.Since(route.fetchedAt) < cacheTTL {
+				cacheHits.Add(1)
+				return route.miles, route.minutes, ProvenanceCache, nil
+			}
+		case err != sql.ErrNoRows:
+			log.Printf("commute: distance cache lookup failed: %v", err)
+		}
+	}
+	cacheMisses.Add(1)
+
+	miles, minutes, err := estimateDistance(ctx, origin, destination, mode)
+	if err == nil {
+		if d != nil {
+			if err := storeCachedDistance(ctx, d, origin, destination, mode, miles, minutes); err != nil {
+				log.Printf("commute: failed to cache distance: %v", err)
+			}
+		}
+		return miles, minutes, ProvenanceLive, nil
+	}
+	if cached != nil {
+		log.Printf("commute: live distance unavailable, using stale cache: %v", err)
+		return cached.miles, cached.minutes, ProvenanceCache, nil
+	}
+	if errors.Is(err, ErrNoAPIKey) || errors.Is(err, ErrInvalidAddress) {
+		return 0, 0, "", err
+	}
+	log.Printf("commute: live distance unavailable, falling back: %v", err)
+
+	if from, ok := parseLatLng(origin); ok {
+		if to, ok := parseLatLng(destination); ok {
+			miles := haversineMiles(from, to) * roadFactor
+			return miles, miles / mode.speedMPH() * 60, ProvenanceHaversine, nil
+		}
+	}
+
+	miles, minutes, err = cityAverage(ctx, d, city, state)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	// City averages describe driving commutes; other modes move at their
+	// own pace over the same distance.
+	if mode.travelMode() != string(ModeDriving) {
+		minutes = miles / mode.speedMPH() * 60
+	}
+	return miles, minutes, ProvenanceCityAverage, nil
+}
+
+// cacheKey normalizes an address so trivially different spellings share a
+// cache entry.
+func cacheKey(addr string) string {
+	return strings.Join(strings.Fields(strings.ToLower(addr)), " ")
+}
+
+// cachedRoute is a distance previously returned by the Maps API.
+type cachedRoute struct {
+	miles, minutes float64
+	fetchedAt      time.Time
+}
+
+func cachedDistance(ctx context.Context, d *db.DB, origin, destination string, mode Mode) (*cachedRoute, error) {
+	var r cachedRoute
+	err := d.QueryRowContext(ctx, `
+        SELECT distance_miles, duration_minutes, fetched_at
+        FROM commute_distance_cache
+        WHERE origin = $1 AND destination = $2 AND mode = $3
+    `, cacheKey(origin), cacheKey(destination), mode.travelMode()).Scan(&r.miles, &r.minutes, &r.fetchedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func storeCachedDistance(ctx context.Context, d *db.DB, origin, destination string, mode Mode, miles, minutes float64) error {
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO commute_distance_cache (origin, destination, mode, distance_miles, duration_minutes, fetched_at)
+        VALUES ($1, $2, $3, $4, $5, NOW())
+        ON CONFLICT (origin, destination, mode)
+        DO UPDATE SET distance_miles = EXCLUDED.distance_miles,
+                      duration_minutes = EXCLUDED.duration_minutes,
+                      fetched_at = NOW()
+    `, cacheKey(origin), cacheKey(destination), mode.travelMode(), miles, minutes)
+	return err
+}
+
+// cityAverage returns the city's typical commute, or the national default
+// when the city is unknown or has no average recorded. Like
+// store.GetCityCostModel, a row for the given state wins over one with no
+// state, so a city name shared by several states resolves to one row.
+func cityAverage(ctx context.Context, d *db.DB, city, state string) (float64, float64, error) {
+	if d == nil || city == "" {
+		return defaultAvgMiles, defaultAvgMinutes, nil
+	}
+	var miles, minutes sql.NullFloat64
+	err := d.QueryRowContext(ctx, `
+        SELECT avg_commute_miles, avg_commute_minutes
+        FROM city_cost_models
+        WHERE lower(city) = lower($1) AND (state IS NULL OR upper(state) = upper($2))
+        ORDER BY state NULLS LAST
+        LIMIT 1
+    `, city, state).Scan(&miles, &minutes)
+	if err == sql.ErrNoRows || (err == nil && (!miles.Valid || !minutes.Valid)) {
+		return defaultAvgMiles, defaultAvgMinutes, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return miles.Float64, minutes.Float64, nil
+}
+
+type latLng struct {
+	lat, lng float64
+}
+
+// parseLatLng accepts "lat,lng" coordinates such as "37.7749,-122.4194".
+func parseLatLng(s string) (latLng, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return latLng{}, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return latLng{}, false
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return latLng{}, false
+	}
+	return latLng{lat: lat, lng: lng}, true
+}
+
+// haversineMiles returns the great-circle distance between two points.
+func haversineMiles(a, b latLng) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.lat - a.lat)
+	dLng := toRad(b.lng - a.lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.lat))*math.Cos(toRad(b.lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,159 @@
+package commute
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dayboard/backend/internal/db/dbtest"
+	"dayboard/backend/internal/store"
+)
+
+// mapsServer points the Distance Matrix client at handler for the test.
+func mapsServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	t.Setenv("MAPS_API_KEY", "test-key")
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	prev := distanceMatrixURL
+	distanceMatrixURL = srv.URL
+	t.Cleanup(func() { distanceMatrixURL = prev })
+}
+
+// liveRoute answers with a 16,093 m (10 mile), 20 minute route.
+func liveRoute(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"status":"OK","rows":[{"elements":[{"status":"OK","distance":{"value":16093},"duration":{"value":1200}}]}]}`))
+}
+
+// mapsDown answers every request with a quota error.
+func mapsDown(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"status":"OVER_QUERY_LIMIT","rows":[]}`))
+}
+
+// commuteDB scripts the distance cache and city averages. A nil cached
+// row means a cache miss; a nil average means the city has none.
+func commuteDB(cached, average []any) dbtest.Handler {
+	return func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM commute_distance_cache"):
+			cols := []string{"distance_miles", "duration_minutes", "fetched_at"}
+			if cached == nil {
+				return dbtest.Result{Columns: cols}
+			}
+			return dbtest.Rows(cols, cached)
+		case strings.Contains(q.SQL, "FROM city_cost_models"):
+			cols := []string{"avg_commute_miles", "avg_commute_minutes"}
+			if average == nil {
+				return dbtest.Result{Columns: cols}
+			}
+			return dbtest.Rows(cols, average)
+		}
+		return dbtest.Result{RowsAffected: 1}
+	}
+}
+
+func TestEstimateCommuteFallbackChain(t *testing.T) {
+	fresh := []any{4.0, 12.0, time.Now().Add(-time.Hour)}
+	stale := []any{6.0, 18.0, time.Now().Add(-2 * cacheTTL)}
+	tests := []struct {
+		name           string
+		maps           http.HandlerFunc
+		cached         []any
+		average        []any
+		from, to       string
+		wantProvenance string
+		wantMiles      float64
+	}{
+		{"live API", liveRoute, nil, nil, "Home", "Office", ProvenanceLive, 10},
+		{"fresh cache skips the API", mapsDown, fresh, nil, "Home", "Office", ProvenanceCache, 4},
+		{"stale cache when the API fails", mapsDown, stale, nil, "Home", "Office", ProvenanceCache, 6},
+		// 0.1 degrees of latitude is 6.9 miles, times the 1.3 road factor.
+		{"haversine for coordinates", mapsDown, nil, nil, "37.7,-122.4", "37.8,-122.4", ProvenanceHaversine, 6.909 * roadFactor},
+		{"city average", mapsDown, nil, []any{8.5, 30.0}, "Home", "Office", ProvenanceCityAverage, 8.5},
+		{"national default", mapsDown, nil, nil, "Home", "Office", ProvenanceCityAverage, defaultAvgMiles},
+	}
+	for _, tt := range tests {
+		mapsServer(t, tt.maps)
+		d, rec := dbtest.Open(t, commuteDB(tt.cached, tt.average))
+		est, err := EstimateCommute(context.Background(), d, tt.from, tt.to, "Portland", "OR", ModeDriving, store.DefaultCityCostModel, 1)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if est.Provenance != tt.wantProvenance {
+			t.Errorf("%s: provenance = %s, want %s", tt.name, est.Provenance, tt.wantProvenance)
+		}
+		if math.Abs(est.DistanceMiles-tt.wantMiles) > 0.01 {
+			t.Errorf("%s: distance = %.3f miles, want %.3f", tt.name, est.DistanceMiles, tt.wantMiles)
+		}
+		if tt.wantProvenance == ProvenanceLive && rec.Count("INSERT INTO commute_distance_cache") != 1 {
+			t.Errorf("%s: live distance was not cached", tt.name)
+		}
+	}
+}
+
+func TestCityAverageFiltersByState(t *testing.T) {
+	var args []any
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		args = q.Args
+		return dbtest.Rows([]string{"avg_commute_miles", "avg_commute_minutes"}, []any{3.0, 15.0})
+	})
+	if _, _, err := cityAverage(context.Background(), d, "Portland", "ME"); err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != "Portland" || args[1] != "ME" {
+		t.Errorf("looked up %v, want Portland, ME", args)
+	}
+}
+
+func TestSecondEstimateServedFromCache(t *testing.T) {
+	calls := 0
+	mapsServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		liveRoute(w, r)
+	})
+	// The fake table keeps whatever the first call stores.
+	var stored []any
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "INSERT INTO commute_distance_cache"):
+			stored = []any{q.Args[3], q.Args[4], time.Now()}
+			return dbtest.Result{RowsAffected: 1}
+		case strings.Contains(q.SQL, "FROM commute_distance_cache"):
+			cols := []string{"distance_miles", "duration_minutes", "fetched_at"}
+			if stored == nil {
+				return dbtest.Result{Columns: cols}
+			}
+			return dbtest.Rows(cols, stored)
+		}
+		return dbtest.Result{}
+	})
+
+	hits, misses := CacheStats()
+	ctx := context.Background()
+	first, err := EstimateCommute(ctx, d, "1 Main St", "500 Market St", "", "", ModeDriving, store.DefaultCityCostModel, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Address spelling differences share the cache entry.
+	second, err := EstimateCommute(ctx, d, "1 main st ", "500  Market St", "", "", ModeDriving, store.DefaultCityCostModel, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("Maps API called %d times, want 1", calls)
+	}
+	if first.Provenance != ProvenanceLive || second.Provenance != ProvenanceCache {
+		t.Errorf("provenance = %s then %s, want live then cache", first.Provenance, second.Provenance)
+	}
+	if second.DistanceMiles != first.DistanceMiles {
+		t.Errorf("cached distance %.2f, want %.2f", second.DistanceMiles, first.DistanceMiles)
+	}
+	if h, m := CacheStats(); h-hits != 1 || m-misses != 1 {
+		t.Errorf("cache stats moved by %d hits, %d misses; want 1 each", h-hits, m-misses)
+	}
+}
@@ -3,12 +3,19 @@ package db
 import (
 	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+// uniqueViolation is the PostgreSQL SQLSTATE for unique_violation.
+const uniqueViolation = "23505"
+
 // DB wraps a sql.DB instance and exposes helper methods for common database
 // operations. All queries should be executed via prepared statements to
 // mitigate SQL injection vulnerabilities. The connection string should be
@@ -20,27 +27,81 @@ import (
 // When using Supabase, copy the connection string from your project's settings.
 type DB struct {
 	*sql.DB
+	pool poolConfig
 }
 
 // New creates a new DB connection pool. It reads the DATABASE_URL
 // environment variable and opens a pooled connection using pgx's stdlib
-// driver. If the variable is not set or the connection fails, the
-// application will log and exit. The returned *DB should be closed
-// gracefully on shutdown.
-func New() *DB {
+// driver. It returns an error if the variable is not set or the pool
+// cannot be opened; the caller decides whether that is fatal. The returned
+// *DB should be closed gracefully on shutdown.
+//
+// Pool limits come from DB_MAX_OPEN_CONNS (default 5), DB_MAX_IDLE_CONNS
+// (default 2) and DB_CONN_MAX_LIFETIME (a duration such as "30m"; default
+// 0, meaning connections are reused indefinitely).
+func New() (*DB, error) {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
-		log.Fatal("DATABASE_URL environment variable not set")
+		return nil, errors.New("DATABASE_URL environment variable not set")
 	}
 	db, err := sql.Open("pgx", dsn)
 	if err != nil {
-		log.Fatalf("failed to open database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-	// Set connection pool parameters. Adjust these based on your hosting
-	// environment's limits (e.g. Supabase free tier supports up to 10 connections).
-	db.SetMaxOpenConns(5)
-	db.SetMaxIdleConns(2)
-	return &DB{db}
+	// Set connection pool parameters. The defaults suit the Supabase free
+	// tier, which supports up to 10 connections.
+	pool := loadPoolConfig()
+	db.SetMaxOpenConns(pool.maxOpen)
+	db.SetMaxIdleConns(pool.maxIdle)
+	db.SetConnMaxLifetime(pool.maxLifetime)
+	return &DB{DB: db, pool: pool}, nil
+}
+
+// Default connection pool limits, used when the environment doesn't set
+// them.
+const (
+	defaultMaxOpenConns = 5
+	defaultMaxIdleConns = 2
+)
+
+// poolConfig holds the connection pool settings New applies.
+type poolConfig struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+// loadPoolConfig reads the pool settings described on New. Invalid values
+// fall back to the defaults.
+func loadPoolConfig() poolConfig {
+	cfg := poolConfig{maxOpen: defaultMaxOpenConns, maxIdle: defaultMaxIdleConns}
+	if v := os.Getenv("DB_MAX_OPEN_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.maxOpen = n
+		}
+	}
+	if v := os.Getenv("DB_MAX_IDLE_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.maxIdle = n
+		}
+	}
+	if v := os.Getenv("DB_CONN_MAX_LIFETIME"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.maxLifetime = d
+		}
+	}
+	return cfg
+}
+
+// PoolLimits reports the connection pool limits the pool was opened with.
+func (d *DB) PoolLimits() (maxOpen, maxIdle int) {
+	return d.pool.maxOpen, d.pool.maxIdle
+}
+
+// ConnMaxLifetime reports how long a connection may be reused; zero means
+// forever.
+func (d *DB) ConnMaxLifetime() time.Duration {
+	return d.pool.maxLifetime
 }
 
 // Ping verifies a connection to the database can be established. It's a
@@ -54,3 +115,35 @@ func (d *DB) Ping(ctx context.Context) error {
 func (d *DB) Close() error {
 	return d.DB.Close()
 }
+
+// Querier is the subset of *sql.DB and *sql.Tx that store functions need,
+// so the same function can run on its own or as part of a transaction.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// WithTx runs fn inside a transaction, committing if it returns nil and
+// rolling back otherwise (including on panic). Keep network calls out of
+// fn so the transaction isn't held open while waiting on them.
+func (d *DB) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsUniqueViolation reports whether err was caused by a unique or primary
+// key constraint. Callers can use it to treat the database constraint as
+// the source of truth instead of racing a SELECT-then-INSERT check.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -0,0 +1,195 @@
+// Package dbtest provides a scripted database/sql driver so code that
+// takes a *db.DB or db.Querier can be tested without a PostgreSQL server.
+// Every statement is passed to a Handler, which decides the rows or error
+// it produces, and is recorded so tests can assert on what was run.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"dayboard/backend/internal/db"
+)
+
+// Query is a statement the code under test ran, with its arguments as
+// passed to database/sql.
+type Query struct {
+	SQL  string
+	Args []any
+}
+
+// Result is what a Handler returns for a statement. Columns and Rows are
+// returned to queries; RowsAffected to execs. A non-nil Err fails the
+// statement.
+type Result struct {
+	Columns      []string
+	Rows         [][]any
+	RowsAffected int64
+	Err          error
+}
+
+// Handler answers a statement. Transaction control (BEGIN, COMMIT,
+// ROLLBACK) is recorded but not passed to the handler.
+type Handler func(q Query) Result
+
+// Recorder collects the statements run against a DB returned by Open.
+type Recorder struct {
+	mu      sync.Mutex
+	handler Handler
+	queries []Query
+}
+
+// Queries returns every statement run so far, in order.
+func (r *Recorder) Queries() []Query {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Query(nil), r.queries...)
+}
+
+// Count returns how many statements run so far contain substr.
+func (r *Recorder) Count(substr string) int {
+	n := 0
+	for _, q := range r.Queries() {
+		if strings.Contains(q.SQL, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Recorder) run(query string, args []driver.NamedValue) Result {
+	q := Query{SQL: query, Args: make([]any, len(args))}
+	for i, a := range args {
+		q.Args[i] = a.Value
+	}
+	r.mu.Lock()
+	r.queries = append(r.queries, q)
+	h := r.handler
+	r.mu.Unlock()
+	if h == nil {
+		return Result{}
+	}
+	return h(q)
+}
+
+func (r *Recorder) record(stmt string) {
+	r.mu.Lock()
+	r.queries = append(r.queries, Query{SQL: stmt})
+	r.mu.Unlock()
+}
+
+// Open returns a DB whose statements are answered by h (which may be nil
+// to succeed with no rows) and the recorder that logs them. The DB is
+// closed when the test finishes.
+func Open(t testing.TB, h Handler) (*db.DB, *Recorder) {
+	t.Helper()
+	r := &Recorder{handler: h}
+	sqlDB := sql.OpenDB(connector{r})
+	t.Cleanup(func() { sqlDB.Close() })
+	return &db.DB{DB: sqlDB}, r
+}
+
+// Rows builds a Result returning rows under columns.
+func Rows(columns []string, rows ...[]any) Result {
+	return Result{Columns: columns, Rows: rows}
+}
+
+type connector struct{ r *Recorder }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return &conn{c.r}, nil }
+func (c connector) Driver() driver.Driver                        { return drv{} }
+
+type drv struct{}
+
+func (drv) Open(string) (driver.Conn, error) {
+	return nil, errors.New("dbtest: use dbtest.Open")
+}
+
+type conn struct{ r *Recorder }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) { return &stmt{c, query}, nil }
+func (c *conn) Close() error                              { return nil }
+func (c *conn) Begin() (driver.Tx, error)                 { return c.BeginTx(context.Background(), driver.TxOptions{}) }
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.r.record("BEGIN")
+	return tx{c.r}, nil
+}
+
+// CheckNamedValue passes arguments through unchanged, as pgx accepts
+// types (slices, UUIDs) the default converter rejects.
+func (c *conn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.r.run(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return driver.RowsAffected(res.RowsAffected), nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.r.run(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return &rows{columns: res.Columns, rows: res.Rows}, nil
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return nv
+}
+
+type tx struct{ r *Recorder }
+
+func (t tx) Commit() error   { t.r.record("COMMIT"); return nil }
+func (t tx) Rollback() error { t.r.record("ROLLBACK"); return nil }
+
+type rows struct {
+	columns []string
+	rows    [][]any
+	next    int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.next]
+	r.next++
+	for i := range dest {
+		if i < len(row) {
+			dest[i] = row[i]
+		}
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package estimate
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"dayboard/backend/internal/db"
 )
@@ -10,6 +13,7 @@ import (
 // TaxResult holds the computed tax amounts and net values for a given
 // income, state and filing status. All monetary values are in cents.
 type TaxResult struct {
+	GrossCents          int `json:"grossCents"`
 	FederalCents        int `json:"federalCents"`
 	StateCents          int `json:"stateCents"`
 	FicaCents           int `json:"ficaCents"`
@@ -17,21 +21,185 @@ type TaxResult struct {
 	TermNetCents        int `json:"termNetCents"`
 }
 
+// StateResult pairs a state with its tax estimate. It is returned by
+// CompareStates in the same order as the requested states. Unsupported is
+// set, and the estimate left zero, for a state without a tax table.
+type StateResult struct {
+	State       string `json:"state"`
+	Unsupported bool   `json:"unsupported,omitempty"`
+	TaxResult
+}
+
+// ErrNoStateTable is returned for a state with no tax table for the year.
+// States without an income tax have a zero-rate table, so a missing one
+// means the state isn't supported rather than untaxed.
+var ErrNoStateTable = errors.New("no state tax table")
+
+// ErrNoTaxTables is returned by TaxYear when no federal tables are loaded.
+var ErrNoTaxTables = errors.New("no federal tax tables loaded")
+
+// TaxYear returns the year whose tables are used to estimate taxes for
+// year: year itself when tax_tables_federal has it, otherwise the latest
+// earlier year, or the earliest loaded year if year predates them all. A
+// new calendar year therefore uses last year's brackets until it is seeded.
+func TaxYear(ctx context.Context, d *db.DB, year int) (int, error) {
+	var found sql.NullInt64
+	row := d.QueryRowContext(ctx, `
+        SELECT COALESCE(MAX(year) FILTER (WHERE year <= $1), MIN(year))
+        FROM tax_tables_federal
+    `, year)
+	if err := row.Scan(&found); err != nil {
+		return 0, fmt.Errorf("failed to look up tax year: %w", err)
+	}
+	if !found.Valid {
+		return 0, ErrNoTaxTables
+	}
+	return int(found.Int64), nil
+}
+
+// bracket is a single progressive tax bracket. A high of zero means the
+// bracket has no upper bound.
+type bracket struct {
+	low     int
+	high    int
+	rateBps int
+}
+
+type stateYear struct {
+	state string
+	year  int
+}
+
+// taxTables memoizes bracket sets and standard deductions for the lifetime
+// of a single request, so that estimating many states for the same year
+// loads the federal tables once and each state's brackets once.
+type taxTables struct {
+	d            *db.DB
+	stdDeduction map[int]int
+	federal      map[int][]bracket
+	state        map[stateYear][]bracket
+}
+
+func newTaxTables(d *db.DB) *taxTables {
+	return &taxTables{
+		d:            d,
+		stdDeduction: make(map[int]int),
+		federal:      make(map[int][]bracket),
+		state:        make(map[stateYear][]bracket),
+	}
+}
+
+// stdDeductionSingle returns the single-filer standard deduction for year.
+func (t *taxTables) stdDeductionSingle(ctx context.Context, year int) (int, error) {
+	if v, ok := t.stdDeduction[year]; ok {
+		return v, nil
+	}
+	var v int
+	row := t.d.QueryRowContext(ctx, `SELECT DISTINCT std_deduction_single FROM tax_tables_federal WHERE year = $1 LIMIT 1`, year)
+	if err := row.Scan(&v); err != nil {
+		return 0, fmt.Errorf("failed to fetch std deduction: %w", err)
+	}
+	t.stdDeduction[year] = v
+	return v, nil
+}
+
+func (t *taxTables) federalBrackets(ctx context.Context, year int) ([]bracket, error) {
+	if b, ok := t.federal[year]; ok {
+		return b, nil
+	}
+	b, err := t.loadBrackets(ctx, `
+        SELECT bracket_low, bracket_high, rate_bps
+        FROM tax_tables_federal WHERE year = $1
+        ORDER BY bracket_low ASC
+    `, year)
+	if err != nil {
+		return nil, err
+	}
+	t.federal[year] = b
+	return b, nil
+}
+
+func (t *taxTables) stateBrackets(ctx context.Context, year int, state string) ([]bracket, error) {
+	key := stateYear{state: state, year: year}
+	b, ok := t.state[key]
+	if !ok {
+		var err error
+		b, err = t.loadBrackets(ctx, `
+        SELECT bracket_low, bracket_high, rate_bps
+        FROM tax_tables_state WHERE year = $1 AND state = $2
+        ORDER BY bracket_low ASC
+    `, year, state)
+		if err != nil {
+			return nil, err
+		}
+		t.state[key] = b
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w for %s in %d", ErrNoStateTable, state, year)
+	}
+	return b, nil
+}
+
+func (t *taxTables) loadBrackets(ctx context.Context, query string, args ...interface{}) ([]bracket, error) {
+	rows, err := t.d.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var brackets []bracket
+	for rows.Next() {
+		var b bracket
+		if err := rows.Scan(&b.low, &b.high, &b.rateBps); err != nil {
+			return nil, err
+		}
+		brackets = append(brackets, b)
+	}
+	return brackets, rows.Err()
+}
+
 // EstimateTaxes estimates U.S. federal, state, and FICA taxes for a given annual
 // income (in cents). It looks up the progressive tax brackets stored in
 // tax_tables_federal and tax_tables_state. FilingStatus must be either
-// "single" or "married"; other values return an error. The year parameter
+// "single" or "married"; other values return an error, as does a state
+// without a tax table (ErrNoStateTable). The year parameter
 // allows supporting future/previous tax years. The result includes the
 // after-tax take-home per paycheck over the given termWeeks.
-func EstimateTaxes(ctx context.Context, d *db.DB, incomeCents int, state string, filingStatus string, year int, payFreq string, termWeeks int) (*TaxResult, error) {
+func EstimateTaxes(ctx context.Context, d *db.DB, incomeCents int, state string, filingStatus string, year int, payFreq PayFreq, termWeeks int) (*TaxResult, error) {
+	return newTaxTables(d).estimate(ctx, incomeCents, state, filingStatus, year, payFreq, termWeeks)
+}
+
+// CompareStates runs the same estimate as EstimateTaxes for each state in
+// states. Bracket sets and the standard deduction are loaded once and
+// reused across states, so comparing all 50 states costs roughly one query
+// per state rather than several. A state without a tax table is returned
+// marked Unsupported instead of failing the comparison.
+func CompareStates(ctx context.Context, d *db.DB, incomeCents int, states []string, filingStatus string, year int, payFreq PayFreq, termWeeks int) ([]StateResult, error) {
+	tables := newTaxTables(d)
+	results := make([]StateResult, 0, len(states))
+	for _, state := range states {
+		res, err := tables.estimate(ctx, incomeCents, state, filingStatus, year, payFreq, termWeeks)
+		if errors.Is(err, ErrNoStateTable) {
+			results = append(results, StateResult{State: state, Unsupported: true})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", state, err)
+		}
+		results = append(results, StateResult{State: state, TaxResult: *res})
+	}
+	return results, nil
+}
+
+func (t *taxTables) estimate(ctx context.Context, incomeCents int, state string, filingStatus string, year int, payFreq PayFreq, termWeeks int) (*TaxResult, error) {
 	// Determine standard deduction based on filing status.
 	var stdDeduction int
 	switch filingStatus {
 	case "single":
-		row := d.QueryRowContext(ctx, `SELECT DISTINCT std_deduction_single FROM tax_tables_federal WHERE year = $1 LIMIT 1`, year)
-		if err := row.Scan(&stdDeduction); err != nil {
-			return nil, fmt.Errorf("failed to fetch std deduction: %w", err)
+		v, err := t.stdDeductionSingle(ctx, year)
+		if err != nil {
+			return nil, err
 		}
+		stdDeduction = v
 	case "married":
 		// Not implemented: add support for married filing jointly.
 		return nil, fmt.Errorf("married filing jointly not yet supported")
@@ -44,79 +212,26 @@ func EstimateTaxes(ctx context.Context, d *db.DB, incomeCents int, state string,
 		taxableIncome = 0
 	}
 	// Compute federal tax.
-	var federalTax int
-	rows, err := d.QueryContext(ctx, `
-        SELECT bracket_low, bracket_high, rate_bps
-        FROM tax_tables_federal WHERE year = $1
-        ORDER BY bracket_low ASC
-    `, year)
+	federal, err := t.federalBrackets(ctx, year)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	remaining := taxableIncome
-	for rows.Next() {
-		var low, high, rateBps int
-		if err := rows.Scan(&low, &high, &rateBps); err != nil {
-			return nil, err
-		}
-		if remaining <= 0 {
-			break
-		}
-		// Determine portion of income in this bracket.
-		upperBound := high
-		if high == 0 { // zero or null high implies no upper bound (top bracket)
-			upperBound = taxableIncome
-		}
-		// Determine taxable amount in this bracket.
-		segment := min(remaining, upperBound-low)
-		federalTax += segment * rateBps / 10000 // rate_bps is basis points
-		remaining -= segment
-	}
+	federalTax := applyBrackets(federal, taxableIncome)
 	// Compute state tax. If state is unknown, assume zero.
 	var stateTax int
 	if state != "" {
-		rows, err := d.QueryContext(ctx, `
-            SELECT bracket_low, bracket_high, rate_bps
-            FROM tax_tables_state WHERE year = $1 AND state = $2
-            ORDER BY bracket_low ASC
-        `, year, state)
+		brackets, err := t.stateBrackets(ctx, year, state)
 		if err != nil {
 			return nil, err
 		}
-		defer rows.Close()
-		remaining = taxableIncome
-		for rows.Next() {
-			var low, high, rateBps int
-			if err := rows.Scan(&low, &high, &rateBps); err != nil {
-				return nil, err
-			}
-			if remaining <= 0 {
-				break
-			}
-			upperBound := high
-			if high == 0 {
-				upperBound = taxableIncome
-			}
-			segment := min(remaining, upperBound-low)
-			stateTax += segment * rateBps / 10000
-			remaining -= segment
-		}
+		stateTax = applyBrackets(brackets, taxableIncome)
 	}
 	// Estimate FICA (Social Security + Medicare) at 7.65% for simplicity.
 	ficaTax := incomeCents * 765 / 10000
 	// Determine number of paychecks in the term.
-	var checks int
-	switch payFreq {
-	case "weekly":
-		checks = termWeeks
-	case "biweekly":
-		checks = termWeeks / 2
-	case "monthly":
-		// Approximate 4 weeks per month. Multiply by termWeeks/4.
-		checks = termWeeks / 4
-	default:
-		checks = termWeeks / 2
+	checks, err := payFreq.Paychecks(termWeeks)
+	if err != nil {
+		return nil, err
 	}
 	totalTax := federalTax + stateTax + ficaTax
 	netAnnual := incomeCents - totalTax
@@ -126,6 +241,7 @@ func EstimateTaxes(ctx context.Context, d *db.DB, incomeCents int, state string,
 		perPay = netAnnual / checks
 	}
 	result := &TaxResult{
+		GrossCents:          incomeCents,
 		FederalCents:        federalTax,
 		StateCents:          stateTax,
 		FicaCents:           ficaTax,
@@ -135,6 +251,37 @@ func EstimateTaxes(ctx context.Context, d *db.DB, incomeCents int, state string,
 	return result, nil
 }
 
+// TermWeeksFromStart returns the number of whole weeks between start and the
+// end of the given tax year. It is used to derive a pay term from a
+// profile's start date; the result is zero or negative when start falls
+// after the year ends.
+func TermWeeksFromStart(start time.Time, year int) int {
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, start.Location())
+	return int(end.Sub(start).Hours() / (24 * 7))
+}
+
+// applyBrackets computes progressive tax on taxableIncome. Brackets must be
+// sorted by low bound ascending.
+func applyBrackets(brackets []bracket, taxableIncome int) int {
+	var tax int
+	remaining := taxableIncome
+	for _, b := range brackets {
+		if remaining <= 0 {
+			break
+		}
+		// Determine portion of income in this bracket.
+		upperBound := b.high
+		if b.high == 0 { // zero or null high implies no upper bound (top bracket)
+			upperBound = taxableIncome
+		}
+		// Determine taxable amount in this bracket.
+		segment := min(remaining, upperBound-b.low)
+		tax += segment * b.rateBps / 10000 // rate_bps is basis points
+		remaining -= segment
+	}
+	return tax
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
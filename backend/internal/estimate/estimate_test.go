@@ -0,0 +1,136 @@
+package estimate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+// taxTableHandler answers the tax table queries with a two-bracket federal
+// table, a $14,600 standard deduction and a flat 5% rate for every state.
+func taxTableHandler(q dbtest.Query) dbtest.Result {
+	cols := []string{"bracket_low", "bracket_high", "rate_bps"}
+	switch {
+	case strings.Contains(q.SQL, "std_deduction_single"):
+		return dbtest.Rows([]string{"std_deduction_single"}, []any{1460000})
+	case strings.Contains(q.SQL, "FROM tax_tables_federal"):
+		return dbtest.Rows(cols, []any{0, 1160000, 1000}, []any{1160000, 0, 1200})
+	case strings.Contains(q.SQL, "FROM tax_tables_state"):
+		return dbtest.Rows(cols, []any{0, 0, 500})
+	}
+	return dbtest.Result{}
+}
+
+func TestEstimateTaxes(t *testing.T) {
+	d, _ := dbtest.Open(t, taxTableHandler)
+	res, err := EstimateTaxes(context.Background(), d, 5000000, "IN", "single", 2024, PayBiweekly, 52)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Taxable income is $35,400: $1,160 at 10% plus $23,800 at 12%.
+	want := TaxResult{
+		GrossCents:          5000000,
+		FederalCents:        401600,
+		StateCents:          177000,
+		FicaCents:           382500,
+		TermNetCents:        4038900,
+		PerPaycheckNetCents: 4038900 / 26,
+	}
+	if *res != want {
+		t.Errorf("EstimateTaxes = %+v, want %+v", *res, want)
+	}
+}
+
+func TestCompareStatesLoadsTablesOnce(t *testing.T) {
+	states := []string{"CA", "TX", "NY", "WA", "IN", "IL", "MA", "CO"}
+	ctx := context.Background()
+
+	d, rec := dbtest.Open(t, taxTableHandler)
+	results, err := CompareStates(ctx, d, 5000000, states, "single", 2024, PayBiweekly, 52)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(states) {
+		t.Fatalf("got %d results, want %d", len(results), len(states))
+	}
+	// One standard deduction and one federal query, then one per state.
+	if got, want := len(rec.Queries()), 2+len(states); got != want {
+		t.Errorf("CompareStates ran %d queries, want %d", got, want)
+	}
+
+	single, singleRec := dbtest.Open(t, taxTableHandler)
+	for i, state := range states {
+		res, err := EstimateTaxes(ctx, single, 5000000, state, "single", 2024, PayBiweekly, 52)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[i].State != state || results[i].TaxResult != *res {
+			t.Errorf("result %d = %+v, want %s %+v", i, results[i], state, *res)
+		}
+	}
+	if len(rec.Queries()) >= len(singleRec.Queries()) {
+		t.Errorf("CompareStates ran %d queries, no fewer than %d separate estimates", len(rec.Queries()), len(singleRec.Queries()))
+	}
+}
+
+func TestCompareStatesFlagsMissingTables(t *testing.T) {
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if strings.Contains(q.SQL, "FROM tax_tables_state") && q.Args[1] == "OR" {
+			return dbtest.Result{Columns: []string{"bracket_low", "bracket_high", "rate_bps"}}
+		}
+		return taxTableHandler(q)
+	})
+	ctx := context.Background()
+
+	results, err := CompareStates(ctx, d, 5000000, []string{"IN", "OR", "TX"}, "single", 2024, PayBiweekly, 52)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Unsupported || !results[1].Unsupported || results[2].Unsupported {
+		t.Fatalf("results = %+v, want only OR unsupported", results)
+	}
+	if results[1].TermNetCents != 0 || results[2].TermNetCents == 0 {
+		t.Errorf("results = %+v, want no estimate for OR only", results)
+	}
+
+	if _, err := EstimateTaxes(ctx, d, 5000000, "OR", "single", 2024, PayBiweekly, 52); !errors.Is(err, ErrNoStateTable) {
+		t.Errorf("EstimateTaxes for OR: err = %v, want ErrNoStateTable", err)
+	}
+}
+
+func TestTaxYear(t *testing.T) {
+	// The fake table resolves the fallback the way the query does.
+	seeded := []int{2023, 2024}
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		want := q.Args[0].(int)
+		var found any
+		for _, y := range seeded {
+			if y <= want {
+				found = y
+			}
+		}
+		if found == nil && len(seeded) > 0 {
+			found = seeded[0]
+		}
+		return dbtest.Rows([]string{"year"}, []any{found})
+	})
+	ctx := context.Background()
+
+	for year, want := range map[int]int{2024: 2024, 2023: 2023, 2026: 2024, 2020: 2023} {
+		got, err := TaxYear(ctx, d, year)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("TaxYear(%d) = %d, want %d", year, got, want)
+		}
+	}
+
+	seeded = nil
+	if _, err := TaxYear(ctx, d, 2024); !errors.Is(err, ErrNoTaxTables) {
+		t.Errorf("empty tables: err = %v, want ErrNoTaxTables", err)
+	}
+}
@@ -0,0 +1,68 @@
+package estimate
+
+import "fmt"
+
+// Overtime defaults follow the FLSA rule: time and a half past 40 hours a
+// week.
+const (
+	DefaultOvertimeAfterHours = 40
+	DefaultOvertimeMultiplier = 1.5
+)
+
+// HourlyPay describes hourly wages. Hours past OvertimeAfterHours in a
+// week are paid at RateCents * OvertimeMultiplier. A nil OvertimeAfterHours
+// uses the default threshold, while zero pays every hour as overtime. A
+// zero OvertimeMultiplier uses the default.
+type HourlyPay struct {
+	RateCents          int
+	HoursPerWeek       float64
+	OvertimeAfterHours *float64
+	OvertimeMultiplier float64
+}
+
+// AnnualGrossCents returns a full year (52 weeks) of gross pay, including
+// overtime.
+func (h HourlyPay) AnnualGrossCents() (int, error) {
+	return h.GrossCents(52)
+}
+
+// GrossCents returns gross pay over the given number of weeks, including
+// overtime. It rejects non-positive rates and weeks, hours outside
+// (0, 168], overtime thresholds outside [0, 168] and multipliers below 1.
+func (h HourlyPay) GrossCents(weeks int) (int, error) {
+	regular, overtime, err := h.split()
+	if err != nil {
+		return 0, err
+	}
+	if weeks <= 0 {
+		return 0, fmt.Errorf("weeks must be positive")
+	}
+	multiplier := h.OvertimeMultiplier
+	if multiplier == 0 {
+		multiplier = DefaultOvertimeMultiplier
+	}
+	weekly := float64(h.RateCents) * (regular + overtime*multiplier)
+	return int(weekly*float64(weeks) + 0.5), nil
+}
+
+// split validates h and divides a week's hours into regular and overtime.
+func (h HourlyPay) split() (regular, overtime float64, err error) {
+	after := float64(DefaultOvertimeAfterHours)
+	if h.OvertimeAfterHours != nil {
+		after = *h.OvertimeAfterHours
+	}
+	switch {
+	case h.RateCents <= 0:
+		return 0, 0, fmt.Errorf("hourly rate must be positive")
+	case h.HoursPerWeek <= 0 || h.HoursPerWeek > 168:
+		return 0, 0, fmt.Errorf("hours per week must be between 0 and 168")
+	case after < 0 || after > 168:
+		return 0, 0, fmt.Errorf("overtime threshold must be between 0 and 168 hours")
+	case h.OvertimeMultiplier != 0 && h.OvertimeMultiplier < 1:
+		return 0, 0, fmt.Errorf("overtime multiplier must be at least 1")
+	}
+	if h.HoursPerWeek > after {
+		return after, h.HoursPerWeek - after, nil
+	}
+	return h.HoursPerWeek, 0, nil
+}
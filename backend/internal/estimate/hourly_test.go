@@ -0,0 +1,48 @@
+package estimate
+
+import "testing"
+
+func TestHourlyPayAnnualGross(t *testing.T) {
+	zero, ten := 0.0, 10.0
+	tests := []struct {
+		name    string
+		pay     HourlyPay
+		want    int
+		wantErr bool
+	}{
+		// $20/h for 40 hours is $800 a week, $41,600 a year.
+		{"straight time", HourlyPay{RateCents: 2000, HoursPerWeek: 40}, 4160000, false},
+		// 5 hours past 40 at 1.5x add $150 a week.
+		{"default overtime", HourlyPay{RateCents: 2000, HoursPerWeek: 45}, 4940000, false},
+		{"double time", HourlyPay{RateCents: 2000, HoursPerWeek: 45, OvertimeMultiplier: 2}, 5200000, false},
+		{"custom threshold", HourlyPay{RateCents: 2000, HoursPerWeek: 20, OvertimeAfterHours: &ten}, 2600000, false},
+		{"overtime from the first hour", HourlyPay{RateCents: 2000, HoursPerWeek: 10, OvertimeAfterHours: &zero}, 1560000, false},
+		{"no rate", HourlyPay{HoursPerWeek: 40}, 0, true},
+		{"too many hours", HourlyPay{RateCents: 2000, HoursPerWeek: 169}, 0, true},
+		{"multiplier below 1", HourlyPay{RateCents: 2000, HoursPerWeek: 45, OvertimeMultiplier: 0.5}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.pay.AnnualGrossCents()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: annual gross = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHourlyPayGrossCentsOverTerm(t *testing.T) {
+	pay := HourlyPay{RateCents: 2000, HoursPerWeek: 45}
+	got, err := pay.GrossCents(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 95000 * 12; got != want {
+		t.Errorf("12-week gross = %d, want %d", got, want)
+	}
+	if _, err := pay.GrossCents(0); err == nil {
+		t.Error("zero weeks accepted")
+	}
+}
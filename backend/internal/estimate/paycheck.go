@@ -0,0 +1,130 @@
+package estimate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/store"
+)
+
+// Paycheck is a projection of one upcoming paycheck. Hours are totals for
+// the pay period. PayDate is set when the profile has a start date, taking
+// the first check to arrive one pay period after it.
+type Paycheck struct {
+	PayFreq       PayFreq    `json:"payFreq"`
+	PayDate       *time.Time `json:"payDate,omitempty"`
+	HoursPerWeek  float64    `json:"hoursPerWeek"`
+	RegularHours  float64    `json:"regularHours"`
+	OvertimeHours float64    `json:"overtimeHours"`
+	WageCents     int        `json:"wageCents"`
+	StipendCents  int        `json:"stipendCents"`
+	GrossCents    int        `json:"grossCents"`
+	FederalCents  int        `json:"federalCents"`
+	StateCents    int        `json:"stateCents"`
+	FicaCents     int        `json:"ficaCents"`
+	NetCents      int        `json:"netCents"`
+}
+
+// periodsPerYear is how many paychecks a pay frequency gives in a year.
+var periodsPerYear = map[PayFreq]int{
+	PayWeekly:   52,
+	PayBiweekly: 26,
+	PayMonthly:  12,
+}
+
+// ProjectNextPaycheck projects the profile's next paycheck. hoursWorked is
+// hours worked in each week of the pay period, with hours past 40 a week
+// paid at time and a half; nil uses the profile's hours per week, while
+// zero means no hourly wages this period. The profile's stipend, paid
+// every period, is added to any hourly wages.
+//
+// Withholding is the period's share of the annual tax: the check's gross
+// is annualized at the profile's pay frequency (default biweekly), taxed
+// with EstimateTaxes for the profile's state (single filer, the TaxYear
+// for the current year) and divided back over the year's paychecks.
+func ProjectNextPaycheck(ctx context.Context, d *db.DB, p *store.Profile, hoursWorked *float64) (*Paycheck, error) {
+	if p == nil {
+		return nil, ErrNoProfileIncome
+	}
+	if hoursWorked != nil && *hoursWorked < 0 {
+		return nil, errors.New("hours worked must not be negative")
+	}
+	payFreq := DefaultPayFreq
+	if p.PayFreq != "" {
+		var err error
+		if payFreq, err = ParsePayFreq(p.PayFreq); err != nil {
+			return nil, err
+		}
+	}
+	periods := periodsPerYear[payFreq]
+	check := &Paycheck{PayFreq: payFreq}
+
+	var hours float64
+	if hoursWorked != nil {
+		hours = *hoursWorked
+	} else if p.HoursPerWeek != nil {
+		hours = float64(*p.HoursPerWeek)
+	}
+	if p.HourlyCents != nil && hours > 0 {
+		pay := HourlyPay{RateCents: *p.HourlyCents, HoursPerWeek: hours}
+		annual, err := pay.AnnualGrossCents()
+		if err != nil {
+			return nil, err
+		}
+		regular, overtime, _ := pay.split()
+		weeks := 52 / float64(periods)
+		check.HoursPerWeek = hours
+		check.RegularHours = regular * weeks
+		check.OvertimeHours = overtime * weeks
+		check.WageCents = (annual + periods/2) / periods
+	}
+	if p.StipendCents != nil && *p.StipendCents > 0 {
+		check.StipendCents = *p.StipendCents
+	}
+	check.GrossCents = check.WageCents + check.StipendCents
+	if check.GrossCents == 0 {
+		return nil, ErrNoProfileIncome
+	}
+
+	year, err := TaxYear(ctx, d, time.Now().Year())
+	if err != nil {
+		return nil, err
+	}
+	res, err := EstimateTaxes(ctx, d, check.GrossCents*periods, p.State, "single", year, payFreq, 52)
+	if err != nil {
+		return nil, err
+	}
+	check.FederalCents = res.FederalCents / periods
+	check.StateCents = res.StateCents / periods
+	check.FicaCents = res.FicaCents / periods
+	check.NetCents = check.GrossCents - check.FederalCents - check.StateCents - check.FicaCents
+
+	if p.StartDate != nil {
+		payDate := nextPayDate(*p.StartDate, payFreq, time.Now())
+		check.PayDate = &payDate
+	}
+	return check, nil
+}
+
+// nextPayDate returns the first pay date on or after today's date, with
+// pay dates falling every pay period starting one period after start.
+func nextPayDate(start time.Time, payFreq PayFreq, now time.Time) time.Time {
+	y, m, d := now.In(start.Location()).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, start.Location())
+	for k := 1; ; k++ {
+		var t time.Time
+		switch payFreq {
+		case PayWeekly:
+			t = start.AddDate(0, 0, 7*k)
+		case PayMonthly:
+			t = start.AddDate(0, k, 0)
+		default:
+			t = start.AddDate(0, 0, 14*k)
+		}
+		if !t.Before(today) {
+			return t
+		}
+	}
+}
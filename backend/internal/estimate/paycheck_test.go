@@ -0,0 +1,51 @@
+package estimate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dayboard/backend/internal/db/dbtest"
+	"dayboard/backend/internal/store"
+)
+
+func TestProjectNextPaycheckHours(t *testing.T) {
+	// Only 2024 is seeded, so every table lookup should be for 2024.
+	var years []any
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if strings.Contains(q.SQL, "MAX(year)") {
+			return dbtest.Rows([]string{"year"}, []any{2024})
+		}
+		years = append(years, q.Args[0])
+		return taxTableHandler(q)
+	})
+	hourly, hoursPerWeek, stipend := 2000, 20, 50000
+	p := &store.Profile{State: "IN", PayFreq: "biweekly", HourlyCents: &hourly, HoursPerWeek: &hoursPerWeek, StipendCents: &stipend}
+	ctx := context.Background()
+
+	zero, thirty := 0.0, 30.0
+	tests := []struct {
+		name      string
+		hours     *float64
+		wantHours float64
+		wantGross int
+	}{
+		{"profile hours", nil, 20, 80000 + stipend},
+		{"no hours worked", &zero, 0, stipend},
+		{"given hours", &thirty, 30, 120000 + stipend},
+	}
+	for _, tt := range tests {
+		check, err := ProjectNextPaycheck(ctx, d, p, tt.hours)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if check.HoursPerWeek != tt.wantHours || check.GrossCents != tt.wantGross {
+			t.Errorf("%s: %v hours a week, gross %d; want %v, %d", tt.name, check.HoursPerWeek, check.GrossCents, tt.wantHours, tt.wantGross)
+		}
+	}
+	for _, y := range years {
+		if y != 2024 {
+			t.Errorf("tax tables queried for %v, want the seeded 2024", y)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package estimate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PayFreq is how often a paycheck arrives. Use ParsePayFreq to build one
+// from user input so spelling variants normalize to the same value.
+type PayFreq string
+
+const (
+	PayWeekly   PayFreq = "weekly"
+	PayBiweekly PayFreq = "biweekly"
+	PayMonthly  PayFreq = "monthly"
+)
+
+// DefaultPayFreq applies when neither the request nor the profile gives a
+// pay frequency.
+const DefaultPayFreq = PayBiweekly
+
+// payFreqAliases maps normalized spellings (lowercase, no spaces, hyphens
+// or underscores) to their canonical PayFreq.
+var payFreqAliases = map[string]PayFreq{
+	"weekly":        PayWeekly,
+	"biweekly":      PayBiweekly,
+	"fortnightly":   PayBiweekly,
+	"everytwoweeks": PayBiweekly,
+	"monthly":       PayMonthly,
+}
+
+// ParsePayFreq normalizes s ("Bi-Weekly", "bi_weekly" and "biweekly" are
+// all PayBiweekly) and rejects anything it doesn't recognize.
+func ParsePayFreq(s string) (PayFreq, error) {
+	key := strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(s)))
+	if f, ok := payFreqAliases[key]; ok {
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported pay frequency: %q (use weekly, biweekly or monthly)", s)
+}
+
+// Paychecks returns how many whole paychecks arrive over termWeeks.
+func (f PayFreq) Paychecks(termWeeks int) (int, error) {
+	periods, err := f.periods(termWeeks)
+	return int(periods), err
+}
+
+// periods returns how many pay periods span weeks, counting a month as
+// 52/12 weeks so that a year is exactly periodsPerYear[f] periods. Both
+// Paychecks and StipendGrossCents use it, so they agree on what a month
+// is.
+func (f PayFreq) periods(weeks int) (float64, error) {
+	switch f {
+	case PayWeekly:
+		return float64(weeks), nil
+	case PayBiweekly:
+		return float64(weeks) / 2, nil
+	case PayMonthly:
+		return float64(weeks) * 12 / 52, nil
+	}
+	return 0, fmt.Errorf("unsupported pay frequency: %q", string(f))
+}
@@ -0,0 +1,67 @@
+package estimate
+
+import "testing"
+
+func TestParsePayFreq(t *testing.T) {
+	accepted := map[string]PayFreq{
+		"weekly":          PayWeekly,
+		" Weekly ":        PayWeekly,
+		"biweekly":        PayBiweekly,
+		"Biweekly":        PayBiweekly,
+		"bi-weekly":       PayBiweekly,
+		"BI_WEEKLY":       PayBiweekly,
+		"every two weeks": PayBiweekly,
+		"fortnightly":     PayBiweekly,
+		"Monthly":         PayMonthly,
+	}
+	for in, want := range accepted {
+		got, err := ParsePayFreq(in)
+		if err != nil {
+			t.Errorf("ParsePayFreq(%q): %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParsePayFreq(%q) = %s, want %s", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "daily", "semi-monthly", "bi weekly please", "42"} {
+		if got, err := ParsePayFreq(in); err == nil {
+			t.Errorf("ParsePayFreq(%q) = %s, want an error", in, got)
+		}
+	}
+}
+
+func TestPaychecksRejectsUnknownFrequency(t *testing.T) {
+	if _, err := PayFreq("daily").Paychecks(12); err == nil {
+		t.Error("expected an error for an unparsed pay frequency")
+	}
+}
+
+func TestPaychecksAgreeWithStipendPeriods(t *testing.T) {
+	for _, f := range []PayFreq{PayWeekly, PayBiweekly, PayMonthly} {
+		// A year holds periodsPerYear paychecks and that many stipends.
+		checks, err := f.Paychecks(52)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checks != periodsPerYear[f] {
+			t.Errorf("%s: %d paychecks in 52 weeks, want %d", f, checks, periodsPerYear[f])
+		}
+		gross, err := StipendGrossCents(100000, f, 52)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gross != checks*100000 {
+			t.Errorf("%s: a year of stipends = %d, want %d checks of 100000", f, gross, checks)
+		}
+	}
+
+	// 13 weeks is a quarter: three monthly checks and three stipends.
+	if checks, _ := PayMonthly.Paychecks(13); checks != 3 {
+		t.Errorf("13 weeks = %d monthly paychecks, want 3", checks)
+	}
+	if gross, _ := StipendGrossCents(100000, PayMonthly, 13); gross != 300000 {
+		t.Errorf("13 weeks of a monthly stipend = %d, want 300000", gross)
+	}
+}
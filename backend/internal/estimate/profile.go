@@ -0,0 +1,99 @@
+package estimate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/store"
+)
+
+// ErrNoProfileIncome is returned when a profile doesn't say how much the
+// user earns.
+var ErrNoProfileIncome = errors.New("profile has neither an hourly rate and hours per week nor a stipend")
+
+// ProfileGrossCents returns what the profile's user grosses over weeks of
+// work. Hourly wages get the default overtime rule for hours past 40 a
+// week. Profiles without an hourly rate fall back to the stipend, which is
+// paid every pay period (PayFreq, default biweekly).
+func ProfileGrossCents(p *store.Profile, weeks int) (int, error) {
+	if p == nil {
+		return 0, ErrNoProfileIncome
+	}
+	if p.HourlyCents == nil && p.StipendCents != nil {
+		payFreq := DefaultPayFreq
+		if p.PayFreq != "" {
+			var err error
+			if payFreq, err = ParsePayFreq(p.PayFreq); err != nil {
+				return 0, err
+			}
+		}
+		return StipendGrossCents(*p.StipendCents, payFreq, weeks)
+	}
+	if p.HourlyCents == nil || p.HoursPerWeek == nil {
+		return 0, ErrNoProfileIncome
+	}
+	pay := HourlyPay{RateCents: *p.HourlyCents, HoursPerWeek: float64(*p.HoursPerWeek)}
+	return pay.GrossCents(weeks)
+}
+
+// StipendGrossCents returns a fixed stipend of stipendCents per pay period
+// summed over weeks. Partial periods are prorated, with a month of 52/12
+// weeks as in PayFreq.Paychecks.
+func StipendGrossCents(stipendCents int, payFreq PayFreq, weeks int) (int, error) {
+	if stipendCents <= 0 {
+		return 0, errors.New("stipend must be positive")
+	}
+	if weeks <= 0 {
+		return 0, errors.New("weeks must be positive")
+	}
+	periods, err := payFreq.periods(weeks)
+	if err != nil {
+		return 0, err
+	}
+	return int(float64(stipendCents)*periods + 0.5), nil
+}
+
+// workingDaysPerYear assumes a five-day week.
+const workingDaysPerYear = 52 * 5
+
+// DailyIncome is what a profile's user earns per working day.
+type DailyIncome struct {
+	AnnualGrossCents int `json:"annualGrossCents"`
+	AnnualNetCents   int `json:"annualNetCents"`
+	DailyGrossCents  int `json:"dailyGrossCents"`
+	DailyNetCents    int `json:"dailyNetCents"`
+	WorkingDays      int `json:"workingDays"`
+}
+
+// DailyTakeHome annualizes the profile's wages, taxes them for the
+// profile's state with EstimateTaxes (single filer, the TaxYear for the
+// current year) and spreads the result over workingDaysPerYear.
+func DailyTakeHome(ctx context.Context, d *db.DB, p *store.Profile) (*DailyIncome, error) {
+	gross, err := ProfileGrossCents(p, 52)
+	if err != nil {
+		return nil, err
+	}
+	payFreq := DefaultPayFreq
+	if p.PayFreq != "" {
+		if payFreq, err = ParsePayFreq(p.PayFreq); err != nil {
+			return nil, err
+		}
+	}
+	year, err := TaxYear(ctx, d, time.Now().Year())
+	if err != nil {
+		return nil, err
+	}
+	res, err := EstimateTaxes(ctx, d, gross, p.State, "single", year, payFreq, 52)
+	if err != nil {
+		return nil, err
+	}
+	return &DailyIncome{
+		AnnualGrossCents: gross,
+		AnnualNetCents:   res.TermNetCents,
+		DailyGrossCents:  gross / workingDaysPerYear,
+		DailyNetCents:    res.TermNetCents / workingDaysPerYear,
+		WorkingDays:      workingDaysPerYear,
+	}, nil
+}
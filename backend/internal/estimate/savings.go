@@ -0,0 +1,64 @@
+package estimate
+
+import (
+	"time"
+
+	"dayboard/backend/internal/store"
+)
+
+// Savings progress statuses.
+const (
+	SavingsOnTrack = "on_track"
+	SavingsBehind  = "behind"
+)
+
+// SavingsProgress projects whether a savings goal will be met at the
+// current rate of earning and spending. GapCents is how far the projection
+// falls short of the target; it is zero or negative when on track.
+type SavingsProgress struct {
+	TargetCents          int    `json:"targetCents"`
+	Deadline             string `json:"deadline"`
+	DaysRemaining        int    `json:"daysRemaining"`
+	WorkingDaysRemaining int    `json:"workingDaysRemaining"`
+	DailyIncomeCents     int    `json:"dailyIncomeCents"`
+	DailyBurnCents       int    `json:"dailyBurnCents"`
+	ProjectedCents       int    `json:"projectedCents"`
+	GapCents             int    `json:"gapCents"`
+	Status               string `json:"status"`
+}
+
+// ProjectSavings projects savings from today through the goal's deadline
+// (both inclusive): dailyIncomeCents is earned on each remaining weekday,
+// since it is a per-working-day figure, and dailyBurnCents is spent on
+// every remaining day.
+func ProjectSavings(goal store.SavingsGoal, dailyIncomeCents, dailyBurnCents int, today time.Time) SavingsProgress {
+	y, m, d := today.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	gy, gm, gd := goal.Deadline.Date()
+	deadline := time.Date(gy, gm, gd, 0, 0, 0, 0, time.UTC)
+
+	var days, workingDays int
+	for ; !day.After(deadline); day = day.AddDate(0, 0, 1) {
+		days++
+		if wd := day.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			workingDays++
+		}
+	}
+
+	projected := dailyIncomeCents*workingDays - dailyBurnCents*days
+	progress := SavingsProgress{
+		TargetCents:          goal.TargetCents,
+		Deadline:             deadline.Format("2006-01-02"),
+		DaysRemaining:        days,
+		WorkingDaysRemaining: workingDays,
+		DailyIncomeCents:     dailyIncomeCents,
+		DailyBurnCents:       dailyBurnCents,
+		ProjectedCents:       projected,
+		GapCents:             goal.TargetCents - projected,
+		Status:               SavingsOnTrack,
+	}
+	if progress.GapCents > 0 {
+		progress.Status = SavingsBehind
+	}
+	return progress
+}
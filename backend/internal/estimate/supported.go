@@ -0,0 +1,97 @@
+package estimate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"dayboard/backend/internal/db"
+)
+
+// Supported lists the inputs EstimateTaxes can handle with the tax tables
+// currently loaded.
+type Supported struct {
+	States         []string `json:"states"`
+	Years          []int    `json:"years"`
+	FilingStatuses []string `json:"filingStatuses"`
+}
+
+// filingStatuses are the statuses EstimateTaxes accepts.
+var filingStatuses = []string{"single"}
+
+// supportedTTL bounds how stale the cached Supported value may be. Tax
+// tables change rarely (a yearly load), so a short cache is plenty.
+const supportedTTL = 5 * time.Minute
+
+var supportedCache struct {
+	sync.Mutex
+	value   *Supported
+	expires time.Time
+}
+
+// SupportedInputs returns the distinct states and years present in the tax
+// tables, plus the supported filing statuses. Results are cached for a few
+// minutes.
+func SupportedInputs(ctx context.Context, d *db.DB) (*Supported, error) {
+	supportedCache.Lock()
+	defer supportedCache.Unlock()
+	if supportedCache.value != nil && time.Now().Before(supportedCache.expires) {
+		return supportedCache.value, nil
+	}
+
+	states, err := queryStrings(ctx, d, `SELECT DISTINCT state FROM tax_tables_state ORDER BY state`)
+	if err != nil {
+		return nil, err
+	}
+	years, err := queryInts(ctx, d, `
+        SELECT year FROM tax_tables_federal
+        UNION
+        SELECT year FROM tax_tables_state
+        ORDER BY year DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+
+	supportedCache.value = &Supported{
+		States:         states,
+		Years:          years,
+		FilingStatuses: filingStatuses,
+	}
+	supportedCache.expires = time.Now().Add(supportedTTL)
+	return supportedCache.value, nil
+}
+
+func queryStrings(ctx context.Context, d *db.DB, query string) ([]string, error) {
+	rows, err := d.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := []string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func queryInts(ctx context.Context, d *db.DB, query string) ([]int, error) {
+	rows, err := d.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := []int{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
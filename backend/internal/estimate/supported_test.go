@@ -0,0 +1,59 @@
+package estimate
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func TestSupportedInputs(t *testing.T) {
+	supportedCache.Lock()
+	supportedCache.value = nil
+	supportedCache.Unlock()
+
+	d, rec := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "DISTINCT state"):
+			return dbtest.Rows([]string{"state"}, []any{"CA"}, []any{"NY"}, []any{"TX"})
+		case strings.Contains(q.SQL, "SELECT year"):
+			return dbtest.Rows([]string{"year"}, []any{2024}, []any{2023})
+		}
+		return dbtest.Result{}
+	})
+	ctx := context.Background()
+
+	got, err := SupportedInputs(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Supported{
+		States:         []string{"CA", "NY", "TX"},
+		Years:          []int{2024, 2023},
+		FilingStatuses: []string{"single"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SupportedInputs = %+v, want %+v", got, want)
+	}
+
+	if _, err := SupportedInputs(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Queries()); n != 2 {
+		t.Errorf("ran %d queries for two calls, want 2 (second call cached)", n)
+	}
+
+	// Once the cache expires the tables are read again.
+	supportedCache.Lock()
+	supportedCache.expires = time.Now().Add(-time.Second)
+	supportedCache.Unlock()
+	if _, err := SupportedInputs(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Queries()); n != 4 {
+		t.Errorf("ran %d queries after expiry, want 4", n)
+	}
+}
@@ -3,20 +3,46 @@ package google
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/metrics"
 )
 
+// ErrRefreshTokenRevoked is returned when Google rejects a refresh token
+// (revoked by the user or expired). The user must reconnect their account.
+var ErrRefreshTokenRevoked = errors.New("google refresh token revoked")
+
+// oauthScopes are requested when connecting Google: read-only Calendar
+// for sync and read-only Gmail for the email summary.
+var oauthScopes = []string{
+	"https://www.googleapis.com/auth/calendar.readonly",
+	"https://www.googleapis.com/auth/gmail.readonly",
+}
+
 // CalendarService handles Google Calendar API operations
 type CalendarService struct {
 	clientID     string
 	clientSecret string
 	redirectURI  string
+	syncDays     int
+	apiURL       string
 }
 
+// calendarAPIURL is the Google Calendar v3 API root.
+const calendarAPIURL = "https://www.googleapis.com/calendar/v3"
+
+// defaultSyncDays is how many days of events a sync pulls, starting today,
+// when GOOGLE_SYNC_DAYS is unset.
+const defaultSyncDays = 7
+
 // Event represents a Google Calendar event
 type CalendarEvent struct {
 	ID          string    `json:"id"`
@@ -27,6 +53,7 @@ type CalendarEvent struct {
 	Location    string    `json:"location"`
 	HangoutLink string    `json:"hangoutLink"`
 	HTMLLink    string    `json:"htmlLink"`
+	AllDay      bool      `json:"allDay"`
 }
 
 // TokenResponse represents the OAuth token response from Google
@@ -44,16 +71,36 @@ func NewCalendarService() *CalendarService {
 		clientID:     os.Getenv("GOOGLE_CLIENT_ID"),
 		clientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
 		redirectURI:  os.Getenv("GOOGLE_REDIRECT_URI"),
+		syncDays:     syncDays(),
+		apiURL:       calendarAPIURL,
 	}
 }
 
+// syncDays reads GOOGLE_SYNC_DAYS, the length of the rolling window of
+// events each sync stores.
+func syncDays() int {
+	if v := os.Getenv("GOOGLE_SYNC_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultSyncDays
+}
+
+// SyncWindow returns the range of events a sync pulls: from the start of
+// today through the configured number of days.
+func (s *CalendarService) SyncWindow(now time.Time) (time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, s.syncDays)
+}
+
 // GetAuthURL returns the OAuth authorization URL for Google Calendar
 func (s *CalendarService) GetAuthURL(state string) string {
 	params := url.Values{}
 	params.Set("client_id", s.clientID)
 	params.Set("redirect_uri", s.redirectURI)
 	params.Set("response_type", "code")
-	params.Set("scope", "https://www.googleapis.com/auth/calendar.readonly")
+	params.Set("scope", strings.Join(oauthScopes, " "))
 	params.Set("state", state)
 	params.Set("access_type", "offline")
 	params.Set("prompt", "consent")
@@ -77,7 +124,9 @@ func (s *CalendarService) ExchangeCodeForToken(ctx context.Context, code string)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := httpx.Client.Do(req)
+	metrics.ObserveExternal(metrics.ServiceGoogle, start, resp, err)
 	if err != nil {
 		return nil, err
 	}
@@ -91,20 +140,160 @@ func (s *CalendarService) ExchangeCodeForToken(ctx context.Context, code string)
 	return &tokenResp, nil
 }
 
-// GetTodaysEvents fetches today's events from Google Calendar
-func (s *CalendarService) GetTodaysEvents(ctx context.Context, accessToken string) ([]CalendarEvent, error) {
+// PrimaryCalendarID is the calendar synced for users who haven't selected
+// any calendars.
+const PrimaryCalendarID = "primary"
+
+// CalendarListEntry is a calendar on the user's Google calendar list
+type CalendarListEntry struct {
+	ID         string `json:"id"`
+	Summary    string `json:"summary"`
+	Primary    bool   `json:"primary"`
+	AccessRole string `json:"accessRole"`
+}
+
+// ListCalendars fetches the calendars on the user's calendar list
+func (s *CalendarService) ListCalendars(ctx context.Context, accessToken string) ([]CalendarListEntry, error) {
+	var calendars []CalendarListEntry
+	pageToken := ""
+	for {
+		params := url.Values{}
+		params.Set("minAccessRole", "reader")
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET",
+			s.apiURL+"/users/me/calendarList?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		start := time.Now()
+		resp, err := httpx.Do(httpx.Client, req, httpx.DefaultRetryPolicy())
+		metrics.ObserveExternal(metrics.ServiceGoogle, start, resp, err)
+		if err != nil {
+			return nil, err
+		}
+
+		var listResp struct {
+			Items         []CalendarListEntry `json:"items"`
+			NextPageToken string              `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("google calendar list error: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&listResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		calendars = append(calendars, listResp.Items...)
+		if listResp.NextPageToken == "" {
+			return calendars, nil
+		}
+		pageToken = listResp.NextPageToken
+	}
+}
+
+// GetTodaysEvents fetches today's events from the given Google calendar
+func (s *CalendarService) GetTodaysEvents(ctx context.Context, accessToken, calendarID string) ([]CalendarEvent, error) {
 	now := time.Now()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
+	return s.GetEventsInRange(ctx, accessToken, calendarID, startOfDay, startOfDay.AddDate(0, 0, 1))
+}
+
+// ErrSyncTokenExpired is returned by SyncEvents when Google no longer
+// accepts the sync token (410 Gone). The caller must do a full sync.
+var ErrSyncTokenExpired = errors.New("google calendar sync token expired")
+
+// EventChanges is the result of SyncEvents
+type EventChanges struct {
+	// Events are the new and changed events.
+	Events []CalendarEvent
+	// Cancelled are the IDs of events deleted or cancelled since the last
+	// sync. A full sync reports none.
+	Cancelled []string
+	// NextSyncToken is passed to the next SyncEvents call to fetch only
+	// what changed after this one.
+	NextSyncToken string
+}
+
+// eventsPage is one page of an events.list response
+type eventsPage struct {
+	events        []CalendarEvent
+	cancelled     []string
+	nextPageToken string
+	nextSyncToken string
+}
 
+// GetEventsInRange fetches the events on the given Google calendar that
+// overlap [start, end), following result pages until all are read
+func (s *CalendarService) GetEventsInRange(ctx context.Context, accessToken, calendarID string, start, end time.Time) ([]CalendarEvent, error) {
 	params := url.Values{}
-	params.Set("timeMin", startOfDay.Format(time.RFC3339))
-	params.Set("timeMax", endOfDay.Format(time.RFC3339))
-	params.Set("singleEvents", "true")
+	params.Set("timeMin", start.Format(time.RFC3339))
+	params.Set("timeMax", end.Format(time.RFC3339))
 	params.Set("orderBy", "startTime")
-	params.Set("maxResults", "20")
 
-	url := "https://www.googleapis.com/calendar/v3/calendars/primary/events?" + params.Encode()
+	var events []CalendarEvent
+	for {
+		page, err := s.getEventsPage(ctx, accessToken, calendarID, params)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page.events...)
+		if page.nextPageToken == "" {
+			return events, nil
+		}
+		params.Set("pageToken", page.nextPageToken)
+	}
+}
+
+// SyncEvents fetches what changed on the given calendar since syncToken
+// was issued. With an empty syncToken it does a full sync of the events
+// overlapping [start, end); start and end are ignored otherwise, since
+// Google doesn't allow a time range with a sync token. ErrSyncTokenExpired
+// means syncToken must be discarded and a full sync done instead.
+func (s *CalendarService) SyncEvents(ctx context.Context, accessToken, calendarID, syncToken string, start, end time.Time) (*EventChanges, error) {
+	params := url.Values{}
+	if syncToken != "" {
+		params.Set("syncToken", syncToken)
+	} else {
+		params.Set("timeMin", start.Format(time.RFC3339))
+		params.Set("timeMax", end.Format(time.RFC3339))
+	}
+
+	changes := &EventChanges{}
+	for {
+		page, err := s.getEventsPage(ctx, accessToken, calendarID, params)
+		if err != nil {
+			return nil, err
+		}
+		changes.Events = append(changes.Events, page.events...)
+		changes.Cancelled = append(changes.Cancelled, page.cancelled...)
+		if page.nextPageToken == "" {
+			// Google only sends the sync token on the last page.
+			changes.NextSyncToken = page.nextSyncToken
+			return changes, nil
+		}
+		params.Set("pageToken", page.nextPageToken)
+	}
+}
+
+// getEventsPage fetches one page of events.list with the given query on
+// top of the defaults every request uses.
+func (s *CalendarService) getEventsPage(ctx context.Context, accessToken, calendarID string, query url.Values) (*eventsPage, error) {
+	params := url.Values{}
+	params.Set("singleEvents", "true")
+	params.Set("maxResults", "250")
+	for k, v := range query {
+		params[k] = v
+	}
+
+	url := s.apiURL + "/calendars/" + url.PathEscape(calendarID) + "/events?" + params.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -112,15 +301,28 @@ func (s *CalendarService) GetTodaysEvents(ctx context.Context, accessToken strin
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := httpx.Do(httpx.Client, req, httpx.DefaultRetryPolicy())
+	metrics.ObserveExternal(metrics.ServiceGoogle, start, resp, err)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusGone {
+		return nil, ErrSyncTokenExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google calendar %s events error: %s", calendarID, resp.Status)
+	}
+
 	var calendarResp struct {
-		Items []struct {
+		TimeZone      string `json:"timeZone"`
+		NextPageToken string `json:"nextPageToken"`
+		NextSyncToken string `json:"nextSyncToken"`
+		Items         []struct {
 			ID      string `json:"id"`
+			Status  string `json:"status"`
 			Summary string `json:"summary"`
 			Start   struct {
 				DateTime string `json:"dateTime"`
@@ -141,8 +343,25 @@ func (s *CalendarService) GetTodaysEvents(ctx context.Context, accessToken strin
 		return nil, err
 	}
 
-	var events []CalendarEvent
+	loc := time.UTC
+	if calendarResp.TimeZone != "" {
+		if l, err := time.LoadLocation(calendarResp.TimeZone); err == nil {
+			loc = l
+		}
+	}
+
+	page := &eventsPage{
+		nextPageToken: calendarResp.NextPageToken,
+		nextSyncToken: calendarResp.NextSyncToken,
+	}
 	for _, item := range calendarResp.Items {
+		// Incremental syncs report deletions as cancelled stubs that carry
+		// little more than the ID.
+		if item.Status == "cancelled" {
+			page.cancelled = append(page.cancelled, item.ID)
+			continue
+		}
+
 		event := CalendarEvent{
 			ID:          item.ID,
 			Summary:     item.Summary,
@@ -150,26 +369,72 @@ func (s *CalendarService) GetTodaysEvents(ctx context.Context, accessToken strin
 			Location:    item.Location,
 			HangoutLink: item.HangoutLink,
 			HTMLLink:    item.HTMLLink,
+			// All-day events use the date form instead of dateTime.
+			AllDay: item.Start.DateTime == "" && item.Start.Date != "",
 		}
 
-		// Parse start time
-		if item.Start.DateTime != "" {
-			if startTime, err := time.Parse(time.RFC3339, item.Start.DateTime); err == nil {
-				event.StartTime = startTime
-			}
-		}
+		// Parse start and end. All-day events only carry a date, which is
+		// interpreted as midnight in the calendar's timezone.
+		event.StartTime = parseEventTime(item.Start.DateTime, item.Start.Date, loc)
+		event.EndTime = parseEventTime(item.End.DateTime, item.End.Date, loc)
+
+		page.events = append(page.events, event)
+	}
 
-		// Parse end time
-		if item.End.DateTime != "" {
-			if endTime, err := time.Parse(time.RFC3339, item.End.DateTime); err == nil {
-				event.EndTime = endTime
-			}
+	return page, nil
+}
+
+// parseEventTime parses a Google Calendar start/end. Timed events use
+// dateTime (RFC3339); all-day events use date (YYYY-MM-DD), which is
+// anchored at midnight in loc. Unparseable values yield the zero time.
+func parseEventTime(dateTime, date string, loc *time.Location) time.Time {
+	if dateTime != "" {
+		if t, err := time.Parse(time.RFC3339, dateTime); err == nil {
+			return t
+		}
+		return time.Time{}
+	}
+	if date != "" {
+		if t, err := time.ParseInLocation("2006-01-02", date, loc); err == nil {
+			return t
 		}
+	}
+	return time.Time{}
+}
+
+// RevokeToken revokes an access or refresh token; revoking a refresh
+// token ends the whole grant. A token Google already considers invalid
+// counts as revoked.
+func (s *CalendarService) RevokeToken(ctx context.Context, token string) error {
+	data := url.Values{}
+	data.Set("token", token)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://oauth2.googleapis.com/revoke",
+		strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-		events = append(events, event)
+	start := time.Now()
+	resp, err := httpx.Client.Do(req)
+	metrics.ObserveExternal(metrics.ServiceGoogle, start, resp, err)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	return events, nil
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.Error == "invalid_token" {
+			return nil
+		}
+		return fmt.Errorf("google token revoke error: %s", resp.Status)
+	}
+	return nil
 }
 
 // RefreshAccessToken uses a refresh token to get a new access token
@@ -187,12 +452,25 @@ func (s *CalendarService) RefreshAccessToken(ctx context.Context, refreshToken s
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := httpx.Client.Do(req)
+	metrics.ObserveExternal(metrics.ServiceGoogle, start, resp, err)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.Error == "invalid_grant" {
+			return nil, ErrRefreshTokenRevoked
+		}
+		return nil, fmt.Errorf("google token refresh error: %s", resp.Status)
+	}
+
 	var tokenResp TokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
 		return nil, err
@@ -0,0 +1,77 @@
+package google
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// calendarServer serves body for every events.list request and returns a
+// CalendarService pointed at it.
+func calendarServer(t *testing.T, handler http.HandlerFunc) *CalendarService {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &CalendarService{apiURL: srv.URL, syncDays: defaultSyncDays}
+}
+
+func TestGetEventsInRangeAllDay(t *testing.T) {
+	s := calendarServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/calendars/primary/events" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{
+			"timeZone": "America/New_York",
+			"items": [
+				{
+					"id": "holiday",
+					"status": "confirmed",
+					"summary": "Company Holiday",
+					"start": {"date": "2024-07-04"},
+					"end": {"date": "2024-07-05"}
+				},
+				{
+					"id": "standup",
+					"status": "confirmed",
+					"summary": "Standup",
+					"start": {"dateTime": "2024-07-05T09:00:00-04:00"},
+					"end": {"dateTime": "2024-07-05T09:15:00-04:00"}
+				}
+			]
+		}`))
+	})
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 7, 4, 0, 0, 0, 0, ny)
+	events, err := s.GetEventsInRange(context.Background(), "token", PrimaryCalendarID, start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+
+	holiday := events[0]
+	if !holiday.AllDay {
+		t.Error("Company Holiday should be all-day")
+	}
+	if !holiday.StartTime.Equal(start) {
+		t.Errorf("holiday start = %v, want midnight New York %v", holiday.StartTime, start)
+	}
+	if !holiday.EndTime.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("holiday end = %v, want the next midnight", holiday.EndTime)
+	}
+
+	standup := events[1]
+	if standup.AllDay {
+		t.Error("Standup should not be all-day")
+	}
+	if want := time.Date(2024, 7, 5, 13, 0, 0, 0, time.UTC); !standup.StartTime.Equal(want) {
+		t.Errorf("standup start = %v, want %v", standup.StartTime, want)
+	}
+}
@@ -0,0 +1,163 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"dayboard/backend/internal/auth"
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/metrics"
+)
+
+// ErrInsufficientScope is returned when the stored Google token wasn't
+// granted Gmail access, e.g. it was issued before the scope was added.
+var ErrInsufficientScope = errors.New("google token lacks gmail scope")
+
+// defaultSummarySubjects and maxSummarySubjects bound ?limit= on the email
+// summary.
+const (
+	defaultSummarySubjects = 3
+	maxSummarySubjects     = 10
+)
+
+const gmailBaseURL = "https://gmail.googleapis.com/gmail/v1/users/me"
+
+// EmailSummary is the user's unread inbox count and the subjects of the
+// newest unread messages.
+type EmailSummary struct {
+	UnreadCount int      `json:"unreadCount"`
+	TopSubjects []string `json:"topSubjects"`
+}
+
+// GetUnreadSummary counts the unread messages in the inbox and returns
+// the subjects of the newest n of them.
+func (s *CalendarService) GetUnreadSummary(ctx context.Context, accessToken string, n int) (*EmailSummary, error) {
+	// The INBOX label carries an exact unread count, which the message
+	// list's resultSizeEstimate doesn't.
+	var label struct {
+		MessagesUnread int `json:"messagesUnread"`
+	}
+	if err := gmailGet(ctx, accessToken, gmailBaseURL+"/labels/INBOX", &label); err != nil {
+		return nil, err
+	}
+
+	summary := &EmailSummary{UnreadCount: label.MessagesUnread, TopSubjects: []string{}}
+	if n <= 0 || label.MessagesUnread == 0 {
+		return summary, nil
+	}
+
+	params := url.Values{}
+	params.Add("labelIds", "INBOX")
+	params.Add("labelIds", "UNREAD")
+	params.Set("maxResults", strconv.Itoa(n))
+	var list struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := gmailGet(ctx, accessToken, gmailBaseURL+"/messages?"+params.Encode(), &list); err != nil {
+		return nil, err
+	}
+
+	for _, m := range list.Messages {
+		var msg struct {
+			Payload struct {
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+			} `json:"payload"`
+		}
+		msgURL := gmailBaseURL + "/messages/" + url.PathEscape(m.ID) + "?format=metadata&metadataHeaders=Subject"
+		if err := gmailGet(ctx, accessToken, msgURL, &msg); err != nil {
+			return nil, err
+		}
+		subject := "(no subject)"
+		for _, h := range msg.Payload.Headers {
+			if h.Name == "Subject" && h.Value != "" {
+				subject = h.Value
+				break
+			}
+		}
+		summary.TopSubjects = append(summary.TopSubjects, subject)
+	}
+
+	return summary, nil
+}
+
+// gmailGet fetches a Gmail API resource into v. ErrInsufficientScope means
+// the token wasn't granted the Gmail scope.
+func gmailGet(ctx context.Context, accessToken, resourceURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", resourceURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	start := time.Now()
+	resp, err := httpx.Do(httpx.Client, req, httpx.DefaultRetryPolicy())
+	metrics.ObserveExternal(metrics.ServiceGoogle, start, resp, err)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		// Quota errors are 403s too; only a scope problem needs a reconnect.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if strings.Contains(strings.ToLower(string(body)), "insufficient") {
+			return ErrInsufficientScope
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gmail api error: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// EmailSummary returns the user's unread inbox count and the subjects of
+// the newest unread messages (?limit=, default 3, at most 10).
+func (h *OAuthHandlers) EmailSummary(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := defaultSummarySubjects
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSummarySubjects {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 0 and %d", maxSummarySubjects)})
+			return
+		}
+		limit = n
+	}
+
+	accessToken, ok := h.accessTokenOrAbort(c, userID)
+	if !ok {
+		return
+	}
+
+	summary, err := h.calendarService.GetUnreadSummary(c.Request.Context(), accessToken, limit)
+	if errors.Is(err, ErrInsufficientScope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Reconnect Google to grant Gmail access"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch email summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -3,8 +3,11 @@ package google
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -17,6 +20,9 @@ import (
 	"dayboard/backend/internal/store"
 )
 
+// stateTTL bounds how long an OAuth state value remains valid.
+const stateTTL = 10 * time.Minute
+
 // OAuthHandlers handles Google OAuth flows
 type OAuthHandlers struct {
 	db              *db.DB
@@ -39,11 +45,13 @@ func (h *OAuthHandlers) InitiateGoogleAuth(c *gin.Context) {
 		return
 	}
 
-	// Generate state parameter for security
-	state := generateState(userID.String())
-
-	// Store state in session or cache (simplified for demo)
-	// In production, you'd store this in Redis or session store
+	// Generate state parameter for security and persist it so the
+	// callback can verify it
+	state := generateState(userID)
+	if err := store.CreateOAuthState(c.Request.Context(), h.db, state, userID, "google_calendar"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start Google authorization"})
+		return
+	}
 
 	authURL := h.calendarService.GetAuthURL(state)
 
@@ -63,8 +71,8 @@ func (h *OAuthHandlers) HandleGoogleCallback(c *gin.Context) {
 		return
 	}
 
-	// Verify state parameter (simplified for demo)
-	userID, err := verifyState(state)
+	// Verify state parameter
+	userID, err := h.verifyState(c.Request.Context(), state)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state parameter"})
 		return
@@ -105,15 +113,14 @@ func (h *OAuthHandlers) SyncCalendarEvents(c *gin.Context) {
 		return
 	}
 
-	// Get stored access token
-	accessToken, err := h.getAccessToken(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Google Calendar not connected"})
+	// Get stored access token, refreshing it if it has expired
+	accessToken, ok := h.accessTokenOrAbort(c, userID)
+	if !ok {
 		return
 	}
 
 	// Sync events
-	err = h.syncCalendarEvents(c.Request.Context(), userID, accessToken)
+	err := h.syncCalendarEvents(c.Request.Context(), userID, accessToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync calendar events"})
 		return
@@ -122,24 +129,157 @@ func (h *OAuthHandlers) SyncCalendarEvents(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Calendar events synced successfully"})
 }
 
+// ListCalendars returns the user's Google calendars and whether each is
+// selected for sync
+func (h *OAuthHandlers) ListCalendars(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	accessToken, ok := h.accessTokenOrAbort(c, userID)
+	if !ok {
+		return
+	}
+
+	calendars, err := h.calendarService.ListCalendars(c.Request.Context(), accessToken)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch Google calendars"})
+		return
+	}
+	selected, err := store.GetSelectedCalendars(c.Request.Context(), h.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar selection"})
+		return
+	}
+	isSelected := make(map[string]bool, len(selected))
+	for _, id := range selected {
+		isSelected[id] = true
+	}
+
+	type calendar struct {
+		CalendarListEntry
+		Selected bool `json:"selected"`
+	}
+	result := make([]calendar, 0, len(calendars))
+	for _, cal := range calendars {
+		// With no selection the primary calendar is the one synced.
+		sel := isSelected[cal.ID] || (len(selected) == 0 && cal.Primary)
+		result = append(result, calendar{CalendarListEntry: cal, Selected: sel})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"calendars": result})
+}
+
+// SelectCalendars replaces the set of Google calendars synced for the user.
+// Every ID must be on the user's calendar list; an empty list goes back to
+// syncing only the primary calendar.
+func (h *OAuthHandlers) SelectCalendars(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		CalendarIDs []string `json:"calendarIds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if len(req.CalendarIDs) > 0 {
+		accessToken, ok := h.accessTokenOrAbort(c, userID)
+		if !ok {
+			return
+		}
+		calendars, err := h.calendarService.ListCalendars(c.Request.Context(), accessToken)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch Google calendars"})
+			return
+		}
+		known := make(map[string]bool, len(calendars))
+		for _, cal := range calendars {
+			known[cal.ID] = true
+		}
+		for _, id := range req.CalendarIDs {
+			if !known[id] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown calendar %q", id)})
+				return
+			}
+		}
+	}
+
+	if err := store.SetSelectedCalendars(c.Request.Context(), h.db, userID, req.CalendarIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calendar selection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"calendarIds": req.CalendarIDs})
+}
+
 // Helper functions
 
-func generateState(userID string) string {
-	// In production, use a proper state generation with expiry
+// accessTokenOrAbort returns the user's Google access token, or writes the
+// matching error response and returns false.
+func (h *OAuthHandlers) accessTokenOrAbort(c *gin.Context, userID uuid.UUID) (string, bool) {
+	accessToken, err := h.getAccessToken(c.Request.Context(), userID)
+	if errors.Is(err, ErrRefreshTokenRevoked) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Google Calendar access was revoked, please reconnect"})
+		return "", false
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Google Calendar not connected"})
+		return "", false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refresh Google access token"})
+		return "", false
+	}
+	return accessToken, true
+}
+
+// generateState returns a state value of the form "<nonce>:<userID>".
+func generateState(userID uuid.UUID) string {
 	randomBytes := make([]byte, 16)
 	rand.Read(randomBytes)
-	return base64.URLEncoding.EncodeToString(randomBytes) + ":" + userID
+	return base64.URLEncoding.EncodeToString(randomBytes) + ":" + userID.String()
 }
 
-func verifyState(state string) (uuid.UUID, error) {
-	// Simplified state verification - extract user ID
-	// In production, verify the random part and check expiry
-	parts := []string{state} // Simplified - would normally split by ":"
-	if len(parts) < 2 {
+// verifyState checks that state was issued by InitiateGoogleAuth, is less
+// than stateTTL old and names the same user it was issued to. The stored
+// state is deleted on lookup so it cannot be replayed. It returns that
+// user's ID.
+func (h *OAuthHandlers) verifyState(ctx context.Context, state string) (uuid.UUID, error) {
+	parts := strings.SplitN(state, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
 		return uuid.Nil, fmt.Errorf("invalid state format")
 	}
 
-	return uuid.Parse(parts[1])
+	userID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid state user: %w", err)
+	}
+
+	issuedTo, createdAt, err := store.ConsumeOAuthState(ctx, h.db, state, "google_calendar")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if time.Since(createdAt) > stateTTL {
+		return uuid.Nil, fmt.Errorf("state expired")
+	}
+	if issuedTo != userID {
+		return uuid.Nil, fmt.Errorf("state user mismatch")
+	}
+
+	// Opportunistically clear out abandoned states.
+	if err := store.DeleteExpiredOAuthStates(ctx, h.db, time.Now().Add(-stateTTL)); err != nil {
+		log.Printf("google oauth: clearing expired states failed: %v", err)
+	}
+
+	return userID, nil
 }
 
 func (h *OAuthHandlers) storeTokens(ctx context.Context, userID uuid.UUID, tokens *TokenResponse) error {
@@ -155,74 +295,235 @@ func (h *OAuthHandlers) storeTokens(ctx context.Context, userID uuid.UUID, token
 	`, userID, "google_calendar",
 		[]byte(tokens.AccessToken),  // Should be encrypted
 		[]byte(tokens.RefreshToken), // Should be encrypted
-		[]string{"https://www.googleapis.com/auth/calendar.readonly"},
+		oauthScopes,
 		time.Now().Add(time.Duration(tokens.ExpiresIn)*time.Second))
 
 	return err
 }
 
+// RevokeConnections revokes the user's Google grant, if they connected
+// Google. The stored tokens are left for the caller to delete.
+func (h *OAuthHandlers) RevokeConnections(ctx context.Context, userID uuid.UUID) error {
+	var accessToken, refreshToken []byte
+	err := h.db.QueryRowContext(ctx, `
+		SELECT access_token_enc, refresh_token_enc
+		FROM oauth_tokens
+		WHERE user_id = $1 AND provider = $2
+	`, userID, "google_calendar").Scan(&accessToken, &refreshToken)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// In production, decrypt the token
+	token := string(refreshToken)
+	if token == "" {
+		token = string(accessToken)
+	}
+	return h.calendarService.RevokeToken(ctx, token)
+}
+
+// getAccessToken returns a usable access token for the user. Expired (or
+// nearly expired) tokens are refreshed with the stored refresh token and the
+// new token is persisted. ErrRefreshTokenRevoked means the user must
+// reconnect Google.
 func (h *OAuthHandlers) getAccessToken(ctx context.Context, userID uuid.UUID) (string, error) {
-	var accessToken []byte
+	var accessToken, refreshToken []byte
 	var expiry time.Time
 
 	err := h.db.QueryRowContext(ctx, `
-		SELECT access_token_enc, expiry 
+		SELECT access_token_enc, refresh_token_enc, expiry 
 		FROM oauth_tokens 
 		WHERE user_id = $1 AND provider = $2
-	`, userID, "google_calendar").Scan(&accessToken, &expiry)
+	`, userID, "google_calendar").Scan(&accessToken, &refreshToken, &expiry)
 
 	if err != nil {
 		return "", err
 	}
 
-	// Check if token is expired (simplified - should refresh if needed)
-	if time.Now().After(expiry) {
-		return "", fmt.Errorf("token expired")
+	// Refresh a minute early so the token doesn't expire mid-request
+	if time.Now().Add(time.Minute).Before(expiry) {
+		// In production, decrypt the token
+		return string(accessToken), nil
 	}
 
-	// In production, decrypt the token
-	return string(accessToken), nil
+	if len(refreshToken) == 0 {
+		return "", ErrRefreshTokenRevoked
+	}
+
+	tokenResp, err := h.calendarService.RefreshAccessToken(ctx, string(refreshToken))
+	if err != nil {
+		return "", err
+	}
+
+	// Google only returns a new refresh token when it rotates it, so keep
+	// the stored one otherwise.
+	var newRefresh []byte
+	if tokenResp.RefreshToken != "" {
+		newRefresh = []byte(tokenResp.RefreshToken) // Should be encrypted
+	}
+	_, err = h.db.ExecContext(ctx, `
+		UPDATE oauth_tokens
+		SET access_token_enc = $3,
+			refresh_token_enc = COALESCE($4, refresh_token_enc),
+			expiry = $5
+		WHERE user_id = $1 AND provider = $2
+	`, userID, "google_calendar",
+		[]byte(tokenResp.AccessToken), // Should be encrypted
+		newRefresh,
+		time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second))
+	if err != nil {
+		return "", err
+	}
+
+	return tokenResp.AccessToken, nil
 }
 
+// syncCalendarEvents stores the events in the sync window (today plus the
+// next GOOGLE_SYNC_DAYS-1 days) from each of the user's selected calendars,
+// or the primary calendar if none are selected. An event shared across
+// calendars has the same ID on each and is stored once.
+//
+// After the first full sync of a calendar, Google's sync token is kept so
+// later syncs fetch only changed and deleted events. A calendar is fully
+// synced again when Google expires the token (410) or when the rolling
+// window has moved past the range the token's full sync covered.
+//
+// Deleted events are removed: incremental syncs report them as cancelled,
+// and when every calendar was fully synced, stored events in the window
+// that none of them returned are pruned.
 func (h *OAuthHandlers) syncCalendarEvents(ctx context.Context, userID uuid.UUID, accessToken string) error {
-	events, err := h.calendarService.GetTodaysEvents(ctx, accessToken)
+	calendarIDs, err := store.GetSelectedCalendars(ctx, h.db, userID)
 	if err != nil {
 		return err
 	}
+	if len(calendarIDs) == 0 {
+		calendarIDs = []string{PrimaryCalendarID}
+	}
 
-	// Store events in database
-	for _, event := range events {
-		// Convert Google Calendar event to store.Event
-		storeEvent := store.Event{
-			ID:       uuid.New(),
-			Start:    event.StartTime,
-			End:      event.EndTime,
-			Title:    event.Summary,
-			JoinURL:  getJoinURL(event),
-			Location: event.Location,
-		}
-
-		// Insert or update event
-		_, err := h.db.ExecContext(ctx, `
-			INSERT INTO calendar_events (id, user_id, source, ext_id, start_ts, end_ts, title, join_url, location)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-			ON CONFLICT (user_id, source, ext_id)
-			DO UPDATE SET
-				start_ts = EXCLUDED.start_ts,
-				end_ts = EXCLUDED.end_ts,
-				title = EXCLUDED.title,
-				join_url = EXCLUDED.join_url,
-				location = EXCLUDED.location,
-				updated_at = NOW()
-		`, storeEvent.ID, userID, "google_calendar", event.ID,
-			event.StartTime, event.EndTime, event.Summary, getJoinURL(event), event.Location)
+	type syncedToken struct {
+		calendarID string
+		token      string
+		windowEnd  time.Time // zero after an incremental sync
+	}
 
+	start, end := h.calendarService.SyncWindow(time.Now())
+	var events []CalendarEvent
+	var cancelled []string
+	var tokens []syncedToken
+	seen := make(map[string]bool)
+	fullSync := true
+	for _, calendarID := range calendarIDs {
+		syncToken, windowEnd, err := store.GetCalendarSyncToken(ctx, h.db, userID, calendarID)
 		if err != nil {
 			return err
 		}
+		if windowEnd.Before(end) {
+			syncToken = ""
+		}
+
+		changes, err := h.calendarService.SyncEvents(ctx, accessToken, calendarID, syncToken, start, end)
+		if errors.Is(err, ErrSyncTokenExpired) {
+			if err := store.ClearCalendarSyncToken(ctx, h.db, userID, calendarID); err != nil {
+				return err
+			}
+			syncToken = ""
+			changes, err = h.calendarService.SyncEvents(ctx, accessToken, calendarID, "", start, end)
+		}
+		if err != nil {
+			return err
+		}
+		if syncToken != "" {
+			fullSync = false
+		}
+
+		for _, event := range changes.Events {
+			if seen[event.ID] {
+				continue
+			}
+			seen[event.ID] = true
+			events = append(events, event)
+		}
+		cancelled = append(cancelled, changes.Cancelled...)
+		if changes.NextSyncToken != "" {
+			t := syncedToken{calendarID: calendarID, token: changes.NextSyncToken}
+			if syncToken == "" {
+				t.windowEnd = end
+			}
+			tokens = append(tokens, t)
+		}
 	}
 
-	return nil
+	// An event deleted from one calendar may still be on another.
+	var removed []string
+	for _, id := range cancelled {
+		if !seen[id] {
+			removed = append(removed, id)
+		}
+	}
+
+	// The writes go in one transaction so a failure leaves the previous
+	// sync's events and tokens intact.
+	return h.db.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := store.DeleteEvents(ctx, tx, userID, "google_calendar", removed); err != nil {
+			return err
+		}
+
+		// Store events in database
+		for _, event := range events {
+			// Convert Google Calendar event to store.Event
+			storeEvent := store.Event{
+				ID:       uuid.New(),
+				Start:    event.StartTime,
+				End:      store.EventEnd(event.StartTime, event.EndTime, event.AllDay),
+				Title:    event.Summary,
+				JoinURL:  getJoinURL(event),
+				Location: event.Location,
+				AllDay:   event.AllDay,
+			}
+
+			// Insert or update event
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO calendar_events (id, user_id, source, ext_id, start_ts, end_ts, title, join_url, location, all_day)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				ON CONFLICT (user_id, source, ext_id)
+				DO UPDATE SET
+					start_ts = EXCLUDED.start_ts,
+					end_ts = EXCLUDED.end_ts,
+					title = EXCLUDED.title,
+					join_url = EXCLUDED.join_url,
+					location = EXCLUDED.location,
+					all_day = EXCLUDED.all_day,
+					updated_at = NOW()
+			`, storeEvent.ID, userID, "google_calendar", event.ID,
+				storeEvent.Start, storeEvent.End, event.Summary, getJoinURL(event), event.Location, storeEvent.AllDay)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if fullSync {
+			keep := make([]string, 0, len(seen))
+			for id := range seen {
+				keep = append(keep, id)
+			}
+			if err := store.PruneEvents(ctx, tx, userID, "google_calendar", start, end, keep); err != nil {
+				return err
+			}
+		}
+
+		// Save tokens only once their changes are stored, so a failed sync
+		// is retried from the same point.
+		for _, t := range tokens {
+			if err := store.SetCalendarSyncToken(ctx, tx, userID, t.calendarID, t.token, t.windowEnd); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func getJoinURL(event CalendarEvent) string {
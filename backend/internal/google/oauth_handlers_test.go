@@ -0,0 +1,68 @@
+package google
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func TestSyncRemovesCancelledEvents(t *testing.T) {
+	// Incremental syncs of two calendars: primary cancels lunch, and team
+	// cancels standup, which is still on primary.
+	cal := calendarServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("syncToken") == "" {
+			t.Errorf("%s synced without its sync token", r.URL.Path)
+		}
+		switch r.URL.Path {
+		case "/calendars/primary/events":
+			w.Write([]byte(`{"items":[
+				{"id":"standup","status":"confirmed","summary":"Standup",
+				 "start":{"dateTime":"2024-07-05T09:00:00Z"},"end":{"dateTime":"2024-07-05T09:15:00Z"}},
+				{"id":"lunch","status":"cancelled"}
+			],"nextSyncToken":"primary-2"}`))
+		case "/calendars/team/events":
+			w.Write([]byte(`{"items":[{"id":"standup","status":"cancelled"}],"nextSyncToken":"team-2"}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+
+	// The fake calendar_events table applies upserts and deletes by ext_id.
+	stored := map[string]bool{"standup": true, "lunch": true, "review": true}
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM google_calendars"):
+			return dbtest.Rows([]string{"calendar_id"}, []any{"primary"}, []any{"team"})
+		case strings.Contains(q.SQL, "FROM google_sync_tokens"):
+			return dbtest.Rows([]string{"sync_token", "window_end"}, []any{q.Args[1].(string) + "-1", time.Now().AddDate(1, 0, 0)})
+		case strings.Contains(q.SQL, "INSERT INTO calendar_events"):
+			stored[q.Args[3].(string)] = true
+		case strings.Contains(q.SQL, "DELETE FROM calendar_events"):
+			if !strings.Contains(q.SQL, "ext_id = ANY($3)") {
+				t.Errorf("incremental sync pruned events: %s", q.SQL)
+				break
+			}
+			for _, id := range q.Args[2].([]string) {
+				delete(stored, id)
+			}
+		}
+		return dbtest.Result{RowsAffected: 1}
+	})
+	h := &OAuthHandlers{db: d, calendarService: cal}
+
+	if err := h.syncCalendarEvents(context.Background(), uuid.New(), "access-token"); err != nil {
+		t.Fatal(err)
+	}
+	if stored["lunch"] {
+		t.Error("cancelled event is still stored")
+	}
+	if !stored["standup"] || !stored["review"] {
+		t.Errorf("stored events = %v, want standup and review kept", stored)
+	}
+}
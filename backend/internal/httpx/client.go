@@ -0,0 +1,32 @@
+package httpx
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultTimeout bounds a single outbound request, including reading the
+// response body, when HTTP_CLIENT_TIMEOUT_SECONDS is unset.
+const defaultTimeout = 15 * time.Second
+
+// Client is shared by outbound API calls so a hung upstream can't hold a
+// request goroutine forever. Its timeout is read from
+// HTTP_CLIENT_TIMEOUT_SECONDS and applies to each attempt made by Do.
+var Client = NewClient(loadTimeout())
+
+func loadTimeout() time.Duration {
+	if v := os.Getenv("HTTP_CLIENT_TIMEOUT_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return defaultTimeout
+}
+
+// NewClient returns a client with the given timeout for callers that need
+// a different bound than Client, such as slow model calls.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout}
+}
@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	resp, err := NewClient(50 * time.Millisecond).Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a hung server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("gave up after %v, want about the 50ms timeout", elapsed)
+	}
+}
+
+func TestLoadTimeout(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", defaultTimeout},
+		{"30", 30 * time.Second},
+		{"-1", defaultTimeout},
+		{"slow", defaultTimeout},
+	}
+	for _, tt := range tests {
+		t.Setenv("HTTP_CLIENT_TIMEOUT_SECONDS", tt.env)
+		if got := loadTimeout(); got != tt.want {
+			t.Errorf("HTTP_CLIENT_TIMEOUT_SECONDS=%q: timeout = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package httpx holds helpers shared by the outbound API clients (Plaid,
+// Google, Gemini, Maps).
+package httpx
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how Do retries transient failures.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; it doubles on each
+	// subsequent retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryNonIdempotent allows retrying methods such as POST, for callers
+	// whose endpoint is safe to repeat. Without it only GET, HEAD, OPTIONS,
+	// PUT and DELETE requests are retried.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy retries up to HTTP_RETRY_MAX_ATTEMPTS times in total
+// (default 3), starting at 200ms and capping each wait at 5s.
+func DefaultRetryPolicy() RetryPolicy {
+	attempts := 3
+	if v := os.Getenv("HTTP_RETRY_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			attempts = n
+		}
+	}
+	return RetryPolicy{
+		MaxAttempts: attempts,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+// Do sends req with client, retrying network errors, 429s and 5xx
+// responses with exponential backoff. A Retry-After header on the response
+// overrides the computed delay. Other responses, including 4xx errors, are
+// returned immediately. Requests with a body must be created with a
+// replayable body (bytes.Reader, strings.Reader, ...) so http.NewRequest
+// sets GetBody; otherwise, and for non-idempotent methods the policy
+// doesn't opt into, Do makes a single attempt. Waiting stops early if the
+// request's context is done.
+func Do(client *http.Client, req *http.Request, policy RetryPolicy) (*http.Response, error) {
+	ctx := req.Context()
+	attempts := max(policy.MaxAttempts, 1)
+	if !policy.RetryNonIdempotent && !idempotent(req.Method) {
+		attempts = 1
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body is consumed by the first try and can't be replayed.
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		try := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try = req.Clone(ctx)
+			try.Body = body
+		}
+
+		resp, err := client.Do(try)
+		if attempt >= attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		if err == nil && !retryable(resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay := backoff(policy, attempt)
+		if err == nil {
+			if ra, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = min(ra, policy.MaxDelay)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// idempotent reports whether repeating a request with method has the same
+// effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a response status is worth retrying.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// backoff returns the delay before retry number attempt (1-based), with up
+// to 20% jitter so concurrent clients don't retry in lockstep.
+func backoff(policy RetryPolicy, attempt int) time.Duration {
+	d := policy.BaseDelay << (attempt - 1)
+	if d <= 0 || d > policy.MaxDelay {
+		d = policy.MaxDelay
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flaky answers 503 to the first failures requests and 200 afterwards,
+// recording each request body it receives.
+func flaky(t *testing.T, failures int) (*httptest.Server, *[]string) {
+	t.Helper()
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &bodies
+}
+
+var testPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestDoRetriesIdempotentRequests(t *testing.T) {
+	srv, bodies := flaky(t, 2)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := Do(srv.Client(), req, testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(*bodies) != 3 {
+		t.Errorf("status %d after %d attempts, want 200 after 3", resp.StatusCode, len(*bodies))
+	}
+}
+
+func TestDoRetryMethods(t *testing.T) {
+	tests := []struct {
+		name         string
+		optIn        bool
+		wantAttempts int
+	}{
+		{"POST is sent once by default", false, 1},
+		{"POST retries when the caller opts in", true, 3},
+	}
+	for _, tt := range tests {
+		srv, bodies := flaky(t, 2)
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"public_token":"p"}`))
+		policy := testPolicy
+		policy.RetryNonIdempotent = tt.optIn
+		resp, err := Do(srv.Client(), req, policy)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if len(*bodies) != tt.wantAttempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, len(*bodies), tt.wantAttempts)
+		}
+		for i, b := range *bodies {
+			if b != `{"public_token":"p"}` {
+				t.Errorf("%s: attempt %d sent body %q", tt.name, i+1, b)
+			}
+		}
+	}
+}
+
+func TestDoDoesNotReplayConsumedBody(t *testing.T) {
+	srv, bodies := flaky(t, 2)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(strings.NewReader("payload")))
+	if req.GetBody != nil {
+		t.Fatal("test body unexpectedly replayable")
+	}
+	resp, err := Do(srv.Client(), req, testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(*bodies) != 1 || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("%d attempts ending in %d, want the single 503", len(*bodies), resp.StatusCode)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("2"); !ok || d != 2*time.Second {
+		t.Errorf("retryAfter(2) = %v, %v", d, ok)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Error("retryAfter accepted an invalid value")
+	}
+}
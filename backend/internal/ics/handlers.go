@@ -0,0 +1,237 @@
+package ics
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/auth"
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/metrics"
+	"dayboard/backend/internal/store"
+)
+
+// Source is the calendar_events source for imported events.
+const Source = "ics"
+
+// syncDays is how many days of occurrences an import stores, starting
+// today.
+const syncDays = 7
+
+// maxFeedSize is the largest feed imported. A larger one is refused
+// rather than cut short, since a truncated feed would prune the events it
+// lost. Uploads are also bound by the server's request body limit
+// (MAX_REQUEST_BODY_BYTES).
+const maxFeedSize = 5 << 20
+
+var errPrivateAddress = errors.New("feed address is not public")
+
+// feedClient fetches feeds from user-supplied URLs. It refuses to connect
+// to loopback, private and link-local addresses, checked on the resolved
+// IP so a public name pointing inward is caught too.
+var feedClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+					ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+					return errPrivateAddress
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+// Handlers serves ICS imports
+type Handlers struct {
+	db *db.DB
+}
+
+// NewHandlers creates ICS import handlers
+func NewHandlers(database *db.DB) *Handlers {
+	return &Handlers{db: database}
+}
+
+// Import loads an ICS feed, either from {"url": ...} (http, https or
+// webcal) or a multipart upload in the "file" field, and stores the
+// occurrences in the next syncDays days. Importing the same URL or file
+// name again replaces what it imported before, including removing events
+// no longer in the feed; other feeds are left alone. Feeds over
+// maxFeedSize are rejected with 413 for uploads and 502 for URLs.
+func (h *Handlers) Import(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body io.ReadCloser
+	var feedName string
+	tooLarge := http.StatusBadGateway
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file upload"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+			return
+		}
+		body, feedName = f, "file:"+file.Filename
+		tooLarge = http.StatusRequestEntityTooLarge
+	} else {
+		var req struct {
+			URL string `json:"url" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+			return
+		}
+		feedURL, err := normalizeFeedURL(req.URL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		body, err = fetchFeed(c.Request.Context(), feedURL)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch calendar feed"})
+			return
+		}
+		feedName = feedURL
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxFeedSize+1))
+	if err != nil {
+		c.JSON(tooLarge, gin.H{"error": "Failed to read calendar feed"})
+		return
+	}
+	if len(data) > maxFeedSize {
+		c.JSON(tooLarge, gin.H{"error": fmt.Sprintf("Calendar feed is larger than %d MB", maxFeedSize>>20)})
+		return
+	}
+	cal, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar file"})
+		return
+	}
+
+	n, err := h.storeEvents(c.Request.Context(), userID, feedKey(feedName), cal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store calendar events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"calendar": cal.Name,
+		"imported": n,
+	})
+}
+
+// storeEvents upserts the feed's occurrences in the sync window and prunes
+// ones the feed no longer has. Each occurrence's ext_id is the feed key,
+// the UID and, for recurring events, the occurrence's original start.
+func (h *Handlers) storeEvents(ctx context.Context, userID uuid.UUID, key string, cal *Calendar) (int, error) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 0, syncDays)
+
+	var keep []string
+	for _, e := range Expand(cal, start, end) {
+		extID := key + ":" + e.UID
+		if !e.RecurrenceID.IsZero() {
+			extID += "@" + e.RecurrenceID.UTC().Format("20060102T150405Z")
+		}
+		keep = append(keep, extID)
+
+		_, err := h.db.ExecContext(ctx, `
+			INSERT INTO calendar_events (id, user_id, source, ext_id, start_ts, end_ts, title, join_url, location, all_day)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (user_id, source, ext_id)
+			DO UPDATE SET
+				start_ts = EXCLUDED.start_ts,
+				end_ts = EXCLUDED.end_ts,
+				title = EXCLUDED.title,
+				join_url = EXCLUDED.join_url,
+				location = EXCLUDED.location,
+				all_day = EXCLUDED.all_day,
+				updated_at = NOW()
+		`, uuid.New(), userID, Source, extID,
+			e.Start, store.EventEnd(e.Start, e.End, e.AllDay), e.Summary, e.URL, e.Location, e.AllDay)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := store.PruneEventsWithPrefix(ctx, h.db, userID, Source, key+":", start, end, keep); err != nil {
+		return 0, err
+	}
+	return len(keep), nil
+}
+
+// normalizeFeedURL accepts http and https URLs, and webcal ones as https.
+func normalizeFeedURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid feed url")
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "webcal":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("feed url must be http, https or webcal")
+	}
+	return u.String(), nil
+}
+
+func fetchFeed(ctx context.Context, feedURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	start := time.Now()
+	resp, err := feedClient.Do(req)
+	metrics.ObserveExternal(metrics.ServiceICS, start, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("calendar feed error: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// feedKey identifies a feed within a user's ics events so re-imports only
+// replace their own events.
+func feedKey(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,72 @@
+package ics
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// oversizeFeed is a valid calendar padded past maxFeedSize, with its
+// VEVENT at the end where truncating would drop it.
+func oversizeFeed() string {
+	padding := strings.Repeat("X-PADDING:"+strings.Repeat("x", 60)+"\n", maxFeedSize/70+1)
+	return feed(padding, "UID:late\nDTSTART:20240710T090000Z\n")
+}
+
+func TestImportRejectsOversizeFeed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(oversizeFeed()))
+	}))
+	defer srv.Close()
+	prev := feedClient
+	feedClient = srv.Client()
+	defer func() { feedClient = prev }()
+
+	var upload bytes.Buffer
+	mw := multipart.NewWriter(&upload)
+	part, err := mw.CreateFormFile("file", "term.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(oversizeFeed()))
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"upload", mw.FormDataContentType(), upload.String(), http.StatusRequestEntityTooLarge},
+		{"url", "application/json", `{"url":"` + srv.URL + `/term.ics"}`, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		d, rec := dbtest.Open(t, func(dbtest.Query) dbtest.Result { return dbtest.Result{RowsAffected: 1} })
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/calendar/ics", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", tt.contentType)
+		c.Set("user_id", uuid.New())
+		NewHandlers(d).Import(c)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (body %s)", tt.name, w.Code, tt.want, w.Body)
+		}
+		// Nothing is stored or pruned from a feed that was cut short.
+		if n := len(rec.Queries()); n != 0 {
+			t.Errorf("%s: ran %d queries, want none", tt.name, n)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Package ics imports events from iCalendar (.ics) feeds, such as the ones
+// universities and sports teams publish, into calendar_events with source
+// "ics". It parses the subset of RFC 5545 that calendar feeds use in
+// practice: VEVENTs with their times, time zones, recurrence rules,
+// exception dates and overridden instances.
+package ics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNoCalendar is returned when the input has no VCALENDAR.
+var ErrNoCalendar = errors.New("not an iCalendar file")
+
+// Event is a VEVENT from a feed. Start and End are absolute instants;
+// all-day events run from midnight to midnight in the calendar's zone.
+type Event struct {
+	UID      string
+	Summary  string
+	Location string
+	URL      string
+	// Categories are the event's CATEGORIES, in feed order.
+	Categories []string
+	Start      time.Time
+	End        time.Time
+	AllDay     bool
+	// Cancelled is set for STATUS:CANCELLED.
+	Cancelled bool
+	// RRule is the raw recurrence rule, e.g. "FREQ=WEEKLY;BYDAY=MO,WE".
+	RRule string
+	// ExDates are occurrence starts removed from the recurrence.
+	ExDates []time.Time
+	// RecurrenceID is set on an event that overrides one occurrence of a
+	// recurring event with the same UID; it is that occurrence's start.
+	RecurrenceID time.Time
+}
+
+// Calendar is a parsed feed.
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// property is one content line: NAME;PARAM=VALUE;...:value
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads an iCalendar feed. Dates without a zone (floating times and
+// all-day dates) are placed in the calendar's X-WR-TIMEZONE, or UTC if it
+// has none. A TZID may be an IANA or Windows zone name, or one the feed
+// defines in a VTIMEZONE; any other TZID falls back like a floating time.
+func Parse(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var props [][]property // one slice per VEVENT
+	var current []property
+	var timezones []vtimezone
+	var tz *vtimezone
+	var obs *observance
+	cal := &Calendar{}
+	defaultLoc := time.UTC
+	inCalendar, inEvent := false, false
+	depth := 0 // nested components inside a VEVENT, e.g. VALARM
+
+	for _, line := range lines {
+		p, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && p.value == "VCALENDAR":
+			inCalendar = true
+		case p.name == "BEGIN" && p.value == "VTIMEZONE" && inCalendar && !inEvent:
+			tz = &vtimezone{}
+		case tz != nil:
+			parseTimezoneLine(p, tz, &obs)
+			if p.name == "END" && p.value == "VTIMEZONE" {
+				timezones = append(timezones, *tz)
+				tz = nil
+			}
+		case p.name == "BEGIN" && p.value == "VEVENT" && inCalendar:
+			inEvent, current = true, nil
+		case p.name == "BEGIN" && inEvent:
+			depth++
+		case p.name == "END" && inEvent && depth > 0:
+			depth--
+		case p.name == "END" && p.value == "VEVENT" && inEvent:
+			inEvent = false
+			props = append(props, current)
+		case inEvent && depth == 0:
+			current = append(current, p)
+		case inCalendar && !inEvent && p.name == "X-WR-CALNAME":
+			cal.Name = unescapeText(p.value)
+		case inCalendar && !inEvent && p.name == "X-WR-TIMEZONE":
+			if loc, ok := loadZone(p.value); ok {
+				defaultLoc = loc
+			}
+		}
+	}
+	if !inCalendar {
+		return nil, ErrNoCalendar
+	}
+
+	z := zones{defaultLoc: defaultLoc, defined: make(map[string]*time.Location)}
+	for _, t := range timezones {
+		if loc, ok := t.location(); ok && t.id != "" {
+			z.defined[t.id] = loc
+		}
+	}
+	for _, ps := range props {
+		if e, ok := buildEvent(ps, z); ok {
+			cal.Events = append(cal.Events, e)
+		}
+	}
+	return cal, nil
+}
+
+// parseTimezoneLine applies one line inside a VTIMEZONE to tz, with *obs
+// the STANDARD or DAYLIGHT block being read, if any.
+func parseTimezoneLine(p property, tz *vtimezone, obs **observance) {
+	switch {
+	case p.name == "BEGIN" && (p.value == "STANDARD" || p.value == "DAYLIGHT"):
+		*obs = &observance{daylight: p.value == "DAYLIGHT"}
+	case p.name == "END" && *obs != nil:
+		if (*obs).start != "" {
+			tz.observances = append(tz.observances, **obs)
+		}
+		*obs = nil
+	case *obs == nil:
+		if p.name == "TZID" {
+			tz.id = p.value
+		}
+	case p.name == "DTSTART":
+		(*obs).start = strings.TrimSpace(p.value)
+	case p.name == "TZOFFSETFROM":
+		(*obs).offsetFrom, _ = parseOffset(p.value)
+	case p.name == "TZOFFSETTO":
+		(*obs).offsetTo, _ = parseOffset(p.value)
+	case p.name == "TZNAME":
+		(*obs).name = p.value
+	case p.name == "RRULE":
+		(*obs).rrule = p.value
+	case p.name == "RDATE":
+		(*obs).rdates = append((*obs).rdates, strings.Split(p.value, ",")...)
+	}
+}
+
+// unfold joins continuation lines (ones starting with a space or tab) onto
+// the line before them.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its name, parameters and value.
+// Parameter values may be quoted, and quoted values may contain ':' or ';'.
+func parseLine(line string) (property, bool) {
+	p := property{params: map[string]string{}}
+	inQuotes := false
+	start := 0
+	var key string
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case ch == ';' || ch == ':':
+			segment := line[start:i]
+			if p.name == "" {
+				p.name = strings.ToUpper(segment)
+			} else if key != "" {
+				p.params[key] = strings.Trim(segment, `"`)
+			}
+			key = ""
+			start = i + 1
+			if ch == ':' {
+				p.value = line[i+1:]
+				return p, p.name != ""
+			}
+		case ch == '=' && p.name != "" && key == "":
+			key = strings.ToUpper(line[start:i])
+			start = i + 1
+		}
+	}
+	return p, false
+}
+
+func buildEvent(props []property, z zones) (Event, bool) {
+	var e Event
+	var duration time.Duration
+	var durationDays int
+	hasEnd := false
+	for _, p := range props {
+		switch p.name {
+		case "UID":
+			e.UID = p.value
+		case "SUMMARY":
+			e.Summary = unescapeText(p.value)
+		case "LOCATION":
+			e.Location = unescapeText(p.value)
+		case "URL":
+			e.URL = p.value
+		case "CATEGORIES":
+			for _, c := range splitText(p.value) {
+				if c = strings.TrimSpace(unescapeText(c)); c != "" {
+					e.Categories = append(e.Categories, c)
+				}
+			}
+		case "STATUS":
+			e.Cancelled = strings.EqualFold(p.value, "CANCELLED")
+		case "DTSTART":
+			t, allDay, err := parseDateTime(p, z)
+			if err != nil {
+				return Event{}, false
+			}
+			e.Start, e.AllDay = t, allDay
+		case "DTEND":
+			if t, _, err := parseDateTime(p, z); err == nil {
+				e.End, hasEnd = t, true
+			}
+		case "DURATION":
+			duration, durationDays = parseDuration(p.value)
+		case "RRULE":
+			e.RRule = p.value
+		case "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				single := property{name: p.name, params: p.params, value: v}
+				if t, _, err := parseDateTime(single, z); err == nil {
+					e.ExDates = append(e.ExDates, t)
+				}
+			}
+		case "RECURRENCE-ID":
+			if t, _, err := parseDateTime(p, z); err == nil {
+				e.RecurrenceID = t
+			}
+		}
+	}
+	if e.UID == "" || e.Start.IsZero() {
+		return Event{}, false
+	}
+
+	switch {
+	case hasEnd:
+	case durationDays != 0 || duration != 0:
+		e.End = e.Start.AddDate(0, 0, durationDays).Add(duration)
+	case e.AllDay:
+		// An all-day event without an end lasts the one day.
+		e.End = e.Start.AddDate(0, 0, 1)
+	default:
+		e.End = e.Start
+	}
+	return e, true
+}
+
+// parseDateTime parses a DTSTART-style value: a date (VALUE=DATE or an
+// 8-digit value), a UTC time ending in Z, or a local time in the TZID
+// parameter's zone as resolved by z. It reports whether the value was a
+// date.
+func parseDateTime(p property, z zones) (time.Time, bool, error) {
+	v := strings.TrimSpace(p.value)
+	loc := z.lookup(p.params["TZID"])
+	if p.params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	return t, false, err
+}
+
+// parseDuration parses an RFC 5545 duration such as "PT1H30M" or "P1D".
+// Days and weeks are returned separately so they can be added as calendar
+// days across DST changes. Negative durations are treated as zero.
+func parseDuration(v string) (time.Duration, int) {
+	if strings.HasPrefix(v, "-") || !strings.HasPrefix(strings.TrimPrefix(v, "+"), "P") {
+		return 0, 0
+	}
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "+"), "P")
+	var d time.Duration
+	days := 0
+	n := 0
+	inTime := false
+	for _, ch := range v {
+		switch {
+		case ch >= '0' && ch <= '9':
+			n = n*10 + int(ch-'0')
+			continue
+		case ch == 'T':
+			inTime = true
+		case ch == 'W':
+			days += 7 * n
+		case ch == 'D':
+			days += n
+		case ch == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case ch == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case ch == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		}
+		n = 0
+	}
+	return d, days
+}
+
+// splitText splits a multi-valued TEXT property on commas that aren't
+// escaped. The parts are still escaped.
+func splitText(v string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, v[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, v[start:])
+}
+
+// unescapeText undoes TEXT value escaping (\n, \, \; \\).
+func unescapeText(v string) string {
+	if !strings.Contains(v, `\`) {
+		return v
+	}
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '\\' && i+1 < len(v) {
+			i++
+			switch v[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(v[i])
+			}
+			continue
+		}
+		b.WriteByte(v[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// customEastern defines US Eastern time under a TZID that isn't an IANA or
+// Windows name, as some feed generators do.
+const customEastern = `BEGIN:VTIMEZONE
+TZID:Campus Time
+BEGIN:STANDARD
+DTSTART:19701101T020000
+RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+TZNAME:EST
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:19700308T020000
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+TZNAME:EDT
+END:DAYLIGHT
+END:VTIMEZONE
+`
+
+// feed wraps VEVENT bodies (without BEGIN/END) in a calendar with the
+// given extra calendar lines.
+func feed(header string, events ...string) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\nVERSION:2.0\n" + header)
+	for _, e := range events {
+		b.WriteString("BEGIN:VEVENT\n" + e + "END:VEVENT\n")
+	}
+	b.WriteString("END:VCALENDAR\n")
+	return b.String()
+}
+
+func TestParseTimeZones(t *testing.T) {
+	src := feed("X-WR-TIMEZONE:America/Chicago\n"+customEastern+`BEGIN:VTIMEZONE
+TZID:India
+BEGIN:STANDARD
+DTSTART:19700101T000000
+TZOFFSETFROM:+0530
+TZOFFSETTO:+0530
+END:STANDARD
+END:VTIMEZONE
+`,
+		"UID:windows\nDTSTART;TZID=\"Eastern Standard Time\":20240710T090000\n",
+		"UID:summer\nDTSTART;TZID=Campus Time:20240710T090000\n",
+		"UID:winter\nDTSTART;TZID=Campus Time:20240110T090000\n",
+		"UID:fixed\nDTSTART;TZID=India:20240710T090000\n",
+		"UID:unknown\nDTSTART;TZID=Mars Standard Time:20240710T090000\n",
+	)
+	cal, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"windows": "2024-07-10T13:00:00Z",
+		"summer":  "2024-07-10T13:00:00Z",
+		"winter":  "2024-01-10T14:00:00Z",
+		"fixed":   "2024-07-10T03:30:00Z",
+		// Unknown zones fall back to X-WR-TIMEZONE.
+		"unknown": "2024-07-10T14:00:00Z",
+	}
+	if len(cal.Events) != len(want) {
+		t.Fatalf("parsed %d events, want %d", len(cal.Events), len(want))
+	}
+	for _, e := range cal.Events {
+		if got := e.Start.UTC().Format(time.RFC3339); got != want[e.UID] {
+			t.Errorf("%s starts at %s, want %s", e.UID, got, want[e.UID])
+		}
+	}
+}
+
+func TestExpandAcrossVTimezoneDSTChange(t *testing.T) {
+	// A weekly 9am Monday class spanning the November 3, 2024 change.
+	src := feed(customEastern, "UID:class\nDTSTART;TZID=Campus Time:20241028T090000\nRRULE:FREQ=WEEKLY;COUNT=3\n")
+	cal, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range Expand(cal, time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		got = append(got, e.Start.UTC().Format(time.RFC3339))
+	}
+	want := []string{"2024-10-28T13:00:00Z", "2024-11-04T14:00:00Z", "2024-11-11T14:00:00Z"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("occurrences = %v, want %v", got, want)
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := map[string]int{"-0500": -5 * 3600, "+0530": 5*3600 + 30*60, "+000000": 0, "+023045": 2*3600 + 30*60 + 45}
+	for v, want := range tests {
+		if got, ok := parseOffset(v); !ok || got != want {
+			t.Errorf("parseOffset(%q) = %d, %v; want %d", v, got, ok, want)
+		}
+	}
+	for _, v := range []string{"", "0500", "-05", "+05:00"} {
+		if _, ok := parseOffset(v); ok {
+			t.Errorf("parseOffset(%q) accepted", v)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package ics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds how many recurrence periods (days, weeks, months or
+// years) Expand walks, so a malformed rule can't loop for long.
+const maxPeriods = 10000
+
+// rule is the supported subset of an RRULE. BYSETPOS, BYWEEKNO, BYYEARDAY
+// and the sub-daily frequencies are not supported; rules using them are
+// expanded as if those parts were absent, or not at all for HOURLY and
+// finer.
+type rule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+// weekdayNum is a BYDAY entry such as "MO" or "-1FR" (last Friday).
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func parseRule(s string, loc *time.Location) (rule, bool) {
+	r := rule{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			r.freq = strings.ToUpper(v)
+		case "INTERVAL":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				r.interval = n
+			}
+		case "COUNT":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				r.count = n
+			}
+		case "UNTIL":
+			t, isDate, err := parseDateTime(property{value: v, params: map[string]string{}}, zones{defaultLoc: loc})
+			if err == nil {
+				if isDate {
+					// A date UNTIL includes that whole day.
+					t = t.AddDate(0, 0, 1).Add(-time.Second)
+				}
+				r.until = t
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				d = strings.ToUpper(strings.TrimSpace(d))
+				if len(d) < 2 {
+					continue
+				}
+				wd, ok := weekdays[d[len(d)-2:]]
+				if !ok {
+					continue
+				}
+				n, _ := strconv.Atoi(d[:len(d)-2])
+				r.byDay = append(r.byDay, weekdayNum{n: n, day: wd})
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(v, ",") {
+				if n, err := strconv.Atoi(d); err == nil && n != 0 {
+					r.byMonthDay = append(r.byMonthDay, n)
+				}
+			}
+		case "BYMONTH":
+			for _, m := range strings.Split(v, ",") {
+				if n, err := strconv.Atoi(m); err == nil && n >= 1 && n <= 12 {
+					r.byMonth = append(r.byMonth, time.Month(n))
+				}
+			}
+		}
+	}
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+		return r, true
+	}
+	return r, false
+}
+
+// Expand returns the occurrences of the events in cal that overlap
+// [start, end). Recurring events are expanded with their RRULE, skipping
+// EXDATEs; an event with a RECURRENCE-ID replaces the matching occurrence,
+// or removes it if cancelled. Each occurrence of a recurring event gets
+// RecurrenceID set to its original start so it can be told apart.
+func Expand(cal *Calendar, start, end time.Time) []Event {
+	overrides := map[string]map[int64]Event{}
+	for _, e := range cal.Events {
+		if e.RecurrenceID.IsZero() {
+			continue
+		}
+		if overrides[e.UID] == nil {
+			overrides[e.UID] = map[int64]Event{}
+		}
+		overrides[e.UID][e.RecurrenceID.Unix()] = e
+	}
+
+	var out []Event
+	emit := func(e Event) {
+		if !e.Cancelled && e.Start.Before(end) && (e.End.After(start) || !e.Start.Before(start)) {
+			out = append(out, e)
+		}
+	}
+
+	for _, e := range cal.Events {
+		if !e.RecurrenceID.IsZero() {
+			// Overrides of a series are emitted in its place below; ones
+			// whose series isn't in the feed stand alone.
+			if !hasSeries(cal, e.UID) {
+				emit(e)
+			}
+			continue
+		}
+		if e.RRule == "" {
+			emit(e)
+			continue
+		}
+		excluded := map[int64]bool{}
+		for _, t := range e.ExDates {
+			excluded[t.Unix()] = true
+		}
+		for _, occStart := range occurrences(e, end) {
+			if excluded[occStart.Unix()] {
+				continue
+			}
+			if o, ok := overrides[e.UID][occStart.Unix()]; ok {
+				emit(o)
+				continue
+			}
+			occ := e
+			occ.Start = occStart
+			occ.End = shiftEnd(e, occStart)
+			occ.RecurrenceID = occStart
+			occ.RRule = ""
+			emit(occ)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+func hasSeries(cal *Calendar, uid string) bool {
+	for _, e := range cal.Events {
+		if e.UID == uid && e.RecurrenceID.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// shiftEnd gives an occurrence starting at occStart the same length as e.
+// All-day lengths are kept in calendar days so DST changes don't skew them.
+func shiftEnd(e Event, occStart time.Time) time.Time {
+	if e.AllDay {
+		days := int(e.End.Sub(e.Start).Hours()+12) / 24
+		return occStart.AddDate(0, 0, days)
+	}
+	return occStart.Add(e.End.Sub(e.Start))
+}
+
+// occurrences lists the start times of e's recurrence, beginning with
+// DTSTART, up to (but not including) before. COUNT is applied from
+// DTSTART, so occurrences before the window still use it up.
+func occurrences(e Event, before time.Time) []time.Time {
+	loc := e.Start.Location()
+	r, ok := parseRule(e.RRule, loc)
+	if !ok {
+		return []time.Time{e.Start}
+	}
+	dtstart := e.Start
+	y, m, d := dtstart.Date()
+	hh, mm, ss := dtstart.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, 0, loc)
+	}
+
+	var out []time.Time
+	n := 0
+	// add records a candidate and reports whether expansion should stop.
+	add := func(t time.Time) bool {
+		if t.Before(dtstart) {
+			return false
+		}
+		if !r.until.IsZero() && t.After(r.until) {
+			return true
+		}
+		if !t.Before(before) {
+			return true
+		}
+		out = append(out, t)
+		n++
+		return r.count > 0 && n >= r.count
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		var candidates []time.Time
+		switch r.freq {
+		case "DAILY":
+			candidates = []time.Time{at(y, m, d+period*r.interval)}
+		case "WEEKLY":
+			// Weeks start on Monday (the RFC 5545 default WKST).
+			offset := (int(dtstart.Weekday()) + 6) % 7
+			weekStart := d - offset + period*7*r.interval
+			days := r.byDay
+			if len(days) == 0 {
+				days = []weekdayNum{{day: dtstart.Weekday()}}
+			}
+			for _, wd := range days {
+				candidates = append(candidates, at(y, m, weekStart+(int(wd.day)+6)%7))
+			}
+		case "MONTHLY":
+			first := time.Date(y, m+time.Month(period*r.interval), 1, 0, 0, 0, 0, loc)
+			candidates = monthDays(r, first.Year(), first.Month(), d, at)
+		case "YEARLY":
+			year := y + period*r.interval
+			months := r.byMonth
+			if len(months) == 0 {
+				months = []time.Month{m}
+			}
+			for _, month := range months {
+				candidates = append(candidates, monthDays(r, year, month, d, at)...)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+		for _, t := range candidates {
+			if add(t) {
+				return out
+			}
+		}
+	}
+	return out
+}
+
+// monthDays lists the candidate days in one month: BYMONTHDAY days
+// (negative counts back from the month's end), else BYDAY weekdays (all of
+// them, or the nth when numbered), else DTSTART's day of month. Days the
+// month doesn't have are skipped rather than rolled over.
+func monthDays(r rule, year int, month time.Month, dtDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	daysIn := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	var days []int
+	switch {
+	case len(r.byMonthDay) > 0:
+		for _, md := range r.byMonthDay {
+			if md < 0 {
+				md = daysIn + md + 1
+			}
+			days = append(days, md)
+		}
+	case len(r.byDay) > 0:
+		firstWeekday := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+		for _, wd := range r.byDay {
+			first := 1 + (int(wd.day)-int(firstWeekday)+7)%7
+			var matches []int
+			for day := first; day <= daysIn; day += 7 {
+				matches = append(matches, day)
+			}
+			switch {
+			case wd.n > 0 && wd.n <= len(matches):
+				days = append(days, matches[wd.n-1])
+			case wd.n < 0 && -wd.n <= len(matches):
+				days = append(days, matches[len(matches)+wd.n])
+			case wd.n == 0:
+				days = append(days, matches...)
+			}
+		}
+	default:
+		days = []int{dtDay}
+	}
+
+	var out []time.Time
+	for _, day := range days {
+		if day >= 1 && day <= daysIn {
+			out = append(out, at(year, month, day))
+		}
+	}
+	return out
+}
@@ -0,0 +1,265 @@
+package ics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// windowsZones maps the Windows time zone names Outlook and Exchange put in
+// TZID to IANA zones, for the zones feeds commonly use. See CLDR's
+// windowsZones.xml for the full table.
+var windowsZones = map[string]string{
+	"Dateline Standard Time":          "Etc/GMT+12",
+	"Hawaiian Standard Time":          "Pacific/Honolulu",
+	"Alaskan Standard Time":           "America/Anchorage",
+	"Pacific Standard Time":           "America/Los_Angeles",
+	"US Mountain Standard Time":       "America/Phoenix",
+	"Mountain Standard Time":          "America/Denver",
+	"Central Standard Time":           "America/Chicago",
+	"Central America Standard Time":   "America/Guatemala",
+	"Canada Central Standard Time":    "America/Regina",
+	"Mexico Standard Time":            "America/Mexico_City",
+	"Central Standard Time (Mexico)":  "America/Mexico_City",
+	"Eastern Standard Time":           "America/New_York",
+	"US Eastern Standard Time":        "America/Indiana/Indianapolis",
+	"SA Pacific Standard Time":        "America/Bogota",
+	"Atlantic Standard Time":          "America/Halifax",
+	"Newfoundland Standard Time":      "America/St_Johns",
+	"E. South America Standard Time":  "America/Sao_Paulo",
+	"Argentina Standard Time":         "America/Buenos_Aires",
+	"UTC":                             "UTC",
+	"GMT Standard Time":               "Europe/London",
+	"Greenwich Standard Time":         "Atlantic/Reykjavik",
+	"W. Europe Standard Time":         "Europe/Berlin",
+	"Central Europe Standard Time":    "Europe/Budapest",
+	"Central European Standard Time":  "Europe/Warsaw",
+	"Romance Standard Time":           "Europe/Paris",
+	"GTB Standard Time":               "Europe/Bucharest",
+	"FLE Standard Time":               "Europe/Kiev",
+	"E. Europe Standard Time":         "Europe/Chisinau",
+	"Israel Standard Time":            "Asia/Jerusalem",
+	"South Africa Standard Time":      "Africa/Johannesburg",
+	"Russian Standard Time":           "Europe/Moscow",
+	"Turkey Standard Time":            "Europe/Istanbul",
+	"Arabian Standard Time":           "Asia/Dubai",
+	"Pakistan Standard Time":          "Asia/Karachi",
+	"India Standard Time":             "Asia/Kolkata",
+	"SE Asia Standard Time":           "Asia/Bangkok",
+	"China Standard Time":             "Asia/Shanghai",
+	"Singapore Standard Time":         "Asia/Singapore",
+	"Taipei Standard Time":            "Asia/Taipei",
+	"Tokyo Standard Time":             "Asia/Tokyo",
+	"Korea Standard Time":             "Asia/Seoul",
+	"AUS Central Standard Time":       "Australia/Darwin",
+	"E. Australia Standard Time":      "Australia/Brisbane",
+	"AUS Eastern Standard Time":       "Australia/Sydney",
+	"New Zealand Standard Time":       "Pacific/Auckland",
+	"W. Australia Standard Time":      "Australia/Perth",
+	"Cen. Australia Standard Time":    "Australia/Adelaide",
+	"Tasmania Standard Time":          "Australia/Hobart",
+	"Hawaii-Aleutian Standard Time":   "Pacific/Honolulu",
+	"Pacific Standard Time (Mexico)":  "America/Tijuana",
+	"Mountain Standard Time (Mexico)": "America/Mazatlan",
+}
+
+// loadZone resolves an IANA or Windows zone name.
+func loadZone(name string) (*time.Location, bool) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+	if name == "" {
+		return nil, false
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc, true
+	}
+	if iana, ok := windowsZones[name]; ok {
+		if loc, err := time.LoadLocation(iana); err == nil {
+			return loc, true
+		}
+	}
+	return nil, false
+}
+
+// zones resolves TZID parameters: IANA and Windows names first, then the
+// feed's own VTIMEZONE definitions, else the calendar's default zone.
+type zones struct {
+	defaultLoc *time.Location
+	defined    map[string]*time.Location
+}
+
+func (z zones) lookup(tzid string) *time.Location {
+	if tzid == "" {
+		return z.defaultLoc
+	}
+	if loc, ok := loadZone(tzid); ok {
+		return loc
+	}
+	if loc, ok := z.defined[tzid]; ok {
+		return loc
+	}
+	return z.defaultLoc
+}
+
+// vtimezone is a VTIMEZONE component: a TZID and its STANDARD and DAYLIGHT
+// observances.
+type vtimezone struct {
+	id          string
+	observances []observance
+}
+
+// observance is a STANDARD or DAYLIGHT block. Its onsets are DTSTART and
+// its RRULE and RDATE repeats, in local time at offsetFrom.
+type observance struct {
+	daylight   bool
+	name       string
+	start      string
+	rrule      string
+	rdates     []string
+	offsetFrom int
+	offsetTo   int
+}
+
+// Transitions are generated over the range a version 1 TZif file holds.
+var (
+	firstTransition = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastTransition  = time.Unix(1<<31-1, 0)
+)
+
+// location builds a time.Location from the observances. A single offset
+// becomes a fixed zone; otherwise each onset from 1970 through 2037 is a
+// transition, so times on either side of a DST change get their own
+// offset and recurrences keep their wall-clock time.
+func (z vtimezone) location() (*time.Location, bool) {
+	if len(z.observances) == 0 {
+		return nil, false
+	}
+
+	type zoneType struct {
+		offset int
+		dst    bool
+		name   string
+	}
+	type transition struct {
+		at  time.Time
+		typ int
+	}
+	var types []zoneType
+	typeOf := func(o observance) int {
+		t := zoneType{offset: o.offsetTo, dst: o.daylight, name: o.name}
+		if t.name == "" {
+			t.name = formatOffset(o.offsetTo)
+		}
+		for i, existing := range types {
+			if existing == t {
+				return i
+			}
+		}
+		types = append(types, t)
+		return len(types) - 1
+	}
+	// Standard time goes first: Go uses it for times before the first
+	// transition.
+	sort.SliceStable(z.observances, func(i, j int) bool {
+		return !z.observances[i].daylight && z.observances[j].daylight
+	})
+
+	var transitions []transition
+	for _, o := range z.observances {
+		typ := typeOf(o)
+		from := time.FixedZone("", o.offsetFrom)
+		start, err := time.ParseInLocation("20060102T150405", o.start, from)
+		if err != nil {
+			continue
+		}
+		onsets := occurrences(Event{Start: start, RRule: o.rrule}, lastTransition)
+		for _, rdate := range o.rdates {
+			if t, err := time.ParseInLocation("20060102T150405", rdate, from); err == nil {
+				onsets = append(onsets, t)
+			}
+		}
+		for _, at := range onsets {
+			if !at.Before(firstTransition) && at.Before(lastTransition) {
+				transitions = append(transitions, transition{at: at, typ: typ})
+			}
+		}
+	}
+	if len(transitions) == 0 {
+		return time.FixedZone(z.id, z.observances[0].offsetTo), true
+	}
+	sort.Slice(transitions, func(i, j int) bool { return transitions[i].at.Before(transitions[j].at) })
+
+	// A version 1 TZif file: header, transition times and their types, the
+	// types, and their abbreviations. See RFC 8536.
+	var abbrevs bytes.Buffer
+	abbrevIndex := make([]int, len(types))
+	for i, t := range types {
+		abbrevIndex[i] = abbrevs.Len()
+		abbrevs.WriteString(t.name)
+		abbrevs.WriteByte(0)
+	}
+	var b bytes.Buffer
+	b.WriteString("TZif")
+	b.Write(make([]byte, 16))
+	for _, n := range []int{0, 0, 0, len(transitions), len(types), abbrevs.Len()} {
+		binary.Write(&b, binary.BigEndian, uint32(n))
+	}
+	for _, t := range transitions {
+		binary.Write(&b, binary.BigEndian, int32(t.at.Unix()))
+	}
+	for _, t := range transitions {
+		b.WriteByte(byte(t.typ))
+	}
+	for i, t := range types {
+		binary.Write(&b, binary.BigEndian, int32(t.offset))
+		dst := byte(0)
+		if t.dst {
+			dst = 1
+		}
+		b.Write([]byte{dst, byte(abbrevIndex[i])})
+	}
+	b.Write(abbrevs.Bytes())
+
+	loc, err := time.LoadLocationFromTZData(z.id, b.Bytes())
+	return loc, err == nil
+}
+
+// parseOffset parses a UTC offset such as "-0500" or "+053000" into
+// seconds east of UTC.
+func parseOffset(v string) (int, bool) {
+	v = strings.TrimSpace(v)
+	if len(v) != 5 && len(v) != 7 {
+		return 0, false
+	}
+	sign := 1
+	switch v[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, false
+	}
+	secs := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if 1+2*i >= len(v) {
+			break
+		}
+		n, err := strconv.Atoi(v[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, false
+		}
+		secs += n * unit
+	}
+	return sign * secs, true
+}
+
+// formatOffset renders seconds east of UTC as "+hhmm".
+func formatOffset(secs int) string {
+	sign := '+'
+	if secs < 0 {
+		sign, secs = '-', -secs
+	}
+	return string(sign) + strconv.Itoa(100 + secs/3600)[1:] + strconv.Itoa(100 + secs%3600/60)[1:]
+}
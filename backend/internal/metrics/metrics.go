@@ -0,0 +1,77 @@
+// Package metrics exposes Prometheus metrics for HTTP routes and calls to
+// external services.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// External services reported by ObserveExternal.
+const (
+	ServicePlaid     = "plaid"
+	ServiceGemini    = "gemini"
+	ServiceGoogle    = "google"
+	ServiceMicrosoft = "microsoft"
+	ServiceMaps      = "maps"
+	ServiceICS       = "ics"
+	ServiceCampus    = "campus"
+)
+
+var (
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dayboard_http_request_duration_seconds",
+		Help:    "Time to serve HTTP requests, by route and status. The _count series doubles as a request counter.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	externalCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dayboard_external_requests_total",
+		Help: "Calls to external APIs, by service and outcome (success or failure).",
+	}, []string{"service", "outcome"})
+
+	externalDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dayboard_external_request_duration_seconds",
+		Help:    "Time spent in calls to external APIs, by service.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service"})
+)
+
+// Middleware records each request's duration labeled by method, route
+// template (such as /api/v1/subs/:id) and status. Requests that match no
+// route share the label "unmatched" so arbitrary paths can't create new
+// series.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveExternal records one call to service that started at start. The
+// call failed if err is non-nil or the response status is 400 or above.
+func ObserveExternal(service string, start time.Time, resp *http.Response, err error) {
+	outcome := "success"
+	if err != nil || (resp != nil && resp.StatusCode >= http.StatusBadRequest) {
+		outcome = "failure"
+	}
+	externalCalls.WithLabelValues(service, outcome).Inc()
+	externalDuration.WithLabelValues(service).Observe(time.Since(start).Seconds())
+}
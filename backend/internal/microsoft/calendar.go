@@ -0,0 +1,276 @@
+// Package microsoft connects Microsoft 365 and Outlook calendars through
+// the Microsoft identity platform and the Graph API. It mirrors the google
+// package: events land in calendar_events with source "microsoft".
+package microsoft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/metrics"
+)
+
+// ErrRefreshTokenRevoked is returned when Microsoft rejects a refresh token
+// (revoked by the user or expired). The user must reconnect their account.
+var ErrRefreshTokenRevoked = errors.New("microsoft refresh token revoked")
+
+// scopes requested from Microsoft identity. offline_access is what makes
+// it return a refresh token.
+const scopes = "offline_access User.Read Calendars.Read"
+
+// graphTimeLayout is how Graph formats dateTimeTimeZone values: a local
+// time with no offset and up to seven fractional digits.
+const graphTimeLayout = "2006-01-02T15:04:05.9999999"
+
+// CalendarService handles Microsoft Graph calendar operations
+type CalendarService struct {
+	clientID     string
+	clientSecret string
+	redirectURI  string
+	tenant       string
+}
+
+// CalendarEvent represents an Outlook calendar event
+type CalendarEvent struct {
+	ID          string    `json:"id"`
+	Subject     string    `json:"subject"`
+	StartTime   time.Time `json:"start"`
+	EndTime     time.Time `json:"end"`
+	Location    string    `json:"location"`
+	JoinURL     string    `json:"joinUrl"`
+	WebLink     string    `json:"webLink"`
+	AllDay      bool      `json:"allDay"`
+	IsCancelled bool      `json:"isCancelled"`
+}
+
+// TokenResponse represents the OAuth token response from Microsoft
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+// NewCalendarService creates a new Microsoft calendar service.
+// MICROSOFT_TENANT defaults to "common", which accepts both work/school
+// and personal accounts.
+func NewCalendarService() *CalendarService {
+	tenant := os.Getenv("MICROSOFT_TENANT")
+	if tenant == "" {
+		tenant = "common"
+	}
+	return &CalendarService{
+		clientID:     os.Getenv("MICROSOFT_CLIENT_ID"),
+		clientSecret: os.Getenv("MICROSOFT_CLIENT_SECRET"),
+		redirectURI:  os.Getenv("MICROSOFT_REDIRECT_URI"),
+		tenant:       tenant,
+	}
+}
+
+func (s *CalendarService) authorityURL(endpoint string) string {
+	return "https://login.microsoftonline.com/" + url.PathEscape(s.tenant) + "/oauth2/v2.0/" + endpoint
+}
+
+// GetAuthURL returns the OAuth authorization URL for Microsoft
+func (s *CalendarService) GetAuthURL(state string) string {
+	params := url.Values{}
+	params.Set("client_id", s.clientID)
+	params.Set("redirect_uri", s.redirectURI)
+	params.Set("response_type", "code")
+	params.Set("response_mode", "query")
+	params.Set("scope", scopes)
+	params.Set("state", state)
+	params.Set("prompt", "select_account")
+
+	return s.authorityURL("authorize") + "?" + params.Encode()
+}
+
+// ExchangeCodeForToken exchanges an authorization code for access tokens
+func (s *CalendarService) ExchangeCodeForToken(ctx context.Context, code string) (*TokenResponse, error) {
+	data := url.Values{}
+	data.Set("client_id", s.clientID)
+	data.Set("client_secret", s.clientSecret)
+	data.Set("code", code)
+	data.Set("grant_type", "authorization_code")
+	data.Set("redirect_uri", s.redirectURI)
+	data.Set("scope", scopes)
+
+	return s.requestToken(ctx, data)
+}
+
+// RefreshAccessToken uses a refresh token to get a new access token
+func (s *CalendarService) RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	data := url.Values{}
+	data.Set("client_id", s.clientID)
+	data.Set("client_secret", s.clientSecret)
+	data.Set("refresh_token", refreshToken)
+	data.Set("grant_type", "refresh_token")
+	data.Set("scope", scopes)
+
+	return s.requestToken(ctx, data)
+}
+
+// requestToken posts data to the token endpoint. An invalid_grant error
+// means the code or refresh token is no longer usable.
+func (s *CalendarService) requestToken(ctx context.Context, data url.Values) (*TokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.authorityURL("token"),
+		strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	start := time.Now()
+	resp, err := httpx.Client.Do(req)
+	metrics.ObserveExternal(metrics.ServiceMicrosoft, start, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.Error == "invalid_grant" {
+			return nil, ErrRefreshTokenRevoked
+		}
+		return nil, fmt.Errorf("microsoft token error: %s", resp.Status)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, err
+	}
+
+	return &tokenResp, nil
+}
+
+// GetTodaysEvents fetches today's events from the user's default calendar
+func (s *CalendarService) GetTodaysEvents(ctx context.Context, accessToken string) ([]CalendarEvent, error) {
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return s.GetEventsInRange(ctx, accessToken, startOfDay, startOfDay.AddDate(0, 0, 1))
+}
+
+// GetEventsInRange fetches the events overlapping [start, end) from the
+// user's calendar view, which expands recurring events into occurrences.
+// Result pages are followed until all are read.
+func (s *CalendarService) GetEventsInRange(ctx context.Context, accessToken string, start, end time.Time) ([]CalendarEvent, error) {
+	params := url.Values{}
+	params.Set("startDateTime", start.UTC().Format(time.RFC3339))
+	params.Set("endDateTime", end.UTC().Format(time.RFC3339))
+	params.Set("$select", "id,subject,start,end,location,isAllDay,isCancelled,onlineMeeting,webLink")
+	params.Set("$orderby", "start/dateTime")
+	params.Set("$top", "100")
+
+	var events []CalendarEvent
+	next := "https://graph.microsoft.com/v1.0/me/calendarView?" + params.Encode()
+	for next != "" {
+		page, nextLink, err := s.getEventsPage(ctx, accessToken, next)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		next = nextLink
+	}
+	return events, nil
+}
+
+// getEventsPage fetches one page of a calendar view and returns the link
+// to the next page, if any.
+func (s *CalendarService) getEventsPage(ctx context.Context, accessToken, pageURL string) ([]CalendarEvent, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	// Have Graph return every time in UTC so they parse unambiguously.
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+
+	start := time.Now()
+	resp, err := httpx.Do(httpx.Client, req, httpx.DefaultRetryPolicy())
+	metrics.ObserveExternal(metrics.ServiceMicrosoft, start, resp, err)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("microsoft graph calendar error: %s", resp.Status)
+	}
+
+	type dateTimeTimeZone struct {
+		DateTime string `json:"dateTime"`
+		TimeZone string `json:"timeZone"`
+	}
+	var viewResp struct {
+		NextLink string `json:"@odata.nextLink"`
+		Value    []struct {
+			ID       string           `json:"id"`
+			Subject  string           `json:"subject"`
+			Start    dateTimeTimeZone `json:"start"`
+			End      dateTimeTimeZone `json:"end"`
+			Location struct {
+				DisplayName string `json:"displayName"`
+			} `json:"location"`
+			IsAllDay      bool `json:"isAllDay"`
+			IsCancelled   bool `json:"isCancelled"`
+			OnlineMeeting *struct {
+				JoinURL string `json:"joinUrl"`
+			} `json:"onlineMeeting"`
+			WebLink string `json:"webLink"`
+		} `json:"value"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&viewResp); err != nil {
+		return nil, "", err
+	}
+
+	var events []CalendarEvent
+	for _, item := range viewResp.Value {
+		event := CalendarEvent{
+			ID:          item.ID,
+			Subject:     item.Subject,
+			Location:    item.Location.DisplayName,
+			WebLink:     item.WebLink,
+			AllDay:      item.IsAllDay,
+			IsCancelled: item.IsCancelled,
+			StartTime:   parseGraphTime(item.Start.DateTime, item.Start.TimeZone),
+			EndTime:     parseGraphTime(item.End.DateTime, item.End.TimeZone),
+		}
+		if item.OnlineMeeting != nil {
+			event.JoinURL = item.OnlineMeeting.JoinURL
+		}
+		events = append(events, event)
+	}
+
+	return events, viewResp.NextLink, nil
+}
+
+// parseGraphTime parses a Graph dateTimeTimeZone. Unknown time zones are
+// treated as UTC, which is what the Prefer header asks for; unparseable
+// values yield the zero time.
+func parseGraphTime(dateTime, timeZone string) time.Time {
+	loc := time.UTC
+	if timeZone != "" {
+		if l, err := time.LoadLocation(timeZone); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(graphTimeLayout, dateTime, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,296 @@
+package microsoft
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/auth"
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/store"
+)
+
+// Provider names the Microsoft connection in oauth_tokens and
+// oauth_states, and is the source of its events in calendar_events.
+const Provider = "microsoft"
+
+// stateTTL bounds how long an OAuth state value remains valid.
+const stateTTL = 10 * time.Minute
+
+// OAuthHandlers handles Microsoft OAuth flows
+type OAuthHandlers struct {
+	db              *db.DB
+	calendarService *CalendarService
+}
+
+// NewOAuthHandlers creates new OAuth handlers
+func NewOAuthHandlers(database *db.DB) *OAuthHandlers {
+	return &OAuthHandlers{
+		db:              database,
+		calendarService: NewCalendarService(),
+	}
+}
+
+// InitiateMicrosoftAuth starts the Microsoft OAuth flow
+func (h *OAuthHandlers) InitiateMicrosoftAuth(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Generate state parameter for security and persist it so the
+	// callback can verify it
+	state := generateState(userID)
+	if err := store.CreateOAuthState(c.Request.Context(), h.db, state, userID, Provider); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start Microsoft authorization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"auth_url": h.calendarService.GetAuthURL(state),
+		"state":    state,
+	})
+}
+
+// HandleMicrosoftCallback handles the OAuth callback from Microsoft
+func (h *OAuthHandlers) HandleMicrosoftCallback(c *gin.Context) {
+	code := c.Query("code")
+	state := c.Query("state")
+
+	if code == "" {
+		// Microsoft reports a denied consent as ?error= instead of a code.
+		if desc := c.Query("error_description"); desc != "" {
+			log.Printf("microsoft oauth: authorization failed: %s", desc)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code not provided"})
+		return
+	}
+
+	userID, err := h.verifyState(c.Request.Context(), state)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state parameter"})
+		return
+	}
+
+	tokenResp, err := h.calendarService.ExchangeCodeForToken(c.Request.Context(), code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange code for token"})
+		return
+	}
+
+	if err := h.storeTokens(c.Request.Context(), userID, tokenResp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store tokens"})
+		return
+	}
+
+	// The initial sync can be retried later, so don't fail the connection.
+	if err := h.syncCalendarEvents(c.Request.Context(), userID, tokenResp.AccessToken); err != nil {
+		log.Printf("microsoft oauth: initial sync for user %s failed: %v", userID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Microsoft calendar connected successfully",
+		"user_id": userID,
+	})
+}
+
+// SyncCalendarEvents manually triggers a calendar sync
+func (h *OAuthHandlers) SyncCalendarEvents(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	accessToken, err := h.getAccessToken(c.Request.Context(), userID)
+	if errors.Is(err, ErrRefreshTokenRevoked) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Microsoft calendar access was revoked, please reconnect"})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Microsoft calendar not connected"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refresh Microsoft access token"})
+		return
+	}
+
+	if err := h.syncCalendarEvents(c.Request.Context(), userID, accessToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync calendar events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar events synced successfully"})
+}
+
+// Helper functions
+
+// generateState returns a state value of the form "<nonce>:<userID>".
+func generateState(userID uuid.UUID) string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	return base64.URLEncoding.EncodeToString(randomBytes) + ":" + userID.String()
+}
+
+// verifyState checks that state was issued by InitiateMicrosoftAuth, is
+// less than stateTTL old and names the same user it was issued to. The
+// stored state is deleted on lookup so it cannot be replayed. It returns
+// that user's ID.
+func (h *OAuthHandlers) verifyState(ctx context.Context, state string) (uuid.UUID, error) {
+	parts := strings.SplitN(state, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return uuid.Nil, fmt.Errorf("invalid state format")
+	}
+
+	userID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid state user: %w", err)
+	}
+
+	issuedTo, createdAt, err := store.ConsumeOAuthState(ctx, h.db, state, Provider)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if time.Since(createdAt) > stateTTL {
+		return uuid.Nil, fmt.Errorf("state expired")
+	}
+	if issuedTo != userID {
+		return uuid.Nil, fmt.Errorf("state user mismatch")
+	}
+
+	// Opportunistically clear out abandoned states.
+	if err := store.DeleteExpiredOAuthStates(ctx, h.db, time.Now().Add(-stateTTL)); err != nil {
+		log.Printf("microsoft oauth: clearing expired states failed: %v", err)
+	}
+
+	return userID, nil
+}
+
+func (h *OAuthHandlers) storeTokens(ctx context.Context, userID uuid.UUID, tokens *TokenResponse) error {
+	// In production, encrypt these tokens before storing
+	_, err := h.db.ExecContext(ctx, `
+		INSERT INTO oauth_tokens (user_id, provider, access_token_enc, refresh_token_enc, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, provider)
+		DO UPDATE SET
+			access_token_enc = EXCLUDED.access_token_enc,
+			refresh_token_enc = EXCLUDED.refresh_token_enc,
+			expiry = EXCLUDED.expiry
+	`, userID, Provider,
+		[]byte(tokens.AccessToken),  // Should be encrypted
+		[]byte(tokens.RefreshToken), // Should be encrypted
+		strings.Fields(scopes),
+		time.Now().Add(time.Duration(tokens.ExpiresIn)*time.Second))
+
+	return err
+}
+
+// getAccessToken returns a usable access token for the user. Expired (or
+// nearly expired) tokens are refreshed with the stored refresh token and the
+// new token is persisted. ErrRefreshTokenRevoked means the user must
+// reconnect Microsoft.
+func (h *OAuthHandlers) getAccessToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	var accessToken, refreshToken []byte
+	var expiry time.Time
+
+	err := h.db.QueryRowContext(ctx, `
+		SELECT access_token_enc, refresh_token_enc, expiry
+		FROM oauth_tokens
+		WHERE user_id = $1 AND provider = $2
+	`, userID, Provider).Scan(&accessToken, &refreshToken, &expiry)
+	if err != nil {
+		return "", err
+	}
+
+	// Refresh a minute early so the token doesn't expire mid-request
+	if time.Now().Add(time.Minute).Before(expiry) {
+		// In production, decrypt the token
+		return string(accessToken), nil
+	}
+
+	if len(refreshToken) == 0 {
+		return "", ErrRefreshTokenRevoked
+	}
+
+	tokenResp, err := h.calendarService.RefreshAccessToken(ctx, string(refreshToken))
+	if err != nil {
+		return "", err
+	}
+
+	// Microsoft rotates refresh tokens on every use, but keep the stored
+	// one if a response ever omits it.
+	var newRefresh []byte
+	if tokenResp.RefreshToken != "" {
+		newRefresh = []byte(tokenResp.RefreshToken) // Should be encrypted
+	}
+	_, err = h.db.ExecContext(ctx, `
+		UPDATE oauth_tokens
+		SET access_token_enc = $3,
+			refresh_token_enc = COALESCE($4, refresh_token_enc),
+			expiry = $5
+		WHERE user_id = $1 AND provider = $2
+	`, userID, Provider,
+		[]byte(tokenResp.AccessToken), // Should be encrypted
+		newRefresh,
+		time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second))
+	if err != nil {
+		return "", err
+	}
+
+	return tokenResp.AccessToken, nil
+}
+
+// syncCalendarEvents stores today's events from the user's Outlook
+// calendar. The calendar view has no deletion feed, so stored events from
+// today that Graph no longer returns, or returns as cancelled, are removed.
+func (h *OAuthHandlers) syncCalendarEvents(ctx context.Context, userID uuid.UUID, accessToken string) error {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 0, 1)
+
+	events, err := h.calendarService.GetEventsInRange(ctx, accessToken, start, end)
+	if err != nil {
+		return err
+	}
+
+	var keep []string
+	for _, event := range events {
+		if event.IsCancelled {
+			continue
+		}
+		keep = append(keep, event.ID)
+
+		_, err := h.db.ExecContext(ctx, `
+			INSERT INTO calendar_events (id, user_id, source, ext_id, start_ts, end_ts, title, join_url, location, all_day)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (user_id, source, ext_id)
+			DO UPDATE SET
+				start_ts = EXCLUDED.start_ts,
+				end_ts = EXCLUDED.end_ts,
+				title = EXCLUDED.title,
+				join_url = EXCLUDED.join_url,
+				location = EXCLUDED.location,
+				all_day = EXCLUDED.all_day,
+				updated_at = NOW()
+		`, uuid.New(), userID, Provider, event.ID,
+			event.StartTime, store.EventEnd(event.StartTime, event.EndTime, event.AllDay),
+			event.Subject, event.JoinURL, event.Location, event.AllDay)
+		if err != nil {
+			return err
+		}
+	}
+
+	return store.PruneEvents(ctx, h.db, userID, Provider, start, end, keep)
+}
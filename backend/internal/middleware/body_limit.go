@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxBodyBytes is the request body cap when MAX_REQUEST_BODY_BYTES
+// is unset.
+const defaultMaxBodyBytes = 1 << 20
+
+// BodyLimit caps request bodies at MaxBodyBytes. Requests that declare a
+// larger Content-Length are rejected with 413 up front. Otherwise the body
+// is wrapped in http.MaxBytesReader, and a handler that hits the limit
+// while binding has its 400 response turned into a 413.
+func BodyLimit() gin.HandlerFunc {
+	return bodyLimit(MaxBodyBytes())
+}
+
+// MaxBodyBytes returns the cap BodyLimit applies, read from
+// MAX_REQUEST_BODY_BYTES (default 1 MiB).
+func MaxBodyBytes() int64 {
+	if v := os.Getenv("MAX_REQUEST_BODY_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultMaxBodyBytes
+}
+
+func bodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, c.Request.Body, limit)}
+		c.Request.Body = body
+		c.Writer = &bodyLimitWriter{ResponseWriter: c.Writer, body: body}
+		c.Next()
+	}
+}
+
+// limitedBody records whether reading it ran into the size limit.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// bodyLimitWriter reports a 400 written after the body overflowed as
+// 413, since the request was rejected for its size rather than content.
+type bodyLimitWriter struct {
+	gin.ResponseWriter
+	body *limitedBody
+}
+
+func (w *bodyLimitWriter) WriteHeader(code int) {
+	if code == http.StatusBadRequest && w.body.exceeded {
+		code = http.StatusRequestEntityTooLarge
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight.
+const corsMaxAge = "600"
+
+// CORS lets the browser origins in CORS_ALLOWED_ORIGINS (comma-separated,
+// e.g. "https://app.dayboard.dev,http://localhost:3000") call the API with
+// credentials. Preflight OPTIONS requests from those origins are answered
+// directly with 204; ones from other origins get 403. With the variable
+// unset no origin is allowed; there is deliberately no "*" wildcard, since
+// browsers refuse it on credentialed requests anyway.
+func CORS() gin.HandlerFunc {
+	return cors(CORSAllowedOrigins())
+}
+
+// CORSAllowedOrigins returns the normalized origins CORS allows.
+func CORSAllowedOrigins() []string {
+	var origins []string
+	for _, o := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if o = normalizeOrigin(o); o != "" && o != "*" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+func normalizeOrigin(o string) string {
+	return strings.TrimRight(strings.ToLower(strings.TrimSpace(o)), "/")
+}
+
+func cors(origins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[o] = true
+	}
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if !allowed[normalizeOrigin(origin)] {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// Serve the request without CORS headers; the browser keeps the
+			// response from the page.
+			c.Next()
+			return
+		}
+
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", "Retry-After")
+			c.Next()
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		h.Set("Access-Control-Max-Age", corsMaxAge)
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
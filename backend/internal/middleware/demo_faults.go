@@ -0,0 +1,54 @@
+// Package middleware holds Gin middleware shared across route groups.
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DemoFaults injects artificial latency and random failures so frontend
+// developers can exercise loading and error states against demo mode.
+// DEMO_LATENCY_MS delays every request; DEMO_ERROR_RATE (0 to 1) is the
+// fraction of requests answered with a 503. Both are off by default, in
+// which case the middleware is a no-op.
+func DemoFaults() gin.HandlerFunc {
+	return demoFaults(DemoFaultSettings())
+}
+
+// DemoFaultSettings returns the latency and error rate DemoFaults injects.
+func DemoFaultSettings() (latency time.Duration, errorRate float64) {
+	if v := os.Getenv("DEMO_LATENCY_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			latency = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v := os.Getenv("DEMO_ERROR_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate > 0 {
+			errorRate = min(rate, 1)
+		}
+	}
+	return latency, errorRate
+}
+
+func demoFaults(latency time.Duration, errorRate float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+		}
+		if errorRate > 0 && rand.Float64() < errorRate {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Injected demo error"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// demoRouter serves GET /agenda behind DemoFaults as configured by the
+// environment.
+func demoRouter() *gin.Engine {
+	r := gin.New()
+	r.GET("/agenda", DemoFaults(), func(c *gin.Context) { c.JSON(http.StatusOK, []string{}) })
+	return r
+}
+
+func serveDemo(r http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/agenda", nil))
+	return w
+}
+
+func TestDemoFaultsErrorRate(t *testing.T) {
+	t.Setenv("DEMO_ERROR_RATE", "1")
+	r := demoRouter()
+	for i := 0; i < 5; i++ {
+		if w := serveDemo(r); w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("request %d: status %d, want 503", i, w.Code)
+		}
+	}
+}
+
+func TestDemoFaultsLatency(t *testing.T) {
+	t.Setenv("DEMO_LATENCY_MS", "50")
+	start := time.Now()
+	if w := serveDemo(demoRouter()); w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("responded after %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestDemoFaultsOffByDefault(t *testing.T) {
+	t.Setenv("DEMO_LATENCY_MS", "")
+	t.Setenv("DEMO_ERROR_RATE", "")
+	if latency, rate := DemoFaultSettings(); latency != 0 || rate != 0 {
+		t.Errorf("settings = %v, %v; want both off", latency, rate)
+	}
+	if w := serveDemo(demoRouter()); w.Code != http.StatusOK {
+		t.Errorf("status %d, want 200", w.Code)
+	}
+}
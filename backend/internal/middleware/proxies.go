@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+)
+
+// TrustedProxies returns the proxies in TRUSTED_PROXIES (comma-separated
+// IPs or CIDRs, e.g. "10.0.0.0/8") whose X-Forwarded-For and X-Real-IP
+// headers are believed when working out a client's IP. With the variable
+// unset no proxy is trusted and the client IP is the connection's remote
+// address, so clients can't pick their own IP for rate limits by sending
+// those headers.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	tests := []struct {
+		env  string
+		want string
+	}{
+		// httptest requests come from 192.0.2.1.
+		{"", "192.0.2.1"},
+		{"10.0.0.0/8", "192.0.2.1"},
+		{"10.0.0.0/8, 192.0.2.1", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TRUSTED_PROXIES", tt.env)
+		r := gin.New()
+		if err := r.SetTrustedProxies(TrustedProxies()); err != nil {
+			t.Fatalf("TRUSTED_PROXIES=%q: %v", tt.env, err)
+		}
+		var got string
+		r.GET("/", func(c *gin.Context) { got = c.ClientIP() })
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("TRUSTED_PROXIES=%q: ClientIP = %s, want %s", tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"dayboard/backend/migrations"
+)
+
+var (
+	createTable    = regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\);`)
+	uniqueIndex    = regexp.MustCompile(`(?s)CREATE UNIQUE INDEX IF NOT EXISTS \w+\s+ON (\w+)\s*\((.*?)\);`)
+	dropKey        = regexp.MustCompile(`ALTER TABLE (\w+) DROP CONSTRAINT IF EXISTS (\w+)`)
+	tableKey       = regexp.MustCompile(`^(PRIMARY KEY|UNIQUE) \((.*)\)`)
+	columnKey      = regexp.MustCompile(`^(\w+) .*\b(PRIMARY KEY|UNIQUE)\b`)
+	conflictTarget = regexp.MustCompile(`ON CONFLICT\s*\(([^)]*)\)`)
+)
+
+// keyColumns normalizes a column list so keys compare regardless of order.
+func keyColumns(list string) string {
+	cols := strings.Split(list, ",")
+	for i, c := range cols {
+		cols[i] = strings.ToLower(strings.TrimSpace(c))
+	}
+	sort.Strings(cols)
+	return strings.Join(cols, ",")
+}
+
+// uniqueKeys replays the migrations' primary keys, unique constraints and
+// unique indexes, by table and then by constraint name. Unnamed unique
+// constraints are keyed by their columns.
+func uniqueKeys(t *testing.T) map[string]map[string]string {
+	t.Helper()
+	names, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	keys := map[string]map[string]string{}
+	add := func(table, name, cols string) {
+		if keys[table] == nil {
+			keys[table] = map[string]string{}
+		}
+		if name == "" {
+			name = cols
+		}
+		keys[table][name] = cols
+	}
+	for _, name := range names {
+		body, err := fs.ReadFile(migrations.FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sql := string(body)
+		for _, m := range createTable.FindAllStringSubmatch(sql, -1) {
+			table := m[1]
+			for _, line := range strings.Split(m[2], "\n") {
+				line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+				var kind, cols string
+				if k := tableKey.FindStringSubmatch(line); k != nil {
+					kind, cols = k[1], keyColumns(k[2])
+				} else if k := columnKey.FindStringSubmatch(line); k != nil {
+					kind, cols = k[2], keyColumns(k[1])
+				} else {
+					continue
+				}
+				if kind == "PRIMARY KEY" {
+					add(table, table+"_pkey", cols)
+				} else {
+					add(table, "", cols)
+				}
+			}
+		}
+		for _, m := range uniqueIndex.FindAllStringSubmatch(sql, -1) {
+			add(m[1], "", keyColumns(m[2]))
+		}
+		for _, m := range dropKey.FindAllStringSubmatch(sql, -1) {
+			delete(keys[m[1]], m[2])
+		}
+	}
+	return keys
+}
+
+func TestOnConflictTargetsHaveUniqueKeys(t *testing.T) {
+	keys := uniqueKeys(t)
+
+	checked := 0
+	err := filepath.WalkDir("../..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// Each INSERT runs to the end of its raw string literal.
+		for _, stmt := range strings.Split(string(src), "INSERT INTO ")[1:] {
+			stmt, _, _ = strings.Cut(stmt, "`")
+			target := conflictTarget.FindStringSubmatch(stmt)
+			if target == nil {
+				continue
+			}
+			table := strings.Fields(strings.ReplaceAll(stmt, "(", " "))[0]
+			cols := keyColumns(target[1])
+			found := false
+			for _, key := range keys[table] {
+				found = found || key == cols
+			}
+			if !found {
+				t.Errorf("%s: ON CONFLICT (%s) on %s has no matching unique key in the migrations", path, target[1], table)
+			}
+			checked++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked == 0 {
+		t.Fatal("found no ON CONFLICT clauses to check")
+	}
+}
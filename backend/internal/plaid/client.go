@@ -3,19 +3,107 @@ package plaid
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"dayboard/backend/internal/httpx"
+	"dayboard/backend/internal/metrics"
+	"dayboard/backend/internal/store"
 )
 
 // PlaidService handles Plaid API operations
 type PlaidService struct {
-	clientID string
-	secret   string
-	env      string
-	baseURL  string
+	clientID  string
+	secret    string
+	env       string
+	baseURL   string
+	detection DetectionConfig
+
+	webhookKeys *verificationKeys
+}
+
+// DetectionConfig tunes DetectRecurringTransactions. The zero value keeps
+// every recurring group regardless of amount or category.
+type DetectionConfig struct {
+	// MinAmount is the smallest charge (in dollars) considered a
+	// subscription. Groups below it are treated as noise.
+	MinAmount float64
+	// RequireSubscriptionCategory only keeps groups whose Plaid category
+	// is one typically used for subscriptions (see subscriptionCategories).
+	RequireSubscriptionCategory bool
+	// AmountTolerance is the relative difference (0.05 = 5%) within which
+	// charges from the same merchant are treated as the same subscription,
+	// so small price changes from tax or FX do not split a series. Zero
+	// requires amounts to match exactly.
+	AmountTolerance float64
+	// IntervalTolerance is how far (as a fraction of the average interval)
+	// the gap between charges may drift and still count as regular, so
+	// yearly charges get more slack than weekly ones. The allowance is
+	// never less than minIntervalSlackDays.
+	IntervalTolerance float64
+}
+
+// defaultAmountTolerance lets a $9.99 charge and a $10.04 charge group
+// together while keeping distinct plans from the same merchant apart.
+const defaultAmountTolerance = 0.05
+
+// defaultIntervalTolerance gives a monthly subscription about three days of
+// drift (billing on the 31st vs the 1st) and a yearly one about five weeks.
+const defaultIntervalTolerance = 0.1
+
+// minIntervalSlackDays is the smallest interval allowance, so weekly
+// charges can slip a weekend.
+const minIntervalSlackDays = 2
+
+// subscriptionCategories lists the Plaid categories that usually carry
+// recurring charges. Matching is case-insensitive against any level of the
+// category hierarchy.
+var subscriptionCategories = map[string]bool{
+	"subscription":               true,
+	"service":                    true,
+	"entertainment":              true,
+	"digital purchase":           true,
+	"music, video, and dvds":     true,
+	"cable":                      true,
+	"internet services":          true,
+	"telecommunication services": true,
+	"gyms and fitness centers":   true,
+}
+
+// loadDetectionConfig reads detection settings from the environment:
+// PLAID_SUB_MIN_AMOUNT (dollars, default 0),
+// PLAID_SUB_REQUIRE_CATEGORY ("true" to enable),
+// PLAID_SUB_AMOUNT_TOLERANCE (fraction, default 0.05) and
+// PLAID_SUB_INTERVAL_TOLERANCE (fraction, default 0.1).
+func loadDetectionConfig() DetectionConfig {
+	cfg := DetectionConfig{
+		AmountTolerance:   defaultAmountTolerance,
+		IntervalTolerance: defaultIntervalTolerance,
+	}
+	if v := os.Getenv("PLAID_SUB_INTERVAL_TOLERANCE"); v != "" {
+		if tol, err := strconv.ParseFloat(v, 64); err == nil && tol >= 0 {
+			cfg.IntervalTolerance = tol
+		}
+	}
+	if v := os.Getenv("PLAID_SUB_AMOUNT_TOLERANCE"); v != "" {
+		if tol, err := strconv.ParseFloat(v, 64); err == nil && tol >= 0 {
+			cfg.AmountTolerance = tol
+		}
+	}
+	if v := os.Getenv("PLAID_SUB_MIN_AMOUNT"); v != "" {
+		if amount, err := strconv.ParseFloat(v, 64); err == nil && amount >= 0 {
+			cfg.MinAmount = amount
+		}
+	}
+	cfg.RequireSubscriptionCategory = strings.EqualFold(os.Getenv("PLAID_SUB_REQUIRE_CATEGORY"), "true")
+	return cfg
 }
 
 // LinkTokenResponse represents the response from creating a link token
@@ -34,12 +122,14 @@ type AccessTokenResponse struct {
 
 // Account represents a Plaid account
 type Account struct {
-	ID           string  `json:"account_id"`
-	Name         string  `json:"name"`
-	Type         string  `json:"type"`
-	Subtype      string  `json:"subtype"`
-	Balance      float64 `json:"balance"`
-	CurrencyCode string  `json:"iso_currency_code"`
+	ID           string     `json:"account_id"`
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Subtype      string     `json:"subtype"`
+	Balance      float64    `json:"balance"`
+	CurrencyCode string     `json:"iso_currency_code"`
+	ItemID       string     `json:"item_id,omitempty"`
+	LastSynced   *time.Time `json:"last_synced,omitempty"`
 }
 
 // Transaction represents a Plaid transaction
@@ -75,13 +165,26 @@ func NewPlaidService() *PlaidService {
 	}
 
 	return &PlaidService{
-		clientID: os.Getenv("PLAID_CLIENT_ID"),
-		secret:   os.Getenv("PLAID_SECRET"),
-		env:      env,
-		baseURL:  baseURL,
+		clientID:  os.Getenv("PLAID_CLIENT_ID"),
+		secret:    os.Getenv("PLAID_SECRET"),
+		env:       env,
+		baseURL:   baseURL,
+		detection: loadDetectionConfig(),
+
+		webhookKeys: newVerificationKeys(),
 	}
 }
 
+// Env returns the Plaid environment requests are sent to.
+func (s *PlaidService) Env() string {
+	return s.env
+}
+
+// Detection returns the recurring-detection settings in effect.
+func (s *PlaidService) Detection() DetectionConfig {
+	return s.detection
+}
+
 // CreateLinkToken creates a link token for Plaid Link
 func (s *PlaidService) CreateLinkToken(ctx context.Context, userID string) (*LinkTokenResponse, error) {
 	payload := map[string]interface{}{
@@ -97,6 +200,10 @@ func (s *PlaidService) CreateLinkToken(ctx context.Context, userID string) (*Lin
 		"required_if_supported_products": []string{"transactions"},
 		"redirect_uri":                   os.Getenv("PLAID_REDIRECT_URI"),
 	}
+	// Items linked with a webhook URL get pushed transaction updates.
+	if webhook := os.Getenv("PLAID_WEBHOOK_URL"); webhook != "" {
+		payload["webhook"] = webhook
+	}
 
 	var result LinkTokenResponse
 	_, err := s.makeRequest(ctx, "/link/token/create", payload, &result)
@@ -112,10 +219,30 @@ func (s *PlaidService) ExchangePublicToken(ctx context.Context, publicToken stri
 	}
 
 	var result AccessTokenResponse
-	_, err := s.makeRequest(ctx, "/link/token/exchange", payload, &result)
+	_, err := s.makeRequest(ctx, "/item/public_token/exchange", payload, &result)
 	return &result, err
 }
 
+// RemoveItem revokes an item's access token so Plaid stops syncing (and
+// billing for) it. An item Plaid no longer knows about counts as removed.
+func (s *PlaidService) RemoveItem(ctx context.Context, accessToken string) error {
+	payload := map[string]interface{}{
+		"client_id":    s.clientID,
+		"secret":       s.secret,
+		"access_token": accessToken,
+	}
+
+	var result struct {
+		RequestID string `json:"request_id"`
+	}
+	_, err := s.makeRequest(ctx, "/item/remove", payload, &result)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode == "ITEM_NOT_FOUND" || apiErr.ErrorCode == "INVALID_ACCESS_TOKEN") {
+		return nil
+	}
+	return err
+}
+
 // GetAccounts retrieves accounts for an access token
 func (s *PlaidService) GetAccounts(ctx context.Context, accessToken string) ([]Account, error) {
 	payload := map[string]interface{}{
@@ -159,69 +286,101 @@ func (s *PlaidService) GetAccounts(ctx context.Context, accessToken string) ([]A
 	return accounts, nil
 }
 
-// GetTransactions retrieves transactions for the last 30 days
-func (s *PlaidService) GetTransactions(ctx context.Context, accessToken string) ([]Transaction, error) {
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -30) // Last 30 days
+// SyncResult is the set of changes since a /transactions/sync cursor.
+type SyncResult struct {
+	Added    []Transaction
+	Modified []Transaction
+	// Removed holds the IDs of transactions Plaid no longer reports.
+	Removed []string
+	// NextCursor is where the next sync should resume.
+	NextCursor string
+}
 
-	payload := map[string]interface{}{
-		"client_id":    s.clientID,
-		"secret":       s.secret,
-		"access_token": accessToken,
-		"start_date":   startDate.Format("2006-01-02"),
-		"end_date":     endDate.Format("2006-01-02"),
-		"count":        500,
-		"offset":       0,
-	}
+// syncPageSize is the largest page /transactions/sync allows.
+const syncPageSize = 500
+
+// SyncTransactions fetches every change since cursor using Plaid's
+// /transactions/sync endpoint, following pages until has_more is false. An
+// empty cursor returns the item's full history.
+func (s *PlaidService) SyncTransactions(ctx context.Context, accessToken, cursor string) (*SyncResult, error) {
+	result := &SyncResult{NextCursor: cursor}
+	for {
+		payload := map[string]interface{}{
+			"client_id":    s.clientID,
+			"secret":       s.secret,
+			"access_token": accessToken,
+			"count":        syncPageSize,
+		}
+		if result.NextCursor != "" {
+			payload["cursor"] = result.NextCursor
+		}
 
-	var response struct {
-		Accounts []struct {
-			ID   string `json:"account_id"`
-			Name string `json:"name"`
-		} `json:"accounts"`
-		Transactions []struct {
-			ID             string   `json:"transaction_id"`
-			AccountID      string   `json:"account_id"`
-			Amount         float64  `json:"amount"`
-			Date           string   `json:"date"`
-			Name           string   `json:"name"`
-			MerchantName   string   `json:"merchant_name"`
-			Category       []string `json:"category"`
-			Pending        bool     `json:"pending"`
-			PaymentChannel string   `json:"payment_channel"`
-		} `json:"transactions"`
-		TotalTransactions int    `json:"total_transactions"`
-		RequestID         string `json:"request_id"`
-	}
-
-	_, err := s.makeRequest(ctx, "/transactions/get", payload, &response)
-	if err != nil {
-		return nil, err
-	}
+		var response struct {
+			Added    []plaidTransaction `json:"added"`
+			Modified []plaidTransaction `json:"modified"`
+			Removed  []struct {
+				ID string `json:"transaction_id"`
+			} `json:"removed"`
+			NextCursor string `json:"next_cursor"`
+			HasMore    bool   `json:"has_more"`
+			RequestID  string `json:"request_id"`
+		}
 
-	var transactions []Transaction
-	for _, txn := range response.Transactions {
-		date, _ := time.Parse("2006-01-02", txn.Date)
-		transactions = append(transactions, Transaction{
-			ID:             txn.ID,
-			AccountID:      txn.AccountID,
-			Amount:         txn.Amount,
-			Date:           date,
-			Name:           txn.Name,
-			MerchantName:   txn.MerchantName,
-			Category:       txn.Category,
-			Pending:        txn.Pending,
-			PaymentChannel: txn.PaymentChannel,
-		})
+		_, err := s.makeRequest(ctx, "/transactions/sync", payload, &response)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, txn := range response.Added {
+			result.Added = append(result.Added, txn.toTransaction())
+		}
+		for _, txn := range response.Modified {
+			result.Modified = append(result.Modified, txn.toTransaction())
+		}
+		for _, r := range response.Removed {
+			result.Removed = append(result.Removed, r.ID)
+		}
+		result.NextCursor = response.NextCursor
+
+		if !response.HasMore {
+			return result, nil
+		}
 	}
+}
 
-	return transactions, nil
+// plaidTransaction is a transaction as returned by the Plaid API.
+type plaidTransaction struct {
+	ID             string   `json:"transaction_id"`
+	AccountID      string   `json:"account_id"`
+	Amount         float64  `json:"amount"`
+	Date           string   `json:"date"`
+	Name           string   `json:"name"`
+	MerchantName   string   `json:"merchant_name"`
+	Category       []string `json:"category"`
+	Pending        bool     `json:"pending"`
+	PaymentChannel string   `json:"payment_channel"`
+}
+
+func (txn plaidTransaction) toTransaction() Transaction {
+	date, _ := time.Parse("2006-01-02", txn.Date)
+	return Transaction{
+		ID:             txn.ID,
+		AccountID:      txn.AccountID,
+		Amount:         txn.Amount,
+		Date:           date,
+		Name:           txn.Name,
+		MerchantName:   txn.MerchantName,
+		Category:       txn.Category,
+		Pending:        txn.Pending,
+		PaymentChannel: txn.PaymentChannel,
+	}
 }
 
 // DetectRecurringTransactions analyzes transactions to find recurring subscriptions
 func (s *PlaidService) DetectRecurringTransactions(transactions []Transaction) []RecurringSubscription {
-	// Group transactions by merchant and amount
-	groups := make(map[string][]Transaction)
+	// Group transactions by merchant, then cluster each merchant's charges
+	// by amount so near-identical prices land in the same group.
+	byMerchant := make(map[string][]Transaction)
 
 	for _, txn := range transactions {
 		// Skip pending transactions and income
@@ -229,34 +388,97 @@ func (s *PlaidService) DetectRecurringTransactions(transactions []Transaction) [
 			continue
 		}
 
-		// Create a key based on merchant name and amount
-		key := fmt.Sprintf("%s_%.2f", strings.ToLower(txn.MerchantName), txn.Amount)
-		groups[key] = append(groups[key], txn)
+		name := txn.MerchantName
+		if name == "" {
+			name = txn.Name
+		}
+		key := store.NormalizeMerchant(name)
+		byMerchant[key] = append(byMerchant[key], txn)
 	}
 
 	var subscriptions []RecurringSubscription
 
-	for _, txns := range groups {
-		// Need at least 2 transactions to detect a pattern
-		if len(txns) < 2 {
-			continue
+	for _, merchantTxns := range byMerchant {
+		for _, txns := range clusterByAmount(merchantTxns, s.detection.AmountTolerance) {
+			if sub, ok := s.detectGroup(txns); ok {
+				subscriptions = append(subscriptions, sub)
+			}
 		}
+	}
+
+	return subscriptions
+}
+
+// detectGroup decides whether a cluster of same-merchant charges is a
+// subscription and, if so, describes it using the cluster's average amount.
+func (s *PlaidService) detectGroup(txns []Transaction) (RecurringSubscription, bool) {
+	// Two charges are too easily a coincidence; require a longer run
+	if len(txns) < minRecurringCharges {
+		return RecurringSubscription{}, false
+	}
+
+	amount := averageAmount(txns)
+
+	// Ignore tiny charges and, if configured, non-subscription categories
+	if amount < s.detection.MinAmount {
+		return RecurringSubscription{}, false
+	}
+	if s.detection.RequireSubscriptionCategory && !isSubscriptionCategory(txns[0].Category) {
+		return RecurringSubscription{}, false
+	}
+
+	// Check if transactions occur at regular intervals
+	if !isRecurring(txns, s.detection.IntervalTolerance) {
+		return RecurringSubscription{}, false
+	}
+	return RecurringSubscription{
+		MerchantName: txns[0].MerchantName,
+		Amount:       amount,
+		Frequency:    determineFrequency(txns),
+		LastCharge:   txns[0].Date,
+		NextDue:      predictNextDue(txns),
+		Category:     txns[0].Category,
+	}, true
+}
 
-		// Check if transactions occur at regular intervals
-		if isRecurring(txns) {
-			subscription := RecurringSubscription{
-				MerchantName: txns[0].MerchantName,
-				Amount:       txns[0].Amount,
-				Frequency:    determineFrequency(txns),
-				LastCharge:   txns[0].Date,
-				NextDue:      predictNextDue(txns),
-				Category:     txns[0].Category,
+// minRecurringCharges is how many charges a group needs before it is
+// considered a subscription.
+const minRecurringCharges = 3
+
+// clusterByAmount splits one merchant's charges into groups whose amounts
+// are within tolerance (relative) of the group's running average. Charges
+// are visited in ascending amount order so each cluster is contiguous.
+func clusterByAmount(txns []Transaction, tolerance float64) [][]Transaction {
+	sorted := make([]Transaction, len(txns))
+	copy(sorted, txns)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Amount < sorted[j].Amount })
+
+	var clusters [][]Transaction
+	var sum float64
+	for _, txn := range sorted {
+		n := len(clusters)
+		if n > 0 {
+			mean := sum / float64(len(clusters[n-1]))
+			// Half a cent of slack absorbs float noise when tolerance is zero.
+			if math.Abs(txn.Amount-mean) <= mean*tolerance+0.005 {
+				clusters[n-1] = append(clusters[n-1], txn)
+				sum += txn.Amount
+				continue
 			}
-			subscriptions = append(subscriptions, subscription)
 		}
+		clusters = append(clusters, []Transaction{txn})
+		sum = txn.Amount
 	}
+	return clusters
+}
 
-	return subscriptions
+// averageAmount returns the mean charge of txns rounded to the cent.
+func averageAmount(txns []Transaction) float64 {
+	var sum float64
+	for _, txn := range txns {
+		sum += txn.Amount
+	}
+	return math.Round(sum/float64(len(txns))*100) / 100
 }
 
 // RecurringSubscription represents a detected recurring subscription
@@ -269,6 +491,32 @@ type RecurringSubscription struct {
 	Category     []string  `json:"category"`
 }
 
+// APIError is a non-200 response from Plaid. ErrorType and ErrorCode are
+// taken from the response body when it has them.
+type APIError struct {
+	Status    string `json:"-"`
+	ErrorType string `json:"error_type"`
+	ErrorCode string `json:"error_code"`
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("plaid API error: %s (%s)", e.Status, e.ErrorCode)
+	}
+	return fmt.Sprintf("plaid API error: %s", e.Status)
+}
+
+// retrySafeEndpoints lists the Plaid endpoints makeRequest may retry. Reads
+// and item removal (already-removed items count as removed) can be
+// repeated; exchanging a public token can't, since the token is single-use.
+var retrySafeEndpoints = map[string]bool{
+	"/link/token/create":            true,
+	"/item/remove":                  true,
+	"/accounts/get":                 true,
+	"/transactions/sync":            true,
+	"/webhook_verification_key/get": true,
+}
+
 // Helper function to make HTTP requests to Plaid API
 func (s *PlaidService) makeRequest(ctx context.Context, endpoint string, payload interface{}, result interface{}) (interface{}, error) {
 	jsonData, err := json.Marshal(payload)
@@ -283,14 +531,22 @@ func (s *PlaidService) makeRequest(ctx context.Context, endpoint string, payload
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	// Every Plaid call is a POST; only endpoints that are safe to repeat
+	// are retried.
+	policy := httpx.DefaultRetryPolicy()
+	policy.RetryNonIdempotent = retrySafeEndpoints[endpoint]
+	start := time.Now()
+	resp, err := httpx.Do(httpx.Client, req, policy)
+	metrics.ObserveExternal(metrics.ServicePlaid, start, resp, err)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("plaid API error: %s", resp.Status)
+		apiErr := &APIError{Status: resp.Status}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return nil, apiErr
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
@@ -301,19 +557,20 @@ func (s *PlaidService) makeRequest(ctx context.Context, endpoint string, payload
 }
 
 // Helper functions for recurring transaction detection
-func isRecurring(transactions []Transaction) bool {
+
+// isRecurring reports whether the gaps between charges stay within
+// tolerance (a fraction of the average gap, at least minIntervalSlackDays)
+// of their average. It sorts transactions newest first in place, which the
+// other helpers rely on.
+func isRecurring(transactions []Transaction, tolerance float64) bool {
 	if len(transactions) < 2 {
 		return false
 	}
 
-	// Sort transactions by date
-	for i := 0; i < len(transactions)-1; i++ {
-		for j := i + 1; j < len(transactions); j++ {
-			if transactions[i].Date.Before(transactions[j].Date) {
-				transactions[i], transactions[j] = transactions[j], transactions[i]
-			}
-		}
-	}
+	// Sort transactions by date, newest first
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].Date.After(transactions[j].Date)
+	})
 
 	// Check if intervals between transactions are consistent
 	intervals := make([]int, 0)
@@ -322,7 +579,7 @@ func isRecurring(transactions []Transaction) bool {
 		intervals = append(intervals, days)
 	}
 
-	// Check if intervals are similar (within 5 days tolerance)
+	// Check if intervals are similar (within the cadence's tolerance)
 	if len(intervals) < 1 {
 		return false
 	}
@@ -333,8 +590,9 @@ func isRecurring(transactions []Transaction) bool {
 	}
 	avgInterval /= len(intervals)
 
+	slack := max(int(math.Round(float64(avgInterval)*tolerance)), minIntervalSlackDays)
 	for _, interval := range intervals {
-		if abs(interval-avgInterval) > 5 {
+		if abs(interval-avgInterval) > slack {
 			return false
 		}
 	}
@@ -374,6 +632,15 @@ func predictNextDue(transactions []Transaction) time.Time {
 	return transactions[0].Date.AddDate(0, 0, avgDays)
 }
 
+func isSubscriptionCategory(category []string) bool {
+	for _, c := range category {
+		if subscriptionCategories[strings.ToLower(c)] {
+			return true
+		}
+	}
+	return false
+}
+
 func abs(x int) int {
 	if x < 0 {
 		return -x
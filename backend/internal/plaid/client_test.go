@@ -0,0 +1,204 @@
+package plaid
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// monthly returns n charges of amount from merchant, 30 days apart, the
+// newest on last.
+func monthly(merchant string, amount float64, n int, last time.Time, category ...string) []Transaction {
+	txns := make([]Transaction, n)
+	for i := range txns {
+		txns[i] = Transaction{
+			ID:           merchant + "-" + last.AddDate(0, 0, -30*i).Format("20060102"),
+			Amount:       amount,
+			Date:         last.AddDate(0, 0, -30*i),
+			Name:         merchant,
+			MerchantName: merchant,
+			Category:     category,
+		}
+	}
+	return txns
+}
+
+// detectedMerchants returns the merchants s detects in txns.
+func detectedMerchants(s *PlaidService, txns []Transaction) map[string]float64 {
+	found := make(map[string]float64)
+	for _, sub := range s.DetectRecurringTransactions(txns) {
+		found[sub.MerchantName] = sub.Amount
+	}
+	return found
+}
+
+func TestDetectMinimumAmount(t *testing.T) {
+	last := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var txns []Transaction
+	txns = append(txns, monthly("iCloud", 0.99, 4, last)...)
+	txns = append(txns, monthly("Netflix", 15.49, 4, last)...)
+
+	s := &PlaidService{detection: DetectionConfig{MinAmount: 1, IntervalTolerance: defaultIntervalTolerance}}
+	found := detectedMerchants(s, txns)
+	if _, ok := found["iCloud"]; ok {
+		t.Error("detected a $0.99 charge below the $1 minimum")
+	}
+	if found["Netflix"] != 15.49 {
+		t.Errorf("Netflix = %v, want 15.49 detected", found["Netflix"])
+	}
+
+	s.detection.MinAmount = 0
+	if _, ok := detectedMerchants(s, txns)["iCloud"]; !ok {
+		t.Error("without a minimum, the $0.99 charge should be detected")
+	}
+}
+
+func TestDetectRequireSubscriptionCategory(t *testing.T) {
+	last := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var txns []Transaction
+	txns = append(txns, monthly("Spotify", 10.99, 3, last, "Service", "Subscription")...)
+	txns = append(txns, monthly("Landlord", 1200, 3, last, "Payment", "Rent")...)
+
+	s := &PlaidService{detection: DetectionConfig{RequireSubscriptionCategory: true, IntervalTolerance: defaultIntervalTolerance}}
+	found := detectedMerchants(s, txns)
+	if _, ok := found["Spotify"]; !ok {
+		t.Error("Spotify in a subscription category was not detected")
+	}
+	if _, ok := found["Landlord"]; ok {
+		t.Error("rent was detected although its category isn't a subscription one")
+	}
+}
+
+func TestDetectGroupsNearbyAmounts(t *testing.T) {
+	last := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	// $9.99, $10.04, $9.99 from the same merchant: one subscription.
+	txns := monthly("Spotify", 9.99, 3, last)
+	txns[1].Amount = 10.04
+
+	s := &PlaidService{detection: DetectionConfig{AmountTolerance: defaultAmountTolerance, IntervalTolerance: defaultIntervalTolerance}}
+	subs := s.DetectRecurringTransactions(txns)
+	if len(subs) != 1 {
+		t.Fatalf("detected %d subscriptions, want 1: %+v", len(subs), subs)
+	}
+	if want := (9.99 + 10.04 + 9.99) / 3; math.Abs(subs[0].Amount-want) > 0.005 {
+		t.Errorf("amount = %v, want the average %.2f", subs[0].Amount, want)
+	}
+
+	// With no tolerance the charges split and none reaches three.
+	s.detection.AmountTolerance = 0
+	if subs := s.DetectRecurringTransactions(txns); len(subs) != 0 {
+		t.Errorf("exact-amount grouping detected %+v, want nothing", subs)
+	}
+}
+
+func TestClusterByAmountSeparatesTiers(t *testing.T) {
+	last := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	// A basic and a premium plan from one merchant stay separate.
+	txns := append(monthly("Hulu", 7.99, 3, last), monthly("Hulu", 17.99, 3, last)...)
+	clusters := clusterByAmount(txns, defaultAmountTolerance)
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2", len(clusters))
+	}
+	for _, c := range clusters {
+		if len(c) != 3 {
+			t.Errorf("cluster of %d charges, want 3", len(c))
+		}
+	}
+}
+
+func TestMakeRequestRetriesOnlySafeEndpoints(t *testing.T) {
+	t.Setenv("HTTP_RETRY_MAX_ATTEMPTS", "3")
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error_code":"INTERNAL_SERVER_ERROR"}`))
+	}))
+	defer srv.Close()
+	s := &PlaidService{baseURL: srv.URL}
+
+	if _, err := s.ExchangePublicToken(context.Background(), "public-sandbox"); err == nil {
+		t.Error("exchange succeeded against a failing server")
+	}
+	if _, err := s.GetAccounts(context.Background(), "access-sandbox"); err == nil {
+		t.Error("accounts succeeded against a failing server")
+	}
+	if calls["/item/public_token/exchange"] != 1 {
+		t.Errorf("public token exchange sent %d times, want 1", calls["/item/public_token/exchange"])
+	}
+	if calls["/accounts/get"] != 3 {
+		t.Errorf("accounts/get sent %d times, want 3", calls["/accounts/get"])
+	}
+}
+
+func TestExchangePublicTokenEndpoint(t *testing.T) {
+	var path, method string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, method = r.URL.Path, r.Method
+		w.Write([]byte(`{"access_token":"access-sandbox-123","item_id":"item-123","request_id":"req"}`))
+	}))
+	defer srv.Close()
+	s := &PlaidService{baseURL: srv.URL}
+
+	res, err := s.ExchangePublicToken(context.Background(), "public-sandbox-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || path != "/item/public_token/exchange" {
+		t.Errorf("called %s %s, want POST /item/public_token/exchange", method, path)
+	}
+	if res.AccessToken != "access-sandbox-123" || res.ItemID != "item-123" {
+		t.Errorf("response = %+v", res)
+	}
+}
+
+func TestDetectRecurringTransactions(t *testing.T) {
+	last := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	// renamed spells each charge's merchant differently, as banks do.
+	renamed := func(txns []Transaction, names ...string) []Transaction {
+		for i := range txns {
+			txns[i].MerchantName = names[i%len(names)]
+		}
+		return txns
+	}
+	tests := []struct {
+		name  string
+		txns  []Transaction
+		count int
+	}{
+		{"three monthly charges", monthly("Netflix", 15.49, 3, last), 1},
+		{"two charges are not enough", monthly("Netflix", 15.49, 2, last), 0},
+		{"merchant spelling varies", renamed(monthly("Amazon Prime", 14.99, 3, last), "AMZN*PRIME", "Amazon Prime", "Amazon Prime #1234"), 1},
+		{"price changes by a cent", func() []Transaction {
+			txns := monthly("Spotify", 10.99, 4, last)
+			txns[0].Amount = 11.00
+			return txns
+		}(), 1},
+		{"pending charges are ignored", func() []Transaction {
+			txns := monthly("Hulu", 7.99, 3, last)
+			txns[0].Pending = true
+			return txns
+		}(), 0},
+		{"refunds are ignored", func() []Transaction {
+			txns := monthly("Hulu", 7.99, 3, last)
+			txns[1].Amount = -7.99
+			return txns
+		}(), 0},
+		{"irregular coffee purchases", []Transaction{
+			{ID: "c1", Amount: 5.25, Date: last, MerchantName: "Blue Bottle"},
+			{ID: "c2", Amount: 5.25, Date: last.AddDate(0, 0, -2), MerchantName: "Blue Bottle"},
+			{ID: "c3", Amount: 5.25, Date: last.AddDate(0, 0, -19), MerchantName: "Blue Bottle"},
+			{ID: "c4", Amount: 5.25, Date: last.AddDate(0, 0, -23), MerchantName: "Blue Bottle"},
+		}, 0},
+		{"two merchants", append(monthly("Netflix", 15.49, 3, last), monthly("Spotify", 10.99, 3, last)...), 2},
+	}
+	s := &PlaidService{detection: DetectionConfig{AmountTolerance: defaultAmountTolerance, IntervalTolerance: defaultIntervalTolerance}}
+	for _, tt := range tests {
+		if subs := s.DetectRecurringTransactions(tt.txns); len(subs) != tt.count {
+			t.Errorf("%s: detected %d subscriptions, want %d: %+v", tt.name, len(subs), tt.count, subs)
+		}
+	}
+}
@@ -2,8 +2,12 @@ package plaid
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"math"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -27,6 +31,11 @@ func NewOAuthHandlers(database *db.DB) *OAuthHandlers {
 	}
 }
 
+// Service returns the Plaid client the handlers use.
+func (h *OAuthHandlers) Service() *PlaidService {
+	return h.plaidService
+}
+
 // CreateLinkToken creates a Plaid Link token for the frontend
 func (h *OAuthHandlers) CreateLinkToken(c *gin.Context) {
 	userID, exists := auth.GetUserIDFromContext(c)
@@ -72,14 +81,17 @@ func (h *OAuthHandlers) ExchangePublicToken(c *gin.Context) {
 	}
 
 	// Store access token in database (encrypted in production)
-	err = h.storeAccessToken(c.Request.Context(), userID, accessTokenResp)
+	err = store.SavePlaidItem(c.Request.Context(), h.db, userID, accessTokenResp.ItemID, accessTokenResp.AccessToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store access token"})
 		return
 	}
 
 	// Sync initial transactions and accounts
-	err = h.syncAccountsAndTransactions(c.Request.Context(), userID, accessTokenResp.AccessToken)
+	err = h.syncAccountsAndTransactions(c.Request.Context(), userID, store.PlaidItem{
+		ItemID:      accessTokenResp.ItemID,
+		AccessToken: accessTokenResp.AccessToken,
+	})
 	if err != nil {
 		// Log error but don't fail the request - can retry sync later
 	}
@@ -90,7 +102,9 @@ func (h *OAuthHandlers) ExchangePublicToken(c *gin.Context) {
 	})
 }
 
-// SyncTransactions manually triggers a transaction sync
+// SyncTransactions manually triggers a transaction sync. By default every
+// linked item is synced; ?item_id= limits the sync to one of the user's
+// items and leaves the others' stored data alone.
 func (h *OAuthHandlers) SyncTransactions(c *gin.Context) {
 	userID, exists := auth.GetUserIDFromContext(c)
 	if !exists {
@@ -98,24 +112,49 @@ func (h *OAuthHandlers) SyncTransactions(c *gin.Context) {
 		return
 	}
 
-	// Get stored access token
-	accessToken, err := h.getAccessToken(c.Request.Context(), userID)
-	if err != nil {
+	var items []store.PlaidItem
+	if itemID := c.Query("item_id"); itemID != "" {
+		item, err := store.GetPlaidItem(c.Request.Context(), h.db, userID, itemID)
+		if errors.Is(err, store.ErrPlaidItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bank connection not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bank connection"})
+			return
+		}
+		items = []store.PlaidItem{*item}
+	} else {
+		var err error
+		items, err = h.linkedItems(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bank connections"})
+			return
+		}
+	}
+	if len(items) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No bank account connected"})
 		return
 	}
 
 	// Sync transactions and detect subscriptions
-	err = h.syncAccountsAndTransactions(c.Request.Context(), userID, accessToken)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync transactions"})
-		return
+	synced := make([]string, 0, len(items))
+	for _, item := range items {
+		if err := h.syncAccountsAndTransactions(c.Request.Context(), userID, item); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync transactions"})
+			return
+		}
+		synced = append(synced, item.ItemID)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Transactions synced successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Transactions synced successfully",
+		"items":   synced,
+	})
 }
 
-// GetConnectedAccounts returns the user's connected bank accounts
+// GetConnectedAccounts returns the user's bank accounts with balances as
+// of the last sync. ?refresh=true fetches fresh balances from Plaid first.
 func (h *OAuthHandlers) GetConnectedAccounts(c *gin.Context) {
 	userID, exists := auth.GetUserIDFromContext(c)
 	if !exists {
@@ -123,42 +162,200 @@ func (h *OAuthHandlers) GetConnectedAccounts(c *gin.Context) {
 		return
 	}
 
-	// Get stored access token
-	accessToken, err := h.getAccessToken(c.Request.Context(), userID)
+	if c.Query("refresh") == "true" {
+		items, err := h.linkedItems(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bank connections"})
+			return
+		}
+		for _, item := range items {
+			if err := h.syncAccounts(c.Request.Context(), userID, item); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounts"})
+				return
+			}
+		}
+	}
+
+	stored, err := store.GetAccounts(c.Request.Context(), h.db, userID)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{"accounts": []interface{}{}})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load accounts"})
+		return
+	}
+
+	accounts := make([]Account, 0, len(stored))
+	for _, acc := range stored {
+		lastSynced := acc.LastSynced
+		accounts = append(accounts, Account{
+			ID:           acc.AccountID,
+			Name:         acc.Name,
+			Type:         acc.Type,
+			Subtype:      acc.Subtype,
+			Balance:      float64(acc.BalanceCents) / 100,
+			CurrencyCode: acc.Currency,
+			ItemID:       acc.ItemID,
+			LastSynced:   &lastSynced,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// RedetectSubscriptions re-runs recurring detection over the user's stored
+// transactions with the current detection settings and reconciles the
+// results against existing subscriptions. It does not call Plaid. The
+// response counts the subscriptions created and updated and lists any
+// that could not be stored under "failed", so a partial success is
+// distinguishable from a full one.
+func (h *OAuthHandlers) RedetectSubscriptions(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Get accounts from Plaid
-	accounts, err := h.plaidService.GetAccounts(c.Request.Context(), accessToken)
+	res, err := h.detectFromStoredTransactions(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounts"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-run subscription detection"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+	c.JSON(http.StatusOK, res)
+}
+
+// Disconnect unlinks bank connections: each item is removed at Plaid so it
+// stops syncing and billing, then its stored token is deleted. The body is
+// optional: {"item_id": ...} limits it to one of the user's items (by
+// default all are disconnected, including a legacy connection from before
+// items were tracked) and {"purge": true} also deletes the
+// accounts and transactions synced from them. Subscriptions detected from
+// those transactions are kept; DELETE /subs?source=plaid clears them.
+func (h *OAuthHandlers) Disconnect(c *gin.Context) {
+	userID, exists := auth.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		ItemID string `json:"item_id"`
+		Purge  bool   `json:"purge"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var items []store.PlaidItem
+	if req.ItemID != "" {
+		item, err := store.GetPlaidItem(ctx, h.db, userID, req.ItemID)
+		if errors.Is(err, store.ErrPlaidItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bank connection not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bank connection"})
+			return
+		}
+		items = []store.PlaidItem{*item}
+	} else {
+		var err error
+		items, err = h.allConnections(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bank connections"})
+			return
+		}
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No bank account connected"})
+		return
+	}
+
+	disconnected := make([]string, 0, len(items))
+	for _, item := range items {
+		// The token is only deleted once Plaid has let go of the item, so
+		// a failure can be retried.
+		if err := h.plaidService.RemoveItem(ctx, item.AccessToken); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to disconnect bank account"})
+			return
+		}
+		if err := store.DeletePlaidItem(ctx, h.db, userID, item.ItemID, req.Purge); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bank connection"})
+			return
+		}
+		disconnected = append(disconnected, item.ItemID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bank account disconnected",
+		"items":   disconnected,
+		"purged":  req.Purge,
+	})
+}
+
+// RevokeConnections removes every item the user has linked at Plaid,
+// including a pre-item legacy connection. It tries them all and returns
+// the combined errors; stored tokens and data are left for the caller.
+func (h *OAuthHandlers) RevokeConnections(ctx context.Context, userID uuid.UUID) error {
+	items, err := h.allConnections(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, item := range items {
+		if err := h.plaidService.RemoveItem(ctx, item.AccessToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Helper functions
 
-func (h *OAuthHandlers) storeAccessToken(ctx context.Context, userID uuid.UUID, tokenResp *AccessTokenResponse) error {
-	// In production, encrypt the access token before storing
-	_, err := h.db.ExecContext(ctx, `
-		INSERT INTO oauth_tokens (user_id, provider, access_token_enc, scopes, expiry)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (user_id, provider) 
-		DO UPDATE SET 
-			access_token_enc = EXCLUDED.access_token_enc
-	`, userID, "plaid",
-		[]byte(tokenResp.AccessToken), // Should be encrypted
-		[]string{"transactions"},
-		time.Now().Add(365*24*time.Hour)) // Plaid tokens don't expire like OAuth tokens
+// linkedItems returns the user's Plaid items. Connections made before
+// items were tracked only have a token in oauth_tokens; they are returned
+// as a single item with an empty ItemID.
+func (h *OAuthHandlers) linkedItems(ctx context.Context, userID uuid.UUID) ([]store.PlaidItem, error) {
+	items, err := store.GetPlaidItems(ctx, h.db, userID)
+	if err != nil || len(items) > 0 {
+		return items, err
+	}
 
-	return err
+	accessToken, err := h.getLegacyAccessToken(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []store.PlaidItem{{AccessToken: accessToken}}, nil
+}
+
+// allConnections returns every Plaid connection the user holds a token
+// for: their items and, unlike linkedItems, a legacy connection (with an
+// empty ItemID) even when they also have items, since it is still live at
+// Plaid until removed there.
+func (h *OAuthHandlers) allConnections(ctx context.Context, userID uuid.UUID) ([]store.PlaidItem, error) {
+	items, err := store.GetPlaidItems(ctx, h.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := h.getLegacyAccessToken(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.AccessToken == accessToken {
+			return items, nil
+		}
+	}
+	return append(items, store.PlaidItem{AccessToken: accessToken}), nil
 }
 
-func (h *OAuthHandlers) getAccessToken(ctx context.Context, userID uuid.UUID) (string, error) {
+func (h *OAuthHandlers) getLegacyAccessToken(ctx context.Context, userID uuid.UUID) (string, error) {
 	var accessToken []byte
 
 	err := h.db.QueryRowContext(ctx, `
@@ -175,50 +372,165 @@ func (h *OAuthHandlers) getAccessToken(ctx context.Context, userID uuid.UUID) (s
 	return string(accessToken), nil
 }
 
-func (h *OAuthHandlers) syncAccountsAndTransactions(ctx context.Context, userID uuid.UUID, accessToken string) error {
-	// Get transactions from Plaid
-	transactions, err := h.plaidService.GetTransactions(ctx, accessToken)
+// syncAccountsAndTransactions pulls one item's transaction changes since
+// its last sync cursor and its accounts, stores them tagged with the item,
+// advances the cursor and re-runs subscription detection over the stored
+// history. The writes happen in one transaction, so a failure part way
+// leaves the previous sync's data and cursor in place to retry from.
+func (h *OAuthHandlers) syncAccountsAndTransactions(ctx context.Context, userID uuid.UUID, item store.PlaidItem) error {
+	// Get transaction changes and balances from Plaid
+	changes, err := h.plaidService.SyncTransactions(ctx, item.AccessToken, item.Cursor)
+	if err != nil {
+		return err
+	}
+	accounts, err := h.plaidService.GetAccounts(ctx, item.AccessToken)
 	if err != nil {
 		return err
 	}
 
-	// Store raw transactions
-	for _, txn := range transactions {
-		_, err := h.db.ExecContext(ctx, `
-			INSERT INTO transactions (user_id, source, ext_id, txn_date, merchant, amount_cents, category, raw)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			ON CONFLICT (user_id, source, ext_id) DO NOTHING
-		`, userID, "plaid", txn.ID, txn.Date, txn.MerchantName,
-			int(txn.Amount*100), txn.Category, nil) // Convert to cents
+	err = h.db.WithTx(ctx, func(tx *sql.Tx) error {
+		// Store raw transactions, updating any that changed since the last sync
+		for _, txn := range append(changes.Added, changes.Modified...) {
+			err := store.UpsertTransaction(ctx, tx, userID, store.Transaction{
+				Source:      "plaid",
+				ExtID:       txn.ID,
+				ItemID:      item.ItemID,
+				Date:        txn.Date,
+				Merchant:    txn.MerchantName,
+				AmountCents: int(math.Round(txn.Amount * 100)), // Convert to cents
+				Category:    store.JoinCategory(txn.Category),
+				Pending:     txn.Pending,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		// Removals are applied after all upserts: a pending transaction that
+		// posts under a new ID can be added and removed within the same sync
+		// (even across pages), and must end up deleted rather than re-inserted.
+		if err := store.DeleteTransactions(ctx, tx, userID, "plaid", changes.Removed); err != nil {
+			return err
+		}
 
+		// Connections from before items were tracked have nowhere to keep a
+		// cursor and resync from the start each time.
+		if item.ItemID != "" {
+			if err := store.SetPlaidItemCursor(ctx, tx, userID, item.ItemID, changes.NextCursor); err != nil {
+				return err
+			}
+		}
+
+		return storeAccounts(ctx, tx, userID, item, accounts)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Detect recurring subscriptions over the full stored history, since
+	// an incremental sync only returns what changed
+	_, err = h.detectFromStoredTransactions(ctx, userID)
+	return err
+}
+
+// syncAccounts fetches an item's accounts and stores their current
+// balances.
+func (h *OAuthHandlers) syncAccounts(ctx context.Context, userID uuid.UUID, item store.PlaidItem) error {
+	accounts, err := h.plaidService.GetAccounts(ctx, item.AccessToken)
+	if err != nil {
+		return err
+	}
+	return storeAccounts(ctx, h.db, userID, item, accounts)
+}
+
+// storeAccounts stores an item's accounts and their current balances.
+func storeAccounts(ctx context.Context, q db.Querier, userID uuid.UUID, item store.PlaidItem, accounts []Account) error {
+	for _, acc := range accounts {
+		err := store.UpsertAccount(ctx, q, userID, store.Account{
+			AccountID:    acc.ID,
+			ItemID:       item.ItemID,
+			Name:         acc.Name,
+			Type:         acc.Type,
+			Subtype:      acc.Subtype,
+			BalanceCents: int64(math.Round(acc.Balance * 100)), // Convert to cents
+			Currency:     acc.CurrencyCode,
+		})
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// detectionResult summarizes a reconcile pass over detected
+// subscriptions.
+type detectionResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	// Failed lists detected subscriptions that could not be stored.
+	Failed []detectionFailure `json:"failed"`
+}
+
+// detectionFailure is a detected subscription reconcileSubscriptions could
+// not store.
+type detectionFailure struct {
+	Merchant string `json:"merchant"`
+	Error    string `json:"error"`
+}
+
+// detectFromStoredTransactions runs recurring detection over transactions
+// already persisted for the user and reconciles the results.
+func (h *OAuthHandlers) detectFromStoredTransactions(ctx context.Context, userID uuid.UUID) (*detectionResult, error) {
+	stored, err := store.GetTransactions(ctx, h.db, userID, "plaid")
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]Transaction, 0, len(stored))
+	for _, t := range stored {
+		transactions = append(transactions, Transaction{
+			ID:           t.ExtID,
+			Amount:       float64(t.AmountCents) / 100,
+			Date:         t.Date,
+			Name:         t.Merchant,
+			MerchantName: t.Merchant,
+			Category:     store.SplitCategory(t.Category),
+			Pending:      t.Pending,
+		})
+	}
 
-	// Detect recurring subscriptions
-	subscriptions := h.plaidService.DetectRecurringTransactions(transactions)
+	return h.reconcileSubscriptions(ctx, userID, h.plaidService.DetectRecurringTransactions(transactions)), nil
+}
 
-	// Store detected subscriptions
-	for _, sub := range subscriptions {
+// reconcileSubscriptions stores detected subscriptions, updating existing
+// Plaid-sourced subscriptions for the same merchant instead of duplicating
+// them. A subscription that fails to store is logged and reported in the
+// result; the others are still stored.
+func (h *OAuthHandlers) reconcileSubscriptions(ctx context.Context, userID uuid.UUID, detected []RecurringSubscription) *detectionResult {
+	res := &detectionResult{Failed: []detectionFailure{}}
+	for _, sub := range detected {
+		nextDue := sub.NextDue
 		subscription := store.Subscription{
-			ID:          uuid.New(),
 			Merchant:    sub.MerchantName,
-			AmountCents: int(sub.Amount * 100), // Convert to cents
+			AmountCents: int(math.Round(sub.Amount * 100)), // Convert to cents
 			CadenceDays: frequencyToDays(sub.Frequency),
-			NextDue:     &sub.NextDue,
+			NextDue:     &nextDue,
 			Source:      "plaid",
 			IsActive:    true,
 		}
 
-		_, err := store.CreateSubscription(ctx, h.db, userID, subscription)
+		isNew, err := store.ReconcileDetectedSubscription(ctx, h.db, userID, subscription)
 		if err != nil {
-			// Log error but continue with other subscriptions
+			log.Printf("plaid: storing detected subscription %q for user %s failed: %v", sub.MerchantName, userID, err)
+			res.Failed = append(res.Failed, detectionFailure{Merchant: sub.MerchantName, Error: err.Error()})
 			continue
 		}
+		if isNew {
+			res.Created++
+		} else {
+			res.Updated++
+		}
 	}
-
-	return nil
+	return res
 }
 
 func frequencyToDays(frequency string) int {
@@ -0,0 +1,330 @@
+package plaid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/db/dbtest"
+	"dayboard/backend/internal/store"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testHandlers returns handlers on d whose Plaid client fails the test if
+// it is called.
+func testHandlers(t *testing.T, d *db.DB) *OAuthHandlers {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected Plaid call to %s", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	return &OAuthHandlers{
+		db:           d,
+		plaidService: &PlaidService{baseURL: srv.URL, detection: DetectionConfig{AmountTolerance: defaultAmountTolerance, IntervalTolerance: defaultIntervalTolerance}},
+	}
+}
+
+// transactionRows returns stored transaction rows (in transactionColumns
+// order) for n monthly charges of amountCents from merchant.
+func transactionRows(merchant string, amountCents, n int, last time.Time) [][]any {
+	rows := make([][]any, n)
+	for i := range rows {
+		date := last.AddDate(0, 0, -30*i)
+		rows[i] = []any{uuid.NewString(), "plaid", merchant + date.Format("0102"), nil, date, merchant, amountCents, "Service > Subscription", false}
+	}
+	return rows
+}
+
+var transactionCols = []string{"id", "source", "ext_id", "item_id", "txn_date", "merchant", "amount_cents", "category", "pending"}
+
+func TestRedetectSubscriptions(t *testing.T) {
+	last := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	stored := append(transactionRows("Netflix", 1549, 3, last), transactionRows("Hulu", 799, 3, last)...)
+
+	d, rec := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM transactions"):
+			return dbtest.Rows(transactionCols, stored...)
+		case strings.Contains(q.SQL, "WITH prev AS"):
+			return dbtest.Rows([]string{"count"}, []any{0})
+		case strings.Contains(q.SQL, "INSERT INTO subscriptions") && q.Args[2] == "Hulu":
+			return dbtest.Result{Err: errors.New("insert failed")}
+		}
+		return dbtest.Result{}
+	})
+	h := testHandlers(t, d)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/subs/redetect", nil)
+	c.Set("user_id", uuid.New())
+	h.RedetectSubscriptions(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var res detectionResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Created != 1 || res.Updated != 0 {
+		t.Errorf("created %d, updated %d; want 1 created", res.Created, res.Updated)
+	}
+	if len(res.Failed) != 1 || res.Failed[0].Merchant != "Hulu" {
+		t.Errorf("failed = %+v, want Hulu", res.Failed)
+	}
+	if n := rec.Count("INSERT INTO subscriptions"); n != 2 {
+		t.Errorf("ran %d subscription inserts, want 2", n)
+	}
+}
+
+func TestSyncTransactionsScopedToItem(t *testing.T) {
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		tokens = append(tokens, payload.AccessToken)
+		switch r.URL.Path {
+		case "/transactions/sync":
+			w.Write([]byte(`{"added":[{"transaction_id":"txn-b","account_id":"acct-b","amount":12.5,"date":"2024-06-01","merchant_name":"Cafe"}],"next_cursor":"cursor-b"}`))
+		case "/accounts/get":
+			w.Write([]byte(`{"accounts":[{"account_id":"acct-b","name":"Checking","balances":{"current":100}}]}`))
+		default:
+			t.Errorf("unexpected Plaid call to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	d, rec := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM plaid_items"):
+			cols := []string{"item_id", "access_token_enc", "sync_cursor", "created_at"}
+			if q.Args[1] != "item-b" {
+				return dbtest.Result{Columns: cols}
+			}
+			return dbtest.Rows(cols, []any{"item-b", []byte("access-b"), "", time.Now()})
+		case strings.Contains(q.SQL, "FROM transactions"):
+			return dbtest.Result{Columns: transactionCols}
+		}
+		return dbtest.Result{RowsAffected: 1}
+	})
+	h := &OAuthHandlers{db: d, plaidService: &PlaidService{baseURL: srv.URL}}
+
+	sync := func(itemID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/plaid/sync?item_id="+itemID, nil)
+		c.Set("user_id", uuid.New())
+		h.SyncTransactions(c)
+		return w
+	}
+
+	if w := sync("item-b"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	for _, token := range tokens {
+		if token != "access-b" {
+			t.Errorf("Plaid called with token %q, want only item-b's", token)
+		}
+	}
+	// Every write is scoped to item-b, so item-a's rows and cursor are
+	// left alone.
+	for _, q := range rec.Queries() {
+		switch {
+		case strings.Contains(q.SQL, "INSERT INTO transactions"):
+			if q.Args[3] != "item-b" {
+				t.Errorf("transaction stored under item %v, want item-b", q.Args[3])
+			}
+		case strings.Contains(q.SQL, "INSERT INTO accounts"):
+			if q.Args[1] != "item-b" {
+				t.Errorf("account stored under item %v, want item-b", q.Args[1])
+			}
+		case strings.Contains(q.SQL, "UPDATE plaid_items SET sync_cursor"):
+			if q.Args[1] != "item-b" || q.Args[2] != "cursor-b" {
+				t.Errorf("cursor update = %v, want item-b at cursor-b", q.Args)
+			}
+		case strings.Contains(q.SQL, "DELETE"):
+			t.Errorf("scoped sync deleted data: %s", q.SQL)
+		}
+	}
+	if rec.Count("INSERT INTO transactions") != 1 || rec.Count("UPDATE plaid_items SET sync_cursor") != 1 {
+		t.Error("item-b's transaction or cursor was not stored")
+	}
+
+	tokens = nil
+	if w := sync("item-of-another-user"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown item: status = %d, want 404", w.Code)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("unknown item reached Plaid %d times", len(tokens))
+	}
+}
+
+func TestSyncRemovesPendingTransactionAddedInSameSync(t *testing.T) {
+	// Page one adds a pending charge; page two adds its posted version
+	// under a new ID and removes the pending one.
+	pages := []string{
+		`{"added":[{"transaction_id":"txn-pending","amount":12.5,"date":"2024-06-01","merchant_name":"Cafe","pending":true}],"next_cursor":"c1","has_more":true}`,
+		`{"added":[{"transaction_id":"txn-posted","amount":12.5,"date":"2024-06-02","merchant_name":"Cafe"}],"removed":[{"transaction_id":"txn-pending"}],"next_cursor":"c2"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/transactions/sync":
+			w.Write([]byte(pages[0]))
+			pages = pages[1:]
+		case "/accounts/get":
+			w.Write([]byte(`{"accounts":[]}`))
+		default:
+			t.Errorf("unexpected Plaid call to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	// The fake transactions table applies inserts and deletes by ext_id.
+	stored := map[string]bool{}
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "INSERT INTO transactions"):
+			stored[q.Args[2].(string)] = true
+		case strings.Contains(q.SQL, "DELETE FROM transactions"):
+			for _, id := range q.Args[2].([]string) {
+				delete(stored, id)
+			}
+		case strings.Contains(q.SQL, "FROM transactions"):
+			return dbtest.Result{Columns: transactionCols}
+		}
+		return dbtest.Result{RowsAffected: 1}
+	})
+	h := &OAuthHandlers{db: d, plaidService: &PlaidService{baseURL: srv.URL}}
+
+	item := store.PlaidItem{ItemID: "item-a", AccessToken: "access-a"}
+	if err := h.syncAccountsAndTransactions(context.Background(), uuid.New(), item); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || !stored["txn-posted"] {
+		t.Errorf("stored transactions = %v, want only txn-posted", stored)
+	}
+}
+
+func TestRevokeConnectionsIncludesLegacyToken(t *testing.T) {
+	var removed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.URL.Path != "/item/remove" {
+			t.Errorf("unexpected Plaid call to %s", r.URL.Path)
+		}
+		removed = append(removed, payload.AccessToken)
+		w.Write([]byte(`{"request_id":"req"}`))
+	}))
+	defer srv.Close()
+
+	// The user linked a bank before items were tracked, then another since.
+	legacy := "access-legacy"
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM plaid_items"):
+			return dbtest.Rows([]string{"item_id", "access_token_enc", "sync_cursor", "created_at"},
+				[]any{"item-b", []byte("access-b"), "", time.Now()})
+		case strings.Contains(q.SQL, "FROM oauth_tokens"):
+			cols := []string{"access_token_enc"}
+			if legacy == "" {
+				return dbtest.Result{Columns: cols}
+			}
+			return dbtest.Rows(cols, []any{[]byte(legacy)})
+		}
+		return dbtest.Result{}
+	})
+	h := &OAuthHandlers{db: d, plaidService: &PlaidService{baseURL: srv.URL}}
+
+	if err := h.RevokeConnections(context.Background(), uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(removed, ",") != "access-b,access-legacy" {
+		t.Errorf("removed %v, want item-b and the legacy connection", removed)
+	}
+
+	// Without a legacy token only the items are removed.
+	removed, legacy = nil, ""
+	if err := h.RevokeConnections(context.Background(), uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(removed, ",") != "access-b" {
+		t.Errorf("removed %v, want only item-b", removed)
+	}
+}
+
+func TestDisconnectKeepsLegacyTokenUntilRemoved(t *testing.T) {
+	var removed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		removed = append(removed, payload.AccessToken)
+		w.Write([]byte(`{"request_id":"req"}`))
+	}))
+	defer srv.Close()
+
+	itemCols := []string{"item_id", "access_token_enc", "sync_cursor", "created_at"}
+	d, rec := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM plaid_items"):
+			return dbtest.Rows(itemCols, []any{"item-b", []byte("access-b"), "", time.Now()})
+		case strings.Contains(q.SQL, "FROM oauth_tokens"):
+			return dbtest.Rows([]string{"access_token_enc"}, []any{[]byte("access-legacy")})
+		}
+		return dbtest.Result{RowsAffected: 1}
+	})
+	h := &OAuthHandlers{db: d, plaidService: &PlaidService{baseURL: srv.URL}}
+
+	disconnect := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/plaid/disconnect", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", uuid.New())
+		h.Disconnect(c)
+		return w
+	}
+
+	// Disconnecting one item leaves the legacy connection's token alone.
+	if w := disconnect(`{"item_id":"item-b"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if strings.Join(removed, ",") != "access-b" {
+		t.Errorf("removed %v at Plaid, want only item-b", removed)
+	}
+	if n := rec.Count("DELETE FROM oauth_tokens"); n != 0 {
+		t.Errorf("deleted the legacy token %d times without removing its item", n)
+	}
+
+	// Disconnecting everything removes the legacy item before its token.
+	removed = nil
+	if w := disconnect(""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if strings.Join(removed, ",") != "access-b,access-legacy" {
+		t.Errorf("removed %v at Plaid, want item-b and the legacy connection", removed)
+	}
+	if n := rec.Count("DELETE FROM oauth_tokens"); n != 1 {
+		t.Errorf("deleted the legacy token %d times, want 1", n)
+	}
+}
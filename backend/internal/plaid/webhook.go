@@ -0,0 +1,302 @@
+package plaid
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"dayboard/backend/internal/store"
+)
+
+// Webhook types and codes handled by Webhook. See
+// https://plaid.com/docs/api/webhooks/.
+const (
+	webhookTypeTransactions   = "TRANSACTIONS"
+	webhookTypeItem           = "ITEM"
+	webhookSyncUpdates        = "SYNC_UPDATES_AVAILABLE"
+	webhookDefaultUpdate      = "DEFAULT_UPDATE"
+	webhookTransactionRemoved = "TRANSACTIONS_REMOVED"
+	webhookItemError          = "ERROR"
+)
+
+// maxWebhookBody caps how much of a webhook request is read. Plaid's
+// payloads are a few hundred bytes.
+const maxWebhookBody = 1 << 20
+
+// maxWebhookAge is how old a webhook's signature may be. Plaid recommends
+// rejecting anything issued more than five minutes ago to limit replays.
+const maxWebhookAge = 5 * time.Minute
+
+// failedKeyTTL is how long a key ID that couldn't be fetched is rejected
+// without asking Plaid again.
+const failedKeyTTL = 10 * time.Minute
+
+// keyFetchInterval is the minimum time between fetches of unknown key IDs,
+// so forged headers with made-up kids can't turn into a stream of Plaid
+// calls. A real webhook that loses the race is rejected and Plaid retries it.
+const keyFetchInterval = 5 * time.Second
+
+var errInvalidWebhook = errors.New("invalid webhook signature")
+
+// webhookPayload is the subset of Plaid webhook fields we act on.
+type webhookPayload struct {
+	WebhookType         string   `json:"webhook_type"`
+	WebhookCode         string   `json:"webhook_code"`
+	ItemID              string   `json:"item_id"`
+	RemovedTransactions []string `json:"removed_transactions"`
+	Error               *struct {
+		ErrorCode    string `json:"error_code"`
+		ErrorMessage string `json:"error_message"`
+	} `json:"error"`
+}
+
+// webhookClaims are the claims in the Plaid-Verification JWT. The body
+// hash ties the signature to this exact request.
+type webhookClaims struct {
+	RequestBodySHA256 string `json:"request_body_sha256"`
+	jwt.RegisteredClaims
+}
+
+// verificationKeys caches Plaid's webhook signing keys by key ID. Keys
+// rotate rarely, so each is fetched once per process. Key IDs whose fetch
+// failed are remembered for failedKeyTTL, and lastFetch paces fetches.
+type verificationKeys struct {
+	mu        sync.Mutex
+	keys      map[string]*ecdsa.PublicKey
+	failed    map[string]time.Time
+	lastFetch time.Time
+}
+
+func newVerificationKeys() *verificationKeys {
+	return &verificationKeys{
+		keys:   make(map[string]*ecdsa.PublicKey),
+		failed: make(map[string]time.Time),
+	}
+}
+
+// Webhook receives Plaid webhooks. It is not behind user auth; instead
+// every request must carry a valid Plaid-Verification header:
+//
+//  1. The header is an ES256 JWT issued within the last five minutes.
+//     Older tokens are rejected before any key is fetched.
+//  2. Its kid names one of Plaid's signing keys, fetched from
+//     /webhook_verification_key/get and cached, and the JWT must verify
+//     against that key.
+//  3. Its request_body_sha256 claim must match the SHA-256 of the raw body.
+//
+// Requests that fail any check get 401 and are not processed. For the
+// affected item, SYNC_UPDATES_AVAILABLE and DEFAULT_UPDATE run an
+// incremental transaction sync, TRANSACTIONS_REMOVED deletes the listed
+// transactions, and ITEM ERROR records the error code on the item. Other
+// webhooks are acknowledged and ignored.
+func (h *OAuthHandlers) Webhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read webhook"})
+		return
+	}
+	if err := h.plaidService.VerifyWebhook(c.Request.Context(), c.GetHeader("Plaid-Verification"), body); err != nil {
+		log.Printf("plaid webhook: rejected: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, item, err := store.FindPlaidItem(ctx, h.db, payload.ItemID)
+	if errors.Is(err, store.ErrPlaidItemNotFound) {
+		// Acknowledge so Plaid stops retrying for an item we no longer hold.
+		log.Printf("plaid webhook: %s %s for unknown item %q", payload.WebhookType, payload.WebhookCode, payload.ItemID)
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load bank connection"})
+		return
+	}
+
+	switch {
+	case payload.WebhookType == webhookTypeTransactions &&
+		(payload.WebhookCode == webhookSyncUpdates || payload.WebhookCode == webhookDefaultUpdate):
+		if err := h.syncAccountsAndTransactions(ctx, userID, *item); err != nil {
+			log.Printf("plaid webhook: sync item %s failed: %v", item.ItemID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync transactions"})
+			return
+		}
+		if err := store.SetPlaidItemError(ctx, h.db, userID, item.ItemID, ""); err != nil {
+			log.Printf("plaid webhook: clear error on item %s failed: %v", item.ItemID, err)
+		}
+	case payload.WebhookType == webhookTypeTransactions && payload.WebhookCode == webhookTransactionRemoved:
+		if err := store.DeleteTransactions(ctx, h.db, userID, "plaid", payload.RemovedTransactions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove transactions"})
+			return
+		}
+		if _, err := h.detectFromStoredTransactions(ctx, userID); err != nil {
+			log.Printf("plaid webhook: redetect after removal failed: %v", err)
+		}
+	case payload.WebhookType == webhookTypeItem && payload.WebhookCode == webhookItemError:
+		code := "UNKNOWN"
+		if payload.Error != nil && payload.Error.ErrorCode != "" {
+			code = payload.Error.ErrorCode
+		}
+		log.Printf("plaid webhook: item %s reported error %s", item.ItemID, code)
+		if err := store.SetPlaidItemError(ctx, h.db, userID, item.ItemID, code); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record item error"})
+			return
+		}
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "processed"})
+}
+
+// VerifyWebhook checks a Plaid-Verification header against the raw request
+// body as described on Webhook.
+func (s *PlaidService) VerifyWebhook(ctx context.Context, header string, body []byte) error {
+	if header == "" {
+		return fmt.Errorf("%w: missing Plaid-Verification header", errInvalidWebhook)
+	}
+	var claims webhookClaims
+	token, err := jwt.ParseWithClaims(header, &claims, func(t *jwt.Token) (interface{}, error) {
+		// Claims are decoded but not yet verified here; checking the age
+		// first keeps replayed or junk tokens from triggering a key fetch.
+		if claims.IssuedAt == nil || time.Since(claims.IssuedAt.Time) > maxWebhookAge {
+			return nil, errors.New("token too old")
+		}
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("missing key id")
+		}
+		return s.verificationKey(ctx, kid)
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithIssuedAt())
+	if err != nil || !token.Valid {
+		return fmt.Errorf("%w: %v", errInvalidWebhook, err)
+	}
+	sum := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(claims.RequestBodySHA256)) != 1 {
+		return fmt.Errorf("%w: body hash mismatch", errInvalidWebhook)
+	}
+	return nil
+}
+
+// verificationKey returns the public key for kid, fetching it on first
+// use. A kid whose fetch failed is rejected for failedKeyTTL, and unknown
+// kids are fetched at most once per keyFetchInterval.
+func (s *PlaidService) verificationKey(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	k := s.webhookKeys
+	k.mu.Lock()
+	if key, ok := k.keys[kid]; ok {
+		k.mu.Unlock()
+		return key, nil
+	}
+	if at, ok := k.failed[kid]; ok && time.Since(at) < failedKeyTTL {
+		k.mu.Unlock()
+		return nil, fmt.Errorf("verification key %s failed recently", kid)
+	}
+	if time.Since(k.lastFetch) < keyFetchInterval {
+		k.mu.Unlock()
+		return nil, fmt.Errorf("verification key %s: fetch rate limited", kid)
+	}
+	k.lastFetch = time.Now()
+	k.mu.Unlock()
+
+	key, err := s.fetchVerificationKey(ctx, kid)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err != nil {
+		now := time.Now()
+		for id, at := range k.failed {
+			if now.Sub(at) >= failedKeyTTL {
+				delete(k.failed, id)
+			}
+		}
+		k.failed[kid] = now
+		return nil, err
+	}
+	delete(k.failed, kid)
+	k.keys[kid] = key
+	return key, nil
+}
+
+// fetchVerificationKey loads kid from /webhook_verification_key/get.
+// Expired keys are rejected.
+func (s *PlaidService) fetchVerificationKey(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	payload := map[string]interface{}{
+		"client_id": s.clientID,
+		"secret":    s.secret,
+		"key_id":    kid,
+	}
+	var response struct {
+		Key struct {
+			Alg       string `json:"alg"`
+			Crv       string `json:"crv"`
+			Kid       string `json:"kid"`
+			Kty       string `json:"kty"`
+			X         string `json:"x"`
+			Y         string `json:"y"`
+			ExpiredAt *int64 `json:"expired_at"`
+		} `json:"key"`
+		RequestID string `json:"request_id"`
+	}
+	if _, err := s.makeRequest(ctx, "/webhook_verification_key/get", payload, &response); err != nil {
+		return nil, err
+	}
+	jwk := response.Key
+	if jwk.ExpiredAt != nil {
+		return nil, fmt.Errorf("verification key %s has expired", kid)
+	}
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported verification key %s/%s", jwk.Kty, jwk.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("verification key x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("verification key y: %w", err)
+	}
+	// Reject points that aren't on the curve before using them.
+	point := append([]byte{4}, append(leftPad(x, 32), leftPad(y, 32)...)...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("verification key %s: %w", kid, err)
+	}
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+// leftPad zero-pads b on the left to n bytes.
+func leftPad(b []byte, n int) []byte {
+	if len(b) >= n {
+		return b
+	}
+	return append(make([]byte, n-len(b)), b...)
+}
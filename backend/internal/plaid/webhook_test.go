@@ -0,0 +1,135 @@
+package plaid
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyServer serves key as the verification key for kid and answers any
+// other kid with Plaid's not-found error. It returns the fetch count.
+func keyServer(t *testing.T, kid string, key *ecdsa.PrivateKey) (*PlaidService, *int) {
+	t.Helper()
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		var req struct {
+			KeyID string `json:"key_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.KeyID != kid {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error_type":"INVALID_INPUT","error_code":"INVALID_WEBHOOK_VERIFICATION_KEY_ID"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"key": map[string]any{
+			"alg": "ES256", "crv": "P-256", "kid": kid, "kty": "EC",
+			"x": base64.RawURLEncoding.EncodeToString(key.PublicKey.X.FillBytes(make([]byte, 32))),
+			"y": base64.RawURLEncoding.EncodeToString(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+		}})
+	}))
+	t.Cleanup(srv.Close)
+	return &PlaidService{baseURL: srv.URL, webhookKeys: newVerificationKeys()}, &fetches
+}
+
+// signWebhook returns a Plaid-Verification header for body signed by key
+// under kid and issued at iat.
+func signWebhook(t *testing.T, key *ecdsa.PrivateKey, kid string, iat time.Time, body []byte) string {
+	t.Helper()
+	sum := sha256.Sum256(body)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, webhookClaims{
+		RequestBodySHA256: hex.EncodeToString(sum[:]),
+		RegisteredClaims:  jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(iat)},
+	})
+	token.Header["kid"] = kid
+	header, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, fetches := keyServer(t, "kid-1", key)
+	ctx := context.Background()
+	body := []byte(`{"webhook_type":"TRANSACTIONS","webhook_code":"SYNC_UPDATES_AVAILABLE"}`)
+
+	if err := s.VerifyWebhook(ctx, signWebhook(t, key, "kid-1", time.Now(), body), body); err != nil {
+		t.Fatalf("valid webhook rejected: %v", err)
+	}
+	if err := s.VerifyWebhook(ctx, signWebhook(t, key, "kid-1", time.Now(), body), []byte(`{}`)); err == nil {
+		t.Error("webhook with a different body was accepted")
+	}
+	if *fetches != 1 {
+		t.Errorf("key fetched %d times, want once and then cached", *fetches)
+	}
+}
+
+func TestVerifyWebhookRejectsStaleTokenBeforeFetch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, fetches := keyServer(t, "kid-1", key)
+	body := []byte(`{}`)
+
+	header := signWebhook(t, key, "kid-1", time.Now().Add(-2*maxWebhookAge), body)
+	if err := s.VerifyWebhook(context.Background(), header, body); err == nil {
+		t.Error("stale webhook was accepted")
+	}
+	if *fetches != 0 {
+		t.Errorf("stale webhook fetched the key %d times, want 0", *fetches)
+	}
+}
+
+func TestVerifyWebhookLimitsKeyFetches(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, fetches := keyServer(t, "kid-1", key)
+	ctx := context.Background()
+	body := []byte(`{}`)
+
+	// An unknown kid fails and is remembered even once fetching is allowed.
+	if err := s.VerifyWebhook(ctx, signWebhook(t, key, "forged", time.Now(), body), body); err == nil {
+		t.Fatal("webhook with an unknown kid was accepted")
+	}
+	s.webhookKeys.lastFetch = time.Time{}
+	if err := s.VerifyWebhook(ctx, signWebhook(t, key, "forged", time.Now(), body), body); err == nil {
+		t.Fatal("webhook with an unknown kid was accepted")
+	}
+	if *fetches != 1 {
+		t.Errorf("failed kid fetched %d times, want 1", *fetches)
+	}
+
+	// Back-to-back unknown kids: only the first reaches Plaid.
+	s.webhookKeys.lastFetch = time.Time{}
+	for _, kid := range []string{"random-1", "random-2", "kid-1"} {
+		s.VerifyWebhook(ctx, signWebhook(t, key, kid, time.Now(), body), body)
+	}
+	if *fetches != 2 {
+		t.Errorf("three new kids fetched %d times in a row, want 1", *fetches-1)
+	}
+
+	// The real key still verifies once the interval has passed.
+	s.webhookKeys.lastFetch = time.Now().Add(-keyFetchInterval)
+	if err := s.VerifyWebhook(ctx, signWebhook(t, key, "kid-1", time.Now(), body), body); err != nil {
+		t.Errorf("valid webhook rejected after the fetch interval: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package reminder
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dayboard/backend/internal/db"
+	"dayboard/backend/internal/store"
+)
+
+// Notifier delivers a reminder to the user. Delivery channels (push, email)
+// plug in here; the default implementation only logs.
+type Notifier interface {
+	Notify(ctx context.Context, r store.Reminder) error
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(ctx context.Context, r store.Reminder) error {
+	log.Printf("reminder: user=%s event=%q starts %s (%d min)", r.UserID, r.Title, r.Start.Format(time.RFC3339), r.MinutesBefore)
+	return nil
+}
+
+// Worker periodically scans upcoming events, enqueues one reminder per
+// event and lead time, and delivers reminders that have come due. Queued
+// reminders live in the database, so anything not delivered before the
+// worker stops is picked up by the next run.
+type Worker struct {
+	db        *db.DB
+	notifier  Notifier
+	interval  time.Duration
+	lookahead time.Duration
+
+	// jobs is the context in-flight ticks run under. It is only cancelled
+	// when Shutdown runs out of time.
+	jobs       context.Context
+	cancelJobs context.CancelFunc
+	started    atomic.Bool
+	stop       chan struct{}
+	stopOnce   sync.Once
+	done       chan struct{}
+}
+
+// NewWorker creates a reminder worker. The poll interval is read from
+// REMINDER_POLL_SECONDS (default 60).
+func NewWorker(database *db.DB) *Worker {
+	interval := 60 * time.Second
+	if v := os.Getenv("REMINDER_POLL_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			interval = time.Duration(secs) * time.Second
+		}
+	}
+	jobs, cancelJobs := context.WithCancel(context.Background())
+	return &Worker{
+		db:         database,
+		notifier:   logNotifier{},
+		interval:   interval,
+		lookahead:  24 * time.Hour,
+		jobs:       jobs,
+		cancelJobs: cancelJobs,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Interval returns how often the worker polls for due reminders.
+func (w *Worker) Interval() time.Duration {
+	return w.interval
+}
+
+// Start runs the worker in the background. The worker counts as started
+// before Start returns, so a Shutdown that races with startup still waits
+// for it to stop.
+func (w *Worker) Start(ctx context.Context) {
+	w.started.Store(true)
+	go w.Run(ctx)
+}
+
+// Run processes reminders every poll interval until Shutdown is called or
+// ctx is cancelled. Cancelling ctx abandons the current tick; Shutdown lets
+// it finish. Use Start rather than calling Run in a new goroutine.
+func (w *Worker) Run(ctx context.Context) {
+	w.started.Store(true)
+	defer close(w.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.jobs.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops the worker from starting new work and waits for the
+// in-flight tick to finish. If ctx expires first, the tick is cancelled and
+// its remaining reminders stay queued for the next start.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	if !w.started.Load() {
+		return nil
+	}
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		log.Printf("reminder: shutdown timed out, abandoning in-flight delivery; undelivered reminders remain queued")
+		w.cancelJobs()
+		<-w.done
+		return ctx.Err()
+	}
+}
+
+// stopping reports whether Shutdown has been called.
+func (w *Worker) stopping() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *Worker) tick(ctx context.Context, now time.Time) {
+	if err := w.enqueue(ctx, now); err != nil {
+		log.Printf("reminder: enqueue failed: %v", err)
+	}
+	if err := w.deliver(ctx, now); err != nil {
+		log.Printf("reminder: delivery failed: %v", err)
+	}
+}
+
+// enqueue schedules reminders for events starting within the lookahead
+// window. Reminders whose time has already passed are skipped.
+func (w *Worker) enqueue(ctx context.Context, now time.Time) error {
+	candidates, err := store.GetReminderCandidates(ctx, w.db, now, now.Add(w.lookahead))
+	if err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		for _, minutes := range LeadTimes(c.MinutesBefore) {
+			remindAt := c.Start.Add(-time.Duration(minutes) * time.Minute)
+			if remindAt.Before(now.Add(-w.interval)) {
+				continue
+			}
+			if err := store.EnqueueReminder(ctx, w.db, c.UserID, c.EventID, minutes, remindAt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Worker) deliver(ctx context.Context, now time.Time) error {
+	due, err := store.GetDueReminders(ctx, w.db, now)
+	if err != nil {
+		return err
+	}
+	for i, r := range due {
+		if w.stopping() {
+			log.Printf("reminder: stopping, leaving %d due reminders queued", len(due)-i)
+			return nil
+		}
+		if err := w.notifier.Notify(ctx, r); err != nil {
+			log.Printf("reminder: notify %s failed: %v", r.ID, err)
+			continue
+		}
+		if err := store.MarkReminderSent(ctx, w.db, r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LeadTimes normalizes a list of reminder offsets: negative values are
+// dropped, duplicates removed, and the result sorted largest first so the
+// earliest reminder is scheduled first.
+func LeadTimes(minutes []int) []int {
+	seen := make(map[int]bool, len(minutes))
+	var out []int
+	for _, m := range minutes {
+		if m < 0 || seen[m] {
+			continue
+		}
+		seen[m] = true
+		out = append(out, m)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(out)))
+	return out
+}
@@ -0,0 +1,108 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+	"dayboard/backend/internal/store"
+)
+
+// blockingNotifier signals on started when a delivery begins and holds it
+// until release is closed or the delivery is cancelled.
+type blockingNotifier struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, r store.Reminder) error {
+	n.started <- struct{}{}
+	select {
+	case <-n.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// testWorker returns a worker with two due reminders and a notifier that
+// blocks on each delivery.
+func testWorker(t *testing.T) (*Worker, *blockingNotifier, *dbtest.Recorder) {
+	t.Helper()
+	d, rec := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM event_reminders"):
+			cols := []string{"id", "user_id", "event_id", "title", "start_ts", "minutes_before", "remind_at"}
+			now := time.Now()
+			return dbtest.Rows(cols,
+				[]any{uuid.NewString(), uuid.NewString(), uuid.NewString(), "Standup", now.Add(10 * time.Minute), 10, now},
+				[]any{uuid.NewString(), uuid.NewString(), uuid.NewString(), "Review", now.Add(10 * time.Minute), 10, now},
+			)
+		case strings.Contains(q.SQL, "FROM calendar_events"):
+			return dbtest.Result{Columns: []string{"id", "user_id", "title", "start_ts", "reminder_minutes_before"}}
+		}
+		return dbtest.Result{RowsAffected: 1}
+	})
+	n := &blockingNotifier{started: make(chan struct{}, 2), release: make(chan struct{})}
+	w := NewWorker(d)
+	w.notifier = n
+	w.interval = time.Hour
+	return w, n, rec
+}
+
+func TestShutdownRacingStartWaitsForWorker(t *testing.T) {
+	w, n, _ := testWorker(t)
+	close(n.release)
+	w.Start(context.Background())
+	if err := w.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.done:
+	default:
+		t.Error("Shutdown returned while the worker was still running")
+	}
+}
+
+func TestShutdownFinishesInFlightDelivery(t *testing.T) {
+	w, n, rec := testWorker(t)
+	w.Start(context.Background())
+	<-n.started
+
+	shutdown := make(chan error)
+	go func() { shutdown <- w.Shutdown(context.Background()) }()
+	// Shutdown waits for the delivery in progress.
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v mid-delivery", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(n.release)
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	// The in-flight reminder is marked sent; the next stays queued.
+	if got := rec.Count("SET sent_at"); got != 1 {
+		t.Errorf("marked %d reminders sent, want 1", got)
+	}
+}
+
+func TestShutdownTimeoutLeavesReminderQueued(t *testing.T) {
+	w, n, rec := testWorker(t)
+	w.Start(context.Background())
+	<-n.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+	if got := rec.Count("SET sent_at"); got != 0 {
+		t.Errorf("marked %d reminders sent, want the abandoned one left queued", got)
+	}
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// Account is a bank account and its balance as of LastSynced.
+type Account struct {
+	AccountID    string    `json:"accountId"`
+	ItemID       string    `json:"itemId,omitempty"`
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	Subtype      string    `json:"subtype"`
+	BalanceCents int64     `json:"balanceCents"`
+	Currency     string    `json:"currency"`
+	LastSynced   time.Time `json:"lastSynced"`
+}
+
+// UpsertAccount stores an account's latest details and balance, stamping
+// it as synced now.
+func UpsertAccount(ctx context.Context, d db.Querier, userID uuid.UUID, a Account) error {
+	if a.AccountID == "" {
+		return errors.New("account id is required")
+	}
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO accounts (user_id, item_id, account_id, name, type, subtype, balance_cents, currency, last_synced)
+        VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, NOW())
+        ON CONFLICT (user_id, account_id)
+        DO UPDATE SET
+            item_id = COALESCE(EXCLUDED.item_id, accounts.item_id),
+            name = EXCLUDED.name,
+            type = EXCLUDED.type,
+            subtype = EXCLUDED.subtype,
+            balance_cents = EXCLUDED.balance_cents,
+            currency = EXCLUDED.currency,
+            last_synced = EXCLUDED.last_synced
+    `, userID, a.ItemID, a.AccountID, a.Name, a.Type, a.Subtype, a.BalanceCents, a.Currency)
+	return err
+}
+
+// GetAccounts returns the user's stored accounts ordered by name.
+func GetAccounts(ctx context.Context, d *db.DB, userID uuid.UUID) ([]Account, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT account_id, item_id, name, type, subtype, balance_cents, currency, last_synced
+        FROM accounts
+        WHERE user_id = $1
+        ORDER BY name ASC, account_id ASC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var accounts []Account
+	for rows.Next() {
+		var a Account
+		var itemID, name, typ, subtype, currency sql.NullString
+		if err := rows.Scan(&a.AccountID, &itemID, &name, &typ, &subtype, &a.BalanceCents, &currency, &a.LastSynced); err != nil {
+			return nil, err
+		}
+		a.ItemID = itemID.String
+		a.Name = name.String
+		a.Type = typ.String
+		a.Subtype = subtype.String
+		a.Currency = currency.String
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
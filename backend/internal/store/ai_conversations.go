@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// ErrConversationNotFound is returned when a conversation does not exist
+// or belongs to another user.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// Roles of an AI message, matching Gemini's content roles.
+const (
+	AIRoleUser  = "user"
+	AIRoleModel = "model"
+)
+
+// AIMessage is one turn of an AI conversation.
+type AIMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateConversation starts an empty conversation for the user.
+func CreateConversation(ctx context.Context, d *db.DB, userID uuid.UUID) (uuid.UUID, error) {
+	id := uuid.New()
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO ai_conversations (id, user_id) VALUES ($1, $2)
+    `, id, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+// GetConversationMessages returns the last limit messages of one of the
+// user's conversations, oldest first. ErrConversationNotFound means the
+// conversation doesn't exist or isn't the user's.
+func GetConversationMessages(ctx context.Context, d *db.DB, userID, conversationID uuid.UUID, limit int) ([]AIMessage, error) {
+	var owned bool
+	err := d.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM ai_conversations WHERE id = $1 AND user_id = $2)
+    `, conversationID, userID).Scan(&owned)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrConversationNotFound
+	}
+
+	rows, err := d.QueryContext(ctx, `
+        SELECT role, content, created_at FROM (
+            SELECT role, content, created_at, id
+            FROM ai_messages
+            WHERE conversation_id = $1
+            ORDER BY created_at DESC, id DESC
+            LIMIT $2
+        ) recent
+        ORDER BY created_at ASC, id ASC
+    `, conversationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []AIMessage{}
+	for rows.Next() {
+		var m AIMessage
+		if err := rows.Scan(&m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// AddConversationTurn appends a question and its answer to a conversation.
+// Both are written in one statement so a thread never holds a question
+// without its answer.
+func AddConversationTurn(ctx context.Context, d *db.DB, conversationID uuid.UUID, question, answer string) error {
+	// The answer is stamped a microsecond later so the pair always sorts
+	// question first.
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO ai_messages (conversation_id, role, content, created_at)
+        VALUES ($1, $2, $3, now()), ($1, $4, $5, now() + interval '1 microsecond')
+    `, conversationID, AIRoleUser, question, AIRoleModel, answer)
+	return err
+}
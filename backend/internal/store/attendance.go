@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// Attendance values for Event.Attendance. An empty value means the event
+// hasn't been marked.
+const (
+	AttendanceAttended = "attended"
+	AttendanceSkipped  = "skipped"
+)
+
+// ErrEventNotFound is returned when an event does not exist or belongs to
+// another user.
+var ErrEventNotFound = errors.New("event not found")
+
+// ValidateAttendance checks that status is an attendance value, or empty
+// to clear one.
+func ValidateAttendance(status string) error {
+	switch status {
+	case AttendanceAttended, AttendanceSkipped, "":
+		return nil
+	}
+	return fmt.Errorf("unsupported attendance status: %q (use %s or %s)", status, AttendanceAttended, AttendanceSkipped)
+}
+
+// SetEventAttendance marks one of the user's events as attended or
+// skipped. An empty status clears the mark.
+func SetEventAttendance(ctx context.Context, d *db.DB, userID, eventID uuid.UUID, status string) error {
+	if err := ValidateAttendance(status); err != nil {
+		return err
+	}
+	res, err := d.ExecContext(ctx, `
+        UPDATE calendar_events SET attendance = NULLIF($3, ''), updated_at = NOW()
+        WHERE user_id = $1 AND id = $2
+    `, userID, eventID, status)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+// InPerson reports whether an event happens somewhere physical: it has a
+// location and no video link, and isn't an all-day placeholder.
+func (e Event) InPerson() bool {
+	return !e.AllDay && e.Location != "" && e.JoinURL == ""
+}
+
+// skippedInPerson reports whether the day's events include in-person ones
+// and every one of them was marked skipped. Unmarked events count as
+// attended, so users who never mark attendance are unaffected.
+func skippedInPerson(events []Event) bool {
+	inPerson := 0
+	for _, e := range events {
+		if !e.InPerson() {
+			continue
+		}
+		if e.Attendance != AttendanceSkipped {
+			return false
+		}
+		inPerson++
+	}
+	return inPerson > 0
+}
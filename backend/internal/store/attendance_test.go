@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+// officeEvent is an in-person event on June 3, 2024 (a Monday) with the
+// given attendance.
+func officeEvent(hour int, attendance string) Event {
+	start := time.Date(2024, 6, 3, hour, 0, 0, 0, time.UTC)
+	return Event{ID: uuid.New(), Start: start, End: start.Add(time.Hour), Title: "Onsite", Location: "Office", Attendance: attendance}
+}
+
+func TestSkippedOfficeDayDropsCommuteAndFood(t *testing.T) {
+	monday := date(2024, time.June, 3)
+	commutes := []CommuteEntry{{Date: monday.Add(8 * time.Hour), CostCents: 1800}}
+	prof := &Profile{InOfficeDays: 3, FoodCostCents: 1500}
+
+	tests := []struct {
+		name        string
+		events      []Event
+		opts        BurnOptions
+		wantSkipped bool
+	}{
+		{"all in-person events skipped", []Event{officeEvent(9, AttendanceSkipped), officeEvent(14, AttendanceSkipped)}, BurnOptions{UseAttendance: true}, true},
+		{"one event attended", []Event{officeEvent(9, AttendanceSkipped), officeEvent(14, AttendanceAttended)}, BurnOptions{UseAttendance: true}, false},
+		{"unmarked events count as attended", []Event{officeEvent(9, "")}, BurnOptions{UseAttendance: true}, false},
+		{"attendance not requested", []Event{officeEvent(9, AttendanceSkipped)}, BurnOptions{}, false},
+	}
+	for _, tt := range tests {
+		series := ComputeBurnSeries(monday, 1, time.UTC, nil, commutes, prof, tt.events, tt.opts)
+		day := series.Days[0]
+		if day.SkippedOffice != tt.wantSkipped {
+			t.Errorf("%s: skippedOffice = %v, want %v", tt.name, day.SkippedOffice, tt.wantSkipped)
+		}
+		wantCents := 1800 + 1500
+		if tt.wantSkipped {
+			wantCents = 0
+		}
+		if got := day.CommuteCents + day.FoodCents; got != wantCents {
+			t.Errorf("%s: commute and food = %d cents, want %d", tt.name, got, wantCents)
+		}
+	}
+}
+
+func TestDailyBurnSkippedOfficeDay(t *testing.T) {
+	commutes := [][]any{{uuid.NewString(), time.Date(2024, 6, 3, 8, 30, 0, 0, time.UTC), "Home", "Office", 1800, "rideshare", 0, 0}}
+	base := burnDB(nil, commutes)
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if strings.Contains(q.SQL, "FROM calendar_events") {
+			e := officeEvent(9, AttendanceSkipped)
+			return dbtest.Rows([]string{"id", "start_ts", "end_ts", "title", "join_url", "location", "all_day", "reminder_minutes_before", "attendance"},
+				[]any{e.ID.String(), e.Start, e.End, e.Title, "", e.Location, false, nil, e.Attendance})
+		}
+		return base(q)
+	})
+	ctx := context.Background()
+	day := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+
+	burn, err := DailyBurn(ctx, d, uuid.New(), day, time.UTC, BurnOptions{UseAttendance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !burn.Breakdown.SkippedOffice || burn.TotalCents != 0 {
+		t.Errorf("skipped day = %d cents (skippedOffice %v), want 0", burn.TotalCents, burn.Breakdown.SkippedOffice)
+	}
+
+	burn, err = DailyBurn(ctx, d, uuid.New(), day, time.UTC, BurnOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if burn.TotalCents != 1800+1500 {
+		t.Errorf("without attendance = %d cents, want the 3300 commute and food", burn.TotalCents)
+	}
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// cadenceMonths maps a cadence in days to whole calendar months for
+// monthly, quarterly, semiannual and yearly subscriptions. Shorter or
+// irregular cadences report false and roll forward by days.
+func cadenceMonths(cadenceDays int) (int, bool) {
+	switch {
+	case cadenceDays >= 28 && cadenceDays <= 31:
+		return 1, true
+	case cadenceDays >= 84 && cadenceDays <= 95:
+		return 3, true
+	case cadenceDays >= 180 && cadenceDays <= 186:
+		return 6, true
+	case cadenceDays >= 360 && cadenceDays <= 370:
+		return 12, true
+	}
+	return 0, false
+}
+
+// AnnualizedCents returns what a subscription costs per year: its amount
+// scaled from CadenceDays to 365 days, rounded to the nearest cent, so
+// $9.99 every 30 days is $121.55. A cadence of zero days costs nothing.
+func AnnualizedCents(s Subscription) int {
+	if s.CadenceDays <= 0 {
+		return 0
+	}
+	return (s.AmountCents*365 + s.CadenceDays/2) / s.CadenceDays
+}
+
+// Savings is what cancelling a set of subscriptions would save, and the
+// subscription spend that would remain, in cents. Monthly figures are a
+// twelfth of the annual ones.
+type Savings struct {
+	AnnualSavingsCents  int `json:"annualSavingsCents"`
+	MonthlySavingsCents int `json:"monthlySavingsCents"`
+	CurrentAnnualCents  int `json:"currentAnnualCents"`
+	CurrentMonthlyCents int `json:"currentMonthlyCents"`
+	NewAnnualCents      int `json:"newAnnualCents"`
+	NewMonthlyCents     int `json:"newMonthlyCents"`
+}
+
+// CancellationSavings computes the Savings from cancelling the subscriptions
+// in subs with the given ids. Repeated ids count once; an id not in subs
+// returns an error wrapping ErrSubscriptionNotFound.
+func CancellationSavings(subs []Subscription, ids []uuid.UUID) (*Savings, error) {
+	owned := make(map[uuid.UUID]Subscription, len(subs))
+	current := 0
+	for _, sub := range subs {
+		owned[sub.ID] = sub
+		current += AnnualizedCents(sub)
+	}
+	selected := make(map[uuid.UUID]bool, len(ids))
+	saved := 0
+	for _, id := range ids {
+		sub, ok := owned[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrSubscriptionNotFound, id)
+		}
+		if selected[id] {
+			continue
+		}
+		selected[id] = true
+		saved += AnnualizedCents(sub)
+	}
+	return &Savings{
+		AnnualSavingsCents:  saved,
+		MonthlySavingsCents: saved / 12,
+		CurrentAnnualCents:  current,
+		CurrentMonthlyCents: current / 12,
+		NewAnnualCents:      current - saved,
+		NewMonthlyCents:     (current - saved) / 12,
+	}, nil
+}
+
+// NextBillingDate returns the billing date one cadence after due. Month
+// based cadences land on billingDay, clamped to the last day of short
+// months; a billingDay of zero uses due's own day. Other cadences add
+// cadenceDays.
+func NextBillingDate(due time.Time, cadenceDays, billingDay int) time.Time {
+	months, ok := cadenceMonths(cadenceDays)
+	if !ok {
+		return due.AddDate(0, 0, cadenceDays)
+	}
+	if billingDay <= 0 {
+		billingDay = due.Day()
+	}
+	// Day 1 of the target month never overflows, unlike AddDate on the 31st.
+	first := time.Date(due.Year(), due.Month()+time.Month(months), 1, 0, 0, 0, 0, due.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if billingDay > lastDay {
+		billingDay = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), billingDay, 0, 0, 0, 0, due.Location())
+}
+
+// RollForwardDue advances due by whole cadences until it is on or after
+// today's date.
+func RollForwardDue(due time.Time, cadenceDays, billingDay int, today time.Time) time.Time {
+	if cadenceDays <= 0 {
+		return due
+	}
+	y, m, d := today.Date()
+	floor := time.Date(y, m, d, 0, 0, 0, 0, due.Location())
+	for due.Before(floor) {
+		due = NextBillingDate(due, cadenceDays, billingDay)
+	}
+	return due
+}
+
+// Occurrences returns the next count charge dates on or after today,
+// starting from due rolled forward and stepping with NextBillingDate so
+// month-end billing days don't drift.
+func Occurrences(due time.Time, cadenceDays, billingDay int, today time.Time, count int) []time.Time {
+	if cadenceDays <= 0 || count <= 0 {
+		return []time.Time{}
+	}
+	dates := make([]time.Time, 0, count)
+	next := RollForwardDue(due, cadenceDays, billingDay, today)
+	for len(dates) < count {
+		dates = append(dates, next)
+		next = NextBillingDate(next, cadenceDays, billingDay)
+	}
+	return dates
+}
+
+// ProjectDueDates rolls each subscription's past NextDue forward to its
+// next billing date on or after today, without storing it, and re-sorts
+// subs to match subscriptionOrder on the projected dates.
+func ProjectDueDates(subs []Subscription, today time.Time) {
+	for i := range subs {
+		if due := subs[i].NextDue; due != nil {
+			next := RollForwardDue(*due, subs[i].CadenceDays, subs[i].BillingDay, today)
+			subs[i].NextDue = &next
+		}
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		a, b := subs[i], subs[j]
+		switch {
+		case a.NextDue == nil || b.NextDue == nil:
+			return a.NextDue != nil && b.NextDue == nil
+		case !a.NextDue.Equal(*b.NextDue):
+			return a.NextDue.Before(*b.NextDue)
+		case a.Merchant != b.Merchant:
+			return a.Merchant < b.Merchant
+		}
+		return a.ID.String() < b.ID.String()
+	})
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNextBillingDateKeepsBillingDay(t *testing.T) {
+	// A monthly subscription billed on the 31st.
+	due := date(2023, time.January, 31)
+	for _, want := range []time.Time{
+		date(2023, time.February, 28),
+		date(2023, time.March, 31),
+		date(2023, time.April, 30),
+		date(2023, time.May, 31),
+	} {
+		due = NextBillingDate(due, 30, 31)
+		if !due.Equal(want) {
+			t.Fatalf("next billing date = %s, want %s", due.Format("2006-01-02"), want.Format("2006-01-02"))
+		}
+	}
+
+	// Weekly cadences keep stepping by days.
+	if got, want := NextBillingDate(date(2023, time.January, 31), 7, 31), date(2023, time.February, 7); !got.Equal(want) {
+		t.Errorf("weekly next = %s, want %s", got.Format("2006-01-02"), want.Format("2006-01-02"))
+	}
+}
+
+func TestProjectDueDatesIsReadOnlyAndSorted(t *testing.T) {
+	overdue := date(2023, time.January, 31)
+	upcoming := date(2023, time.March, 5)
+	subs := []Subscription{
+		{ID: uuid.New(), Merchant: "Gym", AmountCents: 4000, CadenceDays: 30, NextDue: &overdue, BillingDay: 31},
+		{ID: uuid.New(), Merchant: "Spotify", AmountCents: 1099, CadenceDays: 30, NextDue: &upcoming, BillingDay: 5},
+		{ID: uuid.New(), Merchant: "Unknown", AmountCents: 500, CadenceDays: 30},
+	}
+
+	ProjectDueDates(subs, date(2023, time.March, 10))
+
+	if overdue != date(2023, time.January, 31) {
+		t.Errorf("projection modified the stored due date: %s", overdue)
+	}
+	want := []struct {
+		merchant string
+		due      time.Time
+	}{
+		{"Gym", date(2023, time.March, 31)},
+		{"Spotify", date(2023, time.April, 5)},
+		{"Unknown", time.Time{}},
+	}
+	for i, w := range want {
+		got := subs[i]
+		if got.Merchant != w.merchant {
+			t.Fatalf("position %d is %s, want %s", i, got.Merchant, w.merchant)
+		}
+		if w.due.IsZero() {
+			if got.NextDue != nil {
+				t.Errorf("%s: due = %s, want none", got.Merchant, got.NextDue)
+			}
+			continue
+		}
+		if got.NextDue == nil || !got.NextDue.Equal(w.due) {
+			t.Errorf("%s: due = %v, want %s", got.Merchant, got.NextDue, w.due.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestCancellationSavings(t *testing.T) {
+	netflix := Subscription{ID: uuid.New(), Merchant: "Netflix", AmountCents: 1500, CadenceDays: 30}
+	domain := Subscription{ID: uuid.New(), Merchant: "Domain", AmountCents: 1200, CadenceDays: 365}
+	gym := Subscription{ID: uuid.New(), Merchant: "Gym", AmountCents: 4000, CadenceDays: 30}
+	subs := []Subscription{netflix, domain, gym}
+
+	// Netflix is $182.50 a year and the domain $12; listing one twice counts once.
+	got, err := CancellationSavings(subs, []uuid.UUID{netflix.ID, domain.ID, netflix.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := AnnualizedCents(netflix) + AnnualizedCents(domain) + AnnualizedCents(gym)
+	saved := AnnualizedCents(netflix) + AnnualizedCents(domain)
+	want := Savings{
+		AnnualSavingsCents:  saved,
+		MonthlySavingsCents: saved / 12,
+		CurrentAnnualCents:  current,
+		CurrentMonthlyCents: current / 12,
+		NewAnnualCents:      AnnualizedCents(gym),
+		NewMonthlyCents:     AnnualizedCents(gym) / 12,
+	}
+	if *got != want {
+		t.Errorf("savings = %+v, want %+v", *got, want)
+	}
+	if AnnualizedCents(domain) != 1200 {
+		t.Errorf("yearly subscription annualized to %d, want 1200", AnnualizedCents(domain))
+	}
+
+	if _, err := CancellationSavings(subs, []uuid.UUID{uuid.New()}); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("unknown id: err = %v, want ErrSubscriptionNotFound", err)
+	}
+}
+
+func TestAnnualizedCents(t *testing.T) {
+	tests := []struct {
+		amount, cadence, want int
+	}{
+		{999, 30, 12155}, // $9.99 every 30 days is $121.55 a year
+		{1500, 7, 78214},
+		{1200, 365, 1200},
+		{6000, 90, 24333},
+		{999, 0, 0},
+	}
+	for _, tt := range tests {
+		sub := Subscription{AmountCents: tt.amount, CadenceDays: tt.cadence}
+		if got := AnnualizedCents(sub); got != tt.want {
+			t.Errorf("%d cents every %d days annualized to %d, want %d", tt.amount, tt.cadence, got, tt.want)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name        string
+		due         time.Time
+		cadenceDays int
+		billingDay  int
+		today       time.Time
+		count       int
+		want        []time.Time
+	}{
+		{
+			name: "monthly from a future due date", due: date(2024, 3, 15), cadenceDays: 30, billingDay: 15,
+			today: date(2024, 3, 1), count: 3,
+			want: []time.Time{date(2024, 3, 15), date(2024, 4, 15), date(2024, 5, 15)},
+		},
+		{
+			name: "monthly on the 31st clamps without drifting", due: date(2024, 1, 31), cadenceDays: 30, billingDay: 31,
+			today: date(2024, 1, 10), count: 4,
+			want: []time.Time{date(2024, 1, 31), date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30)},
+		},
+		{
+			name: "past due date rolls forward first", due: date(2024, 1, 5), cadenceDays: 30, billingDay: 5,
+			today: date(2024, 3, 20), count: 2,
+			want: []time.Time{date(2024, 4, 5), date(2024, 5, 5)},
+		},
+		{
+			name: "weekly steps by days", due: date(2024, 3, 4), cadenceDays: 7,
+			today: date(2024, 3, 1), count: 3,
+			want: []time.Time{date(2024, 3, 4), date(2024, 3, 11), date(2024, 3, 18)},
+		},
+		{name: "no cadence", due: date(2024, 3, 4), today: date(2024, 3, 1), count: 3, want: []time.Time{}},
+	}
+	for _, tt := range tests {
+		got := Occurrences(tt.due, tt.cadenceDays, tt.billingDay, tt.today, tt.count)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: occurrence %d = %s, want %s", tt.name, i, got[i].Format("2006-01-02"), tt.want[i].Format("2006-01-02"))
+			}
+		}
+	}
+}
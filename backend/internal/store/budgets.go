@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// Budget periods.
+const (
+	BudgetWeekly  = "weekly"
+	BudgetMonthly = "monthly"
+)
+
+var (
+	// ErrBudgetNotFound is returned when a budget does not exist or
+	// belongs to another user.
+	ErrBudgetNotFound = errors.New("budget not found")
+	// ErrBudgetExists is returned when the user already has a budget for
+	// the category (ignoring case) and period.
+	ErrBudgetExists = errors.New("a budget for this category and period already exists")
+)
+
+// Budget is a spending limit for a transaction category over a week or a
+// calendar month. Category matches transactions in that category or any
+// of its subcategories, ignoring case.
+type Budget struct {
+	ID         uuid.UUID `json:"id"`
+	Category   string    `json:"category"`
+	LimitCents int       `json:"limitCents"`
+	Period     string    `json:"period"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// validateBudget normalizes b and checks its fields. An empty period
+// means monthly.
+func validateBudget(b *Budget) error {
+	b.Category = strings.TrimSpace(b.Category)
+	if b.Period == "" {
+		b.Period = BudgetMonthly
+	}
+	if b.Category == "" || b.LimitCents <= 0 || (b.Period != BudgetWeekly && b.Period != BudgetMonthly) {
+		return errors.New("invalid budget fields")
+	}
+	return nil
+}
+
+// CreateBudget stores a new budget for the user.
+func CreateBudget(ctx context.Context, d *db.DB, userID uuid.UUID, b Budget) (*Budget, error) {
+	if err := validateBudget(&b); err != nil {
+		return nil, err
+	}
+	b.ID = uuid.New()
+	err := d.QueryRowContext(ctx, `
+        INSERT INTO budgets (id, user_id, category, limit_cents, period)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING created_at
+    `, b.ID, userID, b.Category, b.LimitCents, b.Period).Scan(&b.CreatedAt)
+	if db.IsUniqueViolation(err) {
+		return nil, ErrBudgetExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetBudgets returns the user's budgets ordered by period and category.
+func GetBudgets(ctx context.Context, d *db.DB, userID uuid.UUID) ([]Budget, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT id, category, limit_cents, period, created_at
+        FROM budgets
+        WHERE user_id = $1
+        ORDER BY period ASC, category ASC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	budgets := []Budget{}
+	for rows.Next() {
+		var b Budget
+		if err := rows.Scan(&b.ID, &b.Category, &b.LimitCents, &b.Period, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// UpdateBudget replaces the category, limit and period of one of the
+// user's budgets.
+func UpdateBudget(ctx context.Context, d *db.DB, userID uuid.UUID, b Budget) (*Budget, error) {
+	if err := validateBudget(&b); err != nil {
+		return nil, err
+	}
+	err := d.QueryRowContext(ctx, `
+        UPDATE budgets
+        SET category = $3, limit_cents = $4, period = $5
+        WHERE user_id = $1 AND id = $2
+        RETURNING created_at
+    `, userID, b.ID, b.Category, b.LimitCents, b.Period).Scan(&b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBudgetNotFound
+	}
+	if db.IsUniqueViolation(err) {
+		return nil, ErrBudgetExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// DeleteBudget removes one of the user's budgets.
+func DeleteBudget(ctx context.Context, d *db.DB, userID, id uuid.UUID) error {
+	res, err := d.ExecContext(ctx, `DELETE FROM budgets WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrBudgetNotFound
+	}
+	return nil
+}
+
+// BudgetStatus is a budget's spending in the current period.
+// OverageCents is how far spending is over the limit, zero when it isn't.
+type BudgetStatus struct {
+	Budget
+	PeriodStart    string `json:"periodStart"`
+	PeriodEnd      string `json:"periodEnd"`
+	SpentCents     int    `json:"spentCents"`
+	RemainingCents int    `json:"remainingCents"`
+	OverLimit      bool   `json:"overLimit"`
+	OverageCents   int    `json:"overageCents"`
+}
+
+// BudgetsStatus is every budget's status on one day, plus what is left of
+// the monthly budgets this month in total (negative when over overall).
+// The totals count each transaction category once, however many budgets
+// cover it, and only the limits of budgets that aren't subcategories of
+// another monthly budget, since the broader limit already includes them.
+type BudgetsStatus struct {
+	Budgets                 []BudgetStatus `json:"budgets"`
+	OverLimit               []string       `json:"overLimit"`
+	MonthlyLimitCents       int            `json:"monthlyLimitCents"`
+	SpentThisMonthCents     int            `json:"spentThisMonthCents"`
+	RemainingThisMonthCents int            `json:"remainingThisMonthCents"`
+}
+
+// GetBudgetsStatus compares each budget's limit with the user's spending
+// in the week (from Monday) or calendar month containing day, in loc.
+// Spending is the net of the category's transactions from every source,
+// pending ones included: outflows count and refunds in the category take
+// them back off, never below zero. Weekly budgets are reported but left
+// out of the monthly totals.
+func GetBudgetsStatus(ctx context.Context, d *db.DB, userID uuid.UUID, day time.Time, loc *time.Location) (*BudgetsStatus, error) {
+	budgets, err := GetBudgets(ctx, d, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// txn_date is a DATE, so periods are compared as UTC dates.
+	y, m, dd := day.In(loc).Date()
+	date := time.Date(y, m, dd, 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	weekStart := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+	weekEnd := weekStart.AddDate(0, 0, 7)
+
+	var monthly, weekly map[string]int
+	for _, b := range budgets {
+		if b.Period == BudgetWeekly && weekly == nil {
+			if weekly, err = spendingByCategory(ctx, d, userID, weekStart, weekEnd); err != nil {
+				return nil, err
+			}
+		}
+		if b.Period == BudgetMonthly && monthly == nil {
+			if monthly, err = spendingByCategory(ctx, d, userID, monthStart, monthEnd); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	status := &BudgetsStatus{Budgets: make([]BudgetStatus, 0, len(budgets)), OverLimit: []string{}}
+	for _, b := range budgets {
+		s := BudgetStatus{Budget: b}
+		spending, start, end := monthly, monthStart, monthEnd
+		if b.Period == BudgetWeekly {
+			spending, start, end = weekly, weekStart, weekEnd
+		}
+		s.PeriodStart = start.Format("2006-01-02")
+		s.PeriodEnd = end.AddDate(0, 0, -1).Format("2006-01-02")
+		for category, cents := range spending {
+			if categoryMatches(category, b.Category) {
+				s.SpentCents += cents
+			}
+		}
+		if s.SpentCents < 0 {
+			s.SpentCents = 0
+		}
+		s.RemainingCents = b.LimitCents - s.SpentCents
+		if s.RemainingCents < 0 {
+			s.OverLimit = true
+			s.OverageCents = -s.RemainingCents
+			status.OverLimit = append(status.OverLimit, b.Category)
+		}
+		status.Budgets = append(status.Budgets, s)
+	}
+
+	var monthlyCategories []string
+	for _, b := range budgets {
+		if b.Period == BudgetMonthly {
+			monthlyCategories = append(monthlyCategories, b.Category)
+		}
+	}
+	for _, b := range budgets {
+		if b.Period == BudgetMonthly && !nestedBudget(b.Category, monthlyCategories) {
+			status.MonthlyLimitCents += b.LimitCents
+		}
+	}
+	for category, cents := range monthly {
+		for _, budget := range monthlyCategories {
+			if categoryMatches(category, budget) {
+				status.SpentThisMonthCents += cents
+				break
+			}
+		}
+	}
+	if status.SpentThisMonthCents < 0 {
+		status.SpentThisMonthCents = 0
+	}
+	status.RemainingThisMonthCents = status.MonthlyLimitCents - status.SpentThisMonthCents
+	return status, nil
+}
+
+// nestedBudget reports whether category is a subcategory of one of the
+// others.
+func nestedBudget(category string, others []string) bool {
+	for _, other := range others {
+		if !strings.EqualFold(category, other) && categoryMatches(category, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// spendingByCategory sums the user's transactions in [from, to) by stored
+// category. Amounts follow Plaid's sign: outflows are positive.
+func spendingByCategory(ctx context.Context, d *db.DB, userID uuid.UUID, from, to time.Time) (map[string]int, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT COALESCE(category, ''), SUM(amount_cents)
+        FROM transactions
+        WHERE user_id = $1 AND txn_date >= $2 AND txn_date < $3
+        GROUP BY 1
+    `, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	spending := map[string]int{}
+	for rows.Next() {
+		var category string
+		var cents int
+		if err := rows.Scan(&category, &cents); err != nil {
+			return nil, err
+		}
+		spending[category] = cents
+	}
+	return spending, rows.Err()
+}
+
+// categoryMatches reports whether a stored category is budget or one of
+// its subcategories, ignoring case.
+func categoryMatches(category, budget string) bool {
+	category, budget = strings.ToLower(category), strings.ToLower(budget)
+	return category == budget || strings.HasPrefix(category, budget+strings.ToLower(CategorySeparator))
+}
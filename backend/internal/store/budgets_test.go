@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func TestBudgetsStatusCountsOverlappingBudgetsOnce(t *testing.T) {
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM budgets"):
+			return dbtest.Rows([]string{"id", "category", "limit_cents", "period", "created_at"},
+				[]any{uuid.NewString(), "Food", 50000, "monthly", created},
+				[]any{uuid.NewString(), "food > restaurants", 20000, "monthly", created},
+				[]any{uuid.NewString(), "Travel", 30000, "monthly", created},
+				[]any{uuid.NewString(), "Food", 10000, "weekly", created},
+			)
+		case strings.Contains(q.SQL, "FROM transactions"):
+			return dbtest.Rows([]string{"category", "sum"},
+				[]any{"Food > Restaurants", 15000},
+				[]any{"Food > Groceries", 10000},
+				[]any{"Travel > Flights", 5000},
+				[]any{"Shops", 7000},
+			)
+		}
+		return dbtest.Result{}
+	})
+
+	day := time.Date(2024, 6, 12, 12, 0, 0, 0, time.UTC)
+	status, err := GetBudgetsStatus(context.Background(), d, uuid.New(), day, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spent := map[string]int{}
+	for _, b := range status.Budgets {
+		if b.Period == BudgetMonthly {
+			spent[b.Category] = b.SpentCents
+		}
+	}
+	if spent["Food"] != 25000 || spent["food > restaurants"] != 15000 || spent["Travel"] != 5000 {
+		t.Errorf("monthly budgets spent %v, want Food 25000, restaurants 15000, Travel 5000", spent)
+	}
+	// Restaurants spending is in both Food budgets but counts once, and the
+	// restaurants limit is part of the Food limit. Weekly budgets and
+	// unbudgeted categories are left out.
+	if status.SpentThisMonthCents != 30000 {
+		t.Errorf("spent this month = %d, want 30000", status.SpentThisMonthCents)
+	}
+	if status.MonthlyLimitCents != 80000 {
+		t.Errorf("monthly limit = %d, want 80000", status.MonthlyLimitCents)
+	}
+	if status.RemainingThisMonthCents != 50000 {
+		t.Errorf("remaining this month = %d, want 50000", status.RemainingThisMonthCents)
+	}
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// Burn is a user's spending for a single day.
+type Burn struct {
+	Date       string        `json:"date"`
+	TotalCents int           `json:"totalCents"`
+	Breakdown  BurnBreakdown `json:"breakdown"`
+}
+
+// BurnBreakdown itemizes a Burn. Commutes list each trip's full cost and
+// the user's share of it; CommuteCents sums the shares, which is what the
+// total counts. Food is the profile food cost when the day is an office
+// day, otherwise zero. SkippedOffice is set when commutes and food were
+// left out because the user skipped the day's in-person events.
+type BurnBreakdown struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+	Commutes      []CommuteEntry `json:"commutes"`
+	CommuteCents  int            `json:"commuteCents"`
+	Food          int            `json:"food"`
+	SkippedOffice bool           `json:"skippedOffice,omitempty"`
+}
+
+// BurnOptions adjusts DailyBurn.
+type BurnOptions struct {
+	// UseAttendance drops commutes and food on days whose in-person events
+	// were all marked skipped.
+	UseAttendance bool
+}
+
+// DailyBurn sums what the user spends on the calendar day of day in loc:
+// subscriptions billing that day, the user's share of commutes logged that
+// day, and the profile food cost on office days. Subscription billing dates are projected
+// forward from next_due by cadence, so future days include upcoming
+// charges; days before a subscription's next_due do not include it.
+func DailyBurn(ctx context.Context, d *db.DB, userID uuid.UUID, day time.Time, loc *time.Location, opts BurnOptions) (*Burn, error) {
+	y, m, dd := day.In(loc).Date()
+	start := time.Date(y, m, dd, 0, 0, 0, 0, loc)
+	end := time.Date(y, m, dd+1, 0, 0, 0, 0, loc)
+	// next_due is a DATE, so billing dates are compared as UTC dates.
+	date := time.Date(y, m, dd, 0, 0, 0, 0, time.UTC)
+
+	burn := &Burn{
+		Date: date.Format("2006-01-02"),
+		Breakdown: BurnBreakdown{
+			Subscriptions: []Subscription{},
+		},
+	}
+
+	subs, err := GetSubscriptions(ctx, d, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		if sub.NextDue == nil || date.Before(*sub.NextDue) {
+			continue
+		}
+		if RollForwardDue(*sub.NextDue, sub.CadenceDays, sub.BillingDay, date).Equal(date) {
+			burn.Breakdown.Subscriptions = append(burn.Breakdown.Subscriptions, sub)
+			burn.TotalCents += sub.AmountCents
+		}
+	}
+
+	if opts.UseAttendance {
+		events, err := GetTodayEvents(ctx, d, userID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if skippedInPerson(events) {
+			burn.Breakdown.Commutes = []CommuteEntry{}
+			burn.Breakdown.SkippedOffice = true
+			return burn, nil
+		}
+	}
+
+	commutes, err := GetCommuteEntries(ctx, d, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	burn.Breakdown.Commutes = commutes
+	for _, entry := range commutes {
+		burn.Breakdown.CommuteCents += entry.ShareCents
+	}
+	burn.TotalCents += burn.Breakdown.CommuteCents
+
+	prof, err := GetProfile(ctx, d, userID)
+	if err != nil {
+		return nil, err
+	}
+	if prof != nil && IsOfficeDay(start, prof.InOfficeDays) {
+		burn.Breakdown.Food = prof.FoodCostCents
+		burn.TotalCents += prof.FoodCostCents
+	}
+	return burn, nil
+}
+
+// IsOfficeDay reports whether day is one of the user's in-office days. The
+// profile only records how many days a week the user is in the office, so
+// those are taken to be the first inOfficeDays weekdays starting Monday.
+func IsOfficeDay(day time.Time, inOfficeDays int) bool {
+	wd := day.Weekday()
+	if wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	return int(wd) <= inOfficeDays
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// BurnDay is one day of a BurnSeries.
+type BurnDay struct {
+	Date              string `json:"date"`
+	SubscriptionCents int    `json:"subscriptionCents"`
+	CommuteCents      int    `json:"commuteCents"`
+	FoodCents         int    `json:"foodCents"`
+	TotalCents        int    `json:"totalCents"`
+	SkippedOffice     bool   `json:"skippedOffice,omitempty"`
+}
+
+// BurnSeries is a user's spending day by day over [From, To], inclusive,
+// with totals for the whole period.
+type BurnSeries struct {
+	From              string    `json:"from"`
+	To                string    `json:"to"`
+	Days              []BurnDay `json:"days"`
+	SubscriptionCents int       `json:"subscriptionCents"`
+	CommuteCents      int       `json:"commuteCents"`
+	FoodCents         int       `json:"foodCents"`
+	TotalCents        int       `json:"totalCents"`
+}
+
+// BurnRange is DailyBurn over the days days starting on the calendar day
+// of start in loc. Unlike DailyBurn, subscriptions are pro-rated rather
+// than counted on their billing day: each active subscription's
+// AnnualizedCents is spread evenly over the year, so a trend isn't
+// dominated by whichever days happen to be billing days.
+func BurnRange(ctx context.Context, d *db.DB, userID uuid.UUID, start time.Time, days int, loc *time.Location, opts BurnOptions) (*BurnSeries, error) {
+	y, m, dd := start.In(loc).Date()
+	from := time.Date(y, m, dd, 0, 0, 0, 0, loc)
+	to := time.Date(y, m, dd+days, 0, 0, 0, 0, loc)
+
+	subs, err := GetSubscriptions(ctx, d, userID)
+	if err != nil {
+		return nil, err
+	}
+	commutes, err := GetCommuteEntries(ctx, d, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	prof, err := GetProfile(ctx, d, userID)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	if opts.UseAttendance {
+		if events, err = GetEventsInRange(ctx, d, userID, from, to); err != nil {
+			return nil, err
+		}
+	}
+	return ComputeBurnSeries(from, days, loc, subs, commutes, prof, events, opts), nil
+}
+
+// ComputeBurnSeries builds a BurnSeries from already loaded data, as
+// BurnRange describes: subs are pro-rated, commutes count the user's share
+// on the day they were logged, and the profile food cost is added on
+// office days. prof may be nil. events are only used with
+// opts.UseAttendance.
+func ComputeBurnSeries(start time.Time, days int, loc *time.Location, subs []Subscription, commutes []CommuteEntry, prof *Profile, events []Event, opts BurnOptions) *BurnSeries {
+	y, m, dd := start.In(loc).Date()
+	series := &BurnSeries{
+		From: time.Date(y, m, dd, 0, 0, 0, 0, time.UTC).Format("2006-01-02"),
+		To:   time.Date(y, m, dd+days-1, 0, 0, 0, 0, time.UTC).Format("2006-01-02"),
+		Days: make([]BurnDay, 0, days),
+	}
+
+	annual := 0
+	for _, sub := range subs {
+		if sub.IsActive {
+			annual += AnnualizedCents(sub)
+		}
+	}
+
+	for i := 0; i < days; i++ {
+		dayStart := time.Date(y, m, dd+i, 0, 0, 0, 0, loc)
+		dayEnd := time.Date(y, m, dd+i+1, 0, 0, 0, 0, loc)
+		day := BurnDay{Date: dayStart.Format("2006-01-02")}
+
+		// Spread the annual cost by day of year so rounding doesn't drift:
+		// the days of a year add up to the annual cost.
+		doy := dayStart.YearDay() - 1
+		day.SubscriptionCents = annual*(doy+1)/365 - annual*doy/365
+
+		if opts.UseAttendance && skippedInPerson(eventsOverlapping(events, dayStart, dayEnd)) {
+			day.SkippedOffice = true
+		} else {
+			for _, entry := range commutes {
+				if !entry.Date.Before(dayStart) && entry.Date.Before(dayEnd) {
+					day.CommuteCents += entry.UserShareCents()
+				}
+			}
+			if prof != nil && IsOfficeDay(dayStart, prof.InOfficeDays) {
+				day.FoodCents = prof.FoodCostCents
+			}
+		}
+
+		day.TotalCents = day.SubscriptionCents + day.CommuteCents + day.FoodCents
+		series.SubscriptionCents += day.SubscriptionCents
+		series.CommuteCents += day.CommuteCents
+		series.FoodCents += day.FoodCents
+		series.TotalCents += day.TotalCents
+		series.Days = append(series.Days, day)
+	}
+	return series
+}
+
+// eventsOverlapping returns the events that overlap [start, end), the same
+// test GetEventsInRange applies in SQL.
+func eventsOverlapping(events []Event, start, end time.Time) []Event {
+	var out []Event
+	for _, e := range events {
+		if e.Start.Before(end) && (!e.Start.Before(start) || e.End.After(start)) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+var profileCols = []string{"home_addr", "office_addr", "city", "state", "hourly_cents", "hours_per_week",
+	"stipend_cents", "pay_freq", "start_date", "in_office_days", "food_cost_cents",
+	"reminder_minutes_before", "school"}
+
+// burnDB scripts one user's subscriptions and logged commutes, and a
+// profile with three office days and $15 food. Commutes are filtered by
+// the queried range as Postgres would.
+func burnDB(subs [][]any, commutes [][]any) dbtest.Handler {
+	return func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM subscriptions"):
+			return dbtest.Rows(subscriptionCols, subs...)
+		case strings.Contains(q.SQL, "FROM commute_entries"):
+			from, to := q.Args[1].(time.Time), q.Args[2].(time.Time)
+			var in [][]any
+			for _, c := range commutes {
+				if at := c[1].(time.Time); !at.Before(from) && at.Before(to) {
+					in = append(in, c)
+				}
+			}
+			return dbtest.Rows([]string{"id", "occurred_at", "from_addr", "to_addr", "cost_cents", "method", "split_with", "share_percent"}, in...)
+		case strings.Contains(q.SQL, "FROM profiles"):
+			return dbtest.Rows(profileCols, []any{"", "", "Austin", "TX", nil, nil, nil, "", nil, 3, 1500, "{10}", ""})
+		}
+		return dbtest.Result{}
+	}
+}
+
+func TestDailyBurn(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stored next_due is overdue; billing dates are projected from it.
+	subs := [][]any{{uuid.NewString(), "Spotify", 1099, 30, date(2024, time.May, 15), "manual", true, 15}}
+	commutes := [][]any{
+		{uuid.NewString(), time.Date(2024, 6, 3, 8, 30, 0, 0, chicago), "Home", "Office", 1800, "rideshare", 2, 0},
+		// 11pm Chicago on June 3 is June 4 in UTC but still June 3 locally.
+		{uuid.NewString(), time.Date(2024, 6, 3, 23, 0, 0, 0, chicago), "Office", "Home", 1200, "rideshare", 0, 0},
+		{uuid.NewString(), time.Date(2024, 6, 4, 9, 0, 0, 0, chicago), "Home", "Office", 2000, "rideshare", 0, 0},
+	}
+	d, rec := dbtest.Open(t, burnDB(subs, commutes))
+	ctx := context.Background()
+
+	// Past Monday: two commutes (one split two ways) and food, no charge.
+	burn, err := DailyBurn(ctx, d, uuid.New(), time.Date(2024, 6, 3, 12, 0, 0, 0, chicago), chicago, BurnOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if burn.Date != "2024-06-03" || len(burn.Breakdown.Commutes) != 2 || burn.Breakdown.CommuteCents != 900+1200 {
+		t.Errorf("past day = %s with commutes %+v (%d cents), want 2024-06-03 with 2 commutes, 2100 cents",
+			burn.Date, burn.Breakdown.Commutes, burn.Breakdown.CommuteCents)
+	}
+	if burn.TotalCents != 2100+1500 || len(burn.Breakdown.Subscriptions) != 0 {
+		t.Errorf("past day total = %d with subs %+v, want 3600 and none", burn.TotalCents, burn.Breakdown.Subscriptions)
+	}
+
+	// Future Saturday the 15th: the monthly charge, no commutes or food.
+	burn, err = DailyBurn(ctx, d, uuid.New(), time.Date(2024, 6, 15, 12, 0, 0, 0, chicago), chicago, BurnOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(burn.Breakdown.Subscriptions) != 1 || burn.TotalCents != 1099 {
+		t.Errorf("future day = %d cents with subs %+v, want the 1099 Spotify charge only", burn.TotalCents, burn.Breakdown.Subscriptions)
+	}
+
+	if n := rec.Count("UPDATE"); n != 0 {
+		t.Errorf("DailyBurn ran %d updates, want none", n)
+	}
+}
+
+func TestBurnRangeIsReadOnly(t *testing.T) {
+	// next_due is long past; the pro-rated series doesn't depend on it.
+	subs := [][]any{{uuid.NewString(), "Spotify", 1099, 30, date(2023, time.January, 15), "manual", true, 15}}
+	d, rec := dbtest.Open(t, burnDB(subs, nil))
+
+	series, err := BurnRange(context.Background(), d, uuid.New(), date(2024, time.June, 3), 7, time.UTC, BurnOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Days) != 7 || series.SubscriptionCents == 0 {
+		t.Errorf("series = %d days with %d subscription cents, want 7 days with a pro-rated charge", len(series.Days), series.SubscriptionCents)
+	}
+	if n := rec.Count("UPDATE"); n != 0 {
+		t.Errorf("BurnRange ran %d updates, want none", n)
+	}
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// ErrCampusSourceNotFound is returned when a school has no events feed.
+var ErrCampusSourceNotFound = errors.New("campus event source not found")
+
+// CampusEvent is an event from a school's public events feed.
+type CampusEvent struct {
+	ID       uuid.UUID `json:"id"`
+	School   string    `json:"school"`
+	ExtID    string    `json:"-"`
+	Title    string    `json:"title"`
+	Start    time.Time `json:"date"`
+	End      time.Time `json:"end"`
+	Location string    `json:"location"`
+	Category string    `json:"category"`
+	URL      string    `json:"url,omitempty"`
+}
+
+// CampusEventSource is a school's events feed. Format is "ics" or "json".
+type CampusEventSource struct {
+	School       string     `json:"school"`
+	FeedURL      string     `json:"feedUrl"`
+	Format       string     `json:"format"`
+	LastSyncedAt *time.Time `json:"lastSyncedAt,omitempty"`
+}
+
+// GetCampusEvents returns the school's events overlapping [from, to),
+// ordered by start. A non-empty category limits them to that category,
+// compared case-insensitively.
+func GetCampusEvents(ctx context.Context, d *db.DB, school string, from, to time.Time, category string) ([]CampusEvent, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT id, school, ext_id, title, start_ts, end_ts,
+               COALESCE(location, ''), COALESCE(category, ''), COALESCE(url, '')
+        FROM campus_events
+        WHERE school = $1
+          AND start_ts < $3 AND (end_ts > $2 OR start_ts >= $2)
+          AND ($4 = '' OR lower(category) = lower($4))
+        ORDER BY start_ts ASC
+    `, school, from, to, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []CampusEvent
+	for rows.Next() {
+		var e CampusEvent
+		if err := rows.Scan(&e.ID, &e.School, &e.ExtID, &e.Title, &e.Start, &e.End, &e.Location, &e.Category, &e.URL); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ReplaceCampusEvents stores a school's events from one feed ingestion:
+// events are upserted by ext_id, and stored events starting at or after
+// from that the feed no longer lists are deleted.
+func ReplaceCampusEvents(ctx context.Context, d *db.DB, school string, from time.Time, events []CampusEvent) error {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keep := make([]string, 0, len(events))
+	for _, e := range events {
+		keep = append(keep, e.ExtID)
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO campus_events (school, ext_id, title, start_ts, end_ts, location, category, url)
+            VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''))
+            ON CONFLICT (school, ext_id)
+            DO UPDATE SET
+                title = EXCLUDED.title,
+                start_ts = EXCLUDED.start_ts,
+                end_ts = EXCLUDED.end_ts,
+                location = EXCLUDED.location,
+                category = EXCLUDED.category,
+                url = EXCLUDED.url,
+                updated_at = NOW()
+        `, school, e.ExtID, e.Title, e.Start, e.End, e.Location, e.Category, e.URL)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM campus_events
+        WHERE school = $1 AND start_ts >= $2 AND NOT (ext_id = ANY($3))
+    `, school, from, keep)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+        UPDATE campus_event_sources SET last_synced_at = NOW() WHERE school = $1
+    `, school)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCampusEventSources returns every configured school feed.
+func GetCampusEventSources(ctx context.Context, d *db.DB) ([]CampusEventSource, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT school, feed_url, format, last_synced_at
+        FROM campus_event_sources
+        ORDER BY school
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []CampusEventSource
+	for rows.Next() {
+		var s CampusEventSource
+		var synced sql.NullTime
+		if err := rows.Scan(&s.School, &s.FeedURL, &s.Format, &synced); err != nil {
+			return nil, err
+		}
+		if synced.Valid {
+			s.LastSyncedAt = &synced.Time
+		}
+		sources = append(sources, s)
+	}
+	return sources, rows.Err()
+}
+
+// GetCampusEventSource returns the school's feed, or
+// ErrCampusSourceNotFound.
+func GetCampusEventSource(ctx context.Context, d *db.DB, school string) (*CampusEventSource, error) {
+	var s CampusEventSource
+	var synced sql.NullTime
+	err := d.QueryRowContext(ctx, `
+        SELECT school, feed_url, format, last_synced_at
+        FROM campus_event_sources
+        WHERE school = $1
+    `, school).Scan(&s.School, &s.FeedURL, &s.Format, &synced)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCampusSourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if synced.Valid {
+		s.LastSyncedAt = &synced.Time
+	}
+	return &s, nil
+}
+
+// UpsertCampusEventSource creates or replaces the school's feed.
+func UpsertCampusEventSource(ctx context.Context, d *db.DB, s CampusEventSource) error {
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO campus_event_sources (school, feed_url, format)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (school)
+        DO UPDATE SET feed_url = EXCLUDED.feed_url, format = EXCLUDED.format
+    `, s.School, s.FeedURL, s.Format)
+	return err
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// CommuteEntry is a single logged trip and its cost in cents. CostCents is
+// the full fare; a shared ride sets SplitWith (the number of people who
+// split it, including the user) or SharePercent (the user's percentage of
+// the fare), and ShareCents is what the user actually paid.
+type CommuteEntry struct {
+	ID           uuid.UUID `json:"id"`
+	Date         time.Time `json:"date"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	CostCents    int       `json:"costCents"`
+	Method       string    `json:"method"`
+	SplitWith    int       `json:"splitWith,omitempty"`
+	SharePercent int       `json:"sharePercent,omitempty"`
+	ShareCents   int       `json:"shareCents"`
+}
+
+// UserShareCents is the user's part of the fare, rounded to the nearest
+// cent: SharePercent of it when set, else an even split SplitWith ways.
+func (e CommuteEntry) UserShareCents() int {
+	if e.SharePercent > 0 {
+		return (e.CostCents*e.SharePercent + 50) / 100
+	}
+	if e.SplitWith > 1 {
+		return (e.CostCents + e.SplitWith/2) / e.SplitWith
+	}
+	return e.CostCents
+}
+
+// CreateCommuteEntry stores a trip for the user. A zero Date means now and
+// a zero SplitWith means the user paid alone. SplitWith and SharePercent
+// are alternatives, so only one may be set.
+func CreateCommuteEntry(ctx context.Context, d *db.DB, userID uuid.UUID, e CommuteEntry) (*CommuteEntry, error) {
+	if e.CostCents < 0 || e.SplitWith < 0 || e.SharePercent < 0 || e.SharePercent > 100 ||
+		(e.SplitWith > 1 && e.SharePercent > 0) {
+		return nil, errors.New("invalid commute entry fields")
+	}
+	if e.SplitWith == 0 {
+		e.SplitWith = 1
+	}
+	if e.Date.IsZero() {
+		e.Date = time.Now().UTC()
+	}
+	e.ID = uuid.New()
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO commute_entries (id, user_id, occurred_at, from_addr, to_addr, cost_cents, method, split_with, share_percent)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, 0))
+    `, e.ID, userID, e.Date, e.From, e.To, e.CostCents, e.Method, e.SplitWith, e.SharePercent)
+	if err != nil {
+		return nil, err
+	}
+	e.ShareCents = e.UserShareCents()
+	return &e, nil
+}
+
+// GetCommuteEntries returns the user's trips in [from, to), oldest first.
+// A zero from or to leaves that side of the range open.
+func GetCommuteEntries(ctx context.Context, d db.Querier, userID uuid.UUID, from, to time.Time) ([]CommuteEntry, error) {
+	var fromArg, toArg interface{}
+	if !from.IsZero() {
+		fromArg = from
+	}
+	if !to.IsZero() {
+		toArg = to
+	}
+	rows, err := d.QueryContext(ctx, `
+        SELECT id, occurred_at, from_addr, to_addr, cost_cents, method,
+               split_with, COALESCE(share_percent, 0)
+        FROM commute_entries
+        WHERE user_id = $1
+          AND ($2::timestamptz IS NULL OR occurred_at >= $2)
+          AND ($3::timestamptz IS NULL OR occurred_at < $3)
+        ORDER BY occurred_at ASC, id ASC
+    `, userID, fromArg, toArg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []CommuteEntry{}
+	for rows.Next() {
+		var e CommuteEntry
+		var fromAddr, toAddr, method sql.NullString
+		if err := rows.Scan(&e.ID, &e.Date, &fromAddr, &toAddr, &e.CostCents, &method, &e.SplitWith, &e.SharePercent); err != nil {
+			return nil, err
+		}
+		e.From, e.To, e.Method = fromAddr.String, toAddr.String, method.String
+		e.ShareCents = e.UserShareCents()
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"dayboard/backend/internal/db"
+)
+
+// CityCostModel holds commute pricing for a market, in cents.
+type CityCostModel struct {
+	City             string `json:"city"`
+	State            string `json:"state,omitempty"`
+	BaseFareCents    int    `json:"baseFareCents"`
+	PerMileCents     int    `json:"perMileCents"`
+	PerMinuteCents   int    `json:"perMinuteCents"`
+	TransitFareCents int    `json:"transitFareCents"` // flat fare per transit ride
+}
+
+// DefaultCityCostModel is used for cities without a model of their own.
+var DefaultCityCostModel = CityCostModel{
+	BaseFareCents:    200, // $2 base fare
+	PerMileCents:     150, // $1.50 per mile
+	PerMinuteCents:   25,  // $0.25 per minute
+	TransitFareCents: 275, // $2.75 per transit ride
+}
+
+// GetCityCostModel returns the cost model for a city, matching names
+// case-insensitively. A model recorded for the given state wins over one
+// with no state. Returns (nil, nil) when the city has no model.
+func GetCityCostModel(ctx context.Context, d *db.DB, city, state string) (*CityCostModel, error) {
+	var m CityCostModel
+	var modelState sql.NullString
+	var transitFare sql.NullInt64
+	err := d.QueryRowContext(ctx, `
+        SELECT city, state, base_fare_cents, per_mile_cents, per_minute_cents, transit_fare_cents
+        FROM city_cost_models
+        WHERE lower(city) = lower($1) AND (state IS NULL OR upper(state) = upper($2))
+        ORDER BY state NULLS LAST
+        LIMIT 1
+    `, city, state).Scan(&m.City, &modelState, &m.BaseFareCents, &m.PerMileCents, &m.PerMinuteCents, &transitFare)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.State = modelState.String
+	m.TransitFareCents = DefaultCityCostModel.TransitFareCents
+	if transitFare.Valid {
+		m.TransitFareCents = int(transitFare.Int64)
+	}
+	return &m, nil
+}
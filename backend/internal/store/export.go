@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// ExportAccount is the users row in an export, without the password hash.
+type ExportAccount struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// UserExport is everything stored for one user, as returned by
+// GET /export/all. Subscriptions include inactive ones and events and
+// transactions cover every source and date. Profile is nil if the user
+// never saved one.
+type UserExport struct {
+	ExportedAt     time.Time      `json:"exportedAt"`
+	Account        ExportAccount  `json:"account"`
+	Profile        *Profile       `json:"profile"`
+	Subscriptions  []Subscription `json:"subscriptions"`
+	Events         []Event        `json:"events"`
+	CommuteEntries []CommuteEntry `json:"commuteEntries"`
+	Transactions   []Transaction  `json:"transactions"`
+}
+
+// ExportUserData collects the user's data for export. Every query is
+// filtered by userID and runs in one read-only transaction, so the export
+// is a consistent snapshot. It returns sql.ErrNoRows if the user doesn't
+// exist.
+func ExportUserData(ctx context.Context, d *db.DB, userID uuid.UUID) (*UserExport, error) {
+	tx, err := d.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	out := &UserExport{ExportedAt: time.Now().UTC()}
+	var createdAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+        SELECT id, email, name, created_at FROM users WHERE id = $1
+    `, userID).Scan(&out.Account.ID, &out.Account.Email, &out.Account.Name, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	out.Account.CreatedAt = createdAt.Time
+
+	if out.Profile, err = GetProfile(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT `+subscriptionColumns+`
+        FROM subscriptions
+        WHERE user_id = $1
+        ORDER BY `+subscriptionOrder, userID)
+	if err != nil {
+		return nil, err
+	}
+	if out.Subscriptions, err = scanSubscriptions(rows); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+        SELECT `+eventColumns+`
+        FROM calendar_events
+        WHERE user_id = $1
+        ORDER BY start_ts ASC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	if out.Events, err = scanEvents(rows); err != nil {
+		return nil, err
+	}
+
+	if out.CommuteEntries, err = GetCommuteEntries(ctx, tx, userID, time.Time{}, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+        SELECT `+transactionColumns+`
+        FROM transactions
+        WHERE user_id = $1
+        ORDER BY txn_date DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	if out.Transactions, err = scanTransactions(rows); err != nil {
+		return nil, err
+	}
+
+	// Empty lists export as [] rather than null.
+	if out.Subscriptions == nil {
+		out.Subscriptions = []Subscription{}
+	}
+	if out.Events == nil {
+		out.Events = []Event{}
+	}
+	if out.Transactions == nil {
+		out.Transactions = []Transaction{}
+	}
+	return out, nil
+}
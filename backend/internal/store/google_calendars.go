@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// GetSelectedCalendars returns the IDs of the Google calendars the user has
+// chosen to sync. An empty result means only the primary calendar.
+func GetSelectedCalendars(ctx context.Context, d *db.DB, userID uuid.UUID) ([]string, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT calendar_id
+        FROM google_calendars
+        WHERE user_id = $1
+        ORDER BY created_at, calendar_id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetSelectedCalendars replaces the user's calendar selection with ids. An
+// empty ids resets the user to syncing only their primary calendar.
+func SetSelectedCalendars(ctx context.Context, d *db.DB, userID uuid.UUID, ids []string) error {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM google_calendars WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO google_calendars (user_id, calendar_id)
+            VALUES ($1, $2)
+            ON CONFLICT (user_id, calendar_id) DO NOTHING
+        `, userID, id)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCalendarSyncToken returns the stored sync token for one of the user's
+// calendars and the end of the window it covers. The token is empty if the
+// calendar has never been synced.
+func GetCalendarSyncToken(ctx context.Context, d *db.DB, userID uuid.UUID, calendarID string) (string, time.Time, error) {
+	var token string
+	var windowEnd time.Time
+	err := d.QueryRowContext(ctx, `
+        SELECT sync_token, window_end
+        FROM google_sync_tokens
+        WHERE user_id = $1 AND calendar_id = $2
+    `, userID, calendarID).Scan(&token, &windowEnd)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, windowEnd, nil
+}
+
+// SetCalendarSyncToken stores the sync token for one of the user's
+// calendars. windowEnd is kept from the last full sync when it is zero.
+func SetCalendarSyncToken(ctx context.Context, d db.Querier, userID uuid.UUID, calendarID, token string, windowEnd time.Time) error {
+	var end *time.Time
+	if !windowEnd.IsZero() {
+		end = &windowEnd
+	}
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO google_sync_tokens (user_id, calendar_id, sync_token, window_end)
+        VALUES ($1, $2, $3, COALESCE($4, NOW()))
+        ON CONFLICT (user_id, calendar_id)
+        DO UPDATE SET
+            sync_token = EXCLUDED.sync_token,
+            window_end = COALESCE($4, google_sync_tokens.window_end),
+            updated_at = NOW()
+    `, userID, calendarID, token, end)
+	return err
+}
+
+// ClearCalendarSyncToken forgets the sync token for one of the user's
+// calendars so the next sync is a full one.
+func ClearCalendarSyncToken(ctx context.Context, d *db.DB, userID uuid.UUID, calendarID string) error {
+	_, err := d.ExecContext(ctx, `
+        DELETE FROM google_sync_tokens
+        WHERE user_id = $1 AND calendar_id = $2
+    `, userID, calendarID)
+	return err
+}
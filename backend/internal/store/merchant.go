@@ -0,0 +1,93 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+// processorPrefixes are payment-processor tags card networks put in front
+// of the merchant name ("SQ *BLUE BOTTLE", "PP*SPOTIFY").
+var processorPrefixes = []string{"sq *", "sq*", "tst* ", "tst*", "pp*", "paypal *"}
+
+// merchantAliases expands abbreviations banks use for well-known
+// merchants.
+var merchantAliases = map[string]string{
+	"amzn": "amazon",
+	"goog": "google",
+	"msft": "microsoft",
+}
+
+// merchantNoise are tokens that don't distinguish merchants.
+var merchantNoise = map[string]bool{
+	"com": true, "inc": true, "llc": true, "co": true, "store": true,
+}
+
+// NormalizeMerchant maps spelling variants of a merchant name to one key
+// so "AMZN*PRIME", "Amazon Prime" and "Amazon Prime #1234" group together.
+// It lowercases, drops processor prefixes, punctuation, store numbers and
+// noise words, expands known abbreviations and collapses whitespace.
+func NormalizeMerchant(name string) string {
+	s := strings.ToLower(strings.TrimSpace(name))
+	for _, prefix := range processorPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			s = s[len(prefix):]
+			break
+		}
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, s)
+
+	var tokens []string
+	for _, tok := range strings.Fields(s) {
+		if merchantNoise[tok] || isStoreNumber(tok) {
+			continue
+		}
+		if alias, ok := merchantAliases[tok]; ok {
+			tok = alias
+		}
+		tokens = append(tokens, tok)
+	}
+	if len(tokens) == 0 {
+		// Keep names made only of digits or noise distinct from each other.
+		return strings.Join(strings.Fields(s), " ")
+	}
+	return strings.Join(tokens, " ")
+}
+
+// merchantWordPatterns returns LIKE patterns matching a space-padded,
+// lower-cased merchant name with punctuation turned into spaces that
+// contains the longest word of the normalized key, or an abbreviation
+// that expands to it. Every name normalizing to key matches one of them.
+func merchantWordPatterns(key string) []string {
+	var word string
+	for _, tok := range strings.Fields(key) {
+		if len(tok) > len(word) {
+			word = tok
+		}
+	}
+	patterns := []string{"% " + word + " %"}
+	for abbrev, alias := range merchantAliases {
+		if alias == word {
+			patterns = append(patterns, "% "+abbrev+" %")
+		}
+	}
+	return patterns
+}
+
+// isStoreNumber reports whether tok is a location or terminal number:
+// all digits, at least three long.
+func isStoreNumber(tok string) bool {
+	if len(tok) < 3 {
+		return false
+	}
+	for _, r := range tok {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeMerchant(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Amazon Prime", "amazon prime"},
+		{"AMZN*PRIME", "amazon prime"},
+		{"Amazon Prime #1234", "amazon prime"},
+		{"  Spotify   USA ", "spotify usa"},
+		{"SQ *BLUE BOTTLE COFFEE 0042", "blue bottle coffee"},
+		{"PP*NETFLIX.COM", "netflix"},
+		{"7-Eleven", "7 eleven"},
+		{"12345", "12345"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeMerchant(tt.name); got != tt.want {
+			t.Errorf("NormalizeMerchant(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMerchantWordPatternsMatchVariants(t *testing.T) {
+	// padded mirrors the history query's expression over merchant.
+	nonAlnum := regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	padded := func(name string) string {
+		return " " + nonAlnum.ReplaceAllString(strings.ToLower(name), " ") + " "
+	}
+	tests := []struct {
+		merchant string
+		variants []string
+	}{
+		{"Amazon Prime", []string{"AMZN*PRIME", "Amazon Prime #1234", "amazon.com prime"}},
+		{"Spotify", []string{"SQ *SPOTIFY", "PP*SPOTIFY", "SPOTIFY #0042"}},
+		{"Blue Bottle Coffee", []string{"SQ *BLUE BOTTLE COFFEE 0042", "TST* Blue Bottle Coffee"}},
+	}
+	for _, tt := range tests {
+		key := NormalizeMerchant(tt.merchant)
+		patterns := merchantWordPatterns(key)
+		for _, v := range tt.variants {
+			if NormalizeMerchant(v) != key {
+				t.Fatalf("%q doesn't normalize to %q", v, key)
+			}
+			matched := false
+			for _, p := range patterns {
+				word := strings.TrimSuffix(strings.TrimPrefix(p, "%"), "%")
+				matched = matched || strings.Contains(padded(v), word)
+			}
+			if !matched {
+				t.Errorf("%q matches none of %v", v, patterns)
+			}
+		}
+	}
+}
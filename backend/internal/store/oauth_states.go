@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// ErrOAuthStateNotFound is returned when a state was never issued or has
+// already been used.
+var ErrOAuthStateNotFound = errors.New("oauth state not found")
+
+// CreateOAuthState records a state value issued to userID for provider.
+func CreateOAuthState(ctx context.Context, d *db.DB, state string, userID uuid.UUID, provider string) error {
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO oauth_states (state, user_id, provider, created_at)
+        VALUES ($1, $2, $3, NOW())
+    `, state, userID, provider)
+	return err
+}
+
+// ConsumeOAuthState deletes a state for provider and returns the user it
+// was issued to and when. Deleting on lookup makes each state single-use.
+func ConsumeOAuthState(ctx context.Context, d *db.DB, state string, provider string) (uuid.UUID, time.Time, error) {
+	var userID string
+	var createdAt time.Time
+	err := d.QueryRowContext(ctx, `
+        DELETE FROM oauth_states
+        WHERE state = $1 AND provider = $2
+        RETURNING user_id, created_at
+    `, state, provider).Scan(&userID, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, time.Time{}, ErrOAuthStateNotFound
+	}
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	return uid, createdAt, nil
+}
+
+// DeleteExpiredOAuthStates removes states created before cutoff.
+func DeleteExpiredOAuthStates(ctx context.Context, d *db.DB, cutoff time.Time) error {
+	_, err := d.ExecContext(ctx, `DELETE FROM oauth_states WHERE created_at < $1`, cutoff)
+	return err
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// ErrPlaidItemNotFound is returned when an item does not exist or belongs
+// to another user.
+var ErrPlaidItemNotFound = errors.New("plaid item not found")
+
+// PlaidItem is a linked Plaid connection (one bank login), its access
+// token and the /transactions/sync cursor it has been synced up to.
+type PlaidItem struct {
+	ItemID      string    `json:"itemId"`
+	AccessToken string    `json:"-"`
+	Cursor      string    `json:"-"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// SavePlaidItem stores the access token for a user's item, replacing the
+// token if the item was linked before.
+func SavePlaidItem(ctx context.Context, d *db.DB, userID uuid.UUID, itemID, accessToken string) error {
+	if itemID == "" || accessToken == "" {
+		return errors.New("plaid item id and access token are required")
+	}
+	// In production, encrypt the access token before storing
+	res, err := d.ExecContext(ctx, `
+        INSERT INTO plaid_items (user_id, item_id, access_token_enc)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (item_id)
+        DO UPDATE SET access_token_enc = EXCLUDED.access_token_enc
+        WHERE plaid_items.user_id = EXCLUDED.user_id
+    `, userID, itemID, []byte(accessToken))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrPlaidItemNotFound
+	}
+	return nil
+}
+
+// GetPlaidItems returns the user's linked items, oldest first.
+func GetPlaidItems(ctx context.Context, d *db.DB, userID uuid.UUID) ([]PlaidItem, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT item_id, access_token_enc, COALESCE(sync_cursor, ''), created_at
+        FROM plaid_items
+        WHERE user_id = $1
+        ORDER BY created_at ASC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PlaidItem
+	for rows.Next() {
+		var it PlaidItem
+		var token []byte
+		if err := rows.Scan(&it.ItemID, &token, &it.Cursor, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		// In production, decrypt the token
+		it.AccessToken = string(token)
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// GetPlaidItem returns one of the user's items. ErrPlaidItemNotFound means
+// the item doesn't exist or isn't the user's.
+func GetPlaidItem(ctx context.Context, d *db.DB, userID uuid.UUID, itemID string) (*PlaidItem, error) {
+	var it PlaidItem
+	var token []byte
+	err := d.QueryRowContext(ctx, `
+        SELECT item_id, access_token_enc, COALESCE(sync_cursor, ''), created_at
+        FROM plaid_items
+        WHERE user_id = $1 AND item_id = $2
+    `, userID, itemID).Scan(&it.ItemID, &token, &it.Cursor, &it.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPlaidItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// In production, decrypt the token
+	it.AccessToken = string(token)
+	return &it, nil
+}
+
+// SetPlaidItemCursor records how far the item has been synced. Call it only
+// after the changes up to cursor have been stored.
+func SetPlaidItemCursor(ctx context.Context, d db.Querier, userID uuid.UUID, itemID, cursor string) error {
+	_, err := d.ExecContext(ctx, `
+        UPDATE plaid_items SET sync_cursor = $3
+        WHERE user_id = $1 AND item_id = $2
+    `, userID, itemID, cursor)
+	return err
+}
+
+// FindPlaidItem looks up an item by its Plaid item ID alone, for callers
+// such as webhooks that don't know the owning user. It returns the owner
+// along with the item.
+func FindPlaidItem(ctx context.Context, d *db.DB, itemID string) (uuid.UUID, *PlaidItem, error) {
+	var userID uuid.UUID
+	var it PlaidItem
+	var token []byte
+	err := d.QueryRowContext(ctx, `
+        SELECT user_id, item_id, access_token_enc, COALESCE(sync_cursor, ''), created_at
+        FROM plaid_items
+        WHERE item_id = $1
+    `, itemID).Scan(&userID, &it.ItemID, &token, &it.Cursor, &it.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil, ErrPlaidItemNotFound
+	}
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	// In production, decrypt the token
+	it.AccessToken = string(token)
+	return userID, &it, nil
+}
+
+// SetPlaidItemError records the error code Plaid reported for an item. An
+// empty code clears it.
+func SetPlaidItemError(ctx context.Context, d *db.DB, userID uuid.UUID, itemID, code string) error {
+	_, err := d.ExecContext(ctx, `
+        UPDATE plaid_items
+        SET error_code = NULLIF($3, ''),
+            error_at = CASE WHEN $3 = '' THEN NULL ELSE now() END
+        WHERE user_id = $1 AND item_id = $2
+    `, userID, itemID, code)
+	return err
+}
+
+// DeletePlaidItem forgets a disconnected item, in one transaction. An
+// item's plaid_items row is deleted; an empty itemID is a legacy
+// connection, whose token is the user's plaid row in oauth_tokens. The
+// legacy row is only deleted for that connection, as it is a separate item
+// at Plaid that must be removed there first. With purge, the accounts and
+// transactions synced from the item go too (for a legacy connection, the
+// ones not tagged with any item).
+func DeletePlaidItem(ctx context.Context, d *db.DB, userID uuid.UUID, itemID string, purge bool) error {
+	return d.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if itemID != "" {
+			_, err = tx.ExecContext(ctx, `
+                DELETE FROM plaid_items WHERE user_id = $1 AND item_id = $2
+            `, userID, itemID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+                DELETE FROM oauth_tokens WHERE user_id = $1 AND provider = 'plaid'
+            `, userID)
+		}
+		if err != nil {
+			return err
+		}
+		if !purge {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, `
+            DELETE FROM accounts
+            WHERE user_id = $1 AND item_id IS NOT DISTINCT FROM NULLIF($2, '')
+        `, userID, itemID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+            DELETE FROM transactions
+            WHERE user_id = $1 AND source = 'plaid' AND item_id IS NOT DISTINCT FROM NULLIF($2, '')
+        `, userID, itemID)
+		return err
+	})
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldErrors maps invalid input fields to what is wrong with each. It is
+// returned as an error so handlers can report every problem at once.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return "invalid fields: " + strings.Join(fields, ", ")
+}
+
+// usStates holds the two-letter codes of the 50 states and DC.
+var usStates = map[string]bool{
+	"AL": true, "AK": true, "AZ": true, "AR": true, "CA": true, "CO": true, "CT": true,
+	"DE": true, "DC": true, "FL": true, "GA": true, "HI": true, "ID": true, "IL": true,
+	"IN": true, "IA": true, "KS": true, "KY": true, "LA": true, "ME": true, "MD": true,
+	"MA": true, "MI": true, "MN": true, "MS": true, "MO": true, "MT": true, "NE": true,
+	"NV": true, "NH": true, "NJ": true, "NM": true, "NY": true, "NC": true, "ND": true,
+	"OH": true, "OK": true, "OR": true, "PA": true, "RI": true, "SC": true, "SD": true,
+	"TN": true, "TX": true, "UT": true, "VT": true, "VA": true, "WA": true, "WV": true,
+	"WI": true, "WY": true,
+}
+
+// IsUSState reports whether code is a two-letter state (or DC) code, in
+// upper case.
+func IsUSState(code string) bool {
+	return usStates[code]
+}
+
+// IsSchoolKey reports whether key is a valid school key: 1 to 64
+// lower-case letters, digits and dashes.
+func IsSchoolKey(key string) bool {
+	if key == "" || len(key) > 64 {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the profile's values, returning nil or FieldErrors keyed
+// by field name. Unset optional values are not checked. PayFreq is left to
+// the caller, which knows the supported frequencies.
+func (p Profile) Validate() FieldErrors {
+	errs := FieldErrors{}
+	if p.State != "" && !IsUSState(p.State) {
+		errs["State"] = "must be a two-letter US state code"
+	}
+	if p.HourlyCents != nil && *p.HourlyCents <= 0 {
+		errs["HourlyCents"] = "must be positive"
+	}
+	if p.HoursPerWeek != nil && (*p.HoursPerWeek <= 0 || *p.HoursPerWeek > 168) {
+		errs["HoursPerWeek"] = "must be between 1 and 168"
+	}
+	if p.StipendCents != nil && *p.StipendCents <= 0 {
+		errs["StipendCents"] = "must be positive"
+	}
+	if p.InOfficeDays < 0 || p.InOfficeDays > 7 {
+		errs["InOfficeDays"] = "must be between 0 and 7"
+	}
+	if p.FoodCostCents < 0 {
+		errs["FoodCostCents"] = "must not be negative"
+	}
+	if p.School != "" && !IsSchoolKey(p.School) {
+		errs["School"] = "must be a school key of lower-case letters, digits and dashes"
+	}
+	for _, m := range p.ReminderMinutesBefore {
+		if m < 0 {
+			errs["ReminderMinutesBefore"] = "must not be negative"
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
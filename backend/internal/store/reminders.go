@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// ReminderCandidate is an upcoming event together with the lead times that
+// apply to it: the event's own ReminderMinutesBefore, or the owner's
+// profile default when the event has none.
+type ReminderCandidate struct {
+	EventID       uuid.UUID
+	UserID        uuid.UUID
+	Title         string
+	Start         time.Time
+	MinutesBefore []int
+}
+
+// Reminder is a queued notification for an event.
+type Reminder struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	EventID       uuid.UUID
+	Title         string
+	Start         time.Time
+	MinutesBefore int
+	RemindAt      time.Time
+}
+
+// GetReminderCandidates returns events starting in [from, to) across all
+// users with their effective reminder lead times.
+func GetReminderCandidates(ctx context.Context, d *db.DB, from, to time.Time) ([]ReminderCandidate, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT e.id, e.user_id, e.title, e.start_ts,
+               COALESCE(e.reminder_minutes_before, p.reminder_minutes_before)
+        FROM calendar_events e
+        LEFT JOIN profiles p ON p.user_id = e.user_id
+        WHERE e.start_ts >= $1 AND e.start_ts < $2
+    `, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var candidates []ReminderCandidate
+	for rows.Next() {
+		var rc ReminderCandidate
+		var eventID, userID string
+		var title *string
+		if err := rows.Scan(&eventID, &userID, &title, &rc.Start, intArray(&rc.MinutesBefore)); err != nil {
+			return nil, err
+		}
+		rc.EventID, _ = uuid.Parse(eventID)
+		rc.UserID, _ = uuid.Parse(userID)
+		if title != nil {
+			rc.Title = *title
+		}
+		candidates = append(candidates, rc)
+	}
+	return candidates, rows.Err()
+}
+
+// EnqueueReminder queues a reminder for an event and lead time. Enqueueing
+// the same pair twice is a no-op, so the worker can rescan freely.
+func EnqueueReminder(ctx context.Context, d *db.DB, userID, eventID uuid.UUID, minutesBefore int, remindAt time.Time) error {
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO event_reminders (id, user_id, event_id, minutes_before, remind_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (event_id, minutes_before) DO UPDATE SET remind_at = EXCLUDED.remind_at
+        WHERE event_reminders.sent_at IS NULL
+    `, uuid.New(), userID, eventID, minutesBefore, remindAt)
+	return err
+}
+
+// GetDueReminders returns unsent reminders whose remind_at is at or before
+// now, oldest first.
+func GetDueReminders(ctx context.Context, d *db.DB, now time.Time) ([]Reminder, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT r.id, r.user_id, r.event_id, e.title, e.start_ts, r.minutes_before, r.remind_at
+        FROM event_reminders r
+        JOIN calendar_events e ON e.id = r.event_id
+        WHERE r.sent_at IS NULL AND r.remind_at <= $1
+        ORDER BY r.remind_at ASC
+    `, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var reminders []Reminder
+	for rows.Next() {
+		var r Reminder
+		var id, userID, eventID string
+		var title *string
+		if err := rows.Scan(&id, &userID, &eventID, &title, &r.Start, &r.MinutesBefore, &r.RemindAt); err != nil {
+			return nil, err
+		}
+		r.ID, _ = uuid.Parse(id)
+		r.UserID, _ = uuid.Parse(userID)
+		r.EventID, _ = uuid.Parse(eventID)
+		if title != nil {
+			r.Title = *title
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// MarkReminderSent records that a reminder was delivered.
+func MarkReminderSent(ctx context.Context, d *db.DB, id uuid.UUID) error {
+	_, err := d.ExecContext(ctx, `UPDATE event_reminders SET sent_at = NOW() WHERE id = $1`, id)
+	return err
+}
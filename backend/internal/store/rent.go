@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"dayboard/backend/internal/db"
+)
+
+// CityRent is the average monthly rent for one unit size in a city.
+type CityRent struct {
+	City         string `json:"city"`
+	State        string `json:"state"`
+	BedroomType  string `json:"bedroomType"`
+	AvgRentCents int    `json:"avgRentCents"`
+}
+
+// GetCityRents returns rent data ordered by state and city. A non-empty
+// bedroomType ("studio", "1", "2", ...) limits the rows to that unit size.
+func GetCityRents(ctx context.Context, d *db.DB, bedroomType string) ([]CityRent, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT city, upper(state), bedroom_type, avg_rent_cents
+        FROM city_rent_data
+        WHERE $1 = '' OR lower(bedroom_type) = $1
+        ORDER BY state ASC, city ASC, bedroom_type ASC
+    `, strings.ToLower(strings.TrimSpace(bedroomType)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rents []CityRent
+	for rows.Next() {
+		var r CityRent
+		if err := rows.Scan(&r.City, &r.State, &r.BedroomType, &r.AvgRentCents); err != nil {
+			return nil, err
+		}
+		rents = append(rents, r)
+	}
+	return rents, rows.Err()
+}
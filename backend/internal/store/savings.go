@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// SavingsGoal is an amount the user wants saved by Deadline.
+type SavingsGoal struct {
+	ID          uuid.UUID `json:"id"`
+	TargetCents int       `json:"targetCents"`
+	Deadline    time.Time `json:"deadline"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// CreateSavingsGoal stores a new goal for the user. It replaces the
+// previous goal as the one GetSavingsGoal returns.
+func CreateSavingsGoal(ctx context.Context, d *db.DB, userID uuid.UUID, g SavingsGoal) (*SavingsGoal, error) {
+	if g.TargetCents <= 0 || g.Deadline.IsZero() {
+		return nil, errors.New("invalid savings goal fields")
+	}
+	g.ID = uuid.New()
+	err := d.QueryRowContext(ctx, `
+        INSERT INTO savings_goals (id, user_id, target_cents, deadline)
+        VALUES ($1, $2, $3, $4)
+        RETURNING created_at
+    `, g.ID, userID, g.TargetCents, g.Deadline).Scan(&g.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// GetSavingsGoal returns the user's most recent goal, or (nil, nil) if they
+// haven't set one.
+func GetSavingsGoal(ctx context.Context, d *db.DB, userID uuid.UUID) (*SavingsGoal, error) {
+	var g SavingsGoal
+	err := d.QueryRowContext(ctx, `
+        SELECT id, target_cents, deadline, created_at
+        FROM savings_goals
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+        LIMIT 1
+    `, userID).Scan(&g.ID, &g.TargetCents, &g.Deadline, &g.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// CategorySpending summarizes outflows for one top-level category. Refunds
+// (inflows in a spending category) are tracked separately so callers can
+// show gross or net figures. All values are positive cents.
+type CategorySpending struct {
+	Category    string `json:"category"`
+	GrossCents  int    `json:"grossCents"`
+	RefundCents int    `json:"refundCents"`
+	NetCents    int    `json:"netCents"`
+}
+
+// incomeCategory matches inflows that are income rather than refunds.
+// These are excluded from spending summaries entirely.
+var incomeCategory = regexp.MustCompile(`(?i)(payroll|transfer|deposit|interest)`)
+
+// refundCategoryPattern matches categories that mark a transaction as a
+// refund whatever the sign of its amount.
+const refundCategoryPattern = `refund`
+
+var refundCategory = regexp.MustCompile(`(?i)` + refundCategoryPattern)
+
+// spendingTxn is a transaction as CategorySpendingSummary sees it.
+// PurchaseCategory is the category of the merchant's latest earlier
+// purchase, looked up only for refund-category transactions.
+type spendingTxn struct {
+	AmountCents      int
+	Category         string
+	PurchaseCategory string
+}
+
+// CategorySpendingSummary totals a user's transactions from from up to but
+// not including to, by top-level category. Purchases count toward
+// GrossCents. Refunds count toward RefundCents and are netted in NetCents:
+// negative amounts count in their own category, and transactions in a
+// refund category count against the category of the merchant's latest
+// earlier purchase. Income-like inflows are ignored. Results are ordered
+// by net spending, largest first.
+func CategorySpendingSummary(ctx context.Context, d *db.DB, userID uuid.UUID, from, to time.Time) ([]CategorySpending, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT t.amount_cents, COALESCE(t.category, ''), COALESCE(p.category, '')
+        FROM transactions t
+        LEFT JOIN LATERAL (
+            SELECT category FROM transactions
+            WHERE user_id = t.user_id AND lower(merchant) = lower(t.merchant)
+              AND amount_cents > 0 AND txn_date <= t.txn_date AND id <> t.id
+              AND COALESCE(category, '') !~* $4
+            ORDER BY txn_date DESC
+            LIMIT 1
+        ) p ON COALESCE(t.category, '') ~* $4
+        WHERE t.user_id = $1 AND t.txn_date >= $2 AND t.txn_date < $3
+    `, userID, from, to, refundCategoryPattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var txns []spendingTxn
+	for rows.Next() {
+		var t spendingTxn
+		if err := rows.Scan(&t.AmountCents, &t.Category, &t.PurchaseCategory); err != nil {
+			return nil, err
+		}
+		txns = append(txns, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return summarizeSpending(txns), nil
+}
+
+// summarizeSpending groups txns by top-level category as described on
+// CategorySpendingSummary.
+func summarizeSpending(txns []spendingTxn) []CategorySpending {
+	byCategory := make(map[string]*CategorySpending)
+	for _, t := range txns {
+		category := t.Category
+		refund := t.AmountCents < 0
+		if refundCategory.MatchString(t.Category) {
+			refund = true
+			if t.PurchaseCategory != "" {
+				category = t.PurchaseCategory
+			}
+		} else if refund && incomeCategory.MatchString(t.Category) {
+			continue
+		}
+		top := "Uncategorized"
+		if levels := SplitCategory(category); len(levels) > 0 && levels[0] != "" {
+			top = levels[0]
+		}
+		cs, ok := byCategory[top]
+		if !ok {
+			cs = &CategorySpending{Category: top}
+			byCategory[top] = cs
+		}
+		amount := t.AmountCents
+		if amount < 0 {
+			amount = -amount
+		}
+		if refund {
+			cs.RefundCents += amount
+		} else {
+			cs.GrossCents += amount
+		}
+	}
+
+	summary := make([]CategorySpending, 0, len(byCategory))
+	for _, cs := range byCategory {
+		cs.NetCents = cs.GrossCents - cs.RefundCents
+		summary = append(summary, *cs)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].NetCents != summary[j].NetCents {
+			return summary[i].NetCents > summary[j].NetCents
+		}
+		return summary[i].Category < summary[j].Category
+	})
+	return summary
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func TestCategorySpendingSummaryNetsRefunds(t *testing.T) {
+	rows := [][]any{
+		{8000, "Shops > Clothing", ""},
+		{-3000, "Shops > Clothing", ""},
+		{2500, "Food and Drink > Restaurants", ""},
+		// A refund booked in its own category nets against the purchase.
+		{1000, "Refund", "Food and Drink > Coffee"},
+		{-200000, "Transfer > Payroll", ""},
+	}
+	var args []any
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if !strings.Contains(q.SQL, "FROM transactions") {
+			return dbtest.Result{}
+		}
+		args = q.Args
+		return dbtest.Rows([]string{"amount_cents", "category", "purchase_category"}, rows...)
+	})
+
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	summary, err := CategorySpendingSummary(context.Background(), d, uuid.New(), from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[1] != from || args[2] != to {
+		t.Errorf("queried %v to %v, want %v to %v", args[1], args[2], from, to)
+	}
+
+	want := []CategorySpending{
+		{Category: "Shops", GrossCents: 8000, RefundCents: 3000, NetCents: 5000},
+		{Category: "Food and Drink", GrossCents: 2500, RefundCents: 1000, NetCents: 1500},
+	}
+	if len(summary) != len(want) {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+	for i := range want {
+		if summary[i] != want[i] {
+			t.Errorf("summary[%d] = %+v, want %+v", i, summary[i], want[i])
+		}
+	}
+}
+
+func TestSummarizeSpendingUnmatchedRefund(t *testing.T) {
+	summary := summarizeSpending([]spendingTxn{{AmountCents: 500, Category: "Refund"}})
+	want := CategorySpending{Category: "Refund", RefundCents: 500, NetCents: -500}
+	if len(summary) != 1 || summary[0] != want {
+		t.Errorf("summary = %+v, want [%+v]", summary, want)
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// StaleSubscription is an active subscription that has stopped charging:
+// its most recent matching transaction is older than twice its cadence.
+type StaleSubscription struct {
+	Subscription
+	LastChargedAt   time.Time `json:"lastChargedAt"`
+	DaysSinceCharge int       `json:"daysSinceCharge"`
+}
+
+// DetectStaleSubscriptions returns the user's active subscriptions whose
+// latest charge from the same merchant (case-insensitive) is more than two
+// cadences before now, oldest charge first. Subscriptions with no matching
+// transactions at all are skipped, since there is nothing to judge them by.
+func DetectStaleSubscriptions(ctx context.Context, d *db.DB, userID uuid.UUID, now time.Time) ([]StaleSubscription, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT s.id, s.merchant, s.amount_cents, s.cadence_days, s.next_due, s.source,
+               s.is_active, COALESCE(s.billing_day, 0), last.txn_date
+        FROM subscriptions s
+        JOIN LATERAL (
+            SELECT max(t.txn_date) AS txn_date
+            FROM transactions t
+            WHERE t.user_id = s.user_id AND lower(t.merchant) = lower(s.merchant) AND t.amount_cents > 0
+        ) last ON last.txn_date IS NOT NULL
+        WHERE s.user_id = $1 AND s.is_active = true AND s.cadence_days > 0
+          AND last.txn_date < $2::date - 2 * s.cadence_days
+        ORDER BY last.txn_date ASC, s.merchant ASC, s.id ASC
+    `, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	y, m, day := now.Date()
+	today := time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+	stale := []StaleSubscription{}
+	for rows.Next() {
+		var s StaleSubscription
+		var nextDue *time.Time
+		if err := rows.Scan(&s.ID, &s.Merchant, &s.AmountCents, &s.CadenceDays, &nextDue, &s.Source,
+			&s.IsActive, &s.BillingDay, &s.LastChargedAt); err != nil {
+			return nil, err
+		}
+		s.NextDue = nextDue
+		ly, lm, ld := s.LastChargedAt.Date()
+		s.DaysSinceCharge = int(today.Sub(time.Date(ly, lm, ld, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+		stale = append(stale, s)
+	}
+	return stale, rows.Err()
+}
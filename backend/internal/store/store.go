@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,6 +24,48 @@ type Event struct {
 	Title    string    `json:"title"`
 	JoinURL  string    `json:"joinURL"`
 	Location string    `json:"location"`
+	AllDay   bool      `json:"allDay"`
+	// ReminderMinutesBefore lists reminder lead times for this event. When
+	// empty, the profile's default lead times apply.
+	ReminderMinutesBefore []int `json:"reminderMinutesBefore,omitempty"`
+	// Attendance is AttendanceAttended, AttendanceSkipped or empty when
+	// unmarked. Set it with SetEventAttendance.
+	Attendance string `json:"attendance,omitempty"`
+}
+
+// ErrEventEndBeforeStart is returned when an event's end precedes its start.
+var ErrEventEndBeforeStart = errors.New("event end must not be before start")
+
+// defaultEventDuration is applied to timed events that arrive without an
+// end time. It is read from EVENT_DEFAULT_DURATION_MINUTES (default 30).
+var defaultEventDuration = loadDefaultEventDuration()
+
+// DefaultEventDuration returns the length EventEnd gives timed events that
+// have no end.
+func DefaultEventDuration() time.Duration {
+	return defaultEventDuration
+}
+
+func loadDefaultEventDuration() time.Duration {
+	if v := os.Getenv("EVENT_DEFAULT_DURATION_MINUTES"); v != "" {
+		if mins, err := strconv.Atoi(v); err == nil && mins > 0 {
+			return time.Duration(mins) * time.Minute
+		}
+	}
+	return 30 * time.Minute
+}
+
+// EventEnd returns end, or a default end when it is missing: the next day
+// for all-day events and start plus the default duration otherwise. Callers
+// should use it wherever events are ingested so durations are never negative.
+func EventEnd(start, end time.Time, allDay bool) time.Time {
+	if !end.IsZero() {
+		return end
+	}
+	if allDay {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(defaultEventDuration)
 }
 
 // Subscription represents a recurring payment. AmountCents and cadence
@@ -33,6 +78,52 @@ type Subscription struct {
 	NextDue     *time.Time `json:"nextDue,omitempty"`
 	Source      string     `json:"source"`
 	IsActive    bool       `json:"isActive"`
+	// BillingDay is the day of month monthly and longer cadences bill on.
+	BillingDay int `json:"billingDay,omitempty"`
+}
+
+// typeMap decodes PostgreSQL types that database/sql can't scan natively,
+// such as INT[] columns.
+var typeMap = pgtype.NewMap()
+
+// intArray returns a scanner that decodes an INT[] column into dst. NULL
+// arrays decode to a nil slice.
+func intArray(dst *[]int) sql.Scanner {
+	return typeMap.SQLScanner(dst)
+}
+
+// Transaction is a raw bank transaction as persisted by the Plaid sync.
+// Amounts follow Plaid's convention: positive values are outflows and
+// negative values are inflows (refunds, deposits). Category holds the
+// provider's category hierarchy joined by CategorySeparator. ItemID is the
+// Plaid item the transaction was synced from, if known.
+type Transaction struct {
+	ID          uuid.UUID `json:"id"`
+	Source      string    `json:"source"`
+	ExtID       string    `json:"extId"`
+	ItemID      string    `json:"itemId,omitempty"`
+	Date        time.Time `json:"date"`
+	Merchant    string    `json:"merchant"`
+	AmountCents int       `json:"amountCents"`
+	Category    string    `json:"category"`
+	Pending     bool      `json:"pending"`
+}
+
+// CategorySeparator joins the levels of a category hierarchy in the
+// transactions.category column, e.g. "Service > Subscription".
+const CategorySeparator = " > "
+
+// JoinCategory flattens a category hierarchy for storage.
+func JoinCategory(levels []string) string {
+	return strings.Join(levels, CategorySeparator)
+}
+
+// SplitCategory is the inverse of JoinCategory.
+func SplitCategory(category string) []string {
+	if category == "" {
+		return nil
+	}
+	return strings.Split(category, CategorySeparator)
 }
 
 // Profile holds user-specific settings used for tax and cost estimation.
@@ -50,28 +141,52 @@ type Profile struct {
 	StartDate     *time.Time
 	InOfficeDays  int
 	FoodCostCents int
+	// School is the key of the user's school in campus_event_sources,
+	// used to show its campus events.
+	School string
+	// ReminderMinutesBefore is the default set of reminder lead times
+	// applied to events that don't specify their own.
+	ReminderMinutesBefore []int
 }
 
-// GetTodayEvents returns all events for a user that start on the given day.
-// The caller is responsible for passing startOfDay and endOfDay in UTC.
+// GetTodayEvents returns all events for a user that start on the given day
+// or that started earlier and are still running (multi-day and all-day
+// events). The caller is responsible for computing startOfDay and endOfDay
+// in the user's timezone; the comparison is done on absolute instants.
 func GetTodayEvents(ctx context.Context, d *db.DB, userID uuid.UUID, startOfDay, endOfDay time.Time) ([]Event, error) {
+	return GetEventsInRange(ctx, d, userID, startOfDay, endOfDay)
+}
+
+// GetEventsInRange returns all events for a user that overlap [start, end),
+// including ones that started before start and are still running, ordered
+// by start time.
+func GetEventsInRange(ctx context.Context, d *db.DB, userID uuid.UUID, start, end time.Time) ([]Event, error) {
 	rows, err := d.QueryContext(ctx, `
-        SELECT id, start_ts, end_ts, title, join_url, location
+        SELECT `+eventColumns+`
         FROM calendar_events
         WHERE user_id = $1
-          AND start_ts >= $2
-          AND start_ts < $3
+          AND ((start_ts >= $2 AND start_ts < $3)
+            OR (end_ts > $2 AND start_ts < $3))
         ORDER BY start_ts ASC
-    `, userID, startOfDay, endOfDay)
+    `, userID, start, end)
 	if err != nil {
 		return nil, err
 	}
+	return scanEvents(rows)
+}
+
+// eventColumns are the calendar_events columns scanEvents reads, in order.
+const eventColumns = `id, start_ts, end_ts, title, join_url, location, all_day, reminder_minutes_before,
+               COALESCE(attendance, '')`
+
+// scanEvents reads events selected with eventColumns and closes rows.
+func scanEvents(rows *sql.Rows) ([]Event, error) {
 	defer rows.Close()
 	var events []Event
 	for rows.Next() {
 		var e Event
 		var id string
-		if err := rows.Scan(&id, &e.Start, &e.End, &e.Title, &e.JoinURL, &e.Location); err != nil {
+		if err := rows.Scan(&id, &e.Start, &e.End, &e.Title, &e.JoinURL, &e.Location, &e.AllDay, intArray(&e.ReminderMinutesBefore), &e.Attendance); err != nil {
 			return nil, err
 		}
 		uid, _ := uuid.Parse(id)
@@ -81,24 +196,64 @@ func GetTodayEvents(ctx context.Context, d *db.DB, userID uuid.UUID, startOfDay,
 	return events, rows.Err()
 }
 
-// GetSubscriptions returns all active subscriptions for a user.
+// CreateEvent inserts a manually entered event for the user. Manual events
+// use their own ID as the external ID so they fit the calendar_events
+// uniqueness constraint alongside provider-synced events.
+func CreateEvent(ctx context.Context, d *db.DB, userID uuid.UUID, e Event) (*Event, error) {
+	if e.Title == "" || e.Start.IsZero() {
+		return nil, errors.New("invalid event fields")
+	}
+	e.End = EventEnd(e.Start, e.End, e.AllDay)
+	if e.End.Before(e.Start) {
+		return nil, ErrEventEndBeforeStart
+	}
+	e.ID = uuid.New()
+	e.Attendance = ""
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO calendar_events (id, user_id, source, ext_id, start_ts, end_ts, title, join_url, location, all_day, reminder_minutes_before)
+        VALUES ($1, $2, 'manual', $3, $4, $5, $6, $7, $8, $9, $10)
+    `, e.ID, userID, e.ID.String(), e.Start, e.End, e.Title, e.JoinURL, e.Location, e.AllDay, e.ReminderMinutesBefore)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// subscriptionOrder is the canonical ordering for subscription listings:
+// soonest due first (unknown due dates last), then merchant name, then ID
+// as a final tiebreaker so results are stable across calls. Any query that
+// lists or pages through subscriptions should use it.
+const subscriptionOrder = "next_due ASC NULLS LAST, merchant ASC, id ASC"
+
+// GetSubscriptions returns all active subscriptions for a user, ordered by
+// subscriptionOrder.
 func GetSubscriptions(ctx context.Context, d *db.DB, userID uuid.UUID) ([]Subscription, error) {
 	rows, err := d.QueryContext(ctx, `
-        SELECT id, merchant, amount_cents, cadence_days, next_due, source, is_active
+        SELECT `+subscriptionColumns+`
         FROM subscriptions
         WHERE user_id = $1 AND is_active = true
-        ORDER BY next_due ASC NULLS LAST
-    `, userID)
+        ORDER BY `+subscriptionOrder, userID)
 	if err != nil {
 		return nil, err
 	}
+	return scanSubscriptions(rows)
+}
+
+// subscriptionColumns are the subscriptions columns scanSubscriptions
+// reads, in order.
+const subscriptionColumns = `id, merchant, amount_cents, cadence_days, next_due, source, is_active,
+               COALESCE(billing_day, 0)`
+
+// scanSubscriptions reads subscriptions selected with subscriptionColumns
+// and closes rows.
+func scanSubscriptions(rows *sql.Rows) ([]Subscription, error) {
 	defer rows.Close()
 	var subs []Subscription
 	for rows.Next() {
 		var s Subscription
 		var id string
 		var nextDue pgtype.Date
-		if err := rows.Scan(&id, &s.Merchant, &s.AmountCents, &s.CadenceDays, &nextDue, &s.Source, &s.IsActive); err != nil {
+		if err := rows.Scan(&id, &s.Merchant, &s.AmountCents, &s.CadenceDays, &nextDue, &s.Source, &s.IsActive, &s.BillingDay); err != nil {
 			return nil, err
 		}
 		s.ID, _ = uuid.Parse(id)
@@ -112,19 +267,82 @@ func GetSubscriptions(ctx context.Context, d *db.DB, userID uuid.UUID) ([]Subscr
 	return subs, rows.Err()
 }
 
+// ErrSubscriptionNotFound is returned when a subscription does not exist,
+// is inactive or belongs to another user.
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// GetSubscription returns one of the user's active subscriptions.
+func GetSubscription(ctx context.Context, d *db.DB, userID, id uuid.UUID) (*Subscription, error) {
+	var s Subscription
+	var nextDue pgtype.Date
+	err := d.QueryRowContext(ctx, `
+        SELECT id, merchant, amount_cents, cadence_days, next_due, source, is_active,
+               COALESCE(billing_day, 0)
+        FROM subscriptions
+        WHERE user_id = $1 AND id = $2 AND is_active = true
+    `, userID, id).Scan(&s.ID, &s.Merchant, &s.AmountCents, &s.CadenceDays, &nextDue, &s.Source, &s.IsActive, &s.BillingDay)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if nextDue.Valid && !nextDue.Time.IsZero() {
+		t := nextDue.Time
+		s.NextDue = &t
+	}
+	return &s, nil
+}
+
+// DeactivateSubscriptionsBySource soft-deletes all of the user's active
+// subscriptions from source (e.g. every Plaid-detected subscription after
+// the bank is unlinked). It returns how many were deactivated.
+func DeactivateSubscriptionsBySource(ctx context.Context, d *db.DB, userID uuid.UUID, source string) (int, error) {
+	if source == "" {
+		return 0, errors.New("subscription source is required")
+	}
+	res, err := d.ExecContext(ctx, `
+        WITH deactivated AS (
+            UPDATE subscriptions SET is_active = false
+            WHERE user_id = $1 AND source = $2 AND is_active = true
+            RETURNING id
+        )
+        INSERT INTO subscription_events (subscription_id, kind)
+        SELECT id, '`+SubEventDeactivated+`' FROM deactivated
+    `, userID, source)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ErrInvalidSubscription is returned for a manual subscription without a
+// merchant or with a non-positive amount or cadence.
+var ErrInvalidSubscription = errors.New("invalid subscription fields")
+
+const insertManualSubscriptionSQL = `
+        INSERT INTO subscriptions (id, user_id, merchant, amount_cents, cadence_days, next_due, billing_day, source, is_active)
+        VALUES ($1, $2, $3, $4, $5, $6, EXTRACT(DAY FROM $6::date)::int, 'manual', true)
+    `
+
+// validateSubscription applies the basic checks for a manual subscription.
+func validateSubscription(s Subscription) error {
+	if s.Merchant == "" || s.AmountCents <= 0 || s.CadenceDays <= 0 {
+		return ErrInvalidSubscription
+	}
+	return nil
+}
+
 // CreateSubscription inserts a new manual subscription for the user. Plaid-detected
 // subscriptions should be inserted via separate routines. Returns the created
 // subscription or an error.
 func CreateSubscription(ctx context.Context, d *db.DB, userID uuid.UUID, s Subscription) (*Subscription, error) {
-	// Basic validation
-	if s.Merchant == "" || s.AmountCents <= 0 || s.CadenceDays <= 0 {
-		return nil, errors.New("invalid subscription fields")
+	if err := validateSubscription(s); err != nil {
+		return nil, err
 	}
 	id := uuid.New()
-	_, err := d.ExecContext(ctx, `
-        INSERT INTO subscriptions (id, user_id, merchant, amount_cents, cadence_days, next_due, source, is_active)
-        VALUES ($1, $2, $3, $4, $5, $6, 'manual', true)
-    `, id, userID, s.Merchant, s.AmountCents, s.CadenceDays, s.NextDue)
+	_, err := d.ExecContext(ctx, insertManualSubscriptionSQL, id, userID, s.Merchant, s.AmountCents, s.CadenceDays, s.NextDue)
 	if err != nil {
 		return nil, err
 	}
@@ -137,10 +355,11 @@ func CreateSubscription(ctx context.Context, d *db.DB, userID uuid.UUID, s Subsc
 // GetProfile retrieves the user's profile. If no profile exists, returns
 // (nil, nil) to signal caller to create a default. Do not create default
 // profiles automatically here to avoid unexpected writes.
-func GetProfile(ctx context.Context, d *db.DB, userID uuid.UUID) (*Profile, error) {
+func GetProfile(ctx context.Context, d db.Querier, userID uuid.UUID) (*Profile, error) {
 	row := d.QueryRowContext(ctx, `
         SELECT home_addr, office_addr, city, state, hourly_cents, hours_per_week,
-               stipend_cents, pay_freq, start_date, in_office_days, food_cost_cents
+               stipend_cents, pay_freq, start_date, in_office_days, food_cost_cents,
+               reminder_minutes_before, COALESCE(school, '')
         FROM profiles WHERE user_id = $1
     `, userID)
 	var p Profile
@@ -148,7 +367,7 @@ func GetProfile(ctx context.Context, d *db.DB, userID uuid.UUID) (*Profile, erro
 	var hourly, stipend sql.NullInt64
 	var hours sql.NullInt32
 	var start sql.NullTime
-	if err := row.Scan(&p.HomeAddr, &p.OfficeAddr, &p.City, &p.State, &hourly, &hours, &stipend, &p.PayFreq, &start, &p.InOfficeDays, &p.FoodCostCents); err != nil {
+	if err := row.Scan(&p.HomeAddr, &p.OfficeAddr, &p.City, &p.State, &hourly, &hours, &stipend, &p.PayFreq, &start, &p.InOfficeDays, &p.FoodCostCents, intArray(&p.ReminderMinutesBefore), &p.School); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -174,15 +393,17 @@ func GetProfile(ctx context.Context, d *db.DB, userID uuid.UUID) (*Profile, erro
 }
 
 // UpsertProfile inserts or updates a user's profile. If a profile does not
-// exist, one is created. Otherwise, the existing record is updated.
+// exist, one is created. Otherwise, the existing record is updated. A nil
+// ReminderMinutesBefore keeps the stored lead times (10 minutes for a new
+// profile).
 func UpsertProfile(ctx context.Context, d *db.DB, p Profile) error {
 	_, err := d.ExecContext(ctx, `
         INSERT INTO profiles (
             user_id, home_addr, office_addr, city, state, hourly_cents,
             hours_per_week, stipend_cents, pay_freq, start_date,
-            in_office_days, food_cost_cents
+            in_office_days, food_cost_cents, reminder_minutes_before, school
         ) VALUES (
-            $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12
+            $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,COALESCE($13, '{10}'),NULLIF($14, '')
         )
         ON CONFLICT (user_id) DO UPDATE SET
             home_addr = EXCLUDED.home_addr,
@@ -195,9 +416,183 @@ func UpsertProfile(ctx context.Context, d *db.DB, p Profile) error {
             pay_freq = EXCLUDED.pay_freq,
             start_date = EXCLUDED.start_date,
             in_office_days = EXCLUDED.in_office_days,
-            food_cost_cents = EXCLUDED.food_cost_cents
+            food_cost_cents = EXCLUDED.food_cost_cents,
+            reminder_minutes_before = COALESCE(EXCLUDED.reminder_minutes_before, profiles.reminder_minutes_before),
+            school = EXCLUDED.school
     `, p.UserID, p.HomeAddr, p.OfficeAddr, p.City, p.State, p.HourlyCents,
 		p.HoursPerWeek, p.StipendCents, p.PayFreq, p.StartDate,
-		p.InOfficeDays, p.FoodCostCents)
+		p.InOfficeDays, p.FoodCostCents, p.ReminderMinutesBefore, p.School)
 	return err
 }
+
+// GetTransactions returns all stored transactions for a user from the given
+// source, newest first.
+func GetTransactions(ctx context.Context, d *db.DB, userID uuid.UUID, source string) ([]Transaction, error) {
+	rows, err := d.QueryContext(ctx, `
+        SELECT `+transactionColumns+`
+        FROM transactions
+        WHERE user_id = $1 AND source = $2
+        ORDER BY txn_date DESC
+    `, userID, source)
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactions(rows)
+}
+
+// transactionColumns are the transactions columns scanTransactions reads,
+// in order.
+const transactionColumns = `id, source, ext_id, item_id, txn_date, merchant, amount_cents, category, pending`
+
+// scanTransactions reads transactions selected with transactionColumns and
+// closes rows.
+func scanTransactions(rows *sql.Rows) ([]Transaction, error) {
+	defer rows.Close()
+	var txns []Transaction
+	for rows.Next() {
+		var t Transaction
+		var id string
+		var extID, itemID, merchant, category sql.NullString
+		if err := rows.Scan(&id, &t.Source, &extID, &itemID, &t.Date, &merchant, &t.AmountCents, &category, &t.Pending); err != nil {
+			return nil, err
+		}
+		t.ID, _ = uuid.Parse(id)
+		t.ExtID = extID.String
+		t.ItemID = itemID.String
+		t.Merchant = merchant.String
+		t.Category = category.String
+		txns = append(txns, t)
+	}
+	return txns, rows.Err()
+}
+
+// UpsertTransaction stores a transaction from an external source. The
+// (user_id, source, ext_id) key is immutable; if the row already exists its
+// date, merchant, amount, category and pending flag are replaced so
+// provider corrections propagate. An empty ItemID keeps the stored one.
+func UpsertTransaction(ctx context.Context, d db.Querier, userID uuid.UUID, t Transaction) error {
+	if t.Source == "" || t.ExtID == "" {
+		return errors.New("transaction source and external id are required")
+	}
+	_, err := d.ExecContext(ctx, `
+        INSERT INTO transactions (user_id, source, ext_id, item_id, txn_date, merchant, amount_cents, category, pending)
+        VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)
+        ON CONFLICT (user_id, source, ext_id)
+        DO UPDATE SET
+            item_id = COALESCE(EXCLUDED.item_id, transactions.item_id),
+            txn_date = EXCLUDED.txn_date,
+            merchant = EXCLUDED.merchant,
+            amount_cents = EXCLUDED.amount_cents,
+            category = EXCLUDED.category,
+            pending = EXCLUDED.pending
+    `, userID, t.Source, t.ExtID, t.ItemID, t.Date, t.Merchant, t.AmountCents, t.Category, t.Pending)
+	return err
+}
+
+// DeleteEvents removes the user's calendar events from source with the
+// given external IDs, e.g. ones deleted in the provider's calendar.
+func DeleteEvents(ctx context.Context, d db.Querier, userID uuid.UUID, source string, extIDs []string) error {
+	if len(extIDs) == 0 {
+		return nil
+	}
+	_, err := d.ExecContext(ctx, `
+        DELETE FROM calendar_events
+        WHERE user_id = $1 AND source = $2 AND ext_id = ANY($3)
+    `, userID, source, extIDs)
+	return err
+}
+
+// PruneEvents removes the user's calendar events from source that overlap
+// [start, end) and whose external IDs are not in keep. It is used after a
+// full sync of that range, when anything the provider didn't return has
+// been deleted there.
+func PruneEvents(ctx context.Context, d db.Querier, userID uuid.UUID, source string, start, end time.Time, keep []string) error {
+	if keep == nil {
+		keep = []string{}
+	}
+	_, err := d.ExecContext(ctx, `
+        DELETE FROM calendar_events
+        WHERE user_id = $1 AND source = $2
+          AND ((start_ts >= $3 AND start_ts < $4)
+            OR (end_ts > $3 AND start_ts < $4))
+          AND NOT (ext_id = ANY($5))
+    `, userID, source, start, end, keep)
+	return err
+}
+
+// PruneEventsWithPrefix is PruneEvents limited to events whose external
+// IDs start with prefix, for sources that hold several feeds.
+func PruneEventsWithPrefix(ctx context.Context, d *db.DB, userID uuid.UUID, source, prefix string, start, end time.Time, keep []string) error {
+	if keep == nil {
+		keep = []string{}
+	}
+	_, err := d.ExecContext(ctx, `
+        DELETE FROM calendar_events
+        WHERE user_id = $1 AND source = $2
+          AND ((start_ts >= $3 AND start_ts < $4)
+            OR (end_ts > $3 AND start_ts < $4))
+          AND NOT (ext_id = ANY($5))
+          AND starts_with(ext_id, $6)
+    `, userID, source, start, end, keep, prefix)
+	return err
+}
+
+// DeleteTransactions removes the user's transactions from source with the
+// given external IDs, e.g. ones the provider has withdrawn.
+func DeleteTransactions(ctx context.Context, d db.Querier, userID uuid.UUID, source string, extIDs []string) error {
+	if len(extIDs) == 0 {
+		return nil
+	}
+	_, err := d.ExecContext(ctx, `
+        DELETE FROM transactions
+        WHERE user_id = $1 AND source = $2 AND ext_id = ANY($3)
+    `, userID, source, extIDs)
+	return err
+}
+
+// ReconcileDetectedSubscription records a subscription found by recurring
+// detection. If the user already has an active subscription from the same
+// source and merchant (case-insensitive), its amount, cadence and next due
+// date are refreshed; otherwise a new subscription is inserted. It reports
+// whether a new row was created.
+func ReconcileDetectedSubscription(ctx context.Context, d *db.DB, userID uuid.UUID, s Subscription) (bool, error) {
+	if err := validateSubscription(s); err != nil {
+		return false, err
+	}
+	// Price changes are logged to subscription_events for the history
+	// timeline.
+	var updated int
+	err := d.QueryRowContext(ctx, `
+        WITH prev AS (
+            SELECT id, amount_cents FROM subscriptions
+            WHERE user_id = $1 AND source = $2 AND lower(merchant) = lower($3) AND is_active = true
+            FOR UPDATE
+        ), updated AS (
+            UPDATE subscriptions s
+            SET amount_cents = $4::int, cadence_days = $5, next_due = $6,
+                billing_day = EXTRACT(DAY FROM $6::date)::int
+            FROM prev
+            WHERE s.id = prev.id
+            RETURNING s.id, prev.amount_cents AS old_amount
+        ), logged AS (
+            INSERT INTO subscription_events (subscription_id, kind, amount_cents, previous_amount_cents)
+            SELECT id, '`+SubEventPriceChanged+`', $4::int, old_amount FROM updated
+            WHERE old_amount <> $4::int
+        )
+        SELECT count(*) FROM updated
+    `, userID, s.Source, s.Merchant, s.AmountCents, s.CadenceDays, s.NextDue).Scan(&updated)
+	if err != nil {
+		return false, err
+	}
+	if updated > 0 {
+		return false, nil
+	}
+	_, err = d.ExecContext(ctx, `
+        INSERT INTO subscriptions (id, user_id, merchant, amount_cents, cadence_days, next_due, billing_day, source, is_active)
+        VALUES ($1, $2, $3, $4, $5, $6, EXTRACT(DAY FROM $6::date)::int, $7, true)
+    `, uuid.New(), userID, s.Merchant, s.AmountCents, s.CadenceDays, s.NextDue, s.Source)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
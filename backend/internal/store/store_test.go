@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+var subscriptionCols = []string{"id", "merchant", "amount_cents", "cadence_days", "next_due", "source", "is_active", "billing_day"}
+
+func TestGetSubscriptionsStableOrder(t *testing.T) {
+	due := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	ids := []string{
+		"00000000-0000-0000-0000-000000000003",
+		"00000000-0000-0000-0000-000000000001",
+		"00000000-0000-0000-0000-000000000002",
+	}
+	rows := [][]any{
+		{ids[0], "Spotify", 1099, 30, due, "manual", true, 0},
+		{ids[1], "Hulu", 799, 30, due, "manual", true, 0},
+		{ids[2], "Spotify", 1099, 30, due, "plaid", true, 0},
+		{uuid.NewString(), "Gym", 4000, 30, nil, "manual", true, 0},
+		{uuid.NewString(), "Netflix", 1549, 30, due.AddDate(0, 0, -3), "manual", true, 0},
+	}
+
+	// Like Postgres, the fake only guarantees the order the query asks
+	// for: rows arrive shuffled and are sorted by whatever of
+	// next_due, merchant and id the ORDER BY names.
+	rng := rand.New(rand.NewSource(1))
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if !strings.Contains(q.SQL, "FROM subscriptions") {
+			return dbtest.Result{}
+		}
+		out := append([][]any(nil), rows...)
+		rng.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+		byMerchant := strings.Contains(q.SQL, "merchant ASC")
+		byID := strings.Contains(q.SQL, "id ASC")
+		sort.SliceStable(out, func(i, j int) bool {
+			a, b := out[i], out[j]
+			if a[4] == nil || b[4] == nil {
+				return a[4] != nil && b[4] == nil
+			}
+			if ta, tb := a[4].(time.Time), b[4].(time.Time); !ta.Equal(tb) {
+				return ta.Before(tb)
+			}
+			if byMerchant && a[1] != b[1] {
+				return a[1].(string) < b[1].(string)
+			}
+			return byID && a[0].(string) < b[0].(string)
+		})
+		return dbtest.Rows(subscriptionCols, out...)
+	})
+
+	want := []string{"Netflix", "Hulu", "Spotify", "Spotify", "Gym"}
+	var first []uuid.UUID
+	for call := 0; call < 5; call++ {
+		subs, err := GetSubscriptions(context.Background(), d, uuid.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(subs) != len(want) {
+			t.Fatalf("got %d subscriptions, want %d", len(subs), len(want))
+		}
+		var got []uuid.UUID
+		for i, s := range subs {
+			if s.Merchant != want[i] {
+				t.Errorf("call %d: position %d is %s, want %s", call, i, s.Merchant, want[i])
+			}
+			got = append(got, s.ID)
+		}
+		if subs[2].ID.String() != ids[2] || subs[3].ID.String() != ids[0] {
+			t.Errorf("call %d: Spotify ties ordered %s, %s; want by id", call, subs[2].ID, subs[3].ID)
+		}
+		if first == nil {
+			first = got
+			continue
+		}
+		for i := range got {
+			if got[i] != first[i] {
+				t.Fatalf("call %d returned a different order than the first call", call)
+			}
+		}
+	}
+}
+
+func TestCreateEventDefaultsMissingEnd(t *testing.T) {
+	var inserted []any
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if strings.Contains(q.SQL, "INSERT INTO calendar_events") {
+			inserted = q.Args
+		}
+		return dbtest.Result{RowsAffected: 1}
+	})
+	start := time.Date(2024, 7, 8, 15, 0, 0, 0, time.UTC)
+
+	ev, err := CreateEvent(context.Background(), d, uuid.New(), Event{Title: "1:1 with mentor", Start: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(DefaultEventDuration()); !ev.End.Equal(want) || inserted[4] != want {
+		t.Errorf("end = %v (stored %v), want %v", ev.End, inserted[4], want)
+	}
+
+	ev, err = CreateEvent(context.Background(), d, uuid.New(), Event{Title: "Offsite", Start: start, AllDay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.AddDate(0, 0, 1); !ev.End.Equal(want) {
+		t.Errorf("all-day end = %v, want %v", ev.End, want)
+	}
+}
+
+func TestCreateEventRejectsInvertedRange(t *testing.T) {
+	d, rec := dbtest.Open(t, nil)
+	start := time.Date(2024, 7, 8, 15, 0, 0, 0, time.UTC)
+	_, err := CreateEvent(context.Background(), d, uuid.New(), Event{Title: "Backwards", Start: start, End: start.Add(-time.Hour)})
+	if !errors.Is(err, ErrEventEndBeforeStart) {
+		t.Errorf("err = %v, want ErrEventEndBeforeStart", err)
+	}
+	if n := rec.Count("INSERT"); n != 0 {
+		t.Errorf("stored an inverted event (%d inserts)", n)
+	}
+}
+
+func TestDeactivateSubscriptionsBySource(t *testing.T) {
+	user, other := uuid.New(), uuid.New()
+	// The fake table applies the UPDATE's WHERE clause to these rows.
+	type row struct {
+		user   uuid.UUID
+		source string
+		active bool
+	}
+	rows := []*row{
+		{user, "plaid", true},
+		{user, "plaid", true},
+		{user, "plaid", false},
+		{user, "manual", true},
+		{other, "plaid", true},
+	}
+	d, rec := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		if !strings.Contains(q.SQL, "UPDATE subscriptions SET is_active = false") {
+			return dbtest.Result{}
+		}
+		n := 0
+		for _, r := range rows {
+			if r.user == q.Args[0] && r.source == q.Args[1] && r.active {
+				r.active = false
+				n++
+			}
+		}
+		return dbtest.Result{RowsAffected: int64(n)}
+	})
+
+	n, err := DeactivateSubscriptionsBySource(context.Background(), d, user, "plaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("deactivated %d, want 2", n)
+	}
+	if !rows[3].active || !rows[4].active {
+		t.Error("deactivated a manual subscription or another user's")
+	}
+
+	if _, err := DeactivateSubscriptionsBySource(context.Background(), d, user, ""); err == nil {
+		t.Error("empty source accepted")
+	}
+	if got := rec.Count("UPDATE subscriptions"); got != 1 {
+		t.Errorf("ran %d updates, want 1", got)
+	}
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// Kinds of entry in a subscription's history. Price changes and
+// deactivations are stored in subscription_events; the others are derived.
+const (
+	SubEventCreated      = "created"
+	SubEventPriceChanged = "price_changed"
+	SubEventDeactivated  = "deactivated"
+	SubEventCharge       = "charge"
+)
+
+// SubscriptionHistoryEntry is one point on a subscription's timeline.
+// AmountCents is the new price for price changes and the charged amount
+// for charges. TransactionID is set for charges.
+type SubscriptionHistoryEntry struct {
+	At                  time.Time  `json:"at"`
+	Kind                string     `json:"kind"`
+	AmountCents         int        `json:"amountCents,omitempty"`
+	PreviousAmountCents *int       `json:"previousAmountCents,omitempty"`
+	TransactionID       *uuid.UUID `json:"transactionId,omitempty"`
+}
+
+// maxHistoryCharges caps the charges in a subscription's history to its
+// most recent ones.
+const maxHistoryCharges = 500
+
+// subEventOrder breaks timestamp ties so a subscription's creation sorts
+// before anything else recorded at the same moment.
+var subEventOrder = map[string]int{
+	SubEventCreated:      0,
+	SubEventCharge:       1,
+	SubEventPriceChanged: 2,
+	SubEventDeactivated:  3,
+}
+
+// GetSubscriptionHistory returns the timeline of one of the user's
+// subscriptions, active or not, oldest first: its creation, recorded price
+// and status changes, and the user's most recent maxHistoryCharges
+// transactions from the same merchant. Merchants match after
+// NormalizeMerchant, so "SQ *SPOTIFY" charges belong to a "Spotify"
+// subscription; the query narrows them to names containing one of the
+// merchant's words and the match is confirmed here.
+func GetSubscriptionHistory(ctx context.Context, d *db.DB, userID, subID uuid.UUID) ([]SubscriptionHistoryEntry, error) {
+	var merchant string
+	var amount int
+	var createdAt time.Time
+	err := d.QueryRowContext(ctx, `
+        SELECT merchant, amount_cents, created_at
+        FROM subscriptions
+        WHERE user_id = $1 AND id = $2
+    `, userID, subID).Scan(&merchant, &amount, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The price at creation is the earliest recorded previous price, or the
+	// current price if it never changed.
+	entries := []SubscriptionHistoryEntry{{At: createdAt, Kind: SubEventCreated, AmountCents: amount}}
+
+	rows, err := d.QueryContext(ctx, `
+        SELECT kind, amount_cents, previous_amount_cents, created_at
+        FROM subscription_events
+        WHERE subscription_id = $1
+        ORDER BY created_at ASC
+    `, subID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	firstPrice := true
+	for rows.Next() {
+		var e SubscriptionHistoryEntry
+		var amt, prev sql.NullInt64
+		if err := rows.Scan(&e.Kind, &amt, &prev, &e.At); err != nil {
+			return nil, err
+		}
+		e.AmountCents = int(amt.Int64)
+		if prev.Valid {
+			p := int(prev.Int64)
+			e.PreviousAmountCents = &p
+			if firstPrice && e.Kind == SubEventPriceChanged {
+				entries[0].AmountCents = p
+				firstPrice = false
+			}
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	key := NormalizeMerchant(merchant)
+	if key == "" {
+		sortSubscriptionHistory(entries)
+		return entries, nil
+	}
+	charges, err := d.QueryContext(ctx, `
+        SELECT id, txn_date, merchant, amount_cents
+        FROM transactions
+        WHERE user_id = $1 AND amount_cents > 0
+          AND ' ' || regexp_replace(lower(merchant), '[^[:alnum:]]+', ' ', 'g') || ' ' LIKE ANY($2)
+        ORDER BY txn_date DESC
+        LIMIT $3
+    `, userID, merchantWordPatterns(key), maxHistoryCharges)
+	if err != nil {
+		return nil, err
+	}
+	defer charges.Close()
+	for charges.Next() {
+		var id uuid.UUID
+		var txnMerchant string
+		e := SubscriptionHistoryEntry{Kind: SubEventCharge}
+		if err := charges.Scan(&id, &e.At, &txnMerchant, &e.AmountCents); err != nil {
+			return nil, err
+		}
+		if NormalizeMerchant(txnMerchant) != key {
+			continue
+		}
+		e.TransactionID = &id
+		entries = append(entries, e)
+	}
+	if err := charges.Err(); err != nil {
+		return nil, err
+	}
+
+	sortSubscriptionHistory(entries)
+	return entries, nil
+}
+
+// sortSubscriptionHistory orders entries oldest first.
+func sortSubscriptionHistory(entries []SubscriptionHistoryEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].At.Equal(entries[j].At) {
+			return entries[i].At.Before(entries[j].At)
+		}
+		return subEventOrder[entries[i].Kind] < subEventOrder[entries[j].Kind]
+	})
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+func TestGetSubscriptionHistoryTimeline(t *testing.T) {
+	created := date(2024, 1, 10)
+	var chargeArgs []any
+	d, _ := dbtest.Open(t, func(q dbtest.Query) dbtest.Result {
+		switch {
+		case strings.Contains(q.SQL, "FROM subscription_events"):
+			return dbtest.Rows([]string{"kind", "amount_cents", "previous_amount_cents", "created_at"},
+				[]any{SubEventPriceChanged, 1199, 1099, date(2024, 3, 1)},
+				[]any{SubEventDeactivated, nil, nil, date(2024, 5, 20)},
+			)
+		case strings.Contains(q.SQL, "FROM subscriptions"):
+			return dbtest.Rows([]string{"merchant", "amount_cents", "created_at"}, []any{"Spotify", 1199, created})
+		case strings.Contains(q.SQL, "FROM transactions"):
+			chargeArgs = q.Args
+			// The query's word filter lets through names that only
+			// contain the merchant's word.
+			return dbtest.Rows([]string{"id", "txn_date", "merchant", "amount_cents"},
+				[]any{uuid.NewString(), date(2024, 1, 10), "Spotify", 1099},
+				[]any{uuid.NewString(), date(2024, 2, 10), "SQ *SPOTIFY", 1099},
+				[]any{uuid.NewString(), date(2024, 2, 12), "Spotify Gift Card", 3000},
+				[]any{uuid.NewString(), date(2024, 3, 10), "SPOTIFY #1234", 1199},
+				[]any{uuid.NewString(), date(2024, 5, 10), "spotify", 1199},
+			)
+		}
+		return dbtest.Result{}
+	})
+
+	history, err := GetSubscriptionHistory(context.Background(), d, uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind   string
+		at     string
+		amount int
+	}{
+		// Creation and the first charge share a timestamp; creation wins.
+		{SubEventCreated, "2024-01-10", 1099},
+		{SubEventCharge, "2024-01-10", 1099},
+		{SubEventCharge, "2024-02-10", 1099},
+		{SubEventPriceChanged, "2024-03-01", 1199},
+		{SubEventCharge, "2024-03-10", 1199},
+		{SubEventCharge, "2024-05-10", 1199},
+		{SubEventDeactivated, "2024-05-20", 0},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(history), len(want), history)
+	}
+	for i, w := range want {
+		got := history[i]
+		if got.Kind != w.kind || got.At.Format("2006-01-02") != w.at || got.AmountCents != w.amount {
+			t.Errorf("entry %d = %s on %s for %d, want %s on %s for %d",
+				i, got.Kind, got.At.Format("2006-01-02"), got.AmountCents, w.kind, w.at, w.amount)
+		}
+	}
+	// Charges are narrowed in the query and capped.
+	patterns, _ := chargeArgs[1].([]string)
+	if len(patterns) != 1 || patterns[0] != "% spotify %" || chargeArgs[2] != maxHistoryCharges {
+		t.Errorf("charges queried with %v, want the spotify word limited to %d", chargeArgs[1:], maxHistoryCharges)
+	}
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// ErrBulkRolledBack marks items that were valid but not kept because an
+// all-or-nothing import had a failure.
+var ErrBulkRolledBack = errors.New("not created: another item failed")
+
+// BulkSubscriptionResult is the outcome of one item of a bulk import, in
+// request order. Exactly one of Subscription and Error is set.
+type BulkSubscriptionResult struct {
+	Index        int           `json:"index"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// CreateSubscriptions inserts manual subscriptions in one transaction,
+// validating each like CreateSubscription. Each insert runs under its own
+// savepoint, so by default a failed item is skipped and the rest are kept.
+// With allOrNothing, any failure rolls back every item. It reports whether
+// anything was committed along with the per-item results.
+func CreateSubscriptions(ctx context.Context, d *db.DB, userID uuid.UUID, subs []Subscription, allOrNothing bool) ([]BulkSubscriptionResult, bool, error) {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BulkSubscriptionResult, len(subs))
+	failed := 0
+	for i, s := range subs {
+		results[i].Index = i
+		if err := validateSubscription(s); err != nil {
+			results[i].Error = err.Error()
+			failed++
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+			return nil, false, err
+		}
+		id := uuid.New()
+		_, err := tx.ExecContext(ctx, insertManualSubscriptionSQL, id, userID, s.Merchant, s.AmountCents, s.CadenceDays, s.NextDue)
+		if err != nil {
+			if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_item`); rbErr != nil {
+				return nil, false, rbErr
+			}
+			results[i].Error = err.Error()
+			failed++
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT bulk_item`); err != nil {
+			return nil, false, err
+		}
+
+		s.ID = id
+		s.Source = "manual"
+		s.IsActive = true
+		results[i].Subscription = &s
+	}
+
+	if allOrNothing && failed > 0 {
+		for i := range results {
+			if results[i].Subscription != nil {
+				results[i].Subscription = nil
+				results[i].Error = ErrBulkRolledBack.Error()
+			}
+		}
+		return results, false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return results, len(subs) > failed, nil
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db/dbtest"
+)
+
+// fakeTransactions is a transactions table keyed like the real one on
+// (user_id, source, ext_id). A conflicting insert replaces the row only
+// when the statement asks for DO UPDATE, as ON CONFLICT would.
+type fakeTransactions struct {
+	rows map[string][]any // key -> transactionColumns values
+}
+
+func (f *fakeTransactions) handle(q dbtest.Query) dbtest.Result {
+	switch {
+	case strings.Contains(q.SQL, "INSERT INTO transactions"):
+		key := q.Args[0].(uuid.UUID).String() + "/" + q.Args[1].(string) + "/" + q.Args[2].(string)
+		if _, exists := f.rows[key]; exists && !strings.Contains(q.SQL, "DO UPDATE") {
+			return dbtest.Result{}
+		}
+		id := uuid.NewString()
+		if existing, ok := f.rows[key]; ok {
+			id = existing[0].(string)
+		}
+		f.rows[key] = []any{id, q.Args[1], q.Args[2], nil, q.Args[4], q.Args[5], q.Args[6], q.Args[7], q.Args[8]}
+		return dbtest.Result{RowsAffected: 1}
+	case strings.Contains(q.SQL, "FROM transactions"):
+		var rows [][]any
+		for _, r := range f.rows {
+			rows = append(rows, r)
+		}
+		return dbtest.Rows(strings.Split(strings.ReplaceAll(transactionColumns, " ", ""), ","), rows...)
+	}
+	return dbtest.Result{}
+}
+
+func TestUpsertTransactionAppliesCorrections(t *testing.T) {
+	table := &fakeTransactions{rows: map[string][]any{}}
+	d, _ := dbtest.Open(t, table.handle)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	txn := Transaction{
+		Source:      "plaid",
+		ExtID:       "txn-1",
+		Date:        time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC),
+		Merchant:    "Blue Bottle",
+		AmountCents: 650,
+		Category:    "Food and Drink > Coffee",
+		Pending:     true,
+	}
+	if err := UpsertTransaction(ctx, d, userID, txn); err != nil {
+		t.Fatal(err)
+	}
+	// The posted charge came through with a tip added.
+	txn.AmountCents = 780
+	txn.Pending = false
+	if err := UpsertTransaction(ctx, d, userID, txn); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetTransactions(ctx, d, userID, "plaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("stored %d rows, want 1", len(stored))
+	}
+	if got := stored[0]; got.AmountCents != 780 || got.Pending || got.ExtID != "txn-1" {
+		t.Errorf("stored %+v, want the corrected 780 cent posted charge", got)
+	}
+
+	if err := UpsertTransaction(ctx, d, userID, Transaction{Source: "plaid"}); err == nil {
+		t.Error("expected an error for a transaction without an external id")
+	}
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"dayboard/backend/internal/db"
+)
+
+// DeleteUser removes a user and everything stored for them in one
+// transaction. The main data tables are cleared explicitly; the rest
+// (Plaid items, accounts, reminders and so on) go with the users row
+// through ON DELETE CASCADE. It returns sql.ErrNoRows if the user doesn't
+// exist.
+func DeleteUser(ctx context.Context, d *db.DB, userID uuid.UUID) error {
+	return d.WithTx(ctx, func(tx *sql.Tx) error {
+		for _, table := range []string{
+			"profiles", "subscriptions", "calendar_events",
+			"commute_entries", "transactions", "oauth_tokens",
+		} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+				return err
+			}
+		}
+		res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
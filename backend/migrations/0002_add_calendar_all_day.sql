@@ -0,0 +1,3 @@
+-- All-day events (Google's date-only form) are flagged so clients can
+-- render them as banners instead of timed blocks.
+ALTER TABLE calendar_events ADD COLUMN IF NOT EXISTS all_day BOOLEAN NOT NULL DEFAULT FALSE;
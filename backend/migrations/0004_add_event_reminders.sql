@@ -0,0 +1,18 @@
+-- Reminder lead times are stored as minutes before the event start. A NULL
+-- on the event means "use the profile default".
+ALTER TABLE calendar_events ADD COLUMN IF NOT EXISTS reminder_minutes_before INT[];
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS reminder_minutes_before INT[] DEFAULT '{10}';
+
+-- Event reminders are the queue the reminder worker fills and drains. One
+-- row exists per event and lead time; sent_at is set once delivered.
+CREATE TABLE IF NOT EXISTS event_reminders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES calendar_events(id) ON DELETE CASCADE,
+    minutes_before INT NOT NULL,
+    remind_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    UNIQUE (event_id, minutes_before)
+);
+
+CREATE INDEX IF NOT EXISTS event_reminders_due_idx ON event_reminders (remind_at) WHERE sent_at IS NULL;
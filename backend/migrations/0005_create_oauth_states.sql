@@ -0,0 +1,9 @@
+-- OAuth states are issued when a user starts an OAuth flow and consumed by
+-- the callback. Each state is single-use and only valid for a short time,
+-- which protects the callback against CSRF.
+CREATE TABLE IF NOT EXISTS oauth_states (
+    state TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
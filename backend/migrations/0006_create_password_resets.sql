@@ -0,0 +1,12 @@
+-- Password reset tokens. Only a SHA-256 hash of the token is stored so a
+-- database leak cannot be used to take over accounts. Tokens are single-use
+-- (used_at) and expire after an hour.
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets(user_id);
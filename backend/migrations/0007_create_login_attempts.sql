@@ -0,0 +1,10 @@
+-- Failed login attempts, keyed by email and client IP. Used to lock out
+-- brute-force attempts; rows for a pair are cleared on successful login.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email_ip ON login_attempts(email, ip, attempted_at);
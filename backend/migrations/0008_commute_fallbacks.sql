@@ -0,0 +1,15 @@
+-- Distances returned by the Maps API are cached so commute estimates keep
+-- working when the API is unavailable. Keys are normalized addresses.
+CREATE TABLE IF NOT EXISTS commute_distance_cache (
+    origin TEXT NOT NULL,
+    destination TEXT NOT NULL,
+    distance_miles DOUBLE PRECISION NOT NULL,
+    duration_minutes DOUBLE PRECISION NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (origin, destination)
+);
+
+-- Typical commute length per city, used as the last-resort estimate when
+-- no route can be computed.
+ALTER TABLE city_cost_models ADD COLUMN IF NOT EXISTS avg_commute_miles DOUBLE PRECISION;
+ALTER TABLE city_cost_models ADD COLUMN IF NOT EXISTS avg_commute_minutes DOUBLE PRECISION;
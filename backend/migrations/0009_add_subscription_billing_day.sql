@@ -0,0 +1,8 @@
+-- The day of month a subscription bills on. Monthly and longer cadences
+-- roll forward by calendar months anchored to this day, so a sub billed on
+-- the 31st goes Jan 31 -> Feb 28 -> Mar 31 instead of drifting.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_day INT;
+
+UPDATE subscriptions
+SET billing_day = EXTRACT(DAY FROM next_due)::int
+WHERE billing_day IS NULL AND next_due IS NOT NULL;
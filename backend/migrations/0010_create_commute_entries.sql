@@ -0,0 +1,14 @@
+-- Commute entries record individual trips the user logged, with what they
+-- cost. They feed the daily burn.
+CREATE TABLE IF NOT EXISTS commute_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    from_addr TEXT,
+    to_addr TEXT,
+    cost_cents INT NOT NULL,
+    method TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_commute_entries_user_time ON commute_entries(user_id, occurred_at);
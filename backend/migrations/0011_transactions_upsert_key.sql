@@ -0,0 +1,14 @@
+-- Transactions are upserted on (user_id, source, ext_id) so corrections
+-- from the provider (amount changes, pending -> posted) replace the stored
+-- row. Remove any duplicates left by earlier syncs before enforcing it.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS pending BOOLEAN NOT NULL DEFAULT FALSE;
+
+DELETE FROM transactions a
+USING transactions b
+WHERE a.user_id = b.user_id
+  AND a.source = b.source
+  AND a.ext_id = b.ext_id
+  AND a.ctid < b.ctid;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_user_source_ext
+    ON transactions(user_id, source, ext_id);
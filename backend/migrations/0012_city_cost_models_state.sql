@@ -0,0 +1,6 @@
+-- Cost models are looked up by city and state, since city names repeat
+-- across states (Portland OR/ME). A NULL state applies to any state.
+ALTER TABLE city_cost_models ADD COLUMN IF NOT EXISTS state TEXT;
+ALTER TABLE city_cost_models DROP CONSTRAINT IF EXISTS city_cost_models_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_city_cost_models_city_state
+    ON city_cost_models(lower(city), COALESCE(upper(state), ''));
@@ -0,0 +1,9 @@
+-- Commute estimates support several travel modes. Distances differ by mode
+-- (walking paths, transit routes), so the cache is keyed by mode too.
+ALTER TABLE commute_distance_cache ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'driving';
+ALTER TABLE commute_distance_cache DROP CONSTRAINT IF EXISTS commute_distance_cache_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commute_distance_cache_route
+    ON commute_distance_cache(origin, destination, mode);
+
+-- Flat per-ride transit fare for the market.
+ALTER TABLE city_cost_models ADD COLUMN IF NOT EXISTS transit_fare_cents INT;
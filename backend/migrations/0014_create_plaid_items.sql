@@ -0,0 +1,14 @@
+-- Plaid items are the bank connections a user has linked, one access token
+-- per item. Transactions remember which item they came from so a sync can
+-- be scoped to a single bank.
+CREATE TABLE IF NOT EXISTS plaid_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id TEXT NOT NULL UNIQUE,
+    access_token_enc BYTEA NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_plaid_items_user ON plaid_items(user_id);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS item_id TEXT;
@@ -0,0 +1,4 @@
+-- Transactions are synced incrementally with Plaid's /transactions/sync.
+-- The cursor marks how far each item has been synced; NULL means the next
+-- sync starts from the item's full history.
+ALTER TABLE plaid_items ADD COLUMN IF NOT EXISTS sync_cursor TEXT;
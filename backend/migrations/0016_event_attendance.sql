@@ -0,0 +1,4 @@
+-- Whether the user attended an event, for reflection and for dropping
+-- office-day costs on days they skipped. NULL means not yet marked.
+ALTER TABLE calendar_events ADD COLUMN IF NOT EXISTS attendance TEXT
+    CHECK (attendance IN ('attended', 'skipped'));
@@ -0,0 +1,13 @@
+-- Subscription events record changes to a subscription over its life
+-- (price changes, deactivation) for the history timeline. Creation comes
+-- from subscriptions.created_at and charges from matching transactions.
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    amount_cents INT,
+    previous_amount_cents INT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_events_sub ON subscription_events(subscription_id, created_at);
@@ -0,0 +1,15 @@
+-- Accounts hold the user's bank accounts and balances as of the last Plaid
+-- sync, so listing accounts doesn't call Plaid every time.
+CREATE TABLE IF NOT EXISTS accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id TEXT,
+    account_id TEXT NOT NULL,
+    name TEXT,
+    type TEXT,
+    subtype TEXT,
+    balance_cents BIGINT NOT NULL DEFAULT 0,
+    currency TEXT,
+    last_synced TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, account_id)
+);
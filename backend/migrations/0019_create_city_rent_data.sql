@@ -0,0 +1,9 @@
+-- Average monthly rent by city and unit size, for the housing comparison.
+-- bedroom_type is 'studio' or a bedroom count ('1', '2', ...).
+CREATE TABLE IF NOT EXISTS city_rent_data (
+    city TEXT NOT NULL,
+    state TEXT NOT NULL,
+    bedroom_type TEXT NOT NULL,
+    avg_rent_cents INT NOT NULL,
+    PRIMARY KEY (city, state, bedroom_type)
+);
@@ -0,0 +1,11 @@
+-- Savings goals are what a user wants to have saved by a deadline. The
+-- newest goal is the active one.
+CREATE TABLE IF NOT EXISTS savings_goals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_cents INT NOT NULL CHECK (target_cents > 0),
+    deadline DATE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_savings_goals_user ON savings_goals(user_id, created_at DESC);
@@ -0,0 +1,4 @@
+-- Last error Plaid reported for an item through an ITEM ERROR webhook,
+-- cleared once the item syncs again.
+ALTER TABLE plaid_items ADD COLUMN IF NOT EXISTS error_code TEXT;
+ALTER TABLE plaid_items ADD COLUMN IF NOT EXISTS error_at TIMESTAMPTZ;
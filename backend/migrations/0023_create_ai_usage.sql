@@ -0,0 +1,15 @@
+-- Daily AI request counts for quotas. Signed-in users are counted by
+-- user_id; anonymous callers by IP under a stricter limit. Days are UTC.
+CREATE TABLE IF NOT EXISTS ai_usage (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    requests INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS ai_anon_usage (
+    ip TEXT NOT NULL,
+    day DATE NOT NULL,
+    requests INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (ip, day)
+);
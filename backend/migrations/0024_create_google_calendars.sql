@@ -0,0 +1,8 @@
+-- Google calendars a user has chosen to sync. Users with no rows sync only
+-- their primary calendar.
+CREATE TABLE IF NOT EXISTS google_calendars (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, calendar_id)
+);
@@ -0,0 +1,12 @@
+-- Google Calendar sync tokens, one per synced calendar. A token lets the
+-- next sync fetch only what changed. window_end is the end of the time
+-- range the token's full sync covered; once the rolling sync window moves
+-- past it, the calendar is fully synced again.
+CREATE TABLE IF NOT EXISTS google_sync_tokens (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id TEXT NOT NULL,
+    sync_token TEXT NOT NULL,
+    window_end TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, calendar_id)
+);
@@ -0,0 +1,28 @@
+-- Campus events are ingested per school from the school's public events
+-- feed (ICS or JSON) and shown to users whose profile names that school.
+-- Schools are identified by a short lower-case key, e.g. "purdue".
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS school TEXT;
+
+CREATE TABLE IF NOT EXISTS campus_event_sources (
+    school TEXT PRIMARY KEY,
+    feed_url TEXT NOT NULL,
+    format TEXT NOT NULL CHECK (format IN ('ics', 'json')),
+    last_synced_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS campus_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    school TEXT NOT NULL,
+    ext_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    start_ts TIMESTAMPTZ NOT NULL,
+    end_ts TIMESTAMPTZ NOT NULL,
+    location TEXT,
+    category TEXT,
+    url TEXT,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (school, ext_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_campus_events_school_start ON campus_events(school, start_ts);
@@ -0,0 +1,5 @@
+-- Commute costs can be split with other riders. cost_cents stays the full
+-- fare; split_with is how many people shared it (including the user) and
+-- share_percent, when set, is the user's share instead of an even split.
+ALTER TABLE commute_entries ADD COLUMN IF NOT EXISTS split_with INT NOT NULL DEFAULT 1 CHECK (split_with >= 1);
+ALTER TABLE commute_entries ADD COLUMN IF NOT EXISTS share_percent INT CHECK (share_percent BETWEEN 1 AND 100);
@@ -0,0 +1,16 @@
+-- Budgets cap what a user wants to spend on a transaction category each
+-- week or month. A category matches transactions in it and in its
+-- subcategories, e.g. "Food and Drink" covers "Food and Drink > Restaurants".
+-- Categories match ignoring case, so a user has one budget per category
+-- and period regardless of case.
+CREATE TABLE IF NOT EXISTS budgets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category TEXT NOT NULL,
+    limit_cents INT NOT NULL CHECK (limit_cents > 0),
+    period TEXT NOT NULL DEFAULT 'monthly' CHECK (period IN ('weekly', 'monthly')),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_user_category_period
+    ON budgets(user_id, lower(category), period);
@@ -0,0 +1,12 @@
+-- OAuth tokens are upserted on (user_id, provider): a user has one
+-- connection per provider. Keep the newest of any duplicates before
+-- enforcing it.
+DELETE FROM oauth_tokens a
+USING oauth_tokens b
+WHERE a.user_id = b.user_id
+  AND a.provider = b.provider
+  AND (COALESCE(a.created_at, '-infinity') < COALESCE(b.created_at, '-infinity')
+    OR (COALESCE(a.created_at, '-infinity') = COALESCE(b.created_at, '-infinity') AND a.ctid < b.ctid));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_tokens_user_provider
+    ON oauth_tokens(user_id, provider);
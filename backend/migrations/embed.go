@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL schema migrations so the server can
+// apply them at startup. Files are named NNNN_description.sql and run in
+// name order.
+package migrations
+
+import "embed"
+
+// FS holds every migration file.
+//
+//go:embed *.sql
+var FS embed.FS
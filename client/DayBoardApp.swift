@@ -571,7 +571,7 @@ final class DayBoardViewModel: ObservableObject {
     }
 
     func addEvent(title: String, start: Date, end: Date, joinURL: String) {
-        let url = baseURL.appendingPathComponent("agenda/today")
+        let url = baseURL.appendingPathComponent("agenda/events")
         var req = URLRequest(url: url)
         req.httpMethod = "POST"
         req.setValue("application/json", forHTTPHeaderField: "Content-Type")
@@ -20,6 +20,12 @@ GOOGLE_CLIENT_SECRET=your_google_client_secret_here
 GOOGLE_REDIRECT_URI=http://localhost:8080/auth/google/callback
 MAPS_API_KEY=your_google_maps_api_key_here
 
+# Microsoft 365 / Outlook calendar - Replace with your Azure app registration
+MICROSOFT_CLIENT_ID=your_microsoft_client_id_here
+MICROSOFT_CLIENT_SECRET=your_microsoft_client_secret_here
+MICROSOFT_REDIRECT_URI=http://localhost:8080/api/v1/microsoft/callback
+MICROSOFT_TENANT=common
+
 # Plaid API - Replace with your actual credentials
 PLAID_CLIENT_ID=your_plaid_client_id_here
 PLAID_SECRET=your_plaid_secret_here
@@ -36,6 +42,23 @@ JWT_EXPIRY_HOURS=168
 # Application Settings
 APP_ENV=development
 APP_URL=http://localhost:8080
+# Browser origins allowed to call the API (comma-separated); none if unset
+CORS_ALLOWED_ORIGINS=http://localhost:3000
+# Reverse proxies whose X-Forwarded-For is trusted (comma-separated IPs or
+# CIDRs); none if unset, so the client IP is the connection's address
+TRUSTED_PROXIES=
+# Largest accepted request body in bytes (default 1 MiB)
+MAX_REQUEST_BODY_BYTES=1048576
+
+# Password reset email. Without SMTP_HOST the server won't start unless
+# PASSWORD_RESET_LOG_ONLY=true, which only logs resets (development).
+SMTP_HOST=
+SMTP_PORT=587
+SMTP_USERNAME=
+SMTP_PASSWORD=
+SMTP_FROM=
+PASSWORD_RESET_URL=http://localhost:3000/reset-password
+PASSWORD_RESET_LOG_ONLY=true
 EOF
 
 echo "✅ Created backend/.env file"